@@ -19,6 +19,7 @@ import (
 	"git.sr.ht/~rjarry/aerc/config"
 	"git.sr.ht/~rjarry/aerc/lib/format"
 	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/secret"
 	"git.sr.ht/~rjarry/aerc/lib/ui"
 	"git.sr.ht/~rjarry/aerc/lib/xdg"
 	"git.sr.ht/~rockorager/vaxis"
@@ -64,10 +65,14 @@ type AccountWizard struct {
 	outgoingCopyTo   *ui.TextInput
 	outgoing         []ui.Interactive
 	// CONFIGURE_COMPLETE
-	complete []ui.Interactive
+	credStore *Selector
+	complete  []ui.Interactive
 }
 
-func showPasswordWarning() {
+// option of the credStore selector
+const CLEAR_TEXT = "accounts.conf (clear text)"
+
+func (wizard *AccountWizard) showPasswordWarning() {
 	title := "ATTENTION"
 	text := `
 The Wizard will store your passwords as clear text in:
@@ -78,6 +83,16 @@ It is recommended to remove the clear text passwords and configure
 'source-cred-cmd' and 'outgoing-cred-cmd' using your own password store
 after the setup.
 `
+	if len(wizard.credStore.options) > 1 {
+		text = `
+The Wizard can save your passwords in one of the password stores available
+on this system. Choose it in the last step.
+
+Otherwise, they will be stored as clear text in:
+
+  ~/.config/aerc/accounts.conf
+`
+	}
 	warning := NewSelectorDialog(
 		title, text, []string{"OK"}, 0,
 		SelectedAccountUiConfig(),
@@ -154,6 +169,7 @@ const (
 	MAILDIR   = "Maildir"
 	MAILDIRPP = "Maildir++"
 	NOTMUCH   = "notmuch"
+	POP3      = "POP3"
 	SMTP      = "SMTP"
 	SENDMAIL  = "sendmail"
 	// transports
@@ -165,7 +181,7 @@ const (
 )
 
 var (
-	sources    = []string{IMAP, JMAP, MAILDIR, MAILDIRPP, NOTMUCH}
+	sources    = []string{IMAP, JMAP, MAILDIR, MAILDIRPP, NOTMUCH, POP3}
 	outgoings  = []string{SMTP, JMAP, SENDMAIL}
 	transports = []string{SSL_TLS, OAUTH, XOAUTH, STARTTLS, INSECURE}
 )
@@ -191,6 +207,13 @@ func NewAccountWizard() *AccountWizard {
 		outgoingProtocol:  NewSelector(outgoings, 0, config.Ui).Chooser(true),
 		outgoingTransport: NewSelector(transports, 0, config.Ui).Chooser(true),
 	}
+	stores := append([]string{CLEAR_TEXT}, secret.Available()...)
+	focus := 0
+	if len(stores) > 1 {
+		// prefer any password store
+		focus = 1
+	}
+	wizard.credStore = NewSelector(stores, focus, config.Ui).Chooser(true)
 
 	// Autofill some stuff for the user
 	wizard.email.OnFocusLost(func(_ *ui.TextInput) {
@@ -208,6 +231,7 @@ func NewAccountWizard() *AccountWizard {
 		wizard.sourceServer.Set("")
 		wizard.autofill()
 		wizard.sourceUri()
+		wizard.updateCredStore()
 	})
 	wizard.sourceServer.OnChange(func(_ *ui.TextInput) {
 		wizard.sourceUri()
@@ -242,7 +266,7 @@ func NewAccountWizard() *AccountWizard {
 	wizard.sourcePassword.OnFocusLost(func(_ *ui.TextInput) {
 		if wizard.sourcePassword.String() != "" {
 			once.Do(func() {
-				showPasswordWarning()
+				wizard.showPasswordWarning()
 			})
 		}
 	})
@@ -250,6 +274,7 @@ func NewAccountWizard() *AccountWizard {
 		wizard.outgoingServer.Set("")
 		wizard.autofill()
 		wizard.outgoingUri()
+		wizard.updateCredStore()
 	})
 	wizard.outgoingServer.OnChange(func(_ *ui.TextInput) {
 		wizard.outgoingUri()
@@ -260,7 +285,7 @@ func NewAccountWizard() *AccountWizard {
 	wizard.outgoingPassword.OnChange(func(_ *ui.TextInput) {
 		if wizard.outgoingPassword.String() != "" {
 			once.Do(func() {
-				showPasswordWarning()
+				wizard.showPasswordWarning()
 			})
 		}
 		wizard.outgoingUri()
@@ -355,6 +380,7 @@ To add another account in the future, run ':new-account'.
 `, xdg.TildeHome(xdg.ConfigPath("aerc"))),
 		&wizard.complete,
 	)
+	complete.AddField("Save passwords in", wizard.credStore)
 	complete.AddField(
 		"", NewSelector([]string{
 			"Previous",
@@ -475,9 +501,21 @@ func (wizard *AccountWizard) finish(tutorial bool) {
 		return
 	}
 	sec, _ = file.NewSection(wizard.accountName.String())
+	sourceUrl, outgoingUrl := wizard.sourceUrl, wizard.outgoingUrl
+	if store := wizard.credStore.Selected(); store != CLEAR_TEXT && !wizard.temporary {
+		saved, err := wizard.savePasswords(store, &sourceUrl, &outgoingUrl)
+		if err != nil {
+			wizard.errorFor(nil, err)
+			return
+		}
+		// local backends and sendmail have no password to look up
+		if saved {
+			_, _ = sec.NewKey("cred-store", store)
+		}
+	}
 	// these can't fail
-	_, _ = sec.NewKey("source", wizard.sourceUrl.String())
-	_, _ = sec.NewKey("outgoing", wizard.outgoingUrl.String())
+	_, _ = sec.NewKey("source", sourceUrl.String())
+	_, _ = sec.NewKey("outgoing", outgoingUrl.String())
 	_, _ = sec.NewKey("default", "INBOX")
 	from := mail.Address{
 		Name:    wizard.fullName.String(),
@@ -583,6 +621,58 @@ func (wizard *AccountWizard) finish(tutorial bool) {
 	RemoveTab(wizard, false)
 }
 
+// hasPasswords returns true if the source or outgoing protocols need
+// a password.
+func (wizard *AccountWizard) hasPasswords() bool {
+	switch wizard.sourceProtocol.Selected() {
+	case IMAP, JMAP, POP3:
+		return true
+	}
+	return wizard.outgoingProtocol.Selected() != SENDMAIL
+}
+
+// updateCredStore only offers the password stores when there are
+// passwords to save.
+func (wizard *AccountWizard) updateCredStore() {
+	options := wizard.credStore.options
+	switch {
+	case !wizard.hasPasswords():
+		wizard.credStore.Select(CLEAR_TEXT)
+	case len(options) > 1 && wizard.credStore.Selected() == CLEAR_TEXT:
+		wizard.credStore.Select(options[1])
+	}
+}
+
+// savePasswords saves the passwords of the source and outgoing URLs in
+// a password store and removes them from the URLs. It returns true if
+// any password was saved.
+func (wizard *AccountWizard) savePasswords(name string, source, outgoing *url.URL) (bool, error) {
+	store, err := secret.New(name)
+	if err != nil {
+		return false, err
+	}
+	saved := false
+	for service, u := range map[string]*url.URL{
+		secret.Source:   source,
+		secret.Outgoing: outgoing,
+	} {
+		if u.User == nil {
+			continue
+		}
+		password, ok := u.User.Password()
+		if !ok {
+			continue
+		}
+		err := store.Save(wizard.accountName.String(), service, password)
+		if err != nil {
+			return false, err
+		}
+		u.User = url.User(u.User.Username())
+		saved = true
+	}
+	return saved, nil
+}
+
 func splitHostPath(server string) (string, string) {
 	host, path, found := strings.Cut(server, "/")
 	if found {
@@ -641,6 +731,15 @@ func (wizard *AccountWizard) sourceUri() url.URL {
 		default:
 			scheme = "jmap"
 		}
+	case POP3:
+		switch wizard.sourceTransport.Selected() {
+		case STARTTLS:
+			scheme = "pop3"
+		case INSECURE:
+			scheme = "pop3+insecure"
+		default:
+			scheme = "pop3s"
+		}
 	case MAILDIR:
 		scheme = "maildir"
 	case MAILDIRPP:
@@ -796,7 +895,7 @@ func (wizard *AccountWizard) discoverServices() {
 	type Service struct{ srv, hostport string }
 	services := make(chan Service)
 
-	for _, service := range []string{"imaps", "imap", "submission", "jmap"} {
+	for _, service := range []string{"imaps", "imap", "pop3s", "pop3", "submission", "jmap"} {
 		wg.Add(1)
 		go func(srv string) {
 			defer log.PanicHandler()
@@ -838,6 +937,14 @@ func (wizard *AccountWizard) autofill() {
 				wizard.sourceServer.Set(s)
 				wizard.sourceTransport.Select(STARTTLS)
 			}
+		case POP3:
+			if s, ok := wizard.discovered["pop3s"]; ok {
+				wizard.sourceServer.Set(s)
+				wizard.sourceTransport.Select(SSL_TLS)
+			} else if s, ok := wizard.discovered["pop3"]; ok {
+				wizard.sourceServer.Set(s)
+				wizard.sourceTransport.Select(STARTTLS)
+			}
 		case JMAP:
 			if s, ok := wizard.discovered["jmap"]; ok {
 				s = strings.TrimSuffix(s, ":443")
@@ -11,6 +11,7 @@ import (
 	"git.sr.ht/~rjarry/aerc/config"
 	"git.sr.ht/~rjarry/aerc/lib"
 	"git.sr.ht/~rjarry/aerc/lib/hooks"
+	"git.sr.ht/~rjarry/aerc/lib/ipc"
 	"git.sr.ht/~rjarry/aerc/lib/log"
 	"git.sr.ht/~rjarry/aerc/lib/marker"
 	"git.sr.ht/~rjarry/aerc/lib/pama"
@@ -47,6 +48,14 @@ type AccountView struct {
 	// Check-mail ticker
 	ticker       *time.Ticker
 	checkingMail bool
+
+	// Outbox retry timer
+	outboxLock  sync.Mutex
+	outboxTimer *time.Timer
+
+	undo *lib.UndoJournal
+	// messages already processed by the rules engine
+	ruled map[string]struct{}
 }
 
 func (acct *AccountView) UiConfig() *config.UIConfig {
@@ -60,8 +69,11 @@ func NewAccountView(
 	acct *config.AccountConfig, deferLoop chan struct{},
 ) (*AccountView, error) {
 	view := &AccountView{
-		acct: acct,
+		acct:  acct,
+		undo:  lib.NewUndoJournal(),
+		ruled: make(map[string]struct{}),
 	}
+	view.undo.KeepDeleted = acct.UndoDelete
 
 	worker, err := worker.NewWorker(acct.Source, acct.Name)
 	if err != nil {
@@ -70,6 +82,7 @@ func NewAccountView(
 		return view, err
 	}
 	view.worker = worker
+	types.RegisterAccount(worker)
 
 	view.dirlist = NewDirectoryList(acct, worker)
 
@@ -138,6 +151,11 @@ func (acct *AccountView) SetStatus(setters ...state.SetStateFunc) {
 	acct.UpdateStatus()
 }
 
+// Connected returns true if the worker of the account is connected.
+func (acct *AccountView) Connected() bool {
+	return acct.state.Connected
+}
+
 func (acct *AccountView) UpdateStatus() {
 	if acct.isSelected() {
 		UpdateStatus()
@@ -168,6 +186,25 @@ func (acct *AccountView) AccountConfig() *config.AccountConfig {
 	return acct.acct
 }
 
+// Owner returns the account that a message belongs to. Messages of a unified
+// account belong to the account that they were merged from. Without message,
+// the first merged account is returned.
+func (acct *AccountView) Owner(msg *models.MessageInfo) *AccountView {
+	if acct.acct.Backend != "unified" {
+		return acct
+	}
+	var name string
+	if msg != nil && msg.Account != "" {
+		name = msg.Account
+	} else if accounts := acct.acct.UnifiedAccounts(); len(accounts) > 0 {
+		name = accounts[0].Name
+	}
+	if owner, err := Account(name); err == nil {
+		return owner
+	}
+	return acct
+}
+
 func (acct *AccountView) Worker() *types.Worker {
 	return acct.worker
 }
@@ -278,17 +315,8 @@ func (acct *AccountView) newStore(name string) *lib.MessageStore {
 				ForFolder(name)
 		},
 		func(msg *models.MessageInfo) {
-			err := hooks.RunHook(&hooks.MailReceived{
-				Account: acct.Name(),
-				Backend: backend,
-				Folder:  name,
-				Role:    role,
-				MsgInfo: msg,
-			})
-			if err != nil {
-				msg := fmt.Sprintf("mail-received hook: %s", err)
-				PushError(msg)
-			}
+			acct.applyRules(name, msg)
+			acct.mailReceived(name, role, msg)
 		}, func() {
 			if uiConf.NewMessageBell {
 				aerc.Beep()
@@ -330,7 +358,7 @@ func (acct *AccountView) newStore(name string) *lib.MessageStore {
 			err := hooks.RunHook(&hooks.FlagChanged{
 				Account:  acct.Name(),
 				Backend:  backend,
-				Folder:   acct.SelectedDirectory(),
+				Folder:   name,
 				Role:     role,
 				FlagName: flagname,
 			})
@@ -338,6 +366,12 @@ func (acct *AccountView) newStore(name string) *lib.MessageStore {
 				msg := fmt.Sprintf("flag-changed hook: %s", err)
 				PushError(msg)
 			}
+			ipc.Publish(&ipc.Event{
+				Type:    ipc.EventFlagChanged,
+				Account: acct.Name(),
+				Folder:  name,
+				Flag:    flagname,
+			})
 		},
 		func(msg *models.MessageInfo) {
 			acct.updateSplitView(msg)
@@ -363,9 +397,33 @@ func (acct *AccountView) newStore(name string) *lib.MessageStore {
 	)
 	store.Configure(acct.SortCriteria(uiConf))
 	store.SetMarker(marker.New(store))
+	store.SetUndoJournal(acct.undo)
+	store.SetHeadless(daemon.enabled)
 	return store
 }
 
+// mailReceived runs the mail-received hook and notifies the IPC subscribers
+// of a new message.
+func (acct *AccountView) mailReceived(folder, role string, msg *models.MessageInfo) {
+	err := hooks.RunHook(&hooks.MailReceived{
+		Account: acct.Name(),
+		Backend: acct.AccountConfig().Backend,
+		Folder:  folder,
+		Role:    role,
+		MsgInfo: msg,
+	})
+	if err != nil {
+		msg := fmt.Sprintf("mail-received hook: %s", err)
+		PushError(msg)
+	}
+	ipc.Publish(&ipc.Event{
+		Type:    ipc.EventNewMail,
+		Account: acct.Name(),
+		Folder:  folder,
+		Message: ipcMessage(acct.Name(), folder, msg),
+	})
+}
+
 func (acct *AccountView) onMessage(msg types.WorkerMessage) {
 	msg = acct.worker.ProcessMessage(msg)
 	switch msg := msg.(type) {
@@ -377,6 +435,7 @@ func (acct *AccountView) onMessage(msg types.WorkerMessage) {
 			acct.SetStatus(state.SetConnected(true))
 			log.Tracef("Listing mailboxes...")
 			acct.worker.PostAction(&types.ListDirectories{}, nil)
+			acct.FlushOutbox(false)
 		case *types.Disconnect:
 			acct.dirlist.ClearList()
 			acct.msglist.SetStore(nil)
@@ -384,6 +443,11 @@ func (acct *AccountView) onMessage(msg types.WorkerMessage) {
 			acct.SetStatus(state.SetConnected(false))
 		case *types.OpenDirectory:
 			acct.dirlist.Update(msg)
+			ipc.Publish(&ipc.Event{
+				Type:    ipc.EventFolderChanged,
+				Account: acct.Name(),
+				Folder:  resp.Directory,
+			})
 			if store, ok := acct.dirlist.SelectedMsgStore(); ok {
 				// If we've opened this dir before, we can re-render it from
 				// memory while we wait for the update and the UI feels
@@ -398,6 +462,7 @@ func (acct *AccountView) onMessage(msg types.WorkerMessage) {
 			store := acct.newStore(resp.Directory)
 			acct.dirlist.SetMsgStore(&models.Directory{
 				Name: resp.Directory,
+				Role: resp.Role,
 			}, store)
 			acct.dirlist.Update(msg)
 		case *types.RemoveDirectory:
@@ -436,49 +501,66 @@ func (acct *AccountView) onMessage(msg types.WorkerMessage) {
 			store = acct.newStore(msg.Dir.Name)
 		}
 		acct.dirlist.SetMsgStore(msg.Dir, store)
+		if acct.acct.DiscoverFolder(msg.Dir.Name, msg.Dir.Role) {
+			log.Infof("[%s] using %s as %s folder.",
+				acct.acct.Name, msg.Dir.Name, msg.Dir.Role)
+		}
 	case *types.DirectoryInfo:
 		acct.dirlist.Update(msg)
+	case *types.NewMessages:
+		role := ""
+		if dir := acct.dirlist.Directory(msg.Directory); dir != nil {
+			role = string(dir.Role)
+		}
+		for _, info := range msg.Infos {
+			acct.applyRules(msg.Directory, info)
+			acct.mailReceived(msg.Directory, role, info)
+		}
 	case *types.DirectoryContents:
-		if store, ok := acct.dirlist.SelectedMsgStore(); ok {
-			if acct.msglist.Store() == nil {
-				acct.msglist.SetStore(store)
-			}
+		if store, ok := acct.msgStore(msg); ok {
 			store.Update(msg)
-			acct.SetStatus(state.Threading(store.ThreadedView()))
+			if acct.isOpened(store) {
+				if acct.msglist.Store() == nil {
+					acct.msglist.SetStore(store)
+				}
+				acct.SetStatus(state.Threading(store.ThreadedView()))
+			}
 		}
 		if acct.newConn && len(msg.Uids) == 0 {
 			acct.checkMailOnStartup()
 		}
 	case *types.DirectoryThreaded:
-		if store, ok := acct.dirlist.SelectedMsgStore(); ok {
-			if acct.msglist.Store() == nil {
-				acct.msglist.SetStore(store)
-			}
+		if store, ok := acct.msgStore(msg); ok {
 			store.Update(msg)
-			acct.SetStatus(state.Threading(store.ThreadedView()))
+			if acct.isOpened(store) {
+				if acct.msglist.Store() == nil {
+					acct.msglist.SetStore(store)
+				}
+				acct.SetStatus(state.Threading(store.ThreadedView()))
+			}
 		}
 		if acct.newConn && len(msg.Threads) == 0 {
 			acct.checkMailOnStartup()
 		}
 	case *types.FullMessage:
-		if store, ok := acct.dirlist.SelectedMsgStore(); ok {
+		if store, ok := acct.msgStore(msg); ok {
 			store.Update(msg)
 		}
 	case *types.MessageInfo:
-		if store, ok := acct.dirlist.SelectedMsgStore(); ok {
+		if store, ok := acct.msgStore(msg); ok {
 			store.Update(msg)
 		}
 	case *types.MessagesDeleted:
-		if dir := acct.dirlist.SelectedDirectory(); dir != nil {
-			dir.Exists -= len(msg.Uids)
-		}
-		if store, ok := acct.dirlist.SelectedMsgStore(); ok {
+		if store, ok := acct.msgStore(msg); ok {
+			if dir := acct.dirlist.Directory(store.Name); dir != nil {
+				dir.Exists -= len(msg.Uids)
+			}
 			store.Update(msg)
 		}
 	case *types.MessagesCopied:
-		acct.updateDirCounts(msg.Destination, msg.Uids)
+		acct.updateDirCounts(msg, msg.Destination, msg.Uids)
 	case *types.MessagesMoved:
-		acct.updateDirCounts(msg.Destination, msg.Uids)
+		acct.updateDirCounts(msg, msg.Destination, msg.Uids)
 	case *types.LabelList:
 		acct.labels = msg.Labels
 	case *types.ConnError:
@@ -495,14 +577,34 @@ func (acct *AccountView) onMessage(msg types.WorkerMessage) {
 	acct.setTitle()
 }
 
-func (acct *AccountView) updateDirCounts(destination string, uids []models.UID) {
+// msgStore returns the message store of the folder the action a worker message
+// responds to was posted for. It defaults to the store of the selected folder.
+func (acct *AccountView) msgStore(msg types.WorkerMessage) (*lib.MessageStore, bool) {
+	if req := msg.InResponseTo(); req != nil && req.Folder() != "" {
+		return acct.dirlist.MsgStore(req.Folder())
+	}
+	return acct.dirlist.SelectedMsgStore()
+}
+
+func (acct *AccountView) isOpened(store *lib.MessageStore) bool {
+	selected, ok := acct.dirlist.SelectedMsgStore()
+	return ok && selected == store
+}
+
+func (acct *AccountView) updateDirCounts(
+	resp types.WorkerMessage, destination string, uids []models.UID,
+) {
+	// Get the messages from the originating store
+	store, ok := acct.msgStore(resp)
+	if !ok {
+		return
+	}
 	// Only update the destination destDir if it is initialized
 	if destDir := acct.dirlist.Directory(destination); destDir != nil {
 		var recent, unseen int
 		var accurate bool = true
 		for _, uid := range uids {
-			// Get the message from the originating store
-			msg, ok := acct.Store().Messages[uid]
+			msg, ok := store.Messages[uid]
 			if !ok {
 				continue
 			}
@@ -705,7 +705,22 @@ func (aerc *Aerc) RegisterChoices(choices []Choice) {
 	aerc.prompts.Push(p)
 }
 
+// Command runs a command received on the IPC socket. It is executed in the
+// UI goroutine.
 func (aerc *Aerc) Command(args []string) error {
+	res := make(chan error, 1)
+	ui.QueueFunc(func() {
+		res <- aerc.command(args)
+	})
+	select {
+	case err := <-res:
+		return err
+	case <-time.After(ipcQueryTimeout):
+		return errors.New("timed out")
+	}
+}
+
+func (aerc *Aerc) command(args []string) error {
 	switch {
 	case len(args) == 0:
 		return nil // noop success, i.e. ping
@@ -794,6 +809,7 @@ func (aerc *Aerc) mailto(addr *url.URL) error {
 	if acct == nil {
 		return errors.New("No account selected")
 	}
+	acct = acct.Owner(nil)
 
 	defer ui.Invalidate()
 
@@ -7,7 +7,6 @@ import (
 	"git.sr.ht/~rjarry/aerc/config"
 	"git.sr.ht/~rjarry/aerc/lib"
 	"git.sr.ht/~rjarry/aerc/lib/crypto"
-	"git.sr.ht/~rjarry/aerc/lib/ipc"
 	"git.sr.ht/~rjarry/aerc/lib/ui"
 	"git.sr.ht/~rjarry/aerc/models"
 	"git.sr.ht/~rjarry/aerc/worker/types"
@@ -27,8 +26,7 @@ func Init(
 }
 
 func Drawable() ui.DrawableInteractive      { return &aerc }
-func IPCHandler() ipc.Handler               { return &aerc }
-func Command(args []string) error           { return aerc.Command(args) }
+func Command(args []string) error           { return aerc.command(args) }
 func HandleMessage(msg types.WorkerMessage) { aerc.HandleMessage(msg) }
 
 func CloseBackends() error { return aerc.CloseBackends() }
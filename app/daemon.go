@@ -0,0 +1,91 @@
+package app
+
+import (
+	"errors"
+	"sync"
+
+	"git.sr.ht/~rjarry/aerc/lib/ipc"
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/ui"
+)
+
+// daemonHandler answers the IPC requests when aerc runs without a user
+// interface. The user interface can be attached to the terminal of a client.
+type daemonHandler struct {
+	*Aerc
+	enabled  bool
+	lock     sync.Mutex
+	detached chan struct{}
+}
+
+var daemon = daemonHandler{Aerc: &aerc}
+
+// SetDaemon must be called before Init when aerc is started without a user
+// interface.
+func SetDaemon() {
+	daemon.enabled = true
+}
+
+func IPCHandler() ipc.Handler {
+	if daemon.enabled {
+		return &daemon
+	}
+	return &aerc
+}
+
+func (d *daemonHandler) Attach(tty string) (<-chan struct{}, func(), error) {
+	d.lock.Lock()
+	if d.detached != nil {
+		d.lock.Unlock()
+		return nil, nil, errors.New("a user interface is already attached")
+	}
+	detached := make(chan struct{})
+	d.detached = detached
+	d.lock.Unlock()
+
+	res := make(chan error, 1)
+	ui.QueueFunc(func() {
+		res <- ui.Attach(d.Aerc, tty)
+	})
+	if err := <-res; err != nil {
+		d.lock.Lock()
+		d.detached = nil
+		d.lock.Unlock()
+		return nil, nil, err
+	}
+	log.Infof("user interface attached to %s", tty)
+
+	return detached, func() { d.detach(detached) }, nil
+}
+
+func (d *daemonHandler) Resize() {
+	ui.QueueFunc(ui.Resize)
+}
+
+func (d *daemonHandler) detach(detached chan struct{}) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.detached == nil || d.detached != detached {
+		return false
+	}
+	d.detached = nil
+	ui.QueueFunc(func() {
+		// restore the terminal before the client exits
+		ui.Detach()
+		close(detached)
+		log.Infof("user interface detached")
+	})
+	return true
+}
+
+// Detach releases the terminal attached to the daemon. It returns false if
+// aerc is not running as a daemon or if no terminal is attached.
+func Detach() bool {
+	if !daemon.enabled {
+		return false
+	}
+	daemon.lock.Lock()
+	detached := daemon.detached
+	daemon.lock.Unlock()
+	return daemon.detach(detached)
+}
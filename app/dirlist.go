@@ -160,7 +160,7 @@ func (dirlist *DirectoryList) Update(msg types.WorkerMessage) {
 		dir.Recent = msg.Info.Recent
 		dir.Unseen = msg.Info.Unseen
 		if msg.Refetch {
-			store, ok := dirlist.SelectedMsgStore()
+			store, ok := dirlist.MsgStore(msg.Info.Name)
 			if ok {
 				store.Sort(store.GetCurrentSortCriteria(), nil)
 			}
@@ -316,6 +316,10 @@ func (dirlist *DirectoryList) renderDir(
 	if r > 0 {
 		styles = append(styles, config.STYLE_DIRLIST_RECENT)
 	}
+	if dir := dirlist.Directory(path); dir != nil &&
+		dir.Namespace != models.PersonalNamespace {
+		styles = append(styles, config.STYLE_DIRLIST_SHARED)
+	}
 	conf = conf.ForFolder(path)
 	if selected {
 		style = conf.GetComposedStyleSelected(
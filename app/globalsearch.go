@@ -0,0 +1,279 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/lib/format"
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/ui"
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+	"github.com/mattn/go-runewidth"
+)
+
+const globalSearchTimeout = 2 * time.Minute
+
+// GlobalResult is a message found by a search across accounts or folders.
+type GlobalResult struct {
+	Account string
+	Folder  string
+	Info    *models.MessageInfo
+}
+
+// GlobalSearch searches messages in several folders of several accounts.
+// When allFolders is false, only the selected folder of each account is
+// searched. The folders are searched through the worker of each account
+// without changing the folder open in its tab. The results are sorted by
+// date, newest first.
+func GlobalSearch(
+	accounts []*AccountView, allFolders bool, criteria *types.SearchCriteria,
+) ([]*GlobalResult, []error) {
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var results []*GlobalResult
+	var errs []error
+
+	for _, acct := range accounts {
+		folders := []string{acct.SelectedDirectory()}
+		if allFolders {
+			folders = acct.searchableFolders()
+		}
+		wg.Add(1)
+		go func(acct *AccountView) {
+			defer log.PanicHandler()
+			defer wg.Done()
+
+			res, err := acct.search(folders, criteria)
+			lock.Lock()
+			defer lock.Unlock()
+			results = append(results, res...)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", acct.Name(), err))
+			}
+		}(acct)
+	}
+	wg.Wait()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].date().After(results[j].date())
+	})
+	return results, errs
+}
+
+func (r *GlobalResult) date() time.Time {
+	if r.Info.Envelope != nil {
+		return r.Info.Envelope.Date
+	}
+	return r.Info.InternalDate
+}
+
+// searchableFolders returns the folders displayed in the sidebar, without the
+// virtual folders of saved searches.
+func (acct *AccountView) searchableFolders() []string {
+	var folders []string
+	for _, name := range acct.dirlist.List() {
+		dir := acct.dirlist.Directory(name)
+		if dir != nil && acct.acct.Backend != "notmuch" &&
+			(dir.Role == models.QueryRole || dir.Role == models.VirtualRole) {
+			continue
+		}
+		folders = append(folders, name)
+	}
+	return folders
+}
+
+// search runs a search in folders of the account.
+func (acct *AccountView) search(
+	folders []string, criteria *types.SearchCriteria,
+) ([]*GlobalResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), globalSearchTimeout)
+	defer cancel()
+
+	var results []*GlobalResult
+	var errs []error
+	for _, folder := range folders {
+		res, err := searchFolder(ctx, acct.worker, folder, criteria)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", folder, err))
+			continue
+		}
+		for _, info := range res {
+			results = append(results, &GlobalResult{
+				Account: acct.acct.Name,
+				Folder:  folder,
+				Info:    info,
+			})
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+func searchFolder(
+	ctx context.Context, w *types.Worker, folder string,
+	criteria *types.SearchCriteria,
+) ([]*models.MessageInfo, error) {
+	msgs, err := postAndCollect(ctx, w, types.InFolder(&types.SearchDirectory{
+		Context:  ctx,
+		Criteria: criteria,
+	}, folder))
+	if err != nil {
+		return nil, err
+	}
+	var uids []models.UID
+	for _, msg := range msgs {
+		if res, ok := msg.(*types.SearchResults); ok {
+			uids = append(uids, res.Uids...)
+		}
+	}
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	msgs, err = postAndCollect(ctx, w, types.InFolder(&types.FetchMessageHeaders{
+		Context: ctx,
+		Uids:    uids,
+	}, folder))
+	if err != nil {
+		return nil, err
+	}
+	var infos []*models.MessageInfo
+	for _, msg := range msgs {
+		if info, ok := msg.(*types.MessageInfo); ok && info.Info.Error == nil {
+			infos = append(infos, info.Info)
+		}
+	}
+	return infos, nil
+}
+
+// postAndCollect sends an action to a worker and returns the messages
+// received in response to it once it is completed. The responses are not
+// dispatched to the account tab.
+func postAndCollect(
+	ctx context.Context, w *types.Worker, action types.WorkerMessage,
+) ([]types.WorkerMessage, error) {
+	replies := make(chan types.WorkerMessage, 50)
+	w.PostAction(types.ReplyTo(action, replies), nil)
+
+	var msgs []types.WorkerMessage
+	for {
+		select {
+		case msg := <-replies:
+			switch msg := msg.(type) {
+			case *types.SearchResults:
+				// some workers send Done after search results, the
+				// channel is large enough to hold it
+				return append(msgs, msg), nil
+			case *types.Done:
+				return msgs, nil
+			case *types.Error:
+				return nil, msg.Error
+			case *types.ConnError:
+				return nil, msg.Error
+			case *types.Unsupported:
+				return nil, errors.New("unsupported by this backend")
+			case *types.Cancelled:
+				return nil, errors.New("cancelled")
+			default:
+				msgs = append(msgs, msg)
+			}
+		case <-ctx.Done():
+			// do not block the worker with the remaining responses
+			go func() {
+				defer log.PanicHandler()
+				for msg := range replies {
+					switch msg.(type) {
+					case *types.Done, *types.Error, *types.ConnError,
+						*types.Unsupported, *types.Cancelled:
+						return
+					}
+				}
+			}()
+			return nil, errors.New("timed-out")
+		}
+	}
+}
+
+// ShowGlobalResults displays the results of a global search in a dialog.
+// Selecting a result opens the message in its account and folder.
+func ShowGlobalResults(title string, results []*GlobalResult) {
+	var accountWidth, folderWidth int
+	for _, r := range results {
+		accountWidth = max(accountWidth, runewidth.StringWidth(r.Account))
+		folderWidth = max(folderWidth, runewidth.StringWidth(r.Folder))
+	}
+	type resultKey struct {
+		account string
+		folder  string
+		uid     models.UID
+	}
+	lines := make([]string, 0, len(results))
+	keys := make(map[string]resultKey, len(results))
+	byKey := make(map[resultKey]*GlobalResult, len(results))
+	for _, r := range results {
+		key := resultKey{r.Account, r.Folder, r.Info.Uid}
+		if _, dup := byKey[key]; dup {
+			continue
+		}
+		var from, subject string
+		if env := r.Info.Envelope; env != nil {
+			from = format.FormatAddresses(env.From)
+			subject = env.Subject
+		}
+		line := fmt.Sprintf("%s  %s  %s  %s  %s",
+			runewidth.FillRight(r.Account, accountWidth),
+			runewidth.FillRight(r.Folder, folderWidth),
+			r.date().Local().Format("2006-01-02 15:04"),
+			runewidth.FillRight(runewidth.Truncate(from, 25, "…"), 25),
+			subject)
+		if _, dup := keys[line]; dup {
+			// different messages which look the same
+			line += fmt.Sprintf("  (%s)", r.Info.Uid)
+		}
+		lines = append(lines, line)
+		keys[line] = key
+		byKey[key] = r
+	}
+
+	uiConfig := SelectedAccountUiConfig()
+	AddDialog(DefaultDialog(
+		ui.NewBox(NewListBox(
+			"Press <Enter> to open a message, <Esc> to close. "+
+				"Start typing to filter.",
+			lines, uiConfig,
+			func(line string) {
+				CloseDialog()
+				if r, ok := byKey[keys[line]]; ok {
+					openGlobalResult(r)
+				}
+			},
+		), title, "", uiConfig),
+	))
+}
+
+// openGlobalResult selects the message of a search result in its account.
+func openGlobalResult(r *GlobalResult) {
+	acct, err := Account(r.Account)
+	if err != nil {
+		PushError(err.Error())
+		return
+	}
+	acct.Select()
+	go func() {
+		defer log.PanicHandler()
+
+		if err := acct.openDirectory(r.Folder); err != nil {
+			acct.PushError(err)
+			return
+		}
+		ui.QueueFunc(func() {
+			if store, ok := acct.dirlist.MsgStore(r.Folder); ok {
+				store.Select(r.Info.Uid)
+			}
+			ui.Invalidate()
+		})
+	}()
+}
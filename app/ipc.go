@@ -0,0 +1,221 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/lib/format"
+	"git.sr.ht/~rjarry/aerc/lib/ipc"
+	"git.sr.ht/~rjarry/aerc/lib/ui"
+	"git.sr.ht/~rjarry/aerc/models"
+	"github.com/emersion/go-message/mail"
+)
+
+const ipcQueryTimeout = 10 * time.Second
+
+// Query answers the JSON-RPC methods of the IPC socket. The state of aerc is
+// read from the UI goroutine.
+func (aerc *Aerc) Query(method string, params json.RawMessage) (any, error) {
+	var query func() (any, error)
+
+	switch method {
+	case ipc.MethodAccounts:
+		query = aerc.ipcAccounts
+	case ipc.MethodFolders:
+		var p ipc.FoldersParams
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &p); err != nil {
+				return nil, &ipc.RPCError{
+					Code: ipc.CodeInvalidParams, Message: err.Error(),
+				}
+			}
+		}
+		query = func() (any, error) { return aerc.ipcFolders(p.Account) }
+	case ipc.MethodMessage:
+		query = aerc.ipcSelectedMessage
+	case ipc.MethodTabs:
+		query = aerc.ipcTabs
+	default:
+		return nil, &ipc.RPCError{
+			Code:    ipc.CodeMethodNotFound,
+			Message: fmt.Sprintf("unknown method %q", method),
+		}
+	}
+
+	type result struct {
+		value any
+		err   error
+	}
+	res := make(chan result, 1)
+	ui.QueueFunc(func() {
+		value, err := query()
+		res <- result{value: value, err: err}
+	})
+	select {
+	case r := <-res:
+		return r.value, r.err
+	case <-time.After(ipcQueryTimeout):
+		return nil, errors.New("timed out")
+	}
+}
+
+func (aerc *Aerc) ipcAccounts() (any, error) {
+	selected := aerc.SelectedAccount()
+	accounts := make([]*ipc.Account, 0, len(aerc.accounts))
+	for _, name := range aerc.AccountNames() {
+		acct, err := aerc.Account(name)
+		if err != nil {
+			continue
+		}
+		accounts = append(accounts, &ipc.Account{
+			Name:      name,
+			Backend:   acct.acct.Backend,
+			Connected: acct.state.Connected,
+			Folder:    acct.SelectedDirectory(),
+			Selected:  acct == selected,
+		})
+	}
+	return accounts, nil
+}
+
+func (aerc *Aerc) ipcFolders(name string) (any, error) {
+	acct := aerc.SelectedAccount()
+	if name != "" {
+		var err error
+		acct, err = aerc.Account(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if acct == nil {
+		return nil, errors.New("no account selected")
+	}
+	selected := acct.SelectedDirectory()
+	names := acct.dirlist.List()
+	folders := make([]*ipc.Folder, 0, len(names))
+	for _, name := range names {
+		folder := &ipc.Folder{Name: name, Selected: name == selected}
+		if dir := acct.dirlist.Directory(name); dir != nil {
+			folder.Role = string(dir.Role)
+		}
+		folder.Recent, folder.Unseen, folder.Exists = acct.dirlist.GetRUECount(name)
+		folders = append(folders, folder)
+	}
+	return folders, nil
+}
+
+func (aerc *Aerc) ipcSelectedMessage() (any, error) {
+	provider, ok := aerc.SelectedTabContent().(ProvidesMessage)
+	if !ok {
+		return nil, nil
+	}
+	acct := provider.SelectedAccount()
+	msg, err := provider.SelectedMessage()
+	if acct == nil || err != nil {
+		return nil, nil
+	}
+	folder := acct.SelectedDirectory()
+	if store := provider.Store(); store != nil {
+		folder = store.Name
+	}
+	return ipcMessage(acct.Name(), folder, msg), nil
+}
+
+func (aerc *Aerc) ipcTabs() (any, error) {
+	selected := aerc.tabs.Selected()
+	var tabs []*ipc.Tab
+	for i := 0; ; i++ {
+		tab := aerc.tabs.Get(i)
+		if tab == nil {
+			break
+		}
+		var kind string
+		switch tab.Content.(type) {
+		case *AccountView:
+			kind = "account"
+		case *MessageViewer:
+			kind = "viewer"
+		case *Composer:
+			kind = "composer"
+		case *Terminal:
+			kind = "terminal"
+		default:
+			kind = "other"
+		}
+		tabs = append(tabs, &ipc.Tab{
+			Title:    tab.Name,
+			Kind:     kind,
+			Selected: tab == selected,
+		})
+	}
+	return tabs, nil
+}
+
+var ipcFlags = []struct {
+	flag models.Flags
+	name string
+}{
+	{models.SeenFlag, "seen"},
+	{models.RecentFlag, "recent"},
+	{models.AnsweredFlag, "answered"},
+	{models.ForwardedFlag, "forwarded"},
+	{models.DeletedFlag, "deleted"},
+	{models.FlaggedFlag, "flagged"},
+	{models.DraftFlag, "draft"},
+}
+
+// ipcMessage converts a message to its representation in the JSON-RPC API.
+func ipcMessage(account, folder string, msg *models.MessageInfo) *ipc.Message {
+	m := &ipc.Message{
+		Account: account,
+		Folder:  folder,
+		UID:     string(msg.Uid),
+		Date:    msg.InternalDate,
+		Flags:   []string{},
+		Labels:  msg.Labels,
+	}
+	if msg.Account != "" {
+		// message of a unified account
+		m.Account = msg.Account
+	}
+	if env := msg.Envelope; env != nil {
+		m.MessageID = env.MessageId
+		m.Subject = env.Subject
+		if !env.Date.IsZero() {
+			m.Date = env.Date
+		}
+		m.From = addressList(env.From)
+		m.To = addressList(env.To)
+		m.Cc = addressList(env.Cc)
+	}
+	for _, f := range ipcFlags {
+		if msg.Flags.Has(f.flag) {
+			m.Flags = append(m.Flags, f.name)
+		}
+	}
+	return m
+}
+
+func addressList(addrs []*mail.Address) []string {
+	list := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		list = append(list, format.AddressForHumans(addr))
+	}
+	return list
+}
+
+// PublishMailSent notifies the IPC clients that a message was sent, or that
+// sending it failed.
+func PublishMailSent(account, subject string, err error) {
+	event := &ipc.Event{
+		Type:    ipc.EventMailSent,
+		Account: account,
+		Subject: subject,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	ipc.Publish(event)
+}
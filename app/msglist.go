@@ -170,6 +170,10 @@ func (ml *MessageList) Draw(ctx *ui.Context) {
 		if showThreads {
 			threadView.Update(data, uid)
 		}
+		if msg := store.Messages[uid]; msg != nil && msg.Account != "" {
+			// messages of unified accounts
+			data.SetAccount(acct.Owner(msg).acct)
+		}
 		if addMessage(store, uid, &table, data, uiConfig) {
 			break
 		}
@@ -18,6 +18,7 @@ import (
 	"git.sr.ht/~rjarry/aerc/config"
 	"git.sr.ht/~rjarry/aerc/lib"
 	"git.sr.ht/~rjarry/aerc/lib/auth"
+	"git.sr.ht/~rjarry/aerc/lib/filters"
 	"git.sr.ht/~rjarry/aerc/lib/format"
 	"git.sr.ht/~rjarry/aerc/lib/log"
 	"git.sr.ht/~rjarry/aerc/lib/parse"
@@ -422,6 +423,8 @@ type PartViewer struct {
 	err        error
 	fetched    bool
 	filter     *exec.Cmd
+	builtin    filters.Filter
+	opts       filters.Options
 	index      []int
 	msg        lib.MessageView
 	pager      *exec.Cmd
@@ -450,6 +453,7 @@ func NewPartViewer(
 ) (*PartViewer, error) {
 	var (
 		filter  *exec.Cmd
+		builtin filters.Filter
 		pager   *exec.Cmd
 		pagerin io.WriteCloser
 		term    *Terminal
@@ -461,7 +465,7 @@ func NewPartViewer(
 		switch f.Type {
 		case config.FILTER_MIMETYPE:
 			if fnmatch.Match(f.Filter, mime, 0) {
-				filter = exec.Command("sh", "-c", f.Command)
+				filter, builtin = newFilter(f)
 			}
 		case config.FILTER_HEADER:
 			var header string
@@ -478,15 +482,15 @@ func NewPartViewer(
 				header = msg.MessageInfo().RFC822Headers.Get(f.Header)
 			}
 			if f.Regex.Match([]byte(header)) {
-				filter = exec.Command("sh", "-c", f.Command)
+				filter, builtin = newFilter(f)
 			}
 		case config.FILTER_FILENAME:
 			if f.Regex.Match([]byte(part.DispositionParams["filename"])) {
-				filter = exec.Command("sh", "-c", f.Command)
+				filter, builtin = newFilter(f)
 				log.Tracef("command %v", f.Command)
 			}
 		}
-		if filter == nil {
+		if filter == nil && builtin == nil {
 			continue
 		}
 		if !f.NeedsPager {
@@ -503,6 +507,10 @@ func NewPartViewer(
 		break
 	}
 	var noFilter *ui.Grid
+	if builtin != nil {
+		log.Debugf("<%s> part=%v %s: builtin | %v",
+			info.Envelope.MessageId, curindex, mime, pager)
+	}
 	if filter != nil {
 		path, _ := os.LookupEnv("PATH")
 		var paths []string
@@ -537,6 +545,8 @@ func NewPartViewer(
 			log.Debugf("<%s> part=%v %s: %v | %v",
 				info.Envelope.MessageId, curindex, mime, filter, pager)
 		}
+	}
+	if pager != nil {
 		var err error
 		if pagerin, err = pager.StdinPipe(); err != nil {
 			return nil, err
@@ -558,9 +568,17 @@ func NewPartViewer(
 	index := make([]int, len(curindex))
 	copy(index, curindex)
 
+	opts := filters.Options{
+		OSC8:    config.General.EnableOSC8,
+		Style:   acct.UiConfig().GetViewerStyle,
+		Subject: info.Envelope.Subject,
+	}
+
 	pv := &PartViewer{
 		acctConfig: acct.AccountConfig(),
 		filter:     filter,
+		builtin:    builtin,
+		opts:       opts,
 		index:      index,
 		msg:        msg,
 		pager:      pager,
@@ -575,6 +593,16 @@ func NewPartViewer(
 	return pv, nil
 }
 
+// newFilter returns the command of an external filter or the function of
+// a builtin filter.
+func newFilter(f *config.FilterConfig) (*exec.Cmd, filters.Filter) {
+	if f.Builtin != "" {
+		builtin, _ := filters.Lookup(f.Builtin)
+		return nil, builtin
+	}
+	return exec.Command("sh", "-c", f.Command), nil
+}
+
 func (pv *PartViewer) SetSource(reader io.Reader) {
 	pv.source = reader
 	switch pv.inlineImg {
@@ -602,7 +630,7 @@ func (pv *PartViewer) decodeImage() {
 
 func (pv *PartViewer) attemptCopy() {
 	if pv.source == nil ||
-		pv.filter == nil ||
+		(pv.filter == nil && pv.builtin == nil) ||
 		atomic.SwapInt32(&pv.copying, copying) == copying {
 		return
 	}
@@ -610,6 +638,21 @@ func (pv *PartViewer) attemptCopy() {
 	if strings.EqualFold(pv.part.MIMEType, "text") {
 		pv.source = parse.StripAnsi(pv.hyperlinks(pv.source))
 	}
+	if pv.builtin != nil {
+		go func() {
+			defer log.PanicHandler()
+			defer atomic.StoreInt32(&pv.copying, 0)
+			err := pv.builtin(pv.source, pv.pagerin, &pv.opts)
+			if err != nil {
+				log.Errorf("error running builtin filter: %v", err)
+			}
+			err = pv.pagerin.Close()
+			if err != nil {
+				log.Errorf("error closing pager pipe: %v", err)
+			}
+		}()
+		return
+	}
 	if pv.filter != pv.pager {
 		// Filter is a separate process that needs to output to the pager.
 		pv.filter.Stdin = pv.source
@@ -662,6 +705,23 @@ func (pv *PartViewer) writeMailHeaders() {
 		}
 		log.Debugf("<%s> piping headers in filter: %s",
 			info.Envelope.MessageId, f.Command)
+		if builtin, ok := filters.Lookup(f.Builtin); ok {
+			r, w := io.Pipe()
+			done := make(chan struct{})
+			go func() {
+				defer log.PanicHandler()
+				defer close(done)
+				err := builtin(r, pv.pagerin, &pv.opts)
+				if err != nil {
+					log.Errorf("builtin header filter: %v", err)
+				}
+				// drain the pipe if the filter stopped early
+				_, _ = io.Copy(io.Discard, r)
+			}()
+			defer func() { <-done }()
+			file = w
+			break
+		}
 		filter := exec.Command("sh", "-c", f.Command)
 		if pv.filter != nil {
 			// inherit from filter env
@@ -790,15 +850,18 @@ func (pv *PartViewer) Invalidate() {
 func (pv *PartViewer) Draw(ctx *ui.Context) {
 	style := pv.uiConfig.GetStyle(config.STYLE_DEFAULT)
 	switch {
-	case pv.filter == nil && canInline(pv.part.FullMIMEType()) && pv.err == nil:
+	case pv.filter == nil && pv.builtin == nil &&
+		canInline(pv.part.FullMIMEType()) && pv.err == nil:
 		pv.inlineImg = true
-	case pv.filter == nil:
+	case pv.filter == nil && pv.builtin == nil:
 		// No filter, can't inline, and/or we attempted to inline an image
 		// and resulted in an error (maybe because of a bad encoding or
 		// the terminal doesn't support any graphics protocol).
 		ctx.Fill(0, 0, ctx.Width(), ctx.Height(), ' ', style)
 		pv.noFilter.Draw(ctx)
 		return
+	case !pv.fetched && pv.builtin != nil:
+		pv.opts.Width, _ = ctx.Window().Size()
 	case !pv.fetched:
 		w, h := ctx.Window().Size()
 		pv.filter.Env = append(pv.filter.Env, fmt.Sprintf("COLUMNS=%d", w))
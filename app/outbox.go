@@ -0,0 +1,106 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/lib/hooks"
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/outbox"
+	"git.sr.ht/~rjarry/aerc/lib/send"
+	"git.sr.ht/~rjarry/aerc/lib/ui"
+)
+
+// FlushOutbox tries to deliver the messages queued in the outbox for this
+// account. Unless force is true, only the messages whose retry delay has
+// expired are sent. It must be called from the main goroutine.
+func (acct *AccountView) FlushOutbox(force bool) {
+	connected := acct.state.Connected
+	go func() {
+		defer log.PanicHandler()
+
+		acct.flushOutbox(force, connected)
+	}()
+}
+
+func (acct *AccountView) flushOutbox(force bool, connected bool) {
+	acct.outboxLock.Lock()
+	defer acct.outboxLock.Unlock()
+
+	if acct.outboxTimer != nil {
+		acct.outboxTimer.Stop()
+		acct.outboxTimer = nil
+	}
+
+	if !connected {
+		// will be flushed again on reconnection
+		if force {
+			acct.PushError(errors.New("outbox: not connected"))
+		}
+		return
+	}
+
+	items, err := outbox.List(acct.Name())
+	if err != nil {
+		acct.PushError(fmt.Errorf("outbox: %w", err))
+		return
+	}
+
+	var pending []*outbox.Item
+	for _, item := range items {
+		if !force && !item.Due(time.Now()) {
+			pending = append(pending, item)
+			continue
+		}
+		log.Debugf("outbox: sending %s (attempt %d)", item.ID, item.Attempts+1)
+		header, err := outbox.Deliver(item, acct.acct, acct.worker)
+		if header == nil {
+			// message was not sent
+			log.Errorf("outbox: %s: %v", item.ID, err)
+			item.Failed(err, send.IsPermanentError(err))
+			if err := outbox.Update(item); err != nil {
+				log.Errorf("outbox: %s: %v", item.ID, err)
+			}
+			acct.PushError(fmt.Errorf("outbox: sending %q failed: %w",
+				item.Subject, err))
+			PublishMailSent(acct.Name(), item.Subject, err)
+			pending = append(pending, item)
+			continue
+		}
+		if rmErr := outbox.Remove(item.ID); rmErr != nil {
+			log.Errorf("outbox: %s: %v", item.ID, rmErr)
+			acct.PushError(fmt.Errorf("outbox: %q sent but not removed: %w",
+				item.Subject, rmErr))
+		}
+		if err != nil {
+			// sent, but copying to the copy-to folders failed
+			acct.PushError(fmt.Errorf("outbox: %w", err))
+		} else {
+			acct.PushStatus(fmt.Sprintf("outbox: %q sent.", item.Subject),
+				10*time.Second)
+		}
+		PublishMailSent(acct.Name(), item.Subject, nil)
+		err = hooks.RunHook(&hooks.MailSent{
+			Account: acct.Name(),
+			Backend: acct.acct.Backend,
+			Header:  header,
+		})
+		if err != nil {
+			log.Errorf("failed to trigger mail-sent hook: %v", err)
+			acct.PushError(fmt.Errorf("[hook.mail-sent] failed: %w", err))
+		}
+	}
+
+	if next, ok := outbox.NextDue(pending); ok {
+		delay := time.Until(next)
+		if delay < time.Second {
+			delay = time.Second
+		}
+		log.Debugf("outbox: next attempt in %s", delay)
+		acct.outboxTimer = time.AfterFunc(delay, func() {
+			ui.QueueFunc(func() { acct.FlushOutbox(false) })
+		})
+	}
+	UpdateStatus()
+}
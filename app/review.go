@@ -0,0 +1,247 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"git.sr.ht/~rjarry/aerc/config"
+	"git.sr.ht/~rjarry/aerc/lib/ui"
+	"git.sr.ht/~rockorager/vaxis"
+	"github.com/mattn/go-runewidth"
+)
+
+// Review displays the lines of a message and lets the user attach comments to
+// them. cb is called with the comments by line number, or with nil if the
+// review is aborted.
+type Review struct {
+	Scrollable
+	lines    []string
+	comments map[int]string
+	cursor   int
+	jump     int
+	editing  bool
+	input    *ui.TextInput
+	uiConfig *config.UIConfig
+	cb       func(map[int]string)
+}
+
+type reviewRow struct {
+	line    int
+	comment bool
+}
+
+func NewReview(text string, uiConfig *config.UIConfig, cb func(map[int]string)) *Review {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.TrimRight(text, "\n")
+	return &Review{
+		lines:    strings.Split(text, "\n"),
+		comments: make(map[int]string),
+		jump:     -1,
+		input:    ui.NewTextInput("", uiConfig).Prompt("Comment: "),
+		uiConfig: uiConfig,
+		cb:       cb,
+	}
+}
+
+// rows returns the displayed rows, comments are shown below their line.
+func (r *Review) rows() []reviewRow {
+	rows := make([]reviewRow, 0, len(r.lines)+len(r.comments))
+	for i := range r.lines {
+		rows = append(rows, reviewRow{line: i})
+		if _, ok := r.comments[i]; ok {
+			rows = append(rows, reviewRow{line: i, comment: true})
+		}
+	}
+	return rows
+}
+
+func (r *Review) Draw(ctx *ui.Context) {
+	defaultStyle := r.uiConfig.GetStyle(config.STYLE_DEFAULT)
+	titleStyle := r.uiConfig.GetStyle(config.STYLE_TITLE)
+	w, h := ctx.Width(), ctx.Height()
+	ctx.Fill(0, 0, w, h, ' ', defaultStyle)
+	ctx.Fill(0, 0, w, 1, ' ', titleStyle)
+	ctx.Printf(0, 0, titleStyle, "%s", runewidth.Truncate(fmt.Sprintf(
+		"%d comments. c: comment, d: delete, [ ]: hunks, r: reply, q: abort",
+		len(r.comments)), w, "…"))
+
+	if r.editing {
+		r.input.Draw(ctx.Subcontext(0, h-1, w, 1))
+		h--
+	}
+	if h > 1 {
+		r.drawLines(ctx.Subcontext(0, 1, w, h-1))
+	}
+}
+
+func (r *Review) drawLines(ctx *ui.Context) {
+	w, h := ctx.Width(), ctx.Height()
+	r.jump = h
+	rows := r.rows()
+
+	r.UpdateScroller(h, len(rows))
+	for i, row := range rows {
+		if row.line == r.cursor && !row.comment {
+			r.EnsureScroll(i)
+			break
+		}
+	}
+
+	needScrollbar := r.NeedScrollbar()
+	if needScrollbar {
+		w -= 1
+	}
+
+	y := 0
+	for i := r.Scroll(); i < len(rows) && y < h; i++ {
+		row := rows[i]
+		var line string
+		var style vaxis.Style
+		if row.comment {
+			line = "  » " + r.comments[row.line]
+			style = r.uiConfig.GetStyle(config.STYLE_WARNING)
+		} else {
+			line = r.lines[row.line]
+			style = r.lineStyle(line)
+			if row.line == r.cursor {
+				style = r.uiConfig.GetComposedStyleSelected(
+					config.STYLE_MSGLIST_DEFAULT, nil)
+				ctx.Fill(0, y, w, 1, ' ', style)
+			}
+		}
+		line = strings.ReplaceAll(line, "\t", "        ")
+		ctx.Printf(0, y, style, "%s", runewidth.Truncate(line, w, "❯"))
+		y++
+	}
+
+	if needScrollbar {
+		r.drawScrollbar(ctx.Subcontext(w, 0, 1, h))
+	}
+}
+
+// lineStyle colors the lines of patches like the hldiff filter.
+func (r *Review) lineStyle(line string) vaxis.Style {
+	switch {
+	case strings.HasPrefix(line, "diff "), strings.HasPrefix(line, "index "),
+		strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "):
+		return r.uiConfig.GetStyle(config.STYLE_HEADER)
+	case strings.HasPrefix(line, "@@"):
+		return r.uiConfig.GetStyle(config.STYLE_TITLE)
+	case strings.HasPrefix(line, "+"):
+		return r.uiConfig.GetStyle(config.STYLE_SUCCESS)
+	case strings.HasPrefix(line, "-") && line != "-- ":
+		return r.uiConfig.GetStyle(config.STYLE_ERROR)
+	}
+	return r.uiConfig.GetStyle(config.STYLE_DEFAULT)
+}
+
+func (r *Review) drawScrollbar(ctx *ui.Context) {
+	gutterStyle := vaxis.Style{}
+	pillStyle := vaxis.Style{Attribute: vaxis.AttrReverse}
+
+	// gutter
+	h := ctx.Height()
+	ctx.Fill(0, 0, 1, h, ' ', gutterStyle)
+
+	// pill
+	pillSize := int(math.Ceil(float64(h) * r.PercentVisible()))
+	pillOffset := int(math.Floor(float64(h) * r.PercentScrolled()))
+	ctx.Fill(0, pillOffset, 1, pillSize, ' ', pillStyle)
+}
+
+func (r *Review) moveCursor(delta int) {
+	r.cursor += delta
+	if r.cursor >= len(r.lines) {
+		r.cursor = len(r.lines) - 1
+	}
+	if r.cursor < 0 {
+		r.cursor = 0
+	}
+}
+
+// nextHunk moves the cursor to the next (or previous) diff hunk header.
+func (r *Review) nextHunk(delta int) {
+	for i := r.cursor + delta; i >= 0 && i < len(r.lines); i += delta {
+		if strings.HasPrefix(r.lines[i], "@@") {
+			r.cursor = i
+			return
+		}
+	}
+}
+
+func (r *Review) Invalidate() {
+	ui.Invalidate()
+}
+
+func (r *Review) Event(event vaxis.Event) bool {
+	key, ok := event.(vaxis.Key)
+	if r.editing {
+		switch {
+		case ok && key.Matches(vaxis.KeyEnter):
+			comment := strings.TrimSpace(r.input.String())
+			if comment == "" {
+				delete(r.comments, r.cursor)
+			} else {
+				r.comments[r.cursor] = comment
+			}
+			fallthrough
+		case ok && key.Matches(vaxis.KeyEsc):
+			r.editing = false
+			r.input.Focus(false)
+		default:
+			r.input.Event(event)
+		}
+		r.Invalidate()
+		return true
+	}
+	if !ok {
+		return false
+	}
+	switch {
+	case key.Matches('k'), key.Matches('p', vaxis.ModCtrl), key.Matches(vaxis.KeyUp):
+		r.moveCursor(-1)
+	case key.Matches('j'), key.Matches('n', vaxis.ModCtrl), key.Matches(vaxis.KeyDown):
+		r.moveCursor(+1)
+	case key.Matches(vaxis.KeyPgUp):
+		if r.jump >= 0 {
+			r.moveCursor(-r.jump)
+		}
+	case key.Matches(vaxis.KeyPgDown):
+		if r.jump >= 0 {
+			r.moveCursor(+r.jump)
+		}
+	case key.Matches('g'), key.Matches(vaxis.KeyHome):
+		r.cursor = 0
+	case key.Matches('G'), key.Matches(vaxis.KeyEnd):
+		r.cursor = len(r.lines) - 1
+	case key.Matches('['):
+		r.nextHunk(-1)
+	case key.Matches(']'):
+		r.nextHunk(+1)
+	case key.Matches('c'), key.Matches(vaxis.KeyEnter):
+		r.editing = true
+		r.input.Set(r.comments[r.cursor])
+		r.input.Focus(true)
+	case key.Matches('d'):
+		delete(r.comments, r.cursor)
+	case key.Matches('r'):
+		if len(r.comments) == 0 {
+			PushError("No comments to reply with.")
+			return true
+		}
+		r.cb(r.comments)
+	case key.Matches('q'), key.Matches(vaxis.KeyEsc):
+		r.cb(nil)
+	default:
+		return false
+	}
+	r.Invalidate()
+	return true
+}
+
+func (r *Review) Focus(f bool) {
+	if !f {
+		r.input.Focus(false)
+	}
+}
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"git.sr.ht/~rjarry/aerc/config"
+	"git.sr.ht/~rjarry/aerc/lib"
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/rules"
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+// applyRules runs the rules defined in rules.conf against a message which
+// was just received. Actions are performed through the message store, they
+// can be reverted with :undo.
+func (acct *AccountView) applyRules(folder string, msg *models.MessageInfo) {
+	selected := rules.Select(config.Rules, acct.Name(), folder)
+	if len(selected) == 0 {
+		return
+	}
+	// worker actions are performed in the folder of the store, which may
+	// not be the one currently opened
+	store, ok := acct.dirlist.MsgStore(folder)
+	if !ok {
+		return
+	}
+	// the same message may be reported as new multiple times
+	key := store.Name + "\x00" + string(msg.Uid)
+	if _, done := acct.ruled[key]; done {
+		return
+	}
+	acct.ruled[key] = struct{}{}
+
+	full := msg.RFC822Headers == nil
+	for _, rule := range selected {
+		if rules.NeedsBody(rule) || rule.Pipe != "" {
+			full = true
+		}
+	}
+	if !full {
+		data, err := rules.Headers(msg)
+		if err != nil {
+			log.Errorf("[%s] rules: %v", acct.Name(), err)
+			return
+		}
+		acct.runRules(store, msg.Uid, selected, data)
+		return
+	}
+	store.FetchFull([]models.UID{msg.Uid}, func(fm *types.FullMessage) {
+		data, err := io.ReadAll(fm.Content.Reader)
+		if err != nil {
+			log.Errorf("[%s] rules: %v", acct.Name(), err)
+			return
+		}
+		acct.runRules(store, msg.Uid, selected, data)
+	})
+}
+
+func (acct *AccountView) runRules(
+	store *lib.MessageStore, uid models.UID,
+	selected []*config.RuleConfig, data []byte,
+) {
+	defer store.UndoJournal().Batch()()
+
+	for _, rule := range selected {
+		match, err := rules.Match(rule, uid, data)
+		if err != nil {
+			log.Errorf("[%s] rule [%s]: %v", acct.Name(), rule.Name, err)
+			continue
+		}
+		if !match {
+			continue
+		}
+		log.Debugf("[%s] rule [%s] matches %s/%s",
+			acct.Name(), rule.Name, store.Name, uid)
+		if acct.applyRule(store, uid, rule, data) {
+			// the message is no longer in this folder
+			break
+		}
+	}
+}
+
+// applyRule performs the actions of a rule. It returns true if the message
+// was moved or deleted.
+func (acct *AccountView) applyRule(
+	store *lib.MessageStore, uid models.UID,
+	rule *config.RuleConfig, data []byte,
+) bool {
+	uids := []models.UID{uid}
+	onError := func(msg types.WorkerMessage) {
+		switch msg := msg.(type) {
+		case *types.Error:
+			PushError(fmt.Sprintf("rule [%s]: %v", rule.Name, msg.Error))
+		case *types.Unsupported:
+			PushError(fmt.Sprintf("rule [%s]: unsupported action", rule.Name))
+		}
+	}
+
+	if rule.Pipe != "" {
+		go acct.pipeRule(store.Name, rule, data)
+	}
+	flags := rule.Flag
+	if rule.MarkRead {
+		flags |= models.SeenFlag
+	}
+	if flags != 0 {
+		store.Flag(uids, flags, true, onError)
+	}
+	if len(rule.Tag) > 0 {
+		add, remove := rules.Tags(rule)
+		store.ModifyLabels(uids, add, remove, onError)
+	}
+	for _, dest := range rule.Copy {
+		store.Copy(uids, dest, false, nil, onError)
+	}
+	switch {
+	case rule.Move != "":
+		store.Move(uids, rule.Move, false, nil, onError)
+		return true
+	case rule.Delete:
+		store.Delete(uids, nil, onError)
+		return true
+	}
+	return false
+}
+
+func (acct *AccountView) pipeRule(folder string, rule *config.RuleConfig, data []byte) {
+	defer log.PanicHandler()
+
+	cmd := exec.Command("sh", "-c", rule.Pipe)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("AERC_ACCOUNT=%s", acct.Name()),
+		fmt.Sprintf("AERC_FOLDER=%s", folder),
+		fmt.Sprintf("AERC_RULE=%s", rule.Name),
+	)
+	cmd.Stdin = bytes.NewReader(data)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Errorf("[%s] rule [%s]: %s: %v: %s", acct.Name(), rule.Name,
+			rule.Pipe, err, strings.TrimSpace(string(out)))
+		PushError(fmt.Sprintf("rule [%s]: %s: %v", rule.Name, rule.Pipe, err))
+	}
+}
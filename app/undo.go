@@ -0,0 +1,226 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/lib"
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/ui"
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+// maximum time to wait for each worker action while reverting an operation
+const undoTimeout = 30 * time.Second
+
+// Undo reverts the last operation done on the messages of this account. The
+// operation is reverted in the background and the folder where it was done
+// is selected once finished.
+func (acct *AccountView) Undo() error {
+	entries, err := acct.undo.Pop()
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer log.PanicHandler()
+
+		var done []string
+		for i := len(entries) - 1; i >= 0; i-- {
+			e := entries[i]
+			if err := acct.revert(e); err != nil {
+				log.Errorf("[%s] undo %s: %v", acct.Name(), e, err)
+				PushError(fmt.Sprintf("Cannot undo %s: %v", e, err))
+				return
+			}
+			done = append(done, e.String())
+		}
+		PushStatus("Undone "+strings.Join(done, ", "), 10*time.Second)
+	}()
+	return nil
+}
+
+func (acct *AccountView) revert(e *lib.UndoEntry) error {
+	switch e.Op {
+	case lib.UndoMove:
+		return acct.revertMove(e)
+	case lib.UndoDelete:
+		return acct.revertDelete(e)
+	case lib.UndoFlag:
+		if err := acct.openDirectory(e.Directory); err != nil {
+			return err
+		}
+		if len(e.Uids) == 0 {
+			return nil
+		}
+		_, err := acct.postAndWait(&types.FlagMessages{
+			Enable: !e.Enable,
+			Flags:  e.Flags,
+			Uids:   e.Uids,
+		})
+		return err
+	case lib.UndoLabels:
+		return acct.revertLabels(e)
+	}
+	return fmt.Errorf("unknown operation %d", e.Op)
+}
+
+func (acct *AccountView) revertMove(e *lib.UndoEntry) error {
+	if len(e.MessageIds) == 0 {
+		return errors.New("no Message-Id to look for")
+	}
+	if err := acct.openDirectory(e.Destination); err != nil {
+		return err
+	}
+	var uids []models.UID
+	for _, id := range e.MessageIds {
+		header := make(textproto.MIMEHeader)
+		header.Set("Message-Id", id)
+		res, err := acct.postAndWait(&types.SearchDirectory{
+			Context:  context.Background(),
+			Criteria: &types.SearchCriteria{Headers: header},
+		})
+		if err != nil {
+			return err
+		}
+		uids = append(uids, res...)
+	}
+	if len(uids) == 0 {
+		return fmt.Errorf("messages not found in %s", e.Destination)
+	}
+	_, err := acct.postAndWait(&types.MoveMessages{
+		Destination: e.Directory,
+		Uids:        uids,
+	})
+	if err != nil {
+		return err
+	}
+	return acct.openDirectory(e.Directory)
+}
+
+func (acct *AccountView) revertDelete(e *lib.UndoEntry) error {
+	if err := acct.openDirectory(e.Directory); err != nil {
+		return err
+	}
+	uids := make([]models.UID, 0, len(e.Messages))
+	for uid := range e.Messages {
+		uids = append(uids, uid)
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	lost := 0
+	for _, uid := range uids {
+		m := e.Messages[uid]
+		if m.Data == nil {
+			lost++
+			continue
+		}
+		_, err := acct.postAndWait(&types.AppendMessage{
+			Destination: e.Directory,
+			Flags:       m.Flags,
+			Date:        m.Date,
+			Reader:      bytes.NewReader(m.Data),
+			Length:      len(m.Data),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if lost > 0 {
+		return fmt.Errorf("%d message(s) could not be fetched before deletion", lost)
+	}
+	return nil
+}
+
+func (acct *AccountView) revertLabels(e *lib.UndoEntry) error {
+	if err := acct.openDirectory(e.Directory); err != nil {
+		return err
+	}
+	// group messages that need the same changes
+	groups := make(map[string][]models.UID)
+	changes := make(map[string]lib.LabelChange)
+	for uid, c := range e.Labels {
+		key := strings.Join(c.Add, ",") + "/" + strings.Join(c.Remove, ",")
+		groups[key] = append(groups[key], uid)
+		changes[key] = c
+	}
+	for key, uids := range groups {
+		_, err := acct.postAndWait(&types.ModifyLabels{
+			Uids:   uids,
+			Add:    changes[key].Add,
+			Remove: changes[key].Remove,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openDirectory selects a folder and waits until it is opened.
+func (acct *AccountView) openDirectory(name string) error {
+	if acct.SelectedDirectory() == name {
+		return nil
+	}
+	res := make(chan error, 1)
+	ui.QueueFunc(func() {
+		acct.dirlist.Open(name, "", 0, func(msg types.WorkerMessage) {
+			switch msg := msg.(type) {
+			case *types.Done:
+				res <- nil
+			case *types.Error:
+				res <- msg.Error
+			case *types.Cancelled:
+				res <- errors.New("cancelled")
+			}
+		}, false)
+	})
+	select {
+	case err := <-res:
+		return err
+	case <-time.After(undoTimeout):
+		return fmt.Errorf("timed-out opening %s", name)
+	}
+}
+
+// postAndWait sends an action to the worker and waits for its completion.
+// The UIDs of search results are returned, if any.
+func (acct *AccountView) postAndWait(action types.WorkerMessage) ([]models.UID, error) {
+	type result struct {
+		uids []models.UID
+		err  error
+	}
+	res := make(chan result, 1)
+	reply := func(r result) {
+		// some workers send Done after search results, ignore it
+		select {
+		case res <- r:
+		default:
+		}
+	}
+	acct.worker.PostAction(action, func(msg types.WorkerMessage) {
+		switch msg := msg.(type) {
+		case *types.SearchResults:
+			reply(result{uids: msg.Uids})
+		case *types.Done:
+			reply(result{})
+		case *types.Error:
+			reply(result{err: msg.Error})
+		case *types.Unsupported:
+			reply(result{err: errors.New("unsupported by this backend")})
+		case *types.Cancelled:
+			reply(result{err: errors.New("cancelled")})
+		}
+	})
+	select {
+	case r := <-res:
+		return r.uids, r.err
+	case <-time.After(undoTimeout):
+		return nil, errors.New("timed-out")
+	}
+}
@@ -71,6 +71,7 @@ func (c Compose) Execute(args []string) error {
 	if acct == nil {
 		return errors.New("No account selected")
 	}
+	acct = acct.Owner(nil)
 
 	msg, err := gomail.ReadMessage(strings.NewReader(c.Body))
 	if errors.Is(err, io.EOF) { // completely empty
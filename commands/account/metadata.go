@@ -0,0 +1,123 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+	"git.sr.ht/~rjarry/go-opt/v2"
+)
+
+// entry holding the special use of a folder, see RFC 6154 section 4
+const specialUseEntry = "/private/specialuse"
+
+type Metadata struct {
+	Delete bool   `opt:"-d" desc:"Remove the entry."`
+	Folder string `opt:"-f" complete:"CompleteFolder" desc:"Folder name."`
+	Entry  string `opt:"entry" complete:"CompleteEntry" desc:"Entry name."`
+	Value  string `opt:"..." required:"false" desc:"New value of the entry."`
+}
+
+func init() {
+	commands.Register(Metadata{})
+}
+
+func (Metadata) Description() string {
+	return "Show or change a folder annotation."
+}
+
+func (Metadata) Context() commands.CommandContext {
+	return commands.MESSAGE_LIST
+}
+
+func (Metadata) Aliases() []string {
+	return []string{"metadata"}
+}
+
+func (*Metadata) CompleteFolder(arg string) []string {
+	acct := app.SelectedAccount()
+	if acct == nil {
+		return nil
+	}
+	return commands.FilterList(acct.Directories().List(), arg, opt.QuoteArg)
+}
+
+func (*Metadata) CompleteEntry(arg string) []string {
+	entries := []string{
+		"/private/comment",
+		"/shared/comment",
+		specialUseEntry,
+	}
+	return commands.FilterList(entries, arg, nil)
+}
+
+func (m Metadata) Execute(args []string) error {
+	acct := app.SelectedAccount()
+	if acct == nil {
+		return errors.New("No account selected")
+	}
+	if m.Delete && m.Value != "" {
+		return errors.New("-d and a value are mutually exclusive")
+	}
+	folder := m.Folder
+	if folder == "" {
+		folder = acct.Directories().Selected()
+	}
+	onError := func(msg types.WorkerMessage) bool {
+		switch msg := msg.(type) {
+		case *types.Error:
+			app.PushError(msg.Error.Error())
+		case *types.Unsupported:
+			app.PushError(":metadata is not supported by the backend.")
+		default:
+			return false
+		}
+		return true
+	}
+
+	if !m.Delete && m.Value == "" {
+		acct.Worker().PostAction(&types.GetMetadata{
+			Directory: folder,
+			Entries:   []string{m.Entry},
+		}, func(msg types.WorkerMessage) {
+			if onError(msg) {
+				return
+			}
+			if msg, ok := msg.(*types.DirectoryMetadata); ok {
+				value, ok := msg.Entries[m.Entry]
+				if !ok {
+					value = "not set"
+				}
+				app.PushStatus(fmt.Sprintf("%s %s: %s",
+					folder, m.Entry, value), 10*time.Second)
+			}
+		})
+		return nil
+	}
+
+	acct.Worker().PostAction(&types.SetMetadata{
+		Directory: folder,
+		Entry:     m.Entry,
+		Value:     m.Value,
+	}, func(msg types.WorkerMessage) {
+		if onError(msg) {
+			return
+		}
+		if _, ok := msg.(*types.Done); !ok {
+			return
+		}
+		if m.Entry == specialUseEntry {
+			if dir := acct.Directories().Directory(folder); dir != nil {
+				use := strings.TrimPrefix(m.Value, "\\")
+				dir.Role = models.Roles[strings.ToLower(use)]
+			}
+		}
+		app.PushStatus("Folder annotation changed.", 10*time.Second)
+	})
+	return nil
+}
@@ -2,16 +2,29 @@ package account
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"git.sr.ht/~rjarry/aerc/app"
 	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/models"
 	"git.sr.ht/~rjarry/aerc/worker/types"
 	"git.sr.ht/~rjarry/go-opt/v2"
 )
 
 type MakeDir struct {
-	Folder string `opt:"folder" complete:"CompleteFolder" desc:"Folder name."`
+	Role   models.Role `opt:"-r" action:"ParseRole" complete:"CompleteRole" desc:"Special use of the folder."`
+	Folder string      `opt:"folder" complete:"CompleteFolder" desc:"Folder name."`
+}
+
+// roles which can be given to new folders
+var specialUseRoles = []models.Role{
+	models.AllRole,
+	models.ArchiveRole,
+	models.DraftsRole,
+	models.JunkRole,
+	models.SentRole,
+	models.TrashRole,
 }
 
 func init() {
@@ -30,6 +43,24 @@ func (MakeDir) Aliases() []string {
 	return []string{"mkdir"}
 }
 
+func (m *MakeDir) ParseRole(arg string) error {
+	for _, role := range specialUseRoles {
+		if string(role) == arg {
+			m.Role = role
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown folder role: %s", arg)
+}
+
+func (*MakeDir) CompleteRole(arg string) []string {
+	roles := make([]string, 0, len(specialUseRoles))
+	for _, role := range specialUseRoles {
+		roles = append(roles, string(role))
+	}
+	return commands.FilterList(roles, arg, nil)
+}
+
 func (*MakeDir) CompleteFolder(arg string) []string {
 	acct := app.SelectedAccount()
 	if acct == nil {
@@ -51,6 +82,7 @@ func (m MakeDir) Execute(args []string) error {
 	}
 	acct.Worker().PostAction(&types.CreateDirectory{
 		Directory: m.Folder,
+		Role:      m.Role,
 	}, func(msg types.WorkerMessage) {
 		switch msg := msg.(type) {
 		case *types.Done:
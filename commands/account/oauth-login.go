@@ -0,0 +1,127 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/lib"
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/oauth"
+	"git.sr.ht/~rjarry/aerc/lib/state"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+	"golang.org/x/oauth2"
+)
+
+const oauthLoginTimeout = 5 * time.Minute
+
+type OAuthLogin struct {
+	Device bool `opt:"-d" desc:"Use the device authorization flow."`
+}
+
+func init() {
+	commands.Register(OAuthLogin{})
+}
+
+func (OAuthLogin) Description() string {
+	return "Authorize aerc to access the selected account with OAuth2."
+}
+
+func (OAuthLogin) Context() commands.CommandContext {
+	return commands.MESSAGE_LIST
+}
+
+func (OAuthLogin) Aliases() []string {
+	return []string{"oauth-login"}
+}
+
+func (o OAuthLogin) Execute(args []string) error {
+	acct := app.SelectedAccount()
+	if acct == nil {
+		return errors.New("No account selected")
+	}
+	confs, err := oauthConfigs(acct)
+	if err != nil {
+		return err
+	}
+	name := acct.Name()
+
+	go func() {
+		defer log.PanicHandler()
+
+		ctx, cancel := context.WithTimeout(
+			context.Background(), oauthLoginTimeout)
+		defer cancel()
+
+		for _, conf := range confs {
+			token, err := oauth.Login(ctx, conf, o.Device, oauthPrompt)
+			if err == nil {
+				err = oauth.Save(name, conf, token)
+			}
+			if err != nil {
+				app.PushError(fmt.Sprintf("oauth-login: %v", err))
+				return
+			}
+		}
+		app.PushSuccess(fmt.Sprintf("%s: authorization granted", name))
+		var msg types.WorkerMessage = &types.Connect{}
+		if acct.Connected() {
+			msg = &types.Reconnect{}
+		}
+		acct.Worker().PostAction(msg, func(types.WorkerMessage) {
+			acct.SetStatus(state.ConnectionActivity(""))
+		})
+		acct.SetStatus(state.ConnectionActivity("Connecting..."))
+	}()
+
+	return nil
+}
+
+// oauthConfigs returns the OAuth2 configurations of the source and of the
+// outgoing server. The outgoing server is skipped if it uses the same
+// authorization server and client as the source.
+func oauthConfigs(acct *app.AccountView) ([]*oauth2.Config, error) {
+	var confs []*oauth2.Config
+	for _, value := range []string{
+		acct.AccountConfig().Source,
+		acct.AccountConfig().Outgoing.Value,
+	} {
+		u, err := url.Parse(value)
+		if err != nil || !oauth.IsOAuth(u.Scheme) {
+			continue
+		}
+		conf := oauth.Config(u.Query())
+		if conf.Endpoint.TokenURL == "" {
+			continue
+		}
+		if len(confs) > 0 && confs[0].Endpoint.TokenURL == conf.Endpoint.TokenURL &&
+			confs[0].ClientID == conf.ClientID {
+			continue
+		}
+		confs = append(confs, conf)
+	}
+	if len(confs) == 0 {
+		return nil, errors.New("No token_endpoint configured for this account")
+	}
+	return confs, nil
+}
+
+func oauthPrompt(uri string, code string) {
+	if code != "" {
+		app.PushStatus(fmt.Sprintf("Visit %s and enter the code %s",
+			uri, code), oauthLoginTimeout)
+		return
+	}
+	if err := lib.XDGOpenMime(uri, "x-scheme-handler/https", ""); err != nil {
+		log.Warnf("oauth-login: %v, open %s in a browser", err, uri)
+		app.PushStatus(fmt.Sprintf("Open %s in a browser", uri),
+			oauthLoginTimeout)
+		return
+	}
+	app.PushStatus("Waiting for authorization in the browser...",
+		oauthLoginTimeout)
+}
@@ -24,6 +24,8 @@ type SearchFilter struct {
 	Body         bool                 `opt:"-b" desc:"Search in the body of the messages."`
 	All          bool                 `opt:"-a" desc:"Search in the entire text of the messages."`
 	UseExtension bool                 `opt:"-e" desc:"Use custom search backend extension."`
+	AllAccounts  bool                 `opt:"-A" desc:"Search in all accounts."`
+	AllFolders   bool                 `opt:"-F" desc:"Search in all folders."`
 	Headers      textproto.MIMEHeader `opt:"-H" action:"ParseHeader" metavar:"<header>:<value>" desc:"Search for messages with the specified header."`
 	WithFlags    models.Flags         `opt:"-x" action:"ParseFlag" complete:"CompleteFlag" desc:"Search messages with specified flag."`
 	WithoutFlags models.Flags         `opt:"-X" action:"ParseNotFlag" complete:"CompleteFlag" desc:"Search messages without specified flag."`
@@ -75,7 +77,7 @@ func (s *SearchFilter) CompleteTerms(arg string) []string {
 	if caps != nil && caps.Has("X-GM-EXT-1") && s.UseExtension {
 		return handleXGMEXTComplete(arg)
 	}
-	return nil
+	return handleQueryComplete(arg)
 }
 
 func (s *SearchFilter) ParseRead(arg string) error {
@@ -160,8 +162,12 @@ func (s SearchFilter) Execute(args []string) error {
 	if acct == nil {
 		return errors.New("No account selected")
 	}
+	global := s.AllAccounts || s.AllFolders
+	if global && args[0] == "filter" {
+		return errors.New("-A and -F can only be used with :search")
+	}
 	store := acct.Store()
-	if store == nil {
+	if store == nil && !global {
 		return errors.New("Cannot perform action. Messages still loading")
 	}
 
@@ -176,11 +182,57 @@ func (s SearchFilter) Execute(args []string) error {
 		EndDate:      s.EndDate,
 		SearchBody:   s.Body,
 		SearchAll:    s.All,
-		Terms:        []string{s.Terms},
 		UseExtension: s.UseExtension,
 	}
+	if s.UseExtension {
+		// the backend extension has its own query syntax
+		criteria.Terms = []string{s.Terms}
+	} else {
+		query, err := types.ParseQuery(s.Terms)
+		switch {
+		case err == nil:
+			criteria.Query = query
+		case acct.AccountConfig().Backend == "notmuch" && !global:
+			// not an aerc query, use the notmuch syntax
+			criteria.Terms = []string{s.Terms}
+		default:
+			return fmt.Errorf("invalid query: %w", err)
+		}
+	}
+
+	switch {
+	case global:
+		accounts := []*app.AccountView{acct}
+		if s.AllAccounts {
+			accounts = nil
+			for _, name := range app.AccountNames() {
+				a, err := app.Account(name)
+				if err != nil || a.AccountConfig().Backend == "unified" {
+					// the merged accounts are searched directly
+					continue
+				}
+				accounts = append(accounts, a)
+			}
+		}
+		terms := strings.Join(args[1:], " ")
+		app.PushStatus("Searching...", 10*time.Second)
+		go func() {
+			defer log.PanicHandler()
 
-	if args[0] == "filter" {
+			results, errs := app.GlobalSearch(accounts, s.AllFolders, &criteria)
+			for _, err := range errs {
+				app.PushError(err.Error())
+			}
+			ui.QueueFunc(func() {
+				if len(results) == 0 {
+					app.PushStatus("No messages found.", 10*time.Second)
+					return
+				}
+				app.ShowGlobalResults(fmt.Sprintf("%d messages matching %s",
+					len(results), terms), results)
+			})
+		}()
+	case args[0] == "filter":
 		if len(args[1:]) == 0 {
 			return Clear{}.Execute([]string{"clear"})
 		}
@@ -193,7 +245,7 @@ func (s SearchFilter) Execute(args []string) error {
 			}
 		}
 		store.Sort(store.GetCurrentSortCriteria(), cb)
-	} else {
+	default:
 		acct.SetStatus(state.Search("Searching..."))
 		cb := func(uids []models.UID) {
 			acct.SetStatus(state.Search(strings.Join(args, " ")))
@@ -207,6 +259,31 @@ func (s SearchFilter) Execute(args []string) error {
 	return nil
 }
 
+func handleQueryComplete(arg string) []string {
+	key, value, found := strings.Cut(arg, ":")
+	if !found {
+		return commands.FilterList(types.QueryKeys(), arg, nil)
+	}
+	prefix := key + ":"
+	var values []string
+	switch strings.ToLower(key) {
+	case "from", "to", "cc":
+		values = commands.GetAddress(value)
+	case "is":
+		for flag := range types.QueryFlags {
+			values = append(values, flag, "un"+flag)
+		}
+	case "has":
+		values = []string{"attachment"}
+	case "tag":
+		values = commands.GetLabels(value)
+	case "date":
+		values = commands.GetDateList()
+	}
+	return commands.FilterList(values, value,
+		func(v string) string { return prefix + v })
+}
+
 func handleXGMEXTComplete(arg string) []string {
 	prefixes := []string{"from:", "to:", "deliveredto:", "cc:", "bcc:"}
 	for _, prefix := range prefixes {
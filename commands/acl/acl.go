@@ -0,0 +1,107 @@
+package acl
+
+import (
+	"errors"
+	"fmt"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+	"git.sr.ht/~rjarry/go-opt/v2"
+)
+
+var subCommands map[string]commands.Command
+
+func register(cmd commands.Command) {
+	if subCommands == nil {
+		subCommands = make(map[string]commands.Command)
+	}
+	for _, alias := range cmd.Aliases() {
+		if subCommands[alias] != nil {
+			panic("duplicate sub command alias: " + alias)
+		}
+		subCommands[alias] = cmd
+	}
+}
+
+type ACL struct {
+	SubCmd commands.Command `opt:"command" action:"ParseSub" complete:"CompleteSubNames" desc:"Sub command."`
+	Args   string           `opt:"..." required:"false" complete:"CompleteSubArgs"`
+}
+
+func init() {
+	commands.Register(ACL{})
+}
+
+func (ACL) Description() string {
+	return "Manage the access control lists of folders."
+}
+
+func (ACL) Context() commands.CommandContext {
+	return commands.MESSAGE_LIST
+}
+
+func (ACL) Aliases() []string {
+	return []string{"acl"}
+}
+
+func (a *ACL) ParseSub(arg string) error {
+	cmd, ok := subCommands[arg]
+	if ok {
+		context := commands.CurrentContext()
+		if cmd.Context()&context != 0 {
+			a.SubCmd = cmd
+			return nil
+		}
+	}
+	return fmt.Errorf("%s unknown sub-command", arg)
+}
+
+func (*ACL) CompleteSubNames(arg string) []string {
+	context := commands.CurrentContext()
+	options := make([]string, 0, len(subCommands))
+	for alias, cmd := range subCommands {
+		if cmd.Context()&context != 0 {
+			options = append(options, alias)
+		}
+	}
+	return commands.FilterList(options, arg, commands.QuoteSpace)
+}
+
+func (a *ACL) CompleteSubArgs(arg string) []string {
+	if a.SubCmd == nil {
+		return nil
+	}
+	// prepend arbitrary string to arg to work with sub-commands
+	options, _ := commands.GetCompletions(a.SubCmd, opt.LexArgs("a "+arg))
+	completions := make([]string, 0, len(options))
+	for _, o := range options {
+		completions = append(completions, o.Value)
+	}
+	return completions
+}
+
+func (a ACL) Execute(args []string) error {
+	q := opt.QuoteArgs(args[1:]...)
+	return commands.ExecuteCommand(a.SubCmd, q.String())
+}
+
+// post sends an action to the worker of the selected account and calls
+// onDone when it succeeds.
+func post(msg types.WorkerMessage, onDone func(types.WorkerMessage)) error {
+	acct := app.SelectedAccount()
+	if acct == nil {
+		return errors.New("No account selected")
+	}
+	acct.Worker().PostAction(msg, func(msg types.WorkerMessage) {
+		switch msg := msg.(type) {
+		case *types.Error:
+			app.PushError(msg.Error.Error())
+		case *types.Unsupported:
+			app.PushError(":acl is not supported by the backend.")
+		default:
+			onDone(msg)
+		}
+	})
+	return nil
+}
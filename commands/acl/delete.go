@@ -0,0 +1,47 @@
+package acl
+
+import (
+	"fmt"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+type Delete struct {
+	Folder     string `opt:"folder" complete:"CompleteFolder" desc:"Folder name."`
+	Identifier string `opt:"identifier" desc:"User or group."`
+}
+
+func init() {
+	register(Delete{})
+}
+
+func (Delete) Description() string {
+	return "Revoke all the rights of a user on a folder."
+}
+
+func (Delete) Context() commands.CommandContext {
+	return commands.MESSAGE_LIST
+}
+
+func (Delete) Aliases() []string {
+	return []string{"delete", "rm"}
+}
+
+func (*Delete) CompleteFolder(arg string) []string {
+	return commands.GetFolders(arg)
+}
+
+func (d Delete) Execute(args []string) error {
+	return post(&types.DeleteACL{
+		Directory:  d.Folder,
+		Identifier: d.Identifier,
+	}, func(msg types.WorkerMessage) {
+		if _, ok := msg.(*types.Done); ok {
+			app.PushStatus(fmt.Sprintf("Rights of %s on %s revoked.",
+				d.Identifier, d.Folder), 10*time.Second)
+		}
+	})
+}
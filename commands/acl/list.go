@@ -0,0 +1,74 @@
+package acl
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/lib/ui"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+type List struct {
+	Folder string `opt:"folder" required:"false" complete:"CompleteFolder" desc:"Folder name."`
+}
+
+func init() {
+	register(List{})
+}
+
+func (List) Description() string {
+	return "List the rights granted on a folder."
+}
+
+func (List) Context() commands.CommandContext {
+	return commands.MESSAGE_LIST
+}
+
+func (List) Aliases() []string {
+	return []string{"list", "ls"}
+}
+
+func (*List) CompleteFolder(arg string) []string {
+	return commands.GetFolders(arg)
+}
+
+func (l List) Execute(args []string) error {
+	folder := l.Folder
+	if folder == "" {
+		acct := app.SelectedAccount()
+		if acct == nil {
+			return errors.New("No account selected")
+		}
+		folder = acct.Directories().Selected()
+	}
+	return post(&types.ListACL{Directory: folder}, func(msg types.WorkerMessage) {
+		acl, ok := msg.(*types.DirectoryACL)
+		if !ok {
+			return
+		}
+		if len(acl.Rights) == 0 {
+			app.PushStatus(fmt.Sprintf("No rights on %s.", folder),
+				10*time.Second)
+			return
+		}
+		lines := make([]string, 0, len(acl.Rights))
+		for identifier, rights := range acl.Rights {
+			lines = append(lines, fmt.Sprintf("%s %s", identifier, rights))
+		}
+		sort.Strings(lines)
+		ui.QueueFunc(func() {
+			uiConf := app.SelectedAccountUiConfig()
+			app.AddDialog(app.DefaultDialog(
+				ui.NewBox(app.NewListBox(
+					"Press <Esc> to close. Start typing to filter.",
+					lines, uiConf,
+					func(string) { app.CloseDialog() },
+				), "Rights on "+folder, "", uiConf),
+			))
+		})
+	})
+}
@@ -0,0 +1,49 @@
+package acl
+
+import (
+	"fmt"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+type Set struct {
+	Folder     string `opt:"folder" complete:"CompleteFolder" desc:"Folder name."`
+	Identifier string `opt:"identifier" desc:"User or group."`
+	Rights     string `opt:"rights" desc:"Rights to grant, prefixed with + or - to modify them."`
+}
+
+func init() {
+	register(Set{})
+}
+
+func (Set) Description() string {
+	return "Grant rights on a folder to a user."
+}
+
+func (Set) Context() commands.CommandContext {
+	return commands.MESSAGE_LIST
+}
+
+func (Set) Aliases() []string {
+	return []string{"set"}
+}
+
+func (*Set) CompleteFolder(arg string) []string {
+	return commands.GetFolders(arg)
+}
+
+func (s Set) Execute(args []string) error {
+	return post(&types.SetACL{
+		Directory:  s.Folder,
+		Identifier: s.Identifier,
+		Rights:     s.Rights,
+	}, func(msg types.WorkerMessage) {
+		if _, ok := msg.(*types.Done); ok {
+			app.PushStatus(fmt.Sprintf("Rights of %s on %s changed.",
+				s.Identifier, s.Folder), 10*time.Second)
+		}
+	})
+}
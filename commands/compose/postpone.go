@@ -54,7 +54,7 @@ func (p Postpone) Execute(args []string) error {
 	config := composer.Config()
 	tabName := tab.Name
 
-	targetFolder := config.Postpone
+	targetFolder := config.PostponeFolder()
 	if composer.RecalledFrom() != "" {
 		targetFolder = composer.RecalledFrom()
 	}
@@ -15,8 +15,11 @@ import (
 	"git.sr.ht/~rjarry/aerc/commands"
 	"git.sr.ht/~rjarry/aerc/commands/mode"
 	"git.sr.ht/~rjarry/aerc/commands/msg"
+	"git.sr.ht/~rjarry/aerc/config"
 	"git.sr.ht/~rjarry/aerc/lib/hooks"
 	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/outbox"
+	"git.sr.ht/~rjarry/aerc/lib/parse"
 	"git.sr.ht/~rjarry/aerc/lib/send"
 	"git.sr.ht/~rjarry/aerc/models"
 	"git.sr.ht/~rjarry/aerc/worker/types"
@@ -30,6 +33,8 @@ type Send struct {
 
 	CopyToReplied   bool `opt:"-r" desc:"Save sent message to current folder."`
 	NoCopyToReplied bool `opt:"-R" desc:"Do not save sent message to current folder."`
+
+	At time.Time `opt:"--at" action:"ParseAt" metavar:"<datetime>" desc:"Send the message at a later time."`
 }
 
 func init() {
@@ -66,6 +71,15 @@ func (s *Send) ParseArchive(arg string) error {
 	return errors.New("unsupported archive type")
 }
 
+func (s *Send) ParseAt(arg string) error {
+	t, err := parse.DateTime(arg)
+	if err != nil {
+		return err
+	}
+	s.At = t
+	return nil
+}
+
 func (s Send) Execute(args []string) error {
 	tab := app.SelectedTab()
 	if tab == nil {
@@ -81,7 +95,7 @@ func (s Send) Execute(args []string) error {
 	config := composer.Config()
 
 	if s.CopyTo == "" {
-		s.CopyTo = config.CopyTo
+		s.CopyTo = config.CopyToFolder()
 	}
 	copyToReplied := config.CopyToReplied || (s.CopyToReplied && !s.NoCopyToReplied)
 
@@ -132,6 +146,21 @@ func (s Send) Execute(args []string) error {
 	log.Debugf("send config rcpts: %s", rcpts)
 	log.Debugf("send config domain: %s", domain)
 
+	send := func() {
+		sendHelper(composer, header, uri, domain, from, rcpts, tab.Name,
+			s.CopyTo, s.Archive, copyToReplied)
+	}
+	if d := undoSendDelay(); !s.At.IsZero() || d > 0 {
+		at, scheduled := s.At, true
+		if at.IsZero() {
+			at, scheduled = time.Now().Add(d), false
+		}
+		send = func() {
+			queueHelper(composer, header, from, rcpts, tab.Name,
+				s.CopyTo, s.Archive, copyToReplied, at, scheduled)
+		}
+	}
+
 	warnSubject := composer.ShouldWarnSubject()
 	warnAttachment := composer.ShouldWarnAttachment()
 	if warnSubject || warnAttachment {
@@ -149,9 +178,7 @@ func (s Send) Execute(args []string) error {
 			msg+" Abort send? [Y/n] ",
 			func(text string) {
 				if text == "n" || text == "N" {
-					sendHelper(composer, header, uri, domain,
-						from, rcpts, tab.Name, s.CopyTo,
-						s.Archive, copyToReplied)
+					send()
 				}
 			}, func(ctx context.Context, cmd string) ([]opt.Completion, string) {
 				var comps []opt.Completion
@@ -165,8 +192,7 @@ func (s Send) Execute(args []string) error {
 
 		app.PushPrompt(prompt)
 	} else {
-		sendHelper(composer, header, uri, domain, from, rcpts, tab.Name,
-			s.CopyTo, s.Archive, copyToReplied)
+		send()
 	}
 
 	return nil
@@ -185,35 +211,32 @@ func sendHelper(composer *app.Composer, header *mail.Header, uri *url.URL, domai
 	mode.NoQuit()
 
 	var shouldCopy bool = copyTo != "" && !strings.HasPrefix(uri.Scheme, "jmap")
-	var copyBuf bytes.Buffer
+	var msgBuf bytes.Buffer
+	var rendered bool
+
+	folders := copyFolders(composer, copyTo, copyToReplied)
 
 	failCh := make(chan error)
 	// writer
 	go func() {
 		defer log.PanicHandler()
 
-		var folders []string
-		if copyTo != "" {
-			folders = append(folders, copyTo)
-		}
-		if copyToReplied && composer.Parent() != nil {
-			folders = append(folders, composer.Parent().Folder)
+		err := composer.WriteMessage(header, &msgBuf)
+		if err != nil {
+			failCh <- err
+			return
 		}
+		rendered = true
+
 		sender, err := send.NewSender(
 			composer.Worker(), uri, domain, from, rcpts, folders)
 		if err != nil {
 			failCh <- errors.Wrap(err, "send:")
 			return
 		}
-
-		var writer io.Writer = sender
-
-		if shouldCopy {
-			writer = io.MultiWriter(writer, &copyBuf)
-		}
-
-		err = composer.WriteMessage(header, writer)
+		_, err = sender.Write(msgBuf.Bytes())
 		if err != nil {
+			sender.Close()
 			failCh <- err
 			return
 		}
@@ -228,15 +251,32 @@ func sendHelper(composer *app.Composer, header *mail.Header, uri *url.URL, domai
 		defer mode.NoQuitDone()
 
 		err := <-failCh
+		subject, _ := header.Subject()
 		if err != nil {
+			app.PublishMailSent(composer.Account().Name(), subject, err)
+			if rendered && composer.Config().UseOutbox &&
+				!send.IsPermanentError(err) {
+				item := newOutboxItem(composer, header, from, rcpts, folders)
+				item.Failed(err, false)
+				qerr := queueMessage(composer, item, msgBuf.Bytes())
+				if qerr == nil {
+					app.PushWarning(fmt.Sprintf(
+						"Sending failed, message queued in outbox: %s",
+						strings.ReplaceAll(err.Error(), "\n", " ")))
+					composer.SetSent(archive)
+					composer.Close()
+					return
+				}
+				log.Errorf("outbox: %v", qerr)
+			}
 			app.PushError(strings.ReplaceAll(err.Error(), "\n", " "))
 			app.NewTab(composer, tabName)
 			return
 		}
 		if shouldCopy {
 			app.PushStatus("Copying to "+copyTo, 10*time.Second)
-			errch := copyToSent(copyTo, copyToReplied, copyBuf.Len(),
-				&copyBuf, composer)
+			errch := copyToSent(copyTo, copyToReplied, msgBuf.Len(),
+				&msgBuf, composer)
 			err = <-errch
 			if err != nil {
 				errmsg := fmt.Sprintf(
@@ -250,6 +290,7 @@ func sendHelper(composer *app.Composer, header *mail.Header, uri *url.URL, domai
 		}
 		app.PushStatus("Message sent.", 10*time.Second)
 		composer.SetSent(archive)
+		app.PublishMailSent(composer.Account().Name(), subject, nil)
 		err = hooks.RunHook(&hooks.MailSent{
 			Account: composer.Account().Name(),
 			Backend: composer.Account().AccountConfig().Backend,
@@ -263,6 +304,88 @@ func sendHelper(composer *app.Composer, header *mail.Header, uri *url.URL, domai
 	}()
 }
 
+// queueHelper renders the message and stores it into the outbox to be sent
+// at the given time. Until then, it can be pulled back into a composer with
+// :outbox edit.
+func queueHelper(composer *app.Composer, header *mail.Header,
+	from *mail.Address, rcpts []*mail.Address, tabName string,
+	copyTo string, archive string, copyToReplied bool,
+	at time.Time, scheduled bool,
+) {
+	app.RemoveTab(composer, false)
+
+	go func() {
+		defer log.PanicHandler()
+
+		var buf bytes.Buffer
+		err := composer.WriteMessage(header, &buf)
+		if err == nil {
+			folders := copyFolders(composer, copyTo, copyToReplied)
+			item := newOutboxItem(composer, header, from, rcpts, folders)
+			item.NextAttempt = at
+			item.Scheduled = scheduled
+			err = queueMessage(composer, item, buf.Bytes())
+		}
+		if err != nil {
+			app.PushError(strings.ReplaceAll(err.Error(), "\n", " "))
+			app.NewTab(composer, tabName)
+			return
+		}
+		if scheduled {
+			app.PushStatus("Message scheduled for "+
+				at.Format("2006-01-02 15:04")+".", 10*time.Second)
+		} else {
+			app.PushStatus(fmt.Sprintf(
+				"Sending in %s. Use :outbox edit to cancel.",
+				time.Until(at).Round(time.Second)), 10*time.Second)
+		}
+		composer.SetSent(archive)
+		composer.Close()
+	}()
+}
+
+// undoSendDelay returns the grace period during which sent messages are kept
+// in the outbox.
+func undoSendDelay() time.Duration {
+	return config.Compose.UndoSendDelay
+}
+
+func copyFolders(composer *app.Composer, copyTo string, copyToReplied bool) []string {
+	var folders []string
+	if copyTo != "" {
+		folders = append(folders, copyTo)
+	}
+	if copyToReplied && composer.Parent() != nil {
+		folders = append(folders, composer.Parent().Folder)
+	}
+	return folders
+}
+
+func newOutboxItem(composer *app.Composer, header *mail.Header,
+	from *mail.Address, rcpts []*mail.Address, folders []string,
+) *outbox.Item {
+	item := &outbox.Item{
+		Account: composer.Account().Name(),
+		Subject: header.Get("Subject"),
+		From:    from.String(),
+		CopyTo:  folders,
+	}
+	for _, rcpt := range rcpts {
+		item.Rcpts = append(item.Rcpts, rcpt.String())
+	}
+	return item
+}
+
+// queueMessage saves a message into the outbox and arms the account retry
+// timer.
+func queueMessage(composer *app.Composer, item *outbox.Item, data []byte) error {
+	if err := outbox.Enqueue(item, data); err != nil {
+		return err
+	}
+	composer.Account().FlushOutbox(false)
+	return nil
+}
+
 func listRecipients(h *mail.Header) ([]*mail.Address, error) {
 	var rcpts []*mail.Address
 	for _, key := range []string{"to", "cc", "bcc"} {
@@ -93,11 +93,15 @@ func archive(msgs []*models.MessageInfo, mfs *types.MultiFileStrategy,
 	if err != nil {
 		return err
 	}
+	err = h.checkRights(store.Name, models.DeleteMsgRight, models.ExpungeRight)
+	if err != nil {
+		return err
+	}
 	var uids []models.UID
 	for _, msg := range msgs {
 		uids = append(uids, msg.Uid)
 	}
-	archiveDir := acct.AccountConfig().Archive
+	archiveDir := acct.AccountConfig().ArchiveFolder()
 	marker := store.Marker()
 	marker.ClearVisualMark()
 	next := findNextNonDeleted(uids, store)
@@ -132,6 +136,8 @@ func archive(msgs []*models.MessageInfo, mfs *types.MultiFileStrategy,
 	wg.Add(len(uidMap))
 	success := true
 
+	// archiving to several folders is reverted by a single :undo
+	endBatch := store.UndoJournal().Batch()
 	for dir, uids := range uidMap {
 		store.Move(uids, dir, true, mfs, func(
 			msg types.WorkerMessage,
@@ -147,6 +153,7 @@ func archive(msgs []*models.MessageInfo, mfs *types.MultiFileStrategy,
 			}
 		})
 	}
+	endBatch()
 	// we need to do that in the background, else we block the main thread
 	go func() {
 		defer log.PanicHandler()
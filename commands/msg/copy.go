@@ -94,6 +94,9 @@ func (c Copy) Execute(args []string) error {
 	}
 
 	if len(c.Account) == 0 {
+		if err := h.checkRights(c.Folder, models.InsertRight); err != nil {
+			return err
+		}
 		store.Copy(uids, c.Folder, c.CreateFolders, c.MultiFileStrategy,
 			func(msg types.WorkerMessage) {
 				c.CallBack(msg, uids, store)
@@ -62,6 +62,10 @@ func (d Delete) Execute(args []string) error {
 	if err != nil {
 		return err
 	}
+	err = h.checkRights(store.Name, models.DeleteMsgRight, models.ExpungeRight)
+	if err != nil {
+		return err
+	}
 	sel := store.Selected()
 	marker := store.Marker()
 	marker.ClearVisualMark()
@@ -72,6 +72,7 @@ func (f forward) Execute(args []string) error {
 	if err != nil {
 		return err
 	}
+	acct = acct.Owner(msg)
 	log.Debugf("Forwarding email <%s>", msg.Envelope.MessageId)
 
 	h := &mail.Header{}
@@ -52,6 +52,7 @@ func (i invite) Execute(args []string) error {
 	if err != nil {
 		return err
 	}
+	acct = acct.Owner(msg)
 
 	part := lib.FindCalendartext(msg.BodyStructure, nil)
 	if part == nil {
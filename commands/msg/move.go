@@ -85,6 +85,15 @@ func (m Move) Execute(args []string) error {
 	if err != nil {
 		return err
 	}
+	err = h.checkRights(store.Name, models.DeleteMsgRight, models.ExpungeRight)
+	if err != nil {
+		return err
+	}
+	if len(m.Account) == 0 {
+		if err := h.checkRights(m.Folder, models.InsertRight); err != nil {
+			return err
+		}
+	}
 
 	next := findNextNonDeleted(uids, store)
 	marker := store.Marker()
@@ -27,6 +27,8 @@ type Pipe struct {
 	Decrypt    bool   `opt:"-d" desc:"Decrypt the full message before piping."`
 	Part       bool   `opt:"-p" desc:"Only pipe the selected message part."`
 	Command    string `opt:"..."`
+	// Transform is applied to the full messages before piping them.
+	Transform func(models.UID, io.Reader) io.Reader
 }
 
 func init() {
@@ -188,6 +190,9 @@ func (p Pipe) Run(cb func()) error {
 				}
 			}
 		addMessage:
+			if p.Transform != nil {
+				fm.Content.Reader = p.Transform(fm.Content.Uid, fm.Content.Reader)
+			}
 			info := store.Messages[fm.Content.Uid]
 			switch {
 			case info != nil && info.Envelope != nil:
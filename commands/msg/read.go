@@ -99,6 +99,13 @@ func (f FlagMsg) Execute(args []string) error {
 	if err != nil {
 		return err
 	}
+	right := models.WriteRight
+	if f.Flag == models.SeenFlag {
+		right = models.SeenRight
+	}
+	if err := h.checkRights(store.Name, right); err != nil {
+		return err
+	}
 
 	// UIDs of messages to enable or disable the flag for.
 	var toEnable []models.UID
@@ -136,6 +143,9 @@ func (f FlagMsg) Execute(args []string) error {
 
 	status := fmt.Sprintf("%s flag %q successful", actionName, f.FlagName)
 
+	// toggling is reverted by a single :undo
+	defer store.UndoJournal().Batch()()
+
 	if len(toEnable) != 0 {
 		store.Flag(toEnable, f.Flag, true, func(msg types.WorkerMessage) {
 			switch msg := msg.(type) {
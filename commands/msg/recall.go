@@ -8,6 +8,7 @@ import (
 	"time"
 
 	_ "github.com/emersion/go-message/charset"
+	"github.com/emersion/go-message/mail"
 	"github.com/pkg/errors"
 
 	"git.sr.ht/~rjarry/aerc/app"
@@ -59,10 +60,10 @@ func (r Recall) Execute(args []string) error {
 		return errors.Wrap(err, "Recall failed")
 	}
 
-	if acct.SelectedDirectory() != acct.AccountConfig().Postpone &&
+	if acct.SelectedDirectory() != acct.AccountConfig().PostponeFolder() &&
 		!msgInfo.Flags.Has(models.DraftFlag) && !r.Force {
 		return errors.New("Use -f to recall non-draft messages from outside the " +
-			acct.AccountConfig().Postpone + " directory.")
+			acct.AccountConfig().PostponeFolder() + " directory.")
 	}
 
 	log.Debugf("Recalling message <%s>", msgInfo.Envelope.MessageId)
@@ -104,69 +105,82 @@ func (r Recall) Execute(args []string) error {
 				app.PushError(err.Error())
 				return
 			}
-			var path []int
-			if len(msg.BodyStructure().Parts) != 0 {
-				path = lib.FindPlaintext(msg.BodyStructure(), path)
-			}
+			ComposeFromView(acct, msg, msgInfo.RFC822Headers, editHeaders,
+				func(composer *app.Composer) {
+					if r.Force {
+						composer.SetRecalledFrom(acct.SelectedDirectory())
+					}
+
+					// focus the terminal since the header fields are likely already done
+					composer.FocusTerminal()
+					addTab(composer)
+				})
+		})
+
+	return nil
+}
+
+// ComposeFromView opens a composer with the text body, attachments and crypto
+// settings of an existing message. The callback is invoked once the composer
+// is created.
+func ComposeFromView(
+	acct *app.AccountView, msg lib.MessageView, h *mail.Header,
+	editHeaders bool, cb func(*app.Composer),
+) {
+	var path []int
+	if len(msg.BodyStructure().Parts) != 0 {
+		path = lib.FindPlaintext(msg.BodyStructure(), path)
+	}
 
-			msg.FetchBodyPart(path, func(reader io.Reader) {
-				composer, err := app.NewComposer(acct,
-					acct.AccountConfig(), acct.Worker(), editHeaders,
-					"", msgInfo.RFC822Headers, nil, reader)
+	msg.FetchBodyPart(path, func(reader io.Reader) {
+		composer, err := app.NewComposer(acct,
+			acct.AccountConfig(), acct.Worker(), editHeaders,
+			"", h, nil, reader)
+		if err != nil {
+			app.PushError(err.Error())
+			return
+		}
+		if md := msg.MessageDetails(); md != nil {
+			if md.IsEncrypted {
+				composer.SetEncrypt(md.IsEncrypted)
+			}
+			if md.IsSigned {
+				err = composer.SetSign(md.IsSigned)
 				if err != nil {
-					app.PushError(err.Error())
-					return
-				}
-				if md := msg.MessageDetails(); md != nil {
-					if md.IsEncrypted {
-						composer.SetEncrypt(md.IsEncrypted)
-					}
-					if md.IsSigned {
-						err = composer.SetSign(md.IsSigned)
-						if err != nil {
-							log.Warnf("failed to set signed state: %v", err)
-						}
-					}
+					log.Warnf("failed to set signed state: %v", err)
 				}
+			}
+		}
 
-				// add attachements if present
-				var mu sync.Mutex
-				parts := lib.FindAllNonMultipart(msg.BodyStructure(), nil, nil)
-				for _, p := range parts {
-					if lib.EqualParts(p, path) {
-						continue
-					}
-					bs, err := msg.BodyStructure().PartAtIndex(p)
-					if err != nil {
-						log.Warnf("cannot get PartAtIndex %v: %v", p, err)
-						continue
-					}
-					msg.FetchBodyPart(p, func(reader io.Reader) {
-						mime := bs.FullMIMEType()
-						params := lib.SetUtf8Charset(bs.Params)
-						name, ok := params["name"]
-						if !ok {
-							name = fmt.Sprintf("%s_%s_%d", bs.MIMEType, bs.MIMESubType, rand.Uint64())
-						}
-						mu.Lock()
-						err := composer.AddPartAttachment(name, mime, params, reader)
-						mu.Unlock()
-						if err != nil {
-							log.Errorf(err.Error())
-							app.PushError(err.Error())
-						}
-					})
+		// add attachements if present
+		var mu sync.Mutex
+		parts := lib.FindAllNonMultipart(msg.BodyStructure(), nil, nil)
+		for _, p := range parts {
+			if lib.EqualParts(p, path) {
+				continue
+			}
+			bs, err := msg.BodyStructure().PartAtIndex(p)
+			if err != nil {
+				log.Warnf("cannot get PartAtIndex %v: %v", p, err)
+				continue
+			}
+			msg.FetchBodyPart(p, func(reader io.Reader) {
+				mime := bs.FullMIMEType()
+				params := lib.SetUtf8Charset(bs.Params)
+				name, ok := params["name"]
+				if !ok {
+					name = fmt.Sprintf("%s_%s_%d", bs.MIMEType, bs.MIMESubType, rand.Uint64())
 				}
-
-				if r.Force {
-					composer.SetRecalledFrom(acct.SelectedDirectory())
+				mu.Lock()
+				err := composer.AddPartAttachment(name, mime, params, reader)
+				mu.Unlock()
+				if err != nil {
+					log.Errorf(err.Error())
+					app.PushError(err.Error())
 				}
-
-				// focus the terminal since the header fields are likely already done
-				composer.FocusTerminal()
-				addTab(composer)
 			})
-		})
+		}
 
-	return nil
+		cb(composer)
+	})
 }
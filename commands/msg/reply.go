@@ -31,6 +31,9 @@ type reply struct {
 	Edit     bool   `opt:"-e" desc:"Force [compose].edit-headers = true."`
 	NoEdit   bool   `opt:"-E" desc:"Force [compose].edit-headers = false."`
 	Account  string `opt:"-A" complete:"CompleteAccount" desc:"Reply with the specified account."`
+
+	// review comments set by :review
+	Comments map[int]string
 }
 
 func init() {
@@ -76,12 +79,15 @@ func (r reply) Execute(args []string) error {
 			return err
 		}
 	}
-	conf := acct.AccountConfig()
 
 	msg, err := widget.SelectedMessage()
 	if err != nil {
 		return err
 	}
+	if r.Account == "" {
+		acct = acct.Owner(msg)
+	}
+	conf := acct.AccountConfig()
 
 	from := chooseFromAddr(conf, msg)
 
@@ -154,6 +160,7 @@ func (r reply) Execute(args []string) error {
 		From:          format.FormatAddresses(msg.Envelope.From),
 		Date:          msg.Envelope.Date,
 		RFC822Headers: msg.RFC822Headers,
+		Comments:      r.Comments,
 	}
 
 	mv, isMsgViewer := app.SelectedTabContent().(*app.MessageViewer)
@@ -207,7 +214,11 @@ func (r reply) Execute(args []string) error {
 		return nil
 	}
 
-	if r.Quote && r.Template == "" {
+	switch {
+	case r.Template != "":
+	case r.Comments != nil:
+		r.Template = config.Templates.Review
+	case r.Quote:
 		r.Template = config.Templates.QuotedReply
 	}
 
@@ -0,0 +1,79 @@
+package msg
+
+import (
+	"errors"
+	"io"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/lib"
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/ui"
+)
+
+type Review struct {
+	All      bool   `opt:"-a" desc:"Reply to all recipients."`
+	Template string `opt:"-T" complete:"CompleteTemplate" desc:"Template name."`
+}
+
+func init() {
+	commands.Register(Review{})
+}
+
+func (Review) Description() string {
+	return "Comment the lines of the viewed message and reply with them."
+}
+
+func (Review) Context() commands.CommandContext {
+	return commands.MESSAGE_VIEWER
+}
+
+func (Review) Aliases() []string {
+	return []string{"review"}
+}
+
+func (*Review) CompleteTemplate(arg string) []string {
+	return commands.GetTemplates(arg)
+}
+
+func (r Review) Execute(args []string) error {
+	mv, ok := app.SelectedTabContent().(*app.MessageViewer)
+	if !ok {
+		return errors.New("no message viewer selected")
+	}
+	msg, err := mv.SelectedMessage()
+	if err != nil {
+		return err
+	}
+	part := getMessagePart(msg, mv)
+	if part == nil {
+		part = lib.FindFirstNonMultipart(msg.BodyStructure, nil)
+	}
+
+	mv.MessageView().FetchBodyPart(part, func(reader io.Reader) {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			log.Warnf("failed to read bodypart: %v", err)
+		}
+		uiConfig := app.SelectedAccountUiConfig()
+		review := app.NewReview(string(data), uiConfig,
+			func(comments map[int]string) {
+				app.CloseDialog()
+				if comments == nil {
+					return
+				}
+				reply := reply{
+					All:      r.All,
+					Template: r.Template,
+					Comments: comments,
+				}
+				if err := reply.Execute([]string{"reply"}); err != nil {
+					app.PushError(err.Error())
+				}
+			})
+		app.AddDialog(app.DefaultDialog(
+			ui.NewBox(review, "Review", "", uiConfig),
+		))
+	})
+	return nil
+}
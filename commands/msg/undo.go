@@ -0,0 +1,34 @@
+package msg
+
+import (
+	"errors"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+)
+
+type Undo struct{}
+
+func init() {
+	commands.Register(Undo{})
+}
+
+func (Undo) Description() string {
+	return "Revert the last move, delete, flag or label change."
+}
+
+func (Undo) Context() commands.CommandContext {
+	return commands.MESSAGE_LIST | commands.MESSAGE_VIEWER
+}
+
+func (Undo) Aliases() []string {
+	return []string{"undo"}
+}
+
+func (Undo) Execute(args []string) error {
+	acct := app.SelectedAccount()
+	if acct == nil {
+		return errors.New("No account selected")
+	}
+	return acct.Undo()
+}
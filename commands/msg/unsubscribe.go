@@ -146,6 +146,8 @@ func unsubscribeMailto(u *url.URL, editHeaders bool) error {
 	if acct == nil {
 		return errors.New("No account selected")
 	}
+	msg, _ := widget.SelectedMessage()
+	acct = acct.Owner(msg)
 
 	h := &mail.Header{}
 	h.SetSubject(u.Query().Get("subject"))
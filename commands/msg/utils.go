@@ -2,6 +2,7 @@ package msg
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"git.sr.ht/~rjarry/aerc/app"
@@ -49,6 +50,20 @@ func (h *helper) account() (*app.AccountView, error) {
 	return acct, nil
 }
 
+// checkRights returns an error if the rights of the user on a folder are
+// known and do not include all the specified ones.
+func (h *helper) checkRights(folder string, rights ...rune) error {
+	acct, err := h.account()
+	if err != nil {
+		return err
+	}
+	dir := acct.Directories().Directory(folder)
+	if dir != nil && !dir.Allows(rights...) {
+		return fmt.Errorf("Insufficient rights on %s", folder)
+	}
+	return nil
+}
+
 func (h *helper) messages() ([]*models.MessageInfo, error) {
 	uid, err := commands.MarkedOrSelected(h.msgProvider)
 	if err != nil {
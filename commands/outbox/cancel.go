@@ -0,0 +1,47 @@
+package outbox
+
+import (
+	"fmt"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/lib/outbox"
+)
+
+type Cancel struct {
+	ID string `opt:"id" complete:"CompleteID" desc:"Message identifier."`
+}
+
+func init() {
+	register(Cancel{})
+}
+
+func (Cancel) Description() string {
+	return "Remove a message from the outbox without sending it."
+}
+
+func (Cancel) Context() commands.CommandContext {
+	return commands.GLOBAL
+}
+
+func (Cancel) Aliases() []string {
+	return []string{"cancel"}
+}
+
+func (*Cancel) CompleteID(arg string) []string {
+	return completeIDs(arg)
+}
+
+func (c Cancel) Execute(args []string) error {
+	item, err := outbox.Get(c.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.ID, err)
+	}
+	if err := outbox.Remove(c.ID); err != nil {
+		return err
+	}
+	app.PushStatus(fmt.Sprintf("%q removed from outbox.", item.Subject),
+		10*time.Second)
+	return nil
+}
@@ -0,0 +1,115 @@
+package outbox
+
+import (
+	"errors"
+	"fmt"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/commands/msg"
+	"git.sr.ht/~rjarry/aerc/config"
+	"git.sr.ht/~rjarry/aerc/lib"
+	"git.sr.ht/~rjarry/aerc/lib/outbox"
+)
+
+type Edit struct {
+	Edit   bool   `opt:"-e" desc:"Force [compose].edit-headers = true."`
+	NoEdit bool   `opt:"-E" desc:"Force [compose].edit-headers = false."`
+	ID     string `opt:"id" required:"false" complete:"CompleteID" desc:"Message identifier."`
+}
+
+func init() {
+	register(Edit{})
+}
+
+func (Edit) Description() string {
+	return "Move a message from the outbox back into a composer."
+}
+
+func (Edit) Context() commands.CommandContext {
+	return commands.GLOBAL
+}
+
+func (Edit) Aliases() []string {
+	return []string{"edit"}
+}
+
+func (*Edit) CompleteID(arg string) []string {
+	return completeIDs(arg)
+}
+
+func (e Edit) Execute(args []string) error {
+	editHeaders := (config.Compose.EditHeaders || e.Edit) && !e.NoEdit
+
+	var item *outbox.Item
+	var err error
+	if e.ID == "" {
+		item, err = lastQueued()
+		if err != nil {
+			return err
+		}
+	} else {
+		item, err = outbox.Get(e.ID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.ID, err)
+		}
+	}
+	acct, err := app.Account(item.Account)
+	if err != nil {
+		return err
+	}
+	data, err := outbox.Message(item.ID)
+	if err != nil {
+		return err
+	}
+	// do not send the message while it is being edited
+	if err := outbox.Remove(item.ID); err != nil {
+		return err
+	}
+
+	lib.NewEmlMessageView(data, app.CryptoProvider(), app.DecryptKeys,
+		func(view lib.MessageView, err error) {
+			if err != nil {
+				app.PushError(err.Error())
+				requeue(item, data)
+				return
+			}
+			h := view.MessageInfo().RFC822Headers
+			msg.ComposeFromView(acct, view, h, editHeaders,
+				func(composer *app.Composer) {
+					subject := item.Subject
+					if subject == "" {
+						subject = "Outbox message"
+					}
+					composer.FocusTerminal()
+					composer.Tab = app.NewTab(composer, subject)
+				})
+		})
+
+	return nil
+}
+
+// lastQueued returns the message most recently queued in the outbox of the
+// selected account.
+func lastQueued() (*outbox.Item, error) {
+	acct := app.SelectedAccount()
+	if acct == nil {
+		return nil, errors.New("no account selected")
+	}
+	items, err := outbox.List(acct.Name())
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errors.New("outbox is empty")
+	}
+	return items[len(items)-1], nil
+}
+
+// requeue puts back a message into the outbox after a failed edit.
+func requeue(item *outbox.Item, data []byte) {
+	item.Held = true
+	if err := outbox.Enqueue(item, data); err != nil {
+		app.PushError(fmt.Sprintf("outbox: %v", err))
+	}
+}
@@ -0,0 +1,88 @@
+package outbox
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/lib/outbox"
+	"git.sr.ht/~rjarry/aerc/lib/ui"
+)
+
+type List struct {
+	All bool `opt:"-a" desc:"List the messages of all accounts."`
+}
+
+func init() {
+	register(List{})
+}
+
+func (List) Description() string {
+	return "List the messages waiting in the outbox."
+}
+
+func (List) Context() commands.CommandContext {
+	return commands.GLOBAL
+}
+
+func (List) Aliases() []string {
+	return []string{"list", "ls"}
+}
+
+func (l List) Execute(args []string) error {
+	var account string
+	if !l.All {
+		acct := app.SelectedAccount()
+		if acct == nil {
+			return errors.New("no account selected")
+		}
+		account = acct.Name()
+	}
+	items, err := outbox.List(account)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		app.PushStatus("Outbox is empty.", 10*time.Second)
+		return nil
+	}
+
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, formatItem(item))
+	}
+
+	app.AddDialog(app.DefaultDialog(
+		ui.NewBox(app.NewListBox(
+			"Press <Esc> or <Enter> to close. "+
+				"Start typing to filter.",
+			lines, app.SelectedAccountUiConfig(),
+			func(_ string) { app.CloseDialog() },
+		), "Outbox", "", app.SelectedAccountUiConfig()),
+	))
+
+	return nil
+}
+
+func formatItem(item *outbox.Item) string {
+	var status string
+	switch {
+	case item.Held:
+		status = "held"
+	case item.Scheduled && item.Attempts == 0:
+		status = "scheduled for " + item.NextAttempt.Format("2006-01-02 15:04")
+	case item.Attempts == 0:
+		status = "sending at " + item.NextAttempt.Format(time.TimeOnly)
+	default:
+		status = fmt.Sprintf("retry #%d at %s", item.Attempts,
+			item.NextAttempt.Format(time.TimeOnly))
+	}
+	s := fmt.Sprintf("%s  [%s] %q (%s)", item.ID, item.Account,
+		item.Subject, status)
+	if item.LastError != "" {
+		s += ": " + item.LastError
+	}
+	return s
+}
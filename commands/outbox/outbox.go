@@ -0,0 +1,103 @@
+package outbox
+
+import (
+	"fmt"
+
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/outbox"
+	"git.sr.ht/~rjarry/go-opt/v2"
+)
+
+var subCommands map[string]commands.Command
+
+func register(cmd commands.Command) {
+	if subCommands == nil {
+		subCommands = make(map[string]commands.Command)
+	}
+	for _, alias := range cmd.Aliases() {
+		if subCommands[alias] != nil {
+			panic("duplicate sub command alias: " + alias)
+		}
+		subCommands[alias] = cmd
+	}
+}
+
+type Outbox struct {
+	SubCmd commands.Command `opt:"command" required:"false" action:"ParseSub" complete:"CompleteSubNames" desc:"Sub command."`
+	Args   string           `opt:"..." required:"false" complete:"CompleteSubArgs"`
+}
+
+func init() {
+	commands.Register(Outbox{})
+}
+
+func (Outbox) Description() string {
+	return "Manage messages waiting to be sent."
+}
+
+func (Outbox) Context() commands.CommandContext {
+	return commands.GLOBAL
+}
+
+func (Outbox) Aliases() []string {
+	return []string{"outbox"}
+}
+
+func (o *Outbox) ParseSub(arg string) error {
+	cmd, ok := subCommands[arg]
+	if ok {
+		context := commands.CurrentContext()
+		if cmd.Context()&context != 0 {
+			o.SubCmd = cmd
+			return nil
+		}
+	}
+	return fmt.Errorf("%s unknown sub-command", arg)
+}
+
+func (*Outbox) CompleteSubNames(arg string) []string {
+	context := commands.CurrentContext()
+	options := make([]string, 0, len(subCommands))
+	for alias, cmd := range subCommands {
+		if cmd.Context()&context != 0 {
+			options = append(options, alias)
+		}
+	}
+	return commands.FilterList(options, arg, commands.QuoteSpace)
+}
+
+func (o *Outbox) CompleteSubArgs(arg string) []string {
+	if o.SubCmd == nil {
+		return nil
+	}
+	// prepend arbitrary string to arg to work with sub-commands
+	options, _ := commands.GetCompletions(o.SubCmd, opt.LexArgs("a "+arg))
+	completions := make([]string, 0, len(options))
+	for _, o := range options {
+		completions = append(completions, o.Value)
+	}
+	return completions
+}
+
+func (o Outbox) Execute(args []string) error {
+	if o.SubCmd == nil {
+		return commands.ExecuteCommand(List{}, "list")
+	}
+	a := opt.QuoteArgs(args[1:]...)
+	return commands.ExecuteCommand(o.SubCmd, a.String())
+}
+
+// completeIDs returns the identifiers of the queued messages.
+func completeIDs(arg string) []string {
+	items, err := outbox.List("")
+	if err != nil {
+		log.Errorf("outbox: %v", err)
+		return nil
+	}
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	return commands.FilterList(ids, arg, nil)
+}
@@ -0,0 +1,73 @@
+package outbox
+
+import (
+	"errors"
+	"fmt"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/lib/outbox"
+)
+
+type Retry struct {
+	IDs []string `opt:"..." required:"false" complete:"CompleteID" desc:"Message identifier."`
+}
+
+func init() {
+	register(Retry{})
+}
+
+func (Retry) Description() string {
+	return "Try sending queued messages immediately."
+}
+
+func (Retry) Context() commands.CommandContext {
+	return commands.GLOBAL
+}
+
+func (Retry) Aliases() []string {
+	return []string{"retry"}
+}
+
+func (*Retry) CompleteID(arg string) []string {
+	return completeIDs(arg)
+}
+
+func (r Retry) Execute(args []string) error {
+	var items []*outbox.Item
+	if len(r.IDs) == 0 {
+		acct := app.SelectedAccount()
+		if acct == nil {
+			return errors.New("no account selected")
+		}
+		var err error
+		items, err = outbox.List(acct.Name())
+		if err != nil {
+			return err
+		}
+	}
+	for _, id := range r.IDs {
+		item, err := outbox.Get(id)
+		if err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		items = append(items, item)
+	}
+
+	accounts := make(map[string]*app.AccountView)
+	for _, item := range items {
+		acct, err := app.Account(item.Account)
+		if err != nil {
+			return fmt.Errorf("%s: %w", item.ID, err)
+		}
+		item.Retry()
+		if err := outbox.Update(item); err != nil {
+			return err
+		}
+		accounts[item.Account] = acct
+	}
+	for _, acct := range accounts {
+		acct.FlushOutbox(false)
+	}
+	return nil
+}
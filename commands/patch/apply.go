@@ -17,6 +17,7 @@ import (
 type Apply struct {
 	Cmd      string `opt:"-c" desc:"Apply patches with provided command."`
 	Worktree string `opt:"-w" desc:"Create linked worktree on this <commit-ish>."`
+	Trailers bool   `opt:"-t" desc:"Add the review trailers found in the replies."`
 	Tag      string `opt:"tag" required:"true" complete:"CompleteTag" desc:"Identify patches with tag."`
 }
 
@@ -127,6 +128,7 @@ func (a Apply) Execute(args []string) error {
 	}
 
 	msgData := collectMessageData()
+	info := collectSeriesInfo()
 
 	// apply patches with the pipe cmd
 	pipe := msg.Pipe{
@@ -135,10 +137,23 @@ func (a Apply) Execute(args []string) error {
 		Part:       false,
 		Command:    applyCmd,
 	}
-	return pipe.Run(func() {
-		p, err = m.ApplyUpdate(p, patch, commit, msgData)
-		if err != nil {
-			log.Errorf("Failed to save patch data: %v", err)
+	run := func() error {
+		return pipe.Run(func() {
+			p, err = m.ApplyUpdate(p, patch, commit, msgData, info)
+			if err != nil {
+				log.Errorf("Failed to save patch data: %v", err)
+			}
+		})
+	}
+	if !a.Trailers {
+		return run()
+	}
+
+	return collectTrailers(msgData, func(trailers map[string][]string) {
+		info.Trailers = trailers
+		pipe.Transform = addTrailers(trailers)
+		if err := run(); err != nil {
+			app.PushError(err.Error())
 		}
 	})
 }
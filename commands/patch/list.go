@@ -54,35 +54,7 @@ func (l List) Execute(args []string) error {
 
 	app.PushStatus(fmt.Sprintf("Current project: %s", current.Name), 30*time.Second)
 
-	createWidget := func(r io.Reader) (ui.DrawableInteractive, error) {
-		pagerCmd, err := app.CmdFallbackSearch(config.PagerCmds(), true)
-		if err != nil {
-			return nil, err
-		}
-
-		cmd := opt.SplitArgs(pagerCmd)
-		pager := exec.Command(cmd[0], cmd[1:]...)
-		pager.Stdin = r
-
-		term, err := app.NewTerminal(pager)
-		if err != nil {
-			return nil, err
-		}
-		start := time.Now()
-		term.OnClose = func(err error) {
-			if time.Since(start) > 250*time.Millisecond {
-				app.CloseDialog()
-				return
-			}
-			term.OnEvent = func(_ vaxis.Event) bool {
-				app.CloseDialog()
-				return true
-			}
-		}
-		return term, nil
-	}
-
-	viewer, err := createWidget(m.NewReader(projects))
+	viewer, err := newPager(m.NewReader(projects))
 	if err != nil {
 		viewer = app.NewListBox(
 			"Press <Esc> or <Enter> to close. "+
@@ -101,6 +73,35 @@ func (l List) Execute(args []string) error {
 	return nil
 }
 
+// newPager returns a terminal running the pager on the content of r.
+func newPager(r io.Reader) (ui.DrawableInteractive, error) {
+	pagerCmd, err := app.CmdFallbackSearch(config.PagerCmds(), true)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := opt.SplitArgs(pagerCmd)
+	pager := exec.Command(cmd[0], cmd[1:]...)
+	pager.Stdin = r
+
+	term, err := app.NewTerminal(pager)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	term.OnClose = func(err error) {
+		if time.Since(start) > 250*time.Millisecond {
+			app.CloseDialog()
+			return
+		}
+		term.OnEvent = func(_ vaxis.Event) bool {
+			app.CloseDialog()
+			return true
+		}
+	}
+	return term, nil
+}
+
 func numerify(r io.Reader) []string {
 	var lines []string
 	nr := 1
@@ -0,0 +1,149 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/pama"
+	"git.sr.ht/~rjarry/aerc/lib/pama/models"
+	"git.sr.ht/~rjarry/aerc/lib/ui"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+type RangeDiff struct {
+	Tag string `opt:"tag" required:"true" complete:"CompleteTag" desc:"Compare with the applied patch."`
+}
+
+func init() {
+	register(RangeDiff{})
+}
+
+func (RangeDiff) Description() string {
+	return "Compare the selected patches with an applied patch."
+}
+
+func (RangeDiff) Context() commands.CommandContext {
+	return commands.MESSAGE_LIST | commands.MESSAGE_VIEWER
+}
+
+func (RangeDiff) Aliases() []string {
+	return []string{"range-diff"}
+}
+
+func (*RangeDiff) CompleteTag(arg string) []string {
+	patches, err := pama.New().CurrentPatches()
+	if err != nil {
+		log.Errorf("failed to get current patches: %v", err)
+		return nil
+	}
+	return commands.FilterList(patches, arg, nil)
+}
+
+func (r RangeDiff) Execute(args []string) error {
+	m := pama.New()
+	p, err := m.CurrentProject()
+	if err != nil {
+		return err
+	}
+	if !models.Commits(p.Commits).HasTag(r.Tag) {
+		return fmt.Errorf("patch %s not found", r.Tag)
+	}
+
+	acct := app.SelectedAccount()
+	if acct == nil {
+		return errors.New("no account selected")
+	}
+	store := acct.Store()
+	if store == nil {
+		return errors.New("cannot get message store")
+	}
+	uids, err := commands.MarkedOrSelected(acct)
+	if err != nil {
+		return err
+	}
+
+	type patch struct {
+		index int
+		models.RangePatch
+	}
+	var lock sync.Mutex
+	var patches []patch
+	fetched := 0
+	done := make(chan struct{})
+
+	store.FetchFull(uids, func(fm *types.FullMessage) {
+		var p patch
+		p.Diff = models.PatchDiff(textBody(fm.Content.Reader))
+		if info := store.Messages[fm.Content.Uid]; info != nil && info.Envelope != nil {
+			subject, _ := models.ParseSubject(info.Envelope.Subject)
+			p.index = subject.Index
+			p.Title = subject.Title
+			if p.Title == "" {
+				p.Title = info.Envelope.Subject
+			}
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		if p.Diff != "" {
+			patches = append(patches, p)
+		}
+		fetched++
+		if fetched == len(uids) {
+			close(done)
+		}
+	})
+
+	go func() {
+		defer log.PanicHandler()
+
+		select {
+		case <-done:
+		case <-time.After(30 * time.Second):
+			app.PushError("Failed to fetch all messages")
+		}
+		lock.Lock()
+		sort.SliceStable(patches, func(i, j int) bool {
+			return patches[i].index < patches[j].index
+		})
+		var rangePatches []models.RangePatch
+		for _, p := range patches {
+			rangePatches = append(rangePatches, p.RangePatch)
+		}
+		lock.Unlock()
+		if len(rangePatches) == 0 {
+			app.PushError("no patches found in the selected messages")
+			return
+		}
+
+		diff, err := m.RangeDiff(p, r.Tag, rangePatches)
+		if err != nil {
+			app.PushError(err.Error())
+			return
+		}
+		ui.QueueFunc(func() {
+			viewer, err := newPager(strings.NewReader(diff))
+			if err != nil {
+				viewer = app.NewListBox(
+					"Press <Esc> or <Enter> to close. "+
+						"Start typing to filter.",
+					strings.Split(strings.TrimRight(diff, "\n"), "\n"),
+					app.SelectedAccountUiConfig(),
+					func(_ string) { app.CloseDialog() },
+				)
+			}
+			app.AddDialog(app.DefaultDialog(
+				ui.NewBox(viewer, "Range Diff", "",
+					app.SelectedAccountUiConfig(),
+				),
+			))
+		})
+	}()
+	return nil
+}
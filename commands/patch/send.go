@@ -0,0 +1,408 @@
+package patch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/emersion/go-message"
+	"github.com/emersion/go-message/mail"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/commands/mode"
+	"git.sr.ht/~rjarry/aerc/config"
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/pama"
+	"git.sr.ht/~rjarry/aerc/lib/pama/models"
+	"git.sr.ht/~rjarry/aerc/lib/send"
+	"git.sr.ht/~rjarry/aerc/lib/ui"
+	aercmodels "git.sr.ht/~rjarry/aerc/models"
+	mboxer "git.sr.ht/~rjarry/aerc/worker/mbox"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+	"git.sr.ht/~rjarry/go-opt/v2"
+)
+
+type Send struct {
+	Version   int    `opt:"-v" default:"1" desc:"Version of the patch series."`
+	Cover     bool   `opt:"-c" desc:"Add a cover letter."`
+	Batch     bool   `opt:"-b" desc:"Review all messages in the editor and send them at once."`
+	Prefix    string `opt:"-p" default:"PATCH" desc:"Subject prefix."`
+	InReplyTo string `opt:"-i" metavar:"<msgid>" desc:"Thread the series under this message."`
+	To        string `opt:"-t" metavar:"<addresses>" desc:"Recipients."`
+	Cc        string `opt:"-C" metavar:"<addresses>" desc:"Carbon copy recipients."`
+	Rev       string `opt:"rev" required:"true" complete:"CompleteRev" desc:"Patch tag or <commit-ish>."`
+}
+
+func init() {
+	register(Send{})
+}
+
+func (Send) Description() string {
+	return "Send commits of the current project as patch emails."
+}
+
+func (Send) Context() commands.CommandContext {
+	return commands.GLOBAL
+}
+
+func (Send) Aliases() []string {
+	return []string{"send"}
+}
+
+func (*Send) CompleteRev(arg string) []string {
+	patches, err := pama.New().CurrentPatches()
+	if err != nil {
+		log.Errorf("failed to get current patches: %v", err)
+		return nil
+	}
+	return commands.FilterList(patches, arg, nil)
+}
+
+func (s Send) Execute(args []string) error {
+	acct := app.SelectedAccount()
+	if acct == nil {
+		return errors.New("no account selected")
+	}
+
+	m := pama.New()
+	p, err := m.CurrentProject()
+	if err != nil {
+		return err
+	}
+	emails, err := m.FormatPatches(p, s.Rev, models.FormatOptions{
+		Prefix:  s.Prefix,
+		Version: s.Version,
+		Cover:   s.Cover,
+	})
+	if err != nil {
+		return err
+	}
+	headers, err := s.headers(acct.AccountConfig(), emails)
+	if err != nil {
+		return err
+	}
+
+	if s.Batch {
+		return reviewPatches(acct, headers, emails)
+	}
+
+	editHeaders := config.Compose.EditHeaders
+	for i, email := range emails {
+		composer, err := app.NewComposer(acct, acct.AccountConfig(),
+			acct.Worker(), editHeaders, "", headers[i], nil,
+			strings.NewReader(email.Body))
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			composer.Tab = app.NewTab(composer, email.Subject)
+		} else {
+			composer.Tab = app.NewBackgroundTab(composer, email.Subject)
+		}
+	}
+	return nil
+}
+
+// headers returns the headers of the emails. The emails are threaded under
+// the first one, which replies to the InReplyTo message if any.
+func (s Send) headers(conf *config.AccountConfig, emails []models.PatchEmail,
+) ([]*mail.Header, error) {
+	var to, cc []*mail.Address
+	var err error
+	if s.To != "" {
+		if to, err = mail.ParseAddressList(s.To); err != nil {
+			return nil, fmt.Errorf("invalid recipients: %w", err)
+		}
+	}
+	if s.Cc != "" {
+		if cc, err = mail.ParseAddressList(s.Cc); err != nil {
+			return nil, fmt.Errorf("invalid recipients: %w", err)
+		}
+	}
+	hostname, err := send.GetMessageIdHostname(conf.SendWithHostname, conf.From)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []string
+	if s.InReplyTo != "" {
+		refs = append(refs, strings.Trim(s.InReplyTo, "<>"))
+	}
+	var headers []*mail.Header
+	for i, email := range emails {
+		h := new(mail.Header)
+		h.SetAddressList("from", []*mail.Address{conf.From})
+		h.SetAddressList("to", to)
+		if len(cc) > 0 {
+			h.SetAddressList("cc", cc)
+		}
+		h.SetSubject(email.Subject)
+		if err := h.GenerateMessageIDWithHostname(hostname); err != nil {
+			return nil, err
+		}
+		if len(refs) > 0 {
+			h.SetMsgIDList("in-reply-to", refs[len(refs)-1:])
+			h.SetMsgIDList("references", refs)
+		}
+		if i == 0 {
+			id, err := h.MessageID()
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, id)
+		}
+		headers = append(headers, h)
+	}
+	return headers, nil
+}
+
+// reviewPatches opens the emails in the editor and sends them after
+// confirmation.
+func reviewPatches(acct *app.AccountView, headers []*mail.Header,
+	emails []models.PatchEmail,
+) error {
+	f, err := os.CreateTemp("", "aerc-patch-send-*.mbox")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	for i, email := range emails {
+		var buf bytes.Buffer
+		if err := writePatchEmail(&buf, headers[i], email.Body); err != nil {
+			f.Close()
+			return err
+		}
+		// the editor is more comfortable with unix line endings
+		data := bytes.ReplaceAll(buf.Bytes(), []byte("\r\n"), []byte("\n"))
+		err := mboxer.Write(f, bytes.NewReader(data), "", time.Now())
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+	f.Close()
+
+	editorCmd, err := app.CmdFallbackSearch(config.EditorCmds(), true)
+	if err != nil {
+		return err
+	}
+	editor := exec.Command("/bin/sh", "-c", editorCmd+" "+name)
+	term, err := app.NewTerminal(editor)
+	if err != nil {
+		return err
+	}
+	term.OnClose = func(_ error) {
+		app.CloseDialog()
+		defer os.Remove(name)
+		defer term.Focus(false)
+
+		if editor.ProcessState.ExitCode() > 0 {
+			app.PushError("Quitting without sending.")
+			return
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			app.PushError(fmt.Sprintf("failed to open file: %v", err))
+			return
+		}
+		defer f.Close()
+		messages, err := mboxer.Read(f)
+		if err != nil {
+			app.PushError(fmt.Sprintf("failed to read patches: %v", err))
+			return
+		}
+		var data [][]byte
+		for _, msg := range messages {
+			r, err := msg.NewReader()
+			if err != nil {
+				app.PushError(err.Error())
+				return
+			}
+			b, err := io.ReadAll(r)
+			if err != nil {
+				app.PushError(err.Error())
+				return
+			}
+			data = append(data, b)
+		}
+		if len(data) == 0 {
+			app.PushError("No patches to send.")
+			return
+		}
+
+		prompt := app.NewPrompt(
+			fmt.Sprintf("Send %d messages? [y/N] ", len(data)),
+			func(text string) {
+				if text == "y" || text == "Y" {
+					sendPatches(acct, data)
+				}
+			}, func(ctx context.Context, cmd string) ([]opt.Completion, string) {
+				var comps []opt.Completion
+				if cmd == "" {
+					comps = append(comps, opt.Completion{Value: "y"})
+					comps = append(comps, opt.Completion{Value: "n"})
+				}
+				return comps, ""
+			},
+		)
+		app.PushPrompt(prompt)
+	}
+	term.Show(true)
+	term.Focus(true)
+
+	app.AddDialog(app.DefaultDialog(
+		ui.NewBox(term, "Patch Send", "", app.SelectedAccountUiConfig()),
+	))
+	return nil
+}
+
+// writePatchEmail writes a plain text email.
+func writePatchEmail(w io.Writer, h *mail.Header, body string) error {
+	h = &mail.Header{Header: h.Header.Copy()}
+	h.SetDate(time.Now())
+	h.Set("MIME-Version", "1.0")
+	h.SetContentType("text/plain", map[string]string{"charset": "UTF-8"})
+	encoding := "7bit"
+	for _, r := range body {
+		if r > unicode.MaxASCII {
+			encoding = "8bit"
+			break
+		}
+	}
+	h.Set("Content-Transfer-Encoding", encoding)
+	mw, err := mail.CreateSingleInlineWriter(w, *h)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mw, body); err != nil {
+		return err
+	}
+	return mw.Close()
+}
+
+// sendPatches sends the messages in order with the outgoing transport of the
+// account.
+func sendPatches(acct *app.AccountView, messages [][]byte) {
+	conf := acct.AccountConfig()
+	outgoing, err := conf.Outgoing.ConnectionString()
+	if err != nil {
+		app.PushError(err.Error())
+		return
+	}
+	if outgoing == "" {
+		app.PushError("No outgoing mail transport configured for this account")
+		return
+	}
+	uri, err := url.Parse(outgoing)
+	if err != nil {
+		app.PushError(err.Error())
+		return
+	}
+	domain := conf.Params["smtp-domain"]
+	var folders []string
+	copyTo := conf.CopyToFolder()
+	if copyTo != "" {
+		folders = append(folders, copyTo)
+	}
+	copyToSent := copyTo != "" && !strings.HasPrefix(uri.Scheme, "jmap")
+
+	mode.NoQuit()
+	app.PushStatus("Sending...", 10*time.Second)
+
+	go func() {
+		defer log.PanicHandler()
+		defer mode.NoQuitDone()
+
+		for i, data := range messages {
+			err := sendPatch(acct, data, uri, domain, folders)
+			if err != nil {
+				app.PushError(fmt.Sprintf("Failed to send message %d/%d: %v",
+					i+1, len(messages), err))
+				return
+			}
+			if copyToSent {
+				appendToSent(acct, copyTo, data)
+			}
+		}
+		app.PushStatus(fmt.Sprintf("%d messages sent.", len(messages)),
+			10*time.Second)
+	}()
+}
+
+func sendPatch(acct *app.AccountView, data []byte, uri *url.URL,
+	domain string, folders []string,
+) error {
+	msg, err := message.Read(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	header := mail.Header{Header: msg.Header}
+	var rcpts []*mail.Address
+	for _, key := range []string{"to", "cc", "bcc"} {
+		list, err := header.AddressList(key)
+		if err != nil {
+			return err
+		}
+		rcpts = append(rcpts, list...)
+	}
+	if len(rcpts) == 0 {
+		return errors.New("no recipients")
+	}
+	from := acct.AccountConfig().From
+	if acct.AccountConfig().UseEnvelopeFrom {
+		if fl, _ := header.AddressList("from"); len(fl) != 0 {
+			from = fl[0]
+		}
+	}
+	if acct.AccountConfig().StripBcc && header.Has("Bcc") {
+		header.Del("Bcc")
+		var buf bytes.Buffer
+		w, err := message.CreateWriter(&buf, header.Header)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, msg.Body); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+
+	sender, err := send.NewSender(acct.Worker(), uri, domain, from, rcpts, folders)
+	if err != nil {
+		return err
+	}
+	if _, err := sender.Write(data); err != nil {
+		sender.Close()
+		return err
+	}
+	return sender.Close()
+}
+
+func appendToSent(acct *app.AccountView, folder string, data []byte) {
+	store := acct.Store()
+	if store == nil {
+		return
+	}
+	store.Append(folder, aercmodels.SeenFlag, time.Now(),
+		bytes.NewReader(data), len(data),
+		func(msg types.WorkerMessage) {
+			if msg, ok := msg.(*types.Error); ok {
+				app.PushError(fmt.Sprintf(
+					"message sent, but copying to %v failed: %v",
+					folder, msg.Error))
+			}
+		},
+	)
+}
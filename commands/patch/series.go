@@ -0,0 +1,175 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/lib"
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/pama"
+	"git.sr.ht/~rjarry/aerc/lib/pama/models"
+	"git.sr.ht/~rjarry/aerc/lib/ui"
+	aercmodels "git.sr.ht/~rjarry/aerc/models"
+)
+
+type Series struct{}
+
+func init() {
+	register(Series{})
+}
+
+func (Series) Description() string {
+	return "List the patch series of the current folder."
+}
+
+func (Series) Context() commands.CommandContext {
+	return commands.MESSAGE_LIST
+}
+
+func (Series) Aliases() []string {
+	return []string{"series"}
+}
+
+func (Series) Execute(args []string) error {
+	acct := app.SelectedAccount()
+	if acct == nil {
+		return errors.New("no account selected")
+	}
+	store := acct.Store()
+	if store == nil {
+		return errors.New("cannot get message store")
+	}
+
+	messages, uids := patchMessages(store, store.Uids())
+	series := models.GroupSeries(messages)
+	if len(series) == 0 {
+		return errors.New("no patch series found in this folder")
+	}
+
+	// applied versions of the series in the current project
+	applied := make(map[string]map[int]bool)
+	if p, err := pama.New().CurrentProject(); err == nil {
+		for _, c := range p.Commits {
+			if c.Series == "" {
+				continue
+			}
+			if applied[c.Series] == nil {
+				applied[c.Series] = make(map[int]bool)
+			}
+			applied[c.Series][c.Version] = true
+		}
+	}
+
+	var lines []string
+	selection := make(map[string]models.Series)
+	for _, s := range series {
+		var versions []string
+		for _, posting := range s.Postings {
+			v := fmt.Sprintf("v%d", posting.Version)
+			if applied[s.Name][posting.Version] {
+				v += "*"
+			}
+			versions = append(versions, v)
+		}
+		line := fmt.Sprintf("%s [%s]", s.Name, strings.Join(versions, " "))
+		lines = append(lines, line)
+		selection[line] = s
+	}
+
+	uiConfig := app.SelectedAccountUiConfig()
+	listBox := app.NewListBox(
+		"Press <Enter> to mark the latest version of a series. "+
+			"Applied versions end with *.",
+		lines, uiConfig,
+		func(line string) {
+			app.CloseDialog()
+			s, ok := selection[line]
+			if !ok {
+				return
+			}
+			latest := s.Latest()
+			marker := store.Marker()
+			for _, uid := range marker.Marked() {
+				marker.Unmark(uid)
+			}
+			for _, patch := range latest.Patches {
+				marker.Mark(uids[patch.MessageId])
+			}
+			app.PushStatus(fmt.Sprintf("Marked %d patches of %s v%d",
+				len(latest.Patches), s.Name, latest.Version), 10*time.Second)
+		},
+	)
+	app.AddDialog(app.DefaultDialog(
+		ui.NewBox(listBox, "Patch Series", "", uiConfig),
+	))
+	return nil
+}
+
+// patchMessages returns the patch emails of the provided messages, and their
+// uids by message id.
+func patchMessages(store *lib.MessageStore, uids []aercmodels.UID,
+) ([]models.PatchMessage, map[string]aercmodels.UID) {
+	var messages []models.PatchMessage
+	byId := make(map[string]aercmodels.UID)
+	for _, uid := range uids {
+		info := store.Messages[uid]
+		msgid, err := info.MsgId()
+		if err != nil {
+			continue
+		}
+		subject, ok := models.ParseSubject(info.Envelope.Subject)
+		if !ok {
+			continue
+		}
+		parent, _ := info.InReplyTo()
+		messages = append(messages, models.PatchMessage{
+			MessageId: msgid,
+			InReplyTo: parent,
+			Subject:   subject,
+		})
+		byId[msgid] = uid
+	}
+	return messages, byId
+}
+
+// collectSeriesInfo returns the series of the marked messages.
+func collectSeriesInfo() models.SeriesInfo {
+	var info models.SeriesInfo
+	acct := app.SelectedAccount()
+	if acct == nil {
+		return info
+	}
+	store := acct.Store()
+	if store == nil {
+		return info
+	}
+	uids, err := commands.MarkedOrSelected(acct)
+	if err != nil {
+		log.Errorf("error occurred: %v", err)
+		return info
+	}
+	marked := make(map[string]bool)
+	for _, uid := range uids {
+		if msgid, err := store.Messages[uid].MsgId(); err == nil {
+			marked[msgid] = true
+		}
+	}
+	// group the whole folder to get the cover letters as well
+	messages, _ := patchMessages(store, store.Uids())
+	for _, s := range models.GroupSeries(messages) {
+		for _, posting := range s.Postings {
+			for _, patch := range posting.Patches {
+				if marked[patch.MessageId] {
+					info.Series = s.Name
+					info.Version = posting.Version
+					return info
+				}
+			}
+		}
+	}
+	return info
+}
@@ -0,0 +1,196 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/lib"
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/pama/models"
+	"git.sr.ht/~rjarry/aerc/lib/rfc822"
+	"git.sr.ht/~rjarry/aerc/lib/ui"
+	aercmodels "git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+	"github.com/emersion/go-message"
+)
+
+// collectTrailers fetches the replies to the patches in the current folder
+// and collects their review trailers by patch message id. Replies to the
+// cover letter apply to all patches. The callback is run in the main loop.
+func collectTrailers(patches map[string]string, cb func(map[string][]string)) error {
+	acct := app.SelectedAccount()
+	if acct == nil {
+		return errors.New("no account selected")
+	}
+	store := acct.Store()
+	if store == nil {
+		return errors.New("cannot get message store")
+	}
+
+	// the cover letter is the parent of the patches
+	parents := make(map[string]bool)
+	for _, uid := range store.Uids() {
+		info := store.Messages[uid]
+		msgid, err := info.MsgId()
+		if err != nil || patches[msgid] == "" {
+			continue
+		}
+		if parent, err := info.InReplyTo(); err == nil {
+			parents[parent] = true
+		}
+	}
+	covers := make(map[string]bool)
+	for _, uid := range store.Uids() {
+		info := store.Messages[uid]
+		msgid, err := info.MsgId()
+		if err != nil || !parents[msgid] {
+			continue
+		}
+		subject, ok := models.ParseSubject(info.Envelope.Subject)
+		if ok && subject.Index == 0 {
+			covers[msgid] = true
+		}
+	}
+
+	// the replies with the patches they apply to
+	targets := make(map[aercmodels.UID][]string)
+	var uids []aercmodels.UID
+	for _, uid := range store.Uids() {
+		info := store.Messages[uid]
+		msgid, err := info.MsgId()
+		if err != nil || patches[msgid] != "" || covers[msgid] {
+			continue
+		}
+		refs, _ := info.References()
+		if parent, err := info.InReplyTo(); err == nil {
+			refs = append(refs, parent)
+		}
+		// the closest known ancestor
+		for i := len(refs) - 1; i >= 0; i-- {
+			if patches[refs[i]] != "" {
+				targets[uid] = []string{refs[i]}
+				break
+			}
+			if covers[refs[i]] {
+				for id := range patches {
+					targets[uid] = append(targets[uid], id)
+				}
+				break
+			}
+		}
+		if len(targets[uid]) > 0 {
+			uids = append(uids, uid)
+		}
+	}
+
+	trailers := make(map[string][]string)
+	if len(uids) == 0 {
+		cb(trailers)
+		return nil
+	}
+
+	var lock sync.Mutex
+	fetched := 0
+	done := make(chan struct{})
+	store.FetchFull(uids, func(fm *types.FullMessage) {
+		found := replyTrailers(fm.Content.Reader)
+		lock.Lock()
+		defer lock.Unlock()
+		for _, id := range targets[fm.Content.Uid] {
+			for _, t := range found {
+				if !contains(trailers[id], t) {
+					trailers[id] = append(trailers[id], t)
+				}
+			}
+		}
+		fetched++
+		if fetched == len(uids) {
+			close(done)
+		}
+	})
+
+	go func() {
+		defer log.PanicHandler()
+
+		select {
+		case <-done:
+		case <-time.After(30 * time.Second):
+			app.PushError("Failed to fetch all replies")
+		}
+		lock.Lock()
+		result := make(map[string][]string, len(trailers))
+		for id, t := range trailers {
+			result[id] = t
+		}
+		lock.Unlock()
+		ui.QueueFunc(func() { cb(result) })
+	}()
+	return nil
+}
+
+// replyTrailers returns the review trailers of the text parts of a reply.
+func replyTrailers(r io.Reader) []string {
+	return models.ParseTrailers(textBody(r))
+}
+
+// textBody returns the decoded text parts of a message.
+func textBody(r io.Reader) string {
+	msg, err := rfc822.ReadMessage(r)
+	if err != nil {
+		log.Warnf("failed to read message: %v", err)
+		return ""
+	}
+	var body strings.Builder
+	err = msg.Walk(func(_ []int, part *message.Entity, err error) error {
+		if err != nil {
+			return err
+		}
+		mimeType, _, _ := part.Header.ContentType()
+		if mimeType == "text/plain" || mimeType == "" {
+			_, err = io.Copy(&body, part.Body)
+		}
+		return err
+	})
+	if err != nil {
+		log.Warnf("failed to read message: %v", err)
+	}
+	return body.String()
+}
+
+// addTrailers returns a pipe transform that adds the trailers to the patches.
+func addTrailers(trailers map[string][]string) func(aercmodels.UID, io.Reader) io.Reader {
+	var store *lib.MessageStore
+	if acct := app.SelectedAccount(); acct != nil {
+		store = acct.Store()
+	}
+	return func(uid aercmodels.UID, r io.Reader) io.Reader {
+		if store == nil {
+			return r
+		}
+		msgid, err := store.Messages[uid].MsgId()
+		if err != nil || len(trailers[msgid]) == 0 {
+			return r
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			log.Warnf("failed to read patch: %v", err)
+			return bytes.NewReader(data)
+		}
+		patch := models.AddTrailers(string(data), trailers[msgid])
+		return strings.NewReader(patch)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
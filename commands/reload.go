@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"errors"
 	"os"
 
 	"git.sr.ht/~rjarry/aerc/app"
@@ -12,6 +13,7 @@ import (
 type Reload struct {
 	Binds bool   `opt:"-B" desc:"Reload binds.conf."`
 	Conf  bool   `opt:"-C" desc:"Reload aerc.conf."`
+	Rules bool   `opt:"-R" desc:"Reload rules.conf."`
 	Style string `opt:"-s" complete:"CompleteStyle" desc:"Reload the specified styleset."`
 }
 
@@ -51,10 +53,13 @@ func (Reload) Aliases() []string {
 }
 
 func (r Reload) Execute(args []string) error {
-	if !r.Binds && !r.Conf && r.Style == "" {
+	optionalRules := false
+	if !r.Binds && !r.Conf && !r.Rules && r.Style == "" {
 		r.Binds = true
 		r.Conf = true
+		r.Rules = true
 		r.Style = config.Ui.StyleSetName
+		optionalRules = true
 	}
 
 	reconfigure := false
@@ -76,6 +81,17 @@ func (r Reload) Execute(args []string) error {
 		reconfigure = true
 	}
 
+	if r.Rules {
+		f, err := config.ReloadRules()
+		switch {
+		case errors.Is(err, os.ErrNotExist) && optionalRules:
+		case err != nil:
+			return err
+		default:
+			app.PushSuccess("Rules reloaded: " + f)
+		}
+	}
+
 	if r.Style != "" {
 		config.Ui.ClearCache()
 		config.Ui.StyleSetName = r.Style
@@ -0,0 +1,46 @@
+package sieve
+
+import (
+	"fmt"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/config"
+	"git.sr.ht/~rjarry/aerc/lib/sieve"
+)
+
+type Activate struct {
+	Script string `opt:"script" complete:"CompleteScript" desc:"Script name."`
+}
+
+func init() {
+	register(Activate{})
+}
+
+func (Activate) Description() string {
+	return "Make a script the active one."
+}
+
+func (Activate) Context() commands.CommandContext {
+	return commands.GLOBAL
+}
+
+func (Activate) Aliases() []string {
+	return []string{"activate"}
+}
+
+func (*Activate) CompleteScript(arg string) []string {
+	return completeScripts(arg)
+}
+
+func (a Activate) Execute(args []string) error {
+	return run(func(_ *config.AccountConfig, c *sieve.Client) error {
+		if err := c.SetActive(a.Script); err != nil {
+			return err
+		}
+		app.PushStatus(fmt.Sprintf("Script %q activated.", a.Script),
+			10*time.Second)
+		return nil
+	})
+}
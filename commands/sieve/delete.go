@@ -0,0 +1,49 @@
+package sieve
+
+import (
+	"fmt"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/config"
+	"git.sr.ht/~rjarry/aerc/lib/sieve"
+)
+
+type Delete struct {
+	Script string `opt:"script" complete:"CompleteScript" desc:"Script name."`
+}
+
+func init() {
+	register(Delete{})
+}
+
+func (Delete) Description() string {
+	return "Delete a script from the server."
+}
+
+func (Delete) Context() commands.CommandContext {
+	return commands.GLOBAL
+}
+
+func (Delete) Aliases() []string {
+	return []string{"delete", "rm"}
+}
+
+func (*Delete) CompleteScript(arg string) []string {
+	return completeScripts(arg)
+}
+
+func (d Delete) Execute(args []string) error {
+	return run(func(acct *config.AccountConfig, c *sieve.Client) error {
+		if err := c.DeleteScript(d.Script); err != nil {
+			return err
+		}
+		if list, err := c.ListScripts(); err == nil {
+			setScripts(acct.Name, list)
+		}
+		app.PushStatus(fmt.Sprintf("Script %q deleted.", d.Script),
+			10*time.Second)
+		return nil
+	})
+}
@@ -0,0 +1,178 @@
+package sieve
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/config"
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/sieve"
+	"git.sr.ht/~rjarry/aerc/lib/ui"
+)
+
+type Edit struct {
+	Script string `opt:"script" complete:"CompleteScript" desc:"Script name."`
+}
+
+func init() {
+	register(Edit{})
+}
+
+func (Edit) Description() string {
+	return "Edit a script in the editor and upload it."
+}
+
+func (Edit) Context() commands.CommandContext {
+	return commands.GLOBAL
+}
+
+func (Edit) Aliases() []string {
+	return []string{"edit"}
+}
+
+func (*Edit) CompleteScript(arg string) []string {
+	return completeScripts(arg)
+}
+
+func (e Edit) Execute(args []string) error {
+	return run(func(acct *config.AccountConfig, c *sieve.Client) error {
+		content, err := c.GetScript(e.Script)
+		var serr *sieve.Error
+		if errors.As(err, &serr) && serr.Code == "NONEXISTENT" {
+			// new script
+			content = ""
+		} else if err != nil {
+			return err
+		}
+		editScript(acct, e.Script, content, content, false)
+		return nil
+	})
+}
+
+// editScript opens a script in the editor and uploads it once the editor
+// exits, unless it is identical to the original script. If the server
+// rejects the script, the editor is opened again. If activate is true, the
+// script is activated unless another one already is.
+func editScript(
+	acct *config.AccountConfig, name, original, content string, activate bool,
+) {
+	f, err := os.CreateTemp("", "aerc-sieve-*.sieve")
+	if err != nil {
+		app.PushError(err.Error())
+		return
+	}
+	path := f.Name()
+	_, err = f.WriteString(content)
+	f.Close()
+	if err != nil {
+		os.Remove(path)
+		app.PushError(err.Error())
+		return
+	}
+	ui.QueueFunc(func() {
+		if err := openEditor(acct, name, path, original, activate); err != nil {
+			os.Remove(path)
+			app.PushError(err.Error())
+		}
+	})
+}
+
+func openEditor(
+	acct *config.AccountConfig, name, path, previous string, activate bool,
+) error {
+	editorCmd, err := app.CmdFallbackSearch(config.EditorCmds(), true)
+	if err != nil {
+		return err
+	}
+	editor := exec.Command("/bin/sh", "-c", editorCmd+" "+path)
+	term, err := app.NewTerminal(editor)
+	if err != nil {
+		return err
+	}
+	term.OnClose = func(_ error) {
+		app.CloseDialog()
+		defer term.Focus(false)
+
+		if editor.ProcessState.ExitCode() > 0 {
+			os.Remove(path)
+			app.PushError("Quitting sieve edit without saving.")
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			os.Remove(path)
+			app.PushError(fmt.Sprintf("failed to read file: %v", err))
+			return
+		}
+		content := string(data)
+		if content == previous {
+			os.Remove(path)
+			app.PushStatus("Script not modified.", 10*time.Second)
+			return
+		}
+		go func() {
+			defer log.PanicHandler()
+
+			err := upload(acct, name, content, activate)
+			if err == nil {
+				os.Remove(path)
+				app.PushStatus(fmt.Sprintf("Script %q uploaded.", name),
+					10*time.Second)
+				return
+			}
+			app.PushError(err.Error())
+			var serr *sieve.Error
+			if !errors.As(err, &serr) {
+				os.Remove(path)
+				return
+			}
+			// let the user fix the script
+			ui.QueueFunc(func() {
+				err := openEditor(acct, name, path, content, activate)
+				if err != nil {
+					os.Remove(path)
+					app.PushError(err.Error())
+				}
+			})
+		}()
+	}
+	term.Show(true)
+	term.Focus(true)
+
+	app.AddDialog(app.DefaultDialog(
+		ui.NewBox(term, "Sieve script "+name, "",
+			app.SelectedAccountUiConfig(),
+		),
+	))
+	return nil
+}
+
+func upload(acct *config.AccountConfig, name, content string, activate bool) error {
+	c, err := connect(acct)
+	if err != nil {
+		return fmt.Errorf("sieve: %w", err)
+	}
+	defer c.Logout()
+	if err := c.PutScript(name, content); err != nil {
+		return err
+	}
+	list, err := c.ListScripts()
+	if err != nil {
+		return err
+	}
+	setScripts(acct.Name, list)
+	if !activate {
+		return nil
+	}
+	for _, s := range list {
+		if s.Active {
+			return nil
+		}
+	}
+	return c.SetActive(name)
+}
@@ -0,0 +1,96 @@
+package sieve
+
+import (
+	"errors"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/config"
+	"git.sr.ht/~rjarry/aerc/lib/sieve"
+)
+
+const defaultScript = "aerc"
+
+type Filter struct {
+	Script string `opt:"-s" complete:"CompleteScript" desc:"Script to add the rule to."`
+	Sender bool   `opt:"-f" desc:"Filter on the sender address even for list messages."`
+	Folder string `opt:"folder" complete:"CompleteFolder" desc:"Folder to file messages into."`
+}
+
+func init() {
+	register(Filter{})
+}
+
+func (Filter) Description() string {
+	return "Create a filter rule from the selected message."
+}
+
+func (Filter) Context() commands.CommandContext {
+	return commands.MESSAGE_LIST | commands.MESSAGE_VIEWER
+}
+
+func (Filter) Aliases() []string {
+	return []string{"filter"}
+}
+
+func (*Filter) CompleteScript(arg string) []string {
+	return completeScripts(arg)
+}
+
+func (*Filter) CompleteFolder(arg string) []string {
+	acct := app.SelectedAccount()
+	if acct == nil {
+		return nil
+	}
+	return commands.FilterList(acct.Directories().List(), arg, nil)
+}
+
+func (f Filter) Execute(args []string) error {
+	widget, ok := app.SelectedTabContent().(app.ProvidesMessage)
+	if !ok {
+		return errors.New("No message selected")
+	}
+	msg, err := widget.SelectedMessage()
+	if err != nil {
+		return err
+	}
+	var from, listID string
+	if msg.Envelope != nil && len(msg.Envelope.From) > 0 {
+		from = msg.Envelope.From[0].Address
+	}
+	if msg.RFC822Headers != nil && !f.Sender {
+		listID = sieve.ListID(msg.RFC822Headers.Get("List-Id"))
+	}
+	rule, err := sieve.Rule(from, listID, f.Folder)
+	if err != nil {
+		return err
+	}
+
+	return run(func(acct *config.AccountConfig, c *sieve.Client) error {
+		name := f.Script
+		if name == "" {
+			// add the rule to the active script
+			list, err := c.ListScripts()
+			if err != nil {
+				return err
+			}
+			setScripts(acct.Name, list)
+			name = defaultScript
+			for _, s := range list {
+				if s.Active {
+					name = s.Name
+					break
+				}
+			}
+		}
+		content, err := c.GetScript(name)
+		var serr *sieve.Error
+		if errors.As(err, &serr) && serr.Code == "NONEXISTENT" {
+			content = ""
+		} else if err != nil {
+			return err
+		}
+		editScript(acct, name, content, sieve.AddRule(content, rule), true)
+		return nil
+	})
+}
@@ -0,0 +1,76 @@
+package sieve
+
+import (
+	"strings"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/config"
+	"git.sr.ht/~rjarry/aerc/lib/sieve"
+	"git.sr.ht/~rjarry/aerc/lib/ui"
+)
+
+const activeSuffix = " (active)"
+
+type List struct{}
+
+func init() {
+	register(List{})
+}
+
+func (List) Description() string {
+	return "List the scripts stored on the server."
+}
+
+func (List) Context() commands.CommandContext {
+	return commands.GLOBAL
+}
+
+func (List) Aliases() []string {
+	return []string{"list", "ls"}
+}
+
+func (List) Execute(args []string) error {
+	return run(func(acct *config.AccountConfig, c *sieve.Client) error {
+		list, err := c.ListScripts()
+		if err != nil {
+			return err
+		}
+		setScripts(acct.Name, list)
+		if len(list) == 0 {
+			app.PushStatus("No sieve scripts.", 10*time.Second)
+			return nil
+		}
+		lines := make([]string, 0, len(list))
+		for _, s := range list {
+			line := s.Name
+			if s.Active {
+				line += activeSuffix
+			}
+			lines = append(lines, line)
+		}
+		ui.QueueFunc(func() {
+			uiConf := app.SelectedAccountUiConfig()
+			app.AddDialog(app.DefaultDialog(
+				ui.NewBox(app.NewListBox(
+					"Press <Enter> to edit a script, <Esc> to close. "+
+						"Start typing to filter.",
+					lines, uiConf,
+					func(line string) {
+						app.CloseDialog()
+						if line == "" {
+							return
+						}
+						name := strings.TrimSuffix(line, activeSuffix)
+						err := Edit{Script: name}.Execute(nil)
+						if err != nil {
+							app.PushError(err.Error())
+						}
+					},
+				), "Sieve scripts", "", uiConf),
+			))
+		})
+		return nil
+	})
+}
@@ -0,0 +1,237 @@
+package sieve
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/emersion/go-sasl"
+
+	"git.sr.ht/~rjarry/aerc/app"
+	"git.sr.ht/~rjarry/aerc/commands"
+	"git.sr.ht/~rjarry/aerc/config"
+	"git.sr.ht/~rjarry/aerc/lib"
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/oauth"
+	"git.sr.ht/~rjarry/aerc/lib/sieve"
+	"git.sr.ht/~rjarry/go-opt/v2"
+)
+
+var subCommands map[string]commands.Command
+
+func register(cmd commands.Command) {
+	if subCommands == nil {
+		subCommands = make(map[string]commands.Command)
+	}
+	for _, alias := range cmd.Aliases() {
+		if subCommands[alias] != nil {
+			panic("duplicate sub command alias: " + alias)
+		}
+		subCommands[alias] = cmd
+	}
+}
+
+type Sieve struct {
+	SubCmd commands.Command `opt:"command" action:"ParseSub" complete:"CompleteSubNames" desc:"Sub command."`
+	Args   string           `opt:"..." required:"false" complete:"CompleteSubArgs"`
+}
+
+func init() {
+	commands.Register(Sieve{})
+}
+
+func (Sieve) Description() string {
+	return "Manage server-side filtering scripts."
+}
+
+func (Sieve) Context() commands.CommandContext {
+	return commands.GLOBAL
+}
+
+func (Sieve) Aliases() []string {
+	return []string{"sieve"}
+}
+
+func (s *Sieve) ParseSub(arg string) error {
+	cmd, ok := subCommands[arg]
+	if ok {
+		context := commands.CurrentContext()
+		if cmd.Context()&context != 0 {
+			s.SubCmd = cmd
+			return nil
+		}
+	}
+	return fmt.Errorf("%s unknown sub-command", arg)
+}
+
+func (*Sieve) CompleteSubNames(arg string) []string {
+	context := commands.CurrentContext()
+	options := make([]string, 0, len(subCommands))
+	for alias, cmd := range subCommands {
+		if cmd.Context()&context != 0 {
+			options = append(options, alias)
+		}
+	}
+	return commands.FilterList(options, arg, commands.QuoteSpace)
+}
+
+func (s *Sieve) CompleteSubArgs(arg string) []string {
+	if s.SubCmd == nil {
+		return nil
+	}
+	// prepend arbitrary string to arg to work with sub-commands
+	options, _ := commands.GetCompletions(s.SubCmd, opt.LexArgs("a "+arg))
+	completions := make([]string, 0, len(options))
+	for _, o := range options {
+		completions = append(completions, o.Value)
+	}
+	return completions
+}
+
+func (s Sieve) Execute(args []string) error {
+	a := opt.QuoteArgs(args[1:]...)
+	return commands.ExecuteCommand(s.SubCmd, a.String())
+}
+
+// serverURL returns the ManageSieve server of an account. Unless specified
+// in the sieve option, the credentials of the source are used.
+func serverURL(acct *config.AccountConfig) (*url.URL, error) {
+	src, err := url.Parse(acct.Source)
+	if err != nil {
+		return nil, err
+	}
+	var u *url.URL
+	if acct.Sieve != "" {
+		u, err = url.Parse(acct.Sieve)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if !strings.HasPrefix(src.Scheme, "imap") {
+			return nil, fmt.Errorf(
+				"no sieve server configured for account %s", acct.Name)
+		}
+		u = &url.URL{Scheme: "sieve", Host: src.Hostname()}
+		if strings.HasSuffix(src.Scheme, "+insecure") {
+			u.Scheme = "sieve+insecure"
+		}
+	}
+	if u.User == nil {
+		u.User = src.User
+	} else if _, ok := u.User.Password(); !ok && src.User != nil &&
+		u.User.Username() == src.User.Username() {
+		u.User = src.User
+	}
+	return u, nil
+}
+
+// saslClient returns the authentication mechanism to use with the source
+// credentials.
+func saslClient(acct *config.AccountConfig, u *url.URL) (sasl.Client, error) {
+	password, _ := u.User.Password()
+	src, err := url.Parse(acct.Source)
+	if err != nil {
+		return nil, err
+	}
+	if oauth.IsOAuth(src.Scheme) {
+		conf := oauth.Config(src.Query())
+		if conf.Endpoint.TokenURL != "" {
+			password, err = oauth.AccessToken(acct.Name, conf, password)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	switch {
+	case strings.HasSuffix(src.Scheme, "+oauthbearer"):
+		return sasl.NewOAuthBearerClient(&sasl.OAuthBearerOptions{
+			Username: u.User.Username(),
+			Token:    password,
+		}), nil
+	case strings.HasSuffix(src.Scheme, "+xoauth2"):
+		return lib.NewXoauth2Client(u.User.Username(), password), nil
+	}
+	return sasl.NewPlainClient("", u.User.Username(), password), nil
+}
+
+func connect(acct *config.AccountConfig) (*sieve.Client, error) {
+	u, err := serverURL(acct)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := saslClient(acct, u)
+	if err != nil {
+		return nil, err
+	}
+	return sieve.Connect(u, auth)
+}
+
+// run connects to the sieve server of the selected account and calls fn in
+// the background.
+func run(fn func(acct *config.AccountConfig, c *sieve.Client) error) error {
+	acct := app.SelectedAccount()
+	if acct == nil {
+		return errors.New("No account selected")
+	}
+	conf := acct.AccountConfig()
+	go func() {
+		defer log.PanicHandler()
+
+		c, err := connect(conf)
+		if err != nil {
+			app.PushError(fmt.Sprintf("sieve: %v", err))
+			return
+		}
+		defer c.Logout()
+		if err := fn(conf, c); err != nil {
+			app.PushError(err.Error())
+		}
+	}()
+	return nil
+}
+
+// known script names per account, used for completion
+var (
+	scriptsLock sync.Mutex
+	scripts     = make(map[string][]string)
+)
+
+func setScripts(account string, list []sieve.Script) {
+	names := make([]string, 0, len(list))
+	for _, s := range list {
+		names = append(names, s.Name)
+	}
+	scriptsLock.Lock()
+	scripts[account] = names
+	scriptsLock.Unlock()
+}
+
+func completeScripts(arg string) []string {
+	acct := app.SelectedAccount()
+	if acct == nil {
+		return nil
+	}
+	scriptsLock.Lock()
+	names, ok := scripts[acct.Name()]
+	if !ok {
+		// fetch the names in the background for the next completion
+		scripts[acct.Name()] = nil
+		go func() {
+			defer log.PanicHandler()
+
+			c, err := connect(acct.AccountConfig())
+			if err != nil {
+				log.Warnf("sieve: %v", err)
+				return
+			}
+			defer c.Logout()
+			if list, err := c.ListScripts(); err == nil {
+				setScripts(acct.Name(), list)
+			}
+		}()
+	}
+	scriptsLock.Unlock()
+	return commands.FilterList(names, arg, commands.QuoteSpace)
+}
@@ -12,9 +12,12 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/secret"
+	"git.sr.ht/~rjarry/aerc/models"
 	"github.com/emersion/go-message/mail"
 	"github.com/go-ini/ini"
 )
@@ -29,7 +32,11 @@ type RemoteConfig struct {
 	Value       string
 	PasswordCmd string
 	CacheCmd    bool
-	cache       string
+	// password store used when there is no password and no command
+	store   secret.Store
+	account string
+	service string
+	cache   string
 }
 
 func (c *RemoteConfig) parseValue() (*url.URL, error) {
@@ -37,7 +44,7 @@ func (c *RemoteConfig) parseValue() (*url.URL, error) {
 }
 
 func (c *RemoteConfig) ConnectionString() (string, error) {
-	if c.Value == "" || c.PasswordCmd == "" {
+	if c.Value == "" || (c.PasswordCmd == "" && c.store == nil) {
 		return c.Value, nil
 	}
 
@@ -56,31 +63,32 @@ func (c *RemoteConfig) ConnectionString() (string, error) {
 		return c.Value, nil
 	}
 
+	// only query the password store for network protocols with a user name,
+	// local backends (maildir, notmuch, etc.) do not need any password
+	if c.PasswordCmd == "" && (u.User.Username() == "" || !isNetworkScheme(u.Scheme)) {
+		return c.Value, nil
+	}
+
 	pw := c.cache
 
 	if pw == "" {
-		usePinentry := EnablePinentry != nil &&
-			DisablePinentry != nil &&
-			SetPinentryEnv != nil
-
-		cmd := exec.Command("sh", "-c", c.PasswordCmd)
-		cmd.Stdin = os.Stdin
-
-		buf := new(bytes.Buffer)
-		cmd.Stderr = buf
-
-		if usePinentry {
-			EnablePinentry()
-			defer DisablePinentry()
-			SetPinentryEnv(cmd)
+		store, isCmd := c.store.(secret.CmdStore)
+		switch {
+		case c.PasswordCmd != "":
+			pw, err = runPasswordCmd(exec.Command("sh", "-c", c.PasswordCmd))
+		case isCmd:
+			pw, err = runPasswordCmd(store.LookupCmd(c.account, c.service))
+			// pass entries may contain more lines after the password
+			pw, _, _ = strings.Cut(pw, "\n")
+		default:
+			pw, err = c.store.Lookup(c.account, c.service)
+			if err != nil {
+				err = fmt.Errorf("failed to read password: %w", err)
+			}
 		}
-
-		output, err := cmd.Output()
 		if err != nil {
-			return "", fmt.Errorf("failed to read password: %v: %w",
-				buf.String(), err)
+			return "", err
 		}
-		pw = strings.TrimSpace(string(output))
 	}
 	u.User = url.UserPassword(u.User.Username(), pw)
 	if c.CacheCmd {
@@ -90,6 +98,41 @@ func (c *RemoteConfig) ConnectionString() (string, error) {
 	return u.String(), nil
 }
 
+// isNetworkScheme returns true for the URL schemes of the network backends
+// and transports, with or without TLS and authentication mechanism suffixes.
+func isNetworkScheme(scheme string) bool {
+	protocol, _, _ := strings.Cut(strings.ToLower(scheme), "+")
+	switch strings.TrimSuffix(protocol, "s") {
+	case "imap", "smtp", "jmap", "pop3":
+		return true
+	}
+	return false
+}
+
+func runPasswordCmd(cmd *exec.Cmd) (string, error) {
+	usePinentry := EnablePinentry != nil &&
+		DisablePinentry != nil &&
+		SetPinentryEnv != nil
+
+	cmd.Stdin = os.Stdin
+
+	buf := new(bytes.Buffer)
+	cmd.Stderr = buf
+
+	if usePinentry {
+		EnablePinentry()
+		defer DisablePinentry()
+		SetPinentryEnv(cmd)
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %v: %w",
+			buf.String(), err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
 type AccountConfig struct {
 	Name    string
 	Backend string
@@ -104,6 +147,8 @@ type AccountConfig struct {
 	Postpone          string          `ini:"postpone" default:"Drafts"`
 	From              *mail.Address   `ini:"from"`
 	UseEnvelopeFrom   bool            `ini:"use-envelope-from" default:"false"`
+	UseOutbox         bool            `ini:"use-outbox" default:"false"`
+	UndoDelete        bool            `ini:"undo-delete" default:"false"`
 	Aliases           []*mail.Address `ini:"aliases"`
 	Source            string          `ini:"source" parse:"ParseSource"`
 	Folders           []string        `ini:"folders" delim:","`
@@ -111,6 +156,9 @@ type AccountConfig struct {
 	Headers           []string        `ini:"headers" delim:","`
 	HeadersExclude    []string        `ini:"headers-exclude" delim:","`
 	Outgoing          RemoteConfig    `ini:"outgoing" parse:"ParseOutgoing"`
+	CredStore         string          `ini:"cred-store"`
+	Searches          string          `ini:"searches"`
+	Sieve             string          `ini:"sieve"`
 	SignatureFile     string          `ini:"signature-file"`
 	SignatureCmd      string          `ini:"signature-cmd"`
 	EnableFoldersSort bool            `ini:"enable-folders-sort" default:"true"`
@@ -137,6 +185,10 @@ type AccountConfig struct {
 
 	// AuthRes
 	TrustedAuthRes []string `ini:"trusted-authres" delim:","`
+
+	// roles of the folders which were not configured and may be
+	// discovered from the special-use attributes of the server folders
+	discoverable map[models.Role]bool
 }
 
 const (
@@ -253,6 +305,17 @@ func ParseAccountConfig(name string, section *ini.Section) (*AccountConfig, erro
 		Name:   name,
 		Params: make(map[string]string),
 	}
+	// must be checked before the defaults are added to the section
+	account.discoverable = make(map[models.Role]bool)
+	for key, role := range map[string]models.Role{
+		"archive":  models.ArchiveRole,
+		"copy-to":  models.SentRole,
+		"postpone": models.DraftsRole,
+	} {
+		if !section.HasKey(key) {
+			account.discoverable[role] = true
+		}
+	}
 	if err := MapToStruct(section, &account, true); err != nil {
 		return nil, err
 	}
@@ -275,7 +338,7 @@ func ParseAccountConfig(name string, section *ini.Section) (*AccountConfig, erro
 	}
 
 	account.Backend = parseBackend(account.Source)
-	if account.From == nil {
+	if account.From == nil && account.Backend != "unified" {
 		return nil, fmt.Errorf("missing 'from' parameter")
 	}
 	if len(account.Headers) > 0 {
@@ -311,15 +374,107 @@ func parseBackend(source string) string {
 	if strings.HasPrefix(u.Scheme, "jmap") {
 		return "jmap"
 	}
+	if strings.HasPrefix(u.Scheme, "pop3") {
+		return "pop3"
+	}
 	return u.Scheme
 }
 
+// protects the folders which are discovered while the accounts are running
+var discoverLock sync.RWMutex
+
+// DiscoverFolder uses a folder for the archive, copy-to or postpone settings
+// if they were not configured and the folder has the matching role. Only the
+// first folder with each role is used.
+func (a *AccountConfig) DiscoverFolder(name string, role models.Role) bool {
+	discoverLock.Lock()
+	defer discoverLock.Unlock()
+	if !a.discoverable[role] {
+		return false
+	}
+	delete(a.discoverable, role)
+	switch role {
+	case models.ArchiveRole:
+		a.Archive = name
+	case models.SentRole:
+		a.CopyTo = name
+	case models.DraftsRole:
+		a.Postpone = name
+	}
+	return true
+}
+
+// ArchiveFolder returns the archive setting, which may be discovered once the
+// account is connected.
+func (a *AccountConfig) ArchiveFolder() string {
+	discoverLock.RLock()
+	defer discoverLock.RUnlock()
+	return a.Archive
+}
+
+// CopyToFolder returns the copy-to setting, which may be discovered once the
+// account is connected.
+func (a *AccountConfig) CopyToFolder() string {
+	discoverLock.RLock()
+	defer discoverLock.RUnlock()
+	return a.CopyTo
+}
+
+// PostponeFolder returns the postpone setting, which may be discovered once
+// the account is connected.
+func (a *AccountConfig) PostponeFolder() string {
+	discoverLock.RLock()
+	defer discoverLock.RUnlock()
+	return a.Postpone
+}
+
+// UnifiedAccounts returns the accounts merged by a unified account. These are
+// the accounts listed in its accounts parameter or, by default, all the other
+// accounts.
+func (a *AccountConfig) UnifiedAccounts() []*AccountConfig {
+	var accounts []*AccountConfig
+	names := a.Params["accounts"]
+	if names == "" {
+		for _, acct := range Accounts {
+			if acct.Backend != "unified" {
+				accounts = append(accounts, acct)
+			}
+		}
+		return accounts
+	}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		for _, acct := range Accounts {
+			if acct.Name == name && acct.Backend != "unified" {
+				accounts = append(accounts, acct)
+			}
+		}
+	}
+	return accounts
+}
+
+// credStore returns the password store configured for the account, if any.
+func (a *AccountConfig) credStore(sec *ini.Section) (secret.Store, error) {
+	k, err := sec.GetKey("cred-store")
+	if err != nil || k.String() == "" {
+		return nil, nil
+	}
+	return secret.New(k.String())
+}
+
 func (a *AccountConfig) ParseSource(sec *ini.Section, key *ini.Key) (string, error) {
 	var remote RemoteConfig
 	remote.Value = key.String()
 	if k, err := sec.GetKey("source-cred-cmd"); err == nil {
 		remote.PasswordCmd = k.String()
 	}
+	store, err := a.credStore(sec)
+	if err != nil {
+		return "", err
+	}
+	remote.store = store
+	remote.account = a.Name
+	remote.service = secret.Source
 	return remote.ConnectionString()
 }
 
@@ -329,6 +484,13 @@ func (a *AccountConfig) ParseOutgoing(sec *ini.Section, key *ini.Key) (RemoteCon
 	if k, err := sec.GetKey("outgoing-cred-cmd"); err == nil {
 		remote.PasswordCmd = k.String()
 	}
+	store, err := a.credStore(sec)
+	if err != nil {
+		return remote, err
+	}
+	remote.store = store
+	remote.account = a.Name
+	remote.service = secret.Outgoing
 	if k, err := sec.GetKey("outgoing-cred-cmd-cache"); err == nil {
 		cache, err := k.Bool()
 		if err != nil {
@@ -336,7 +498,7 @@ func (a *AccountConfig) ParseOutgoing(sec *ini.Section, key *ini.Key) (RemoteCon
 		}
 		remote.CacheCmd = cache
 	}
-	_, err := remote.parseValue()
+	_, err = remote.parseValue()
 	return remote, err
 }
 
@@ -2,6 +2,7 @@ package config
 
 import (
 	"regexp"
+	"time"
 
 	"git.sr.ht/~rjarry/aerc/lib/log"
 	"github.com/go-ini/ini"
@@ -19,6 +20,7 @@ type ComposeConfig struct {
 	EditHeaders         bool           `ini:"edit-headers"`
 	FocusBody           bool           `ini:"focus-body"`
 	LFEditor            bool           `ini:"lf-editor"`
+	UndoSendDelay       time.Duration  `ini:"undo-send-delay"`
 }
 
 var Compose = new(ComposeConfig)
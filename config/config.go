@@ -148,6 +148,9 @@ func LoadConfigFromFile(
 	if err := parseBinds(*root, bindPath); err != nil {
 		return err
 	}
+	if err := parseRules(*root); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -1,9 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
 
+	builtins "git.sr.ht/~rjarry/aerc/lib/filters"
 	"git.sr.ht/~rjarry/aerc/lib/log"
 	"github.com/go-ini/ini"
 )
@@ -21,6 +23,7 @@ type FilterConfig struct {
 	Type       FilterType
 	Filter     string
 	Command    string
+	Builtin    string
 	NeedsPager bool
 	Header     string
 	Regex      *regexp.Regexp
@@ -46,6 +49,16 @@ func parseFilters(file *ini.File) error {
 			NeedsPager: pager,
 			Filter:     key.Name(),
 		}
+		if name, ok := builtinName(cmd); ok {
+			if _, ok := builtins.Lookup(name); !ok {
+				return fmt.Errorf(
+					"[filters] %s: unknown builtin filter %q (available: %s)",
+					key.Name(), name, strings.Join(builtins.Names(), ", "))
+			}
+			// builtin filters always output to the pager
+			filter.Builtin = name
+			filter.NeedsPager = true
+		}
 
 		switch {
 		case strings.HasPrefix(filter.Filter, ".filename,~"):
@@ -94,3 +107,13 @@ end:
 	log.Debugf("aerc.conf: [filters] %#v", Filters)
 	return nil
 }
+
+// builtinName returns the name of the builtin filter used by a filter command
+// of the form ":builtin <name>".
+func builtinName(cmd string) (string, bool) {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 || fields[0] != ":builtin" {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(cmd, ":builtin")), true
+}
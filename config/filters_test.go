@@ -0,0 +1,23 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestBuiltinName(t *testing.T) {
+	for cmd, expected := range map[string]string{
+		":builtin html":    "html",
+		":builtin\twrap  ": "wrap",
+		":builtin":         "",
+	} {
+		name, ok := builtinName(cmd)
+		if !ok || name != expected {
+			t.Errorf("%q: expected %q, got %q (%v)", cmd, expected, name, ok)
+		}
+	}
+	for _, cmd := range []string{":builtinhtml", "colorize", "builtin html"} {
+		if name, ok := builtinName(cmd); ok {
+			t.Errorf("%q: unexpected builtin %q", cmd, name)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/models"
+	"github.com/go-ini/ini"
+)
+
+type RuleHeader struct {
+	Name  string
+	Value string
+}
+
+type RuleConfig struct {
+	Name string
+
+	// conditions
+	Accounts []string     `ini:"account" delim:","`
+	Folders  []string     `ini:"folder" delim:","`
+	From     string       `ini:"from"`
+	To       string       `ini:"to"`
+	Cc       string       `ini:"cc"`
+	Subject  string       `ini:"subject"`
+	Headers  []RuleHeader `ini:"header" parse:"ParseHeaders"`
+	Body     string       `ini:"body"`
+	Text     string       `ini:"text"`
+
+	// actions
+	Pipe     string       `ini:"pipe"`
+	Flag     models.Flags `ini:"flag" parse:"ParseFlag"`
+	MarkRead bool         `ini:"mark-read"`
+	Tag      []string     `ini:"tag" delim:" "`
+	Copy     []string     `ini:"copy" delim:","`
+	Move     string       `ini:"move"`
+	Delete   bool         `ini:"delete"`
+}
+
+var Rules []*RuleConfig
+
+func (r *RuleConfig) ParseHeaders(sec *ini.Section, key *ini.Key) ([]RuleHeader, error) {
+	var headers []RuleHeader
+	for _, header := range key.ValueWithShadows() {
+		name, value, found := strings.Cut(header, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid header %q, expected 'Name: value'", header)
+		}
+		headers = append(headers, RuleHeader{
+			Name:  name,
+			Value: strings.TrimSpace(value),
+		})
+	}
+	return headers, nil
+}
+
+func (r *RuleConfig) ParseFlag(sec *ini.Section, key *ini.Key) (models.Flags, error) {
+	var flags models.Flags
+	for _, name := range key.Strings(",") {
+		switch strings.ToLower(name) {
+		case "answered":
+			flags |= models.AnsweredFlag
+		case "forwarded":
+			flags |= models.ForwardedFlag
+		case "flagged":
+			flags |= models.FlaggedFlag
+		case "draft":
+			flags |= models.DraftFlag
+		default:
+			return 0, fmt.Errorf("unknown flag: %s", name)
+		}
+	}
+	return flags, nil
+}
+
+// Matches returns true if the rule applies to the given account and folder.
+func (r *RuleConfig) Matches(account, folder string) bool {
+	if len(r.Accounts) > 0 && !contains(r.Accounts, account) {
+		return false
+	}
+	if len(r.Folders) > 0 && !contains(r.Folders, folder) {
+		return false
+	}
+	return true
+}
+
+func parseRuleConfig(name string, section *ini.Section) (*RuleConfig, error) {
+	rule := RuleConfig{Name: name}
+	if err := MapToStruct(section, &rule, false); err != nil {
+		return nil, err
+	}
+	if rule.Body != "" && rule.Text != "" {
+		return nil, errors.New("body and text cannot be used together")
+	}
+	if rule.Move != "" && rule.Delete {
+		return nil, errors.New("move and delete cannot be used together")
+	}
+	for _, tag := range rule.Tag {
+		if tag == "+" || tag == "-" {
+			return nil, fmt.Errorf("invalid tag %q", tag)
+		}
+	}
+	if rule.Pipe == "" && rule.Flag == 0 && !rule.MarkRead &&
+		len(rule.Tag) == 0 && len(rule.Copy) == 0 &&
+		rule.Move == "" && !rule.Delete {
+		return nil, errors.New("no action specified")
+	}
+	return &rule, nil
+}
+
+func parseRulesFromFile(filename string) error {
+	log.Debugf("Parsing rules configuration from %s", filename)
+
+	file, err := ini.LoadSources(ini.LoadOptions{
+		KeyValueDelimiters: "=",
+		AllowShadows:       true,
+	}, filename)
+	if err != nil {
+		return err
+	}
+
+	var rules []*RuleConfig
+	for _, sec := range file.Sections() {
+		if sec.Name() == ini.DefaultSection {
+			if len(sec.Keys()) > 0 {
+				return errors.New("settings must be inside a [rule] section")
+			}
+			continue
+		}
+		rule, err := parseRuleConfig(sec.Name(), sec)
+		if err != nil {
+			return fmt.Errorf("[%s]: %w", sec.Name(), err)
+		}
+		log.Debugf("rules.conf: [%s] %#v", rule.Name, rule)
+		rules = append(rules, rule)
+	}
+	Rules = rules
+
+	return nil
+}
+
+func parseRules(root string) error {
+	filename := path.Join(root, "rules.conf")
+	SetRulesFilename(filename)
+	if _, err := os.Stat(filename); errors.Is(err, os.ErrNotExist) {
+		// rules are optional
+		return nil
+	}
+	if err := parseRulesFromFile(filename); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"testing"
+
+	"git.sr.ht/~rjarry/aerc/models"
+	"github.com/go-ini/ini"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRules(t *testing.T) {
+	assert := assert.New(t)
+
+	file, err := ini.LoadSources(ini.LoadOptions{
+		KeyValueDelimiters: "=",
+		AllowShadows:       true,
+	}, []byte(`
+[lists]
+account = work,home
+header = List-Id: aerc-devel
+header = X-Mailer: git-send-email
+flag = flagged,answered
+tag = +lists -inbox
+move = Lists
+
+[invalid]
+body = foo
+text = bar
+delete = true
+
+[noop]
+from = joe@example.org
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule, err := parseRuleConfig("lists", file.Section("lists"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]string{"work", "home"}, rule.Accounts)
+	assert.Equal([]RuleHeader{
+		{Name: "List-Id", Value: "aerc-devel"},
+		{Name: "X-Mailer", Value: "git-send-email"},
+	}, rule.Headers)
+	assert.Equal(models.FlaggedFlag|models.AnsweredFlag, rule.Flag)
+	assert.Equal([]string{"+lists", "-inbox"}, rule.Tag)
+	assert.Equal("Lists", rule.Move)
+	assert.True(rule.Matches("work", "INBOX"))
+	assert.False(rule.Matches("other", "INBOX"))
+
+	_, err = parseRuleConfig("invalid", file.Section("invalid"))
+	assert.Error(err)
+	_, err = parseRuleConfig("noop", file.Section("noop"))
+	assert.Error(err)
+}
@@ -49,6 +49,7 @@ const (
 	STYLE_DIRLIST_DEFAULT
 	STYLE_DIRLIST_UNREAD
 	STYLE_DIRLIST_RECENT
+	STYLE_DIRLIST_SHARED
 
 	STYLE_PART_SWITCHER
 	STYLE_PART_FILENAME
@@ -102,6 +103,7 @@ var StyleNames = map[string]StyleObject{
 	"dirlist_default": STYLE_DIRLIST_DEFAULT,
 	"dirlist_unread":  STYLE_DIRLIST_UNREAD,
 	"dirlist_recent":  STYLE_DIRLIST_RECENT,
+	"dirlist_shared":  STYLE_DIRLIST_SHARED,
 
 	"part_switcher": STYLE_PART_SWITCHER,
 	"part_filename": STYLE_PART_FILENAME,
@@ -319,9 +321,18 @@ type StyleSet struct {
 	objects  map[StyleObject]*StyleConf
 	selected map[StyleObject]*StyleConf
 	user     map[string]*Style
+	viewer   map[string]*Style
 	path     string
 }
 
+// ViewerStyleNames are the style objects of the [viewer] section which are
+// used by the builtin filters.
+var ViewerStyleNames = []string{
+	"url", "header", "signature",
+	"diff_meta", "diff_chunk", "diff_chunk_func", "diff_add", "diff_del",
+	"quote_1", "quote_2", "quote_3", "quote_4", "quote_x",
+}
+
 const defaultStyleset string = `
 *.selected.bg = 12
 *.selected.fg = 15
@@ -356,6 +367,28 @@ completion_pill.bg = 12
 completion_default.fg = 15
 completion_description.fg = 15
 completion_description.dim = true
+
+[viewer]
+url.underline = true
+url.fg = 3
+header.bold = true
+header.fg = 4
+signature.dim = true
+signature.fg = 4
+diff_meta.bold = true
+diff_chunk.fg = 6
+diff_chunk_func.fg = 6
+diff_chunk_func.dim = true
+diff_add.fg = 2
+diff_del.fg = 1
+quote_1.fg = 6
+quote_2.fg = 4
+quote_3.fg = 6
+quote_3.dim = true
+quote_4.fg = 4
+quote_4.dim = true
+quote_x.fg = 5
+quote_x.dim = true
 `
 
 func NewStyleSet() StyleSet {
@@ -363,11 +396,15 @@ func NewStyleSet() StyleSet {
 		objects:  make(map[StyleObject]*StyleConf),
 		selected: make(map[StyleObject]*StyleConf),
 		user:     make(map[string]*Style),
+		viewer:   make(map[string]*Style),
 	}
 	for _, so := range StyleNames {
 		ss.objects[so] = new(StyleConf)
 		ss.selected[so] = new(StyleConf)
 	}
+	for _, name := range ViewerStyleNames {
+		ss.viewer[name] = new(Style)
+	}
 	f, err := ini.Load([]byte(defaultStyleset))
 	if err == nil {
 		err = ss.ParseStyleSet(f)
@@ -416,6 +453,13 @@ func (ss StyleSet) UserStyle(name string) vaxis.Style {
 	return vaxis.Style{}
 }
 
+func (ss StyleSet) ViewerStyle(name string) vaxis.Style {
+	if style, found := ss.viewer[name]; found {
+		return style.Get()
+	}
+	return vaxis.Style{}
+}
+
 func (ss StyleSet) Compose(
 	so StyleObject, sos []StyleObject, h *mail.Header,
 ) vaxis.Style {
@@ -475,6 +519,12 @@ func (ss *StyleSet) ParseStyleSet(file *ini.File) error {
 		}
 	}
 
+	if viewer, err := file.GetSection("viewer"); err == nil {
+		if err := ss.parseViewer(viewer); err != nil {
+			return err
+		}
+	}
+
 	user, err := file.GetSection("user")
 	if err != nil {
 		// This errors if the section doesn't exist, which is ok
@@ -504,6 +554,33 @@ func (ss *StyleSet) ParseStyleSet(file *ini.File) error {
 	return nil
 }
 
+// parseViewer parses the [viewer] section. Style object names may contain
+// wildcards. Unknown objects are ignored.
+func (ss *StyleSet) parseViewer(section *ini.Section) error {
+	for _, key := range section.Keys() {
+		obj, attr, found := strings.Cut(key.Name(), ".")
+		if !found {
+			return errors.New("Style parsing error: [viewer]." + key.Name())
+		}
+		objRe, err := fnmatchToRegex(obj)
+		if err != nil {
+			return err
+		}
+		// only match whole object names
+		objRe = regexp.MustCompile("^(?:" + objRe.String() + ")$")
+		for _, name := range ViewerStyleNames {
+			if !objRe.MatchString(name) {
+				continue
+			}
+			if err := ss.viewer[name].Set(attr, key.Value()); err != nil {
+				return fmt.Errorf("[viewer].%s=%s: %w",
+					key.Name(), key.Value(), err)
+			}
+		}
+	}
+	return nil
+}
+
 var (
 	styleObjRe            = regexp.MustCompile(`^([\w\*\?]+)(?:\.([\w-]+,.+?)+?)?(\.selected)?\.(\w+)$`)
 	styleHeaderPatternsRe = regexp.MustCompile(`([\w-]+),(.+?)\.`)
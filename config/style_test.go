@@ -87,3 +87,25 @@ func TestStyleMultiHeaderPattern(t *testing.T) {
 		}
 	})
 }
+
+func TestStyleViewer(t *testing.T) {
+	ini, err := ini.Load([]byte(`
+[viewer]
+*.default = true
+*.normal = true
+diff_add.fg = lime
+`))
+	if err != nil {
+		t.Fatalf("failed to load styleset: %v", err)
+	}
+	ss := NewStyleSet()
+	if err := ss.ParseStyleSet(ini); err != nil {
+		t.Fatalf("failed to parse styleset: %v", err)
+	}
+	if s := ss.ViewerStyle("diff_add"); s.Foreground != colorNames["lime"] {
+		t.Errorf("expected:#%v got:#%v", colorNames["lime"], s.Foreground)
+	}
+	if s := ss.ViewerStyle("url"); s.Foreground != 0 || s.UnderlineStyle != 0 {
+		t.Errorf("url style was not reset: %#v", s)
+	}
+}
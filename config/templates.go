@@ -16,6 +16,7 @@ type TemplateConfig struct {
 	NewMessage   string   `ini:"new-message" default:"new_message"`
 	QuotedReply  string   `ini:"quoted-reply" default:"quoted_reply"`
 	Forwards     string   `ini:"forwards" default:"forward_as_body"`
+	Review       string   `ini:"review" default:"review"`
 }
 
 var Templates = new(TemplateConfig)
@@ -44,6 +45,9 @@ func parseTemplates(file *ini.File) error {
 	if err := checkTemplate(t.Forwards, t.TemplateDirs); err != nil {
 		return err
 	}
+	if err := checkTemplate(t.Review, t.TemplateDirs); err != nil {
+		return err
+	}
 
 	log.Debugf("aerc.conf: [templates] %#v", Templates)
 
@@ -123,3 +127,5 @@ func (d *dummyData) Style(string, string) string               { return "" }
 func (d *dummyData) StyleSwitch(string, ...models.Case) string { return "" }
 
 func (d *dummyData) StyleMap([]string, ...models.Case) []string { return []string{} }
+
+func (d *dummyData) OriginalComments() map[int]string { return map[int]string{0: "Blah"} }
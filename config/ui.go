@@ -371,6 +371,10 @@ func (uiConfig *UIConfig) GetUserStyle(name string) vaxis.Style {
 	return uiConfig.style.UserStyle(name)
 }
 
+func (uiConfig *UIConfig) GetViewerStyle(name string) vaxis.Style {
+	return uiConfig.style.ViewerStyle(name)
+}
+
 func (uiConfig *UIConfig) GetStyle(so StyleObject) vaxis.Style {
 	return uiConfig.style.Get(so, nil)
 }
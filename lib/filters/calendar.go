@@ -0,0 +1,149 @@
+package filters
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+var mailtoRe = regexp.MustCompile("MAILTO:(.+@)")
+
+// Calendar renders the events of a text/calendar part as a readable summary.
+// It is equivalent to the calendar filter.
+func Calendar(r io.Reader, w io.Writer, opts *Options) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	// fix capitalized mailto for parsing
+	text := mailtoRe.ReplaceAllString(string(data), "mailto:${1}")
+	cal, err := ics.ParseCalendar(strings.NewReader(text))
+	if err != nil {
+		return err
+	}
+
+	var method string
+	for _, p := range cal.CalendarProperties {
+		if p.IANAToken == string(ics.PropertyMethod) {
+			method = p.Value
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	field := func(name, value string) {
+		fmt.Fprintf(bw, "  %-14s%s\n", name, value)
+	}
+	seen := make(map[string]bool)
+	for _, event := range cal.Events() {
+		if method != "" {
+			fmt.Fprintf(bw, "\n  This is a meeting %s\n\n", method)
+		}
+		if id := event.Id(); id != "" {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+		}
+		value := func(p ics.ComponentProperty) string {
+			if prop := event.GetProperty(p); prop != nil {
+				return prop.Value
+			}
+			return ""
+		}
+
+		field("SUMMARY", strings.ReplaceAll(value(ics.ComponentPropertySummary), "\n", " "))
+		field("START", dateTime(event.GetProperty(ics.ComponentPropertyDtStart)))
+		field("END", dateTime(event.GetProperty(ics.ComponentPropertyDtEnd)))
+		if rrule := value(ics.ComponentPropertyRrule); rrule != "" {
+			params := make(map[string]string)
+			for _, kv := range strings.Split(rrule, ";") {
+				k, v, _ := strings.Cut(kv, "=")
+				params[k] = v
+			}
+			freq := strings.ToLower(params["FREQ"])
+			if params["INTERVAL"] != "" {
+				freq = " +" + params["INTERVAL"] + freq
+			}
+			fmt.Fprintln(bw)
+			field("RECURRENCE", freq)
+			if params["COUNT"] != "" {
+				field("COUNTS", params["COUNT"])
+			}
+			if params["UNTIL"] != "" {
+				field("END DATE", params["UNTIL"])
+			}
+		}
+		if location := value(ics.ComponentPropertyLocation); location != "" {
+			field("LOCATION", strings.ReplaceAll(location, "\n", " "))
+		}
+		if organizer := event.GetProperty(ics.ComponentPropertyOrganizer); organizer != nil {
+			field("ORGANIZER", person(&organizer.BaseProperty))
+		}
+
+		attendees := event.Attendees()
+		if len(attendees) > 0 {
+			for i, a := range attendees {
+				name, sep := "", ","
+				if i == 0 {
+					name = "ATTENDEES "
+				}
+				if i == len(attendees)-1 {
+					sep = ""
+				}
+				field(name, person(&a.BaseProperty)+sep)
+			}
+			fmt.Fprintf(bw, "\n\n  %-14s\n", "DETAILED LIST:")
+			for i, a := range attendees {
+				field(fmt.Sprintf("ATTENDEE [%d]", i+1), person(&a.BaseProperty))
+				if partstat := param(&a.BaseProperty, ics.ParameterParticipationStatus); partstat != "" {
+					field("", "STATUS\t"+partstat)
+				}
+				if rsvp := param(&a.BaseProperty, ics.ParameterRsvp); rsvp != "" {
+					field("", "RSVP\t"+rsvp)
+				}
+			}
+		}
+		if description := value(ics.ComponentPropertyDescription); description != "" {
+			fmt.Fprintf(bw, "\n%s\n", description)
+		}
+	}
+	return bw.Flush()
+}
+
+func param(p *ics.BaseProperty, name ics.Parameter) string {
+	if values := p.ICalParameters[string(name)]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// person formats the common name and email of an organizer or attendee.
+func person(p *ics.BaseProperty) string {
+	email := p.Value
+	if i := strings.Index(strings.ToLower(email), "mailto:"); i >= 0 {
+		email = email[i+len("mailto:"):]
+	}
+	if name := param(p, ics.ParameterCn); name != "" {
+		return fmt.Sprintf("%s <%s>", name, email)
+	}
+	return fmt.Sprintf("<%s>", email)
+}
+
+// dateTime formats a DTSTART or DTEND value like 2006/01/02 15:04:05 TZID.
+// The value is not validated.
+func dateTime(p *ics.IANAProperty) string {
+	if p == nil {
+		return ""
+	}
+	date, clock, found := strings.Cut(p.Value, "T")
+	tz := param(&p.BaseProperty, ics.ParameterTzid)
+	if !found || len(date) < 8 || len(clock) < 6 {
+		return strings.TrimSpace(p.Value + " " + tz)
+	}
+	return fmt.Sprintf("%s/%s/%s %s:%s:%s %s", date[:4], date[4:6], date[6:8],
+		clock[:2], clock[2:4], clock[4:6], tz)
+}
@@ -0,0 +1,282 @@
+package filters
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	headerRe    = regexp.MustCompilePOSIX(`^[A-Z][[:alnum:]_-]+:`)
+	diffStartRe = regexp.MustCompilePOSIX(`^(diff (--git|-up|-u)|---) [[:graph:]]`)
+	diffMetaRe  = regexp.MustCompilePOSIX(`^(diff (--git|-up|-u)|(new|deleted) file|similarity` +
+		` index|(rename|copy) (to|from)|index|---|\+\+\+) `)
+	urlRe = regexp.MustCompilePOSIX(`([a-z]{2,8})://` +
+		`|(mailto:)?[[:alnum:]_+.~/-]*[[:alnum:]]@[[:alnum:]][[:alnum:].-]*[[:alnum:]]`)
+)
+
+type colorizeState int
+
+const (
+	stateBody colorizeState = iota
+	stateDiff
+	stateSignature
+)
+
+type colorizer struct {
+	w      *bufio.Writer
+	opts   *Options
+	state  colorizeState
+	urlID  int
+	styles map[string]string
+}
+
+// Colorize adds colors to plain text email bodies: quotes, signatures, URLs,
+// header-like lines and patches. It is equivalent to the colorize filter.
+func Colorize(r io.Reader, w io.Writer, opts *Options) error {
+	c := colorizer{
+		w:      bufio.NewWriter(w),
+		opts:   opts,
+		styles: make(map[string]string),
+	}
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			if i := strings.IndexAny(line, "\r\n"); i >= 0 {
+				line = line[:i]
+			}
+			c.line(line)
+			c.print("\n")
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return c.w.Flush()
+}
+
+func (c *colorizer) seq(name string) string {
+	s, ok := c.styles[name]
+	if !ok {
+		s = sgr(c.opts.style(name))
+		if s == "" {
+			s = reset
+		}
+		c.styles[name] = s
+	}
+	return s
+}
+
+func (c *colorizer) print(s string) {
+	//nolint:errcheck // errors are returned by Flush
+	c.w.WriteString(s)
+}
+
+// printNoTabs replaces tabs with spaces since they are interpreted as cursor
+// movements and are not colored like regular characters.
+func (c *colorizer) printNoTabs(s string) {
+	c.print(strings.ReplaceAll(s, "\t", "        "))
+}
+
+func (c *colorizer) printStyle(s string, name string) {
+	c.print(c.seq(name))
+	c.printNoTabs(s)
+	c.print(reset)
+}
+
+func (c *colorizer) line(in string) {
+	switch c.state {
+	case stateDiff:
+		switch {
+		case in == "-- ":
+			c.state = stateSignature
+			c.signature(in)
+		case strings.HasPrefix(in, "@@ "):
+			c.diffChunk(in)
+		case diffMetaRe.MatchString(in):
+			c.printStyle(in, "diff_meta")
+		case strings.HasPrefix(in, "+"):
+			c.printStyle(in, "diff_add")
+		case strings.HasPrefix(in, "-"):
+			c.printStyle(in, "diff_del")
+		case !strings.HasPrefix(in, " ") && in != "":
+			c.state = stateBody
+			if strings.HasPrefix(in, ">") {
+				c.quote(in)
+			} else {
+				c.urls(in, "")
+			}
+		default:
+			c.printNoTabs(in)
+		}
+	case stateSignature:
+		c.signature(in)
+	default:
+		switch {
+		case diffStartRe.MatchString(in):
+			c.state = stateDiff
+			c.printStyle(in, "diff_meta")
+		case in == "-- ":
+			c.state = stateSignature
+			c.signature(in)
+		case strings.HasPrefix(in, ">"):
+			c.quote(in)
+		case headerRe.MatchString(in):
+			c.header(in)
+		default:
+			c.urls(in, "")
+		}
+	}
+}
+
+func (c *colorizer) diffChunk(in string) {
+	n := 0
+	for n < len(in) && in[n] == '@' {
+		n++
+	}
+	for n < len(in) && in[n] != '@' {
+		n++
+	}
+	for n < len(in) && in[n] == '@' {
+		n++
+	}
+	c.printStyle(in[:n], "diff_chunk")
+	c.printStyle(in[n:], "diff_chunk_func")
+}
+
+func (c *colorizer) signature(in string) {
+	c.print(c.seq("signature"))
+	c.urls(in, c.seq("signature"))
+	c.print(reset)
+}
+
+func (c *colorizer) header(in string) {
+	n := headerRe.FindStringIndex(in)[1]
+	c.printStyle(in[:n], "header")
+	c.urls(in[n:], "")
+}
+
+func (c *colorizer) quote(in string) {
+	q, level := 0, 0
+	for q < len(in) && in[q] == '>' {
+		level++
+		q++
+		if q < len(in) && in[q] == ' ' {
+			q++
+		}
+	}
+	name := "quote_x"
+	if level <= 4 {
+		name = fmt.Sprintf("quote_%d", level)
+	}
+	c.print(c.seq(name))
+	c.printNoTabs(in[:q])
+	in = in[q:]
+	switch {
+	case strings.HasPrefix(in, "+"):
+		c.print(reset + c.seq("diff_add"))
+		c.printNoTabs(in)
+	case strings.HasPrefix(in, "-"):
+		c.print(reset + c.seq("diff_del"))
+		c.printNoTabs(in)
+	case diffMetaRe.MatchString(in):
+		c.print("\x1b[1m")
+		c.printNoTabs(in)
+	default:
+		c.urls(in, c.seq(name))
+	}
+	c.print(reset)
+}
+
+func isURIChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("-_.,~:;/?#@!$&%*+=\"'|<>()[]", c) >= 0
+}
+
+// urls highlights the URLs and email addresses of in. ctx is the escape
+// sequence of the surrounding style, restored after each URL.
+func (c *colorizer) urls(in string, ctx string) {
+	for {
+		m := urlRe.FindStringSubmatchIndex(in)
+		if m == nil {
+			break
+		}
+		c.printNoTabs(in[:m[0]])
+		in = in[m[0]:]
+		n := m[1] - m[0]
+
+		if m[2] != -1 {
+			// Standard URL (i.e. not mailto: nor email address).
+			// Regular expressions do not really cut it here and we
+			// need to detect opening/closing braces to handle
+			// markdown link syntax.
+			paren, bracket, ltgt := 0, 0, 0
+			l := n
+		scan:
+			for l < len(in) && isURIChar(in[l]) {
+				switch in[l] {
+				case '[':
+					bracket++
+				case '(':
+					paren++
+				case '<':
+					ltgt++
+				case ']':
+					if bracket--; bracket < 0 {
+						break scan
+					}
+				case ')':
+					if paren--; paren < 0 {
+						break scan
+					}
+				case '>':
+					if ltgt--; ltgt < 0 {
+						break scan
+					}
+				}
+				l++
+			}
+			// Remove trailing characters that are valid URL
+			// characters, but typically not at the end of the URL.
+			for l > n && strings.IndexByte(".,:;?!\"'%", in[l-1]) >= 0 {
+				l--
+			}
+			if l == n {
+				// only an URL protocol, do not colorize
+				c.printNoTabs(in[:n])
+				in = in[n:]
+				continue
+			}
+			n = l
+		}
+
+		email := m[2] == -1 && m[4] == -1
+		url := in[:n]
+		c.print(c.seq("url"))
+		if c.opts != nil && c.opts.OSC8 {
+			target := url
+			if email {
+				target = "mailto://" + url
+			}
+			c.print(osc8(target, fmt.Sprintf("colorize-%d", c.urlID)))
+		}
+		c.printNoTabs(url)
+		if c.opts != nil && c.opts.OSC8 {
+			c.print(osc8("", ""))
+		}
+		c.urlID++
+		c.print(reset + ctx)
+		in = in[n:]
+	}
+	c.printNoTabs(in)
+}
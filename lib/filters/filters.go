@@ -0,0 +1,122 @@
+// Package filters implements the builtin filters which render message parts
+// in the viewer without running external programs.
+package filters
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"git.sr.ht/~rockorager/vaxis"
+)
+
+// Options are passed to the builtin filters.
+type Options struct {
+	// Width of the viewer in columns, 0 if unknown.
+	Width int
+	// OSC8 enables hyperlink escape sequences for URLs.
+	OSC8 bool
+	// Style returns the style of a [viewer] styleset object.
+	Style func(name string) vaxis.Style
+	// Subject of the message being displayed.
+	Subject string
+}
+
+// Filter reads a message part from r and writes it to w, with terminal escape
+// codes for colors.
+type Filter func(r io.Reader, w io.Writer, opts *Options) error
+
+var builtins = map[string]Filter{
+	"calendar": Calendar,
+	"colorize": Colorize,
+	"html":     HTML,
+	"wrap":     Wrap,
+}
+
+// Lookup returns the builtin filter with the given name.
+func Lookup(name string) (Filter, bool) {
+	f, ok := builtins[name]
+	return f, ok
+}
+
+// Names returns the sorted names of the builtin filters.
+func Names() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (o *Options) style(name string) vaxis.Style {
+	if o == nil || o.Style == nil {
+		return vaxis.Style{}
+	}
+	return o.Style(name)
+}
+
+const reset = "\x1b[0m"
+
+// sgr returns the escape sequence which selects the style, or an empty string
+// for the default style.
+func sgr(s vaxis.Style) string {
+	var params []string
+	for _, a := range []struct {
+		attr vaxis.AttributeMask
+		code string
+	}{
+		{vaxis.AttrBold, "1"},
+		{vaxis.AttrDim, "2"},
+		{vaxis.AttrItalic, "3"},
+	} {
+		if s.Attribute&a.attr != 0 {
+			params = append(params, a.code)
+		}
+	}
+	if s.UnderlineStyle != vaxis.UnderlineOff {
+		params = append(params, "4")
+	}
+	if s.Attribute&vaxis.AttrBlink != 0 {
+		params = append(params, "5")
+	}
+	if s.Attribute&vaxis.AttrReverse != 0 {
+		params = append(params, "7")
+	}
+	if s.Attribute&vaxis.AttrStrikethrough != 0 {
+		params = append(params, "9")
+	}
+	params = append(params, color(s.Foreground, 3)...)
+	params = append(params, color(s.Background, 4)...)
+	if len(params) == 0 {
+		return ""
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+func color(c vaxis.Color, base int) []string {
+	p := c.Params()
+	switch len(p) {
+	case 1:
+		if p[0] < 8 {
+			return []string{fmt.Sprintf("%d%d", base, p[0])}
+		}
+		return []string{fmt.Sprintf("%d8", base), "5", fmt.Sprint(p[0])}
+	case 3:
+		return []string{
+			fmt.Sprintf("%d8", base), "2",
+			fmt.Sprint(p[0]), fmt.Sprint(p[1]), fmt.Sprint(p[2]),
+		}
+	}
+	return nil
+}
+
+// osc8 returns the escape sequence which starts a hyperlink, or terminates it
+// if url is empty.
+func osc8(url string, id string) string {
+	if url == "" {
+		return "\x1b]8;;\x1b\\"
+	}
+	return "\x1b]8;id=" + id + ";" + url + "\x1b\\"
+}
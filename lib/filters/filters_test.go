@@ -0,0 +1,152 @@
+package filters_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~rjarry/aerc/config"
+	"git.sr.ht/~rjarry/aerc/lib/filters"
+	"github.com/go-ini/ini"
+)
+
+// same as filters/test.sh
+const styleset = `
+[viewer]
+*.normal=true
+*.default=true
+url.underline = true
+header.bold = true
+signature.dim = true
+diff_meta.bold = true
+diff_chunk.dim = true
+diff_add.fg = #00ff00
+diff_del.fg = 1
+quote_*.fg = 6
+quote_*.dim = true
+quote_1.dim = false
+`
+
+func TestFilters_Vectors(t *testing.T) {
+	file, err := ini.LoadSources(ini.LoadOptions{
+		SpaceBeforeInlineComment: true,
+	}, []byte(styleset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss := config.NewStyleSet()
+	if err := ss.ParseStyleSet(file); err != nil {
+		t.Fatal(err)
+	}
+	opts := filters.Options{OSC8: true, Style: ss.ViewerStyle}
+
+	vectors, err := filepath.Glob("../../filters/vectors/*.in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, vec := range vectors {
+		name, _, _ := strings.Cut(filepath.Base(vec), "-")
+		filter, ok := filters.Lookup(name)
+		if !ok {
+			continue
+		}
+		t.Run(filepath.Base(vec), func(t *testing.T) {
+			in, err := os.Open(vec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer in.Close()
+			expected, err := os.ReadFile(strings.TrimSuffix(vec, ".in") + ".expected")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := filter(in, &out, &opts); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != string(expected) {
+				t.Errorf("got:\n%q\nexpected:\n%q", out.String(), expected)
+			}
+		})
+	}
+}
+
+func TestFilters_HTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		width    int
+		input    string
+		expected string
+	}{
+		{
+			name:  "paragraphs",
+			width: 30,
+			input: `<html><head><title>x</title><style>p {}</style></head>
+<body><p>The quick brown fox jumps over the lazy dog.</p>
+<p>Second<br>paragraph</p></body></html>`,
+			expected: "The quick brown fox jumps over\n" +
+				"the lazy dog.\n" +
+				"\n" +
+				"Second\n" +
+				"paragraph\n",
+		},
+		{
+			name:  "links",
+			input: `<p>See <a href="https://example.org">the site</a> or <a href="#top">top</a>.</p>`,
+			expected: "See the site[1] or top.\n" +
+				"\n" +
+				"[1]: https://example.org\n",
+		},
+		{
+			name:  "lists",
+			input: `<ul><li>one</li><li>two</li></ul><ol><li>first</li><li>second</li></ol>`,
+			expected: "* one\n" +
+				"* two\n" +
+				"\n" +
+				"1. first\n" +
+				"2. second\n",
+		},
+		{
+			name:  "blockquote",
+			input: `<p>Hello,</p><blockquote><p>quoted</p><p>text</p></blockquote>`,
+			expected: "Hello,\n" +
+				"\n" +
+				"> quoted\n" +
+				">\n" +
+				"> text\n",
+		},
+		{
+			name:  "pre",
+			input: "<pre>a  b\n\tc</pre>",
+			expected: "a  b\n" +
+				"        c\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			opts := filters.Options{Width: test.width}
+			err := filters.HTML(strings.NewReader(test.input), &out, &opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != test.expected {
+				t.Errorf("got:\n%q\nexpected:\n%q", out.String(), test.expected)
+			}
+		})
+	}
+}
+
+func TestFilters_WrapPatch(t *testing.T) {
+	input := strings.Repeat("+ a long line added by a patch which must not be wrapped ", 3) + "\n"
+	opts := filters.Options{Subject: "[PATCH aerc v2] wrap: do not wrap patches"}
+	var buf bytes.Buffer
+	if err := filters.Wrap(strings.NewReader(input), &buf, &opts); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != input {
+		t.Errorf("got %q, expected %q", buf.String(), input)
+	}
+}
@@ -0,0 +1,383 @@
+package filters
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"git.sr.ht/~rockorager/vaxis"
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTML renders an HTML part as styled text. Links are numbered and listed at
+// the end of the text. Paragraphs are wrapped to the width of the viewer.
+func HTML(r io.Reader, w io.Writer, opts *Options) error {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return err
+	}
+	h := htmlRenderer{
+		w:        bufio.NewWriter(w),
+		opts:     opts,
+		newlines: 2,
+	}
+	h.walk(doc)
+	h.flush()
+	if len(h.links) > 0 {
+		h.blank(2)
+		h.padding()
+		for i, link := range h.links {
+			fmt.Fprintf(h.w, "[%d]: %s\n", i+1, h.link(link, link))
+		}
+	}
+	return h.w.Flush()
+}
+
+// htmlToken is a word of text. Lines are only wrapped between tokens that
+// are separated by spaces.
+type htmlToken struct {
+	text  string
+	style vaxis.Style
+	link  string
+	space bool
+}
+
+type htmlRenderer struct {
+	w    *bufio.Writer
+	opts *Options
+	// tokens of the current block
+	tokens []htmlToken
+	// line prefixes of the enclosing blocks
+	prefixes []string
+	// marker of the current list item, printed instead of the prefix on
+	// its first line
+	marker string
+	styles []vaxis.Style
+	links  []string
+	href   string
+	pre    int
+	space  bool
+	// number of newlines at the end of the output
+	newlines int
+	// number of newlines expected before the next line
+	blanks      int
+	blankPrefix string
+}
+
+func (h *htmlRenderer) style() vaxis.Style {
+	var s vaxis.Style
+	for _, st := range h.styles {
+		s.Attribute |= st.Attribute
+		if st.UnderlineStyle != vaxis.UnderlineOff {
+			s.UnderlineStyle = st.UnderlineStyle
+		}
+		if st.Foreground != 0 {
+			s.Foreground = st.Foreground
+		}
+		if st.Background != 0 {
+			s.Background = st.Background
+		}
+	}
+	return s
+}
+
+func (h *htmlRenderer) text(data string) {
+	if h.pre > 0 {
+		h.tokens = append(h.tokens, htmlToken{
+			text: data, style: h.style(), link: h.href,
+		})
+		return
+	}
+	if strings.TrimSpace(data) == "" {
+		h.space = h.space || data != ""
+		return
+	}
+	space := h.space || strings.IndexAny(data[:1], " \t\r\n\f") == 0
+	for _, word := range strings.Fields(data) {
+		h.tokens = append(h.tokens, htmlToken{
+			text: word, style: h.style(), link: h.href, space: space,
+		})
+		space = true
+	}
+	h.space = strings.ContainsAny(data[len(data)-1:], " \t\r\n\f")
+}
+
+// blank ensures that the next line is preceded by n newlines. The empty lines
+// are only printed before the next line so that the output does not end with
+// empty lines.
+func (h *htmlRenderer) blank(n int) {
+	h.flush()
+	if n > h.blanks {
+		if h.blanks <= h.newlines {
+			h.blankPrefix = strings.TrimRight(h.prefix(), " ")
+		}
+		h.blanks = n
+	}
+}
+
+func (h *htmlRenderer) padding() {
+	for ; h.newlines < h.blanks; h.newlines++ {
+		h.w.WriteString(h.blankPrefix + "\n")
+	}
+	h.blanks = 0
+}
+
+func (h *htmlRenderer) prefix() string {
+	return strings.Join(h.prefixes, "")
+}
+
+func (h *htmlRenderer) line(s string) {
+	prefix := h.prefix()
+	if h.marker != "" {
+		indent := strings.Repeat(" ", len(h.marker))
+		if strings.HasSuffix(prefix, indent) {
+			prefix = strings.TrimSuffix(prefix, indent) + h.marker
+		}
+		h.marker = ""
+	}
+	h.padding()
+	h.w.WriteString(prefix + s + "\n")
+	h.newlines = 1
+}
+
+func (h *htmlRenderer) link(text, url string) string {
+	if h.opts == nil || !h.opts.OSC8 || url == "" {
+		return text
+	}
+	return osc8(url, "") + text + osc8("", "")
+}
+
+func (h *htmlRenderer) render(t htmlToken) string {
+	seq := sgr(t.style)
+	if seq == "" {
+		return h.link(t.text, t.link)
+	}
+	return seq + h.link(t.text, t.link) + reset
+}
+
+// flush prints the pending tokens, wrapped to the viewer width.
+func (h *htmlRenderer) flush() {
+	tokens := h.tokens
+	h.tokens = nil
+	h.space = false
+	if len(tokens) == 0 {
+		return
+	}
+	if h.pre > 0 {
+		var text strings.Builder
+		for _, t := range tokens {
+			for i, l := range strings.Split(t.text, "\n") {
+				if i > 0 {
+					text.WriteString("\n")
+				}
+				if l != "" {
+					t.text = strings.ReplaceAll(l, "\t", "        ")
+					text.WriteString(h.render(t))
+				}
+			}
+		}
+		for _, l := range strings.Split(strings.Trim(text.String(), "\n"), "\n") {
+			h.line(l)
+		}
+		return
+	}
+
+	width := 0
+	if h.opts != nil && h.opts.Width > 0 {
+		width = max(h.opts.Width-runewidth.StringWidth(h.prefix()), 20)
+	}
+	var line strings.Builder
+	lineWidth := 0
+	for i := 0; i < len(tokens); {
+		// tokens which are not separated by spaces cannot be split
+		j, w := i+1, runewidth.StringWidth(tokens[i].text)
+		for ; j < len(tokens) && !tokens[j].space; j++ {
+			w += runewidth.StringWidth(tokens[j].text)
+		}
+		if lineWidth > 0 {
+			if width > 0 && lineWidth+1+w > width {
+				h.line(line.String())
+				line.Reset()
+				lineWidth = 0
+			} else {
+				line.WriteString(" ")
+				lineWidth++
+			}
+		}
+		for _, t := range tokens[i:j] {
+			line.WriteString(h.render(t))
+		}
+		lineWidth += w
+		i = j
+	}
+	h.line(line.String())
+}
+
+// blocks separated by an empty line
+var paragraphs = map[atom.Atom]bool{
+	atom.P: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true,
+	atom.H5: true, atom.H6: true, atom.Blockquote: true, atom.Pre: true,
+	atom.Ul: true, atom.Ol: true, atom.Dl: true, atom.Table: true,
+	atom.Hr: true, atom.Figure: true,
+}
+
+// blocks which start on a new line
+var blocks = map[atom.Atom]bool{
+	atom.Div: true, atom.Li: true, atom.Tr: true, atom.Dt: true,
+	atom.Dd: true, atom.Section: true, atom.Article: true, atom.Header: true,
+	atom.Footer: true, atom.Nav: true, atom.Main: true, atom.Aside: true,
+	atom.Address: true, atom.Center: true, atom.Form: true,
+	atom.Figcaption: true, atom.Caption: true,
+}
+
+func (h *htmlRenderer) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		h.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			h.walk(c)
+		}
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Head, atom.Script, atom.Style, atom.Title, atom.Template,
+		atom.Noscript, atom.Svg:
+		return
+	case atom.Br:
+		if len(h.tokens) == 0 {
+			h.line("")
+		}
+		h.flush()
+		return
+	case atom.Hr:
+		h.blank(2)
+		width := 40
+		if h.opts != nil && h.opts.Width > 0 {
+			width = min(h.opts.Width, 72)
+		}
+		h.line(strings.Repeat("─", width))
+		h.blank(2)
+		return
+	case atom.Img:
+		if alt := strings.TrimSpace(attr(n, "alt")); alt != "" {
+			h.text(" [" + alt + "] ")
+		}
+		return
+	case atom.Td, atom.Th:
+		h.space = true
+	}
+
+	switch {
+	case paragraphs[n.DataAtom]:
+		h.blank(2)
+	case blocks[n.DataAtom]:
+		h.blank(1)
+	}
+
+	popStyle := h.pushStyle(n)
+	popPrefix := false
+	switch n.DataAtom {
+	case atom.Blockquote:
+		h.prefixes = append(h.prefixes, "> ")
+		popPrefix = true
+	case atom.Pre:
+		h.pre++
+	case atom.Li:
+		marker := "* "
+		if n.Parent != nil && n.Parent.DataAtom == atom.Ol {
+			index := 1
+			for s := n.PrevSibling; s != nil; s = s.PrevSibling {
+				if s.Type == html.ElementNode && s.DataAtom == atom.Li {
+					index++
+				}
+			}
+			marker = fmt.Sprintf("%d. ", index)
+		}
+		h.prefixes = append(h.prefixes, strings.Repeat(" ", len(marker)))
+		h.marker = marker
+		popPrefix = true
+	case atom.Dd:
+		h.prefixes = append(h.prefixes, "    ")
+		popPrefix = true
+	}
+
+	href := ""
+	if n.DataAtom == atom.A {
+		href = strings.TrimSpace(attr(n, "href"))
+		if strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+			href = ""
+		}
+		if href != "" {
+			h.href = href
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		h.walk(c)
+	}
+
+	if href != "" {
+		h.href = ""
+		h.links = append(h.links, href)
+		h.tokens = append(h.tokens, htmlToken{
+			text: fmt.Sprintf("[%d]", len(h.links)),
+		})
+	}
+
+	switch {
+	case paragraphs[n.DataAtom]:
+		h.blank(2)
+	case blocks[n.DataAtom]:
+		h.blank(1)
+	}
+	if n.DataAtom == atom.Pre {
+		h.pre--
+	}
+	if popPrefix {
+		h.prefixes = h.prefixes[:len(h.prefixes)-1]
+		h.marker = ""
+	}
+	if popStyle {
+		h.styles = h.styles[:len(h.styles)-1]
+	}
+}
+
+func (h *htmlRenderer) pushStyle(n *html.Node) bool {
+	var s vaxis.Style
+	switch n.DataAtom {
+	case atom.B, atom.Strong, atom.Th, atom.Dt:
+		s.Attribute = vaxis.AttrBold
+	case atom.I, atom.Em, atom.Cite, atom.Var:
+		s.Attribute = vaxis.AttrItalic
+	case atom.U, atom.Ins:
+		s.UnderlineStyle = vaxis.UnderlineSingle
+	case atom.S, atom.Strike, atom.Del:
+		s.Attribute = vaxis.AttrStrikethrough
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		s = h.opts.style("header")
+	case atom.A:
+		s = h.opts.style("url")
+	case atom.Blockquote:
+		s = h.opts.style("quote_1")
+	default:
+		return false
+	}
+	h.styles = append(h.styles, s)
+	return true
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,337 @@
+package filters
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	// wrapMargin is the default margin of the wrap filter.
+	wrapMargin = 80
+	// wrapProseRatio is the minimum percentage of letters in a line for
+	// it to be considered as prose and be wrapped.
+	wrapProseRatio = 50
+	spacesPerTab   = 8
+	// wrapBufferSize is the maximum number of characters read at once.
+	// Longer lines are processed in chunks, like the wrap filter does.
+	wrapBufferSize = 8192
+)
+
+var patchSubject = regexp.MustCompile(`\bPATCH\b`)
+
+// Wrap reflows paragraphs of text/plain parts at 80 columns, equivalent to
+// the wrap filter. Quotes, list items and format=flowed text are handled.
+// Lines which do not look like prose are printed as-is. Patches are never
+// wrapped.
+func Wrap(r io.Reader, w io.Writer, opts *Options) error {
+	if opts != nil && patchSubject.MatchString(opts.Subject) {
+		_, err := io.Copy(w, r)
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	br := bufio.NewReader(r)
+	var cur *paragraph
+	for {
+		line, err := readChunk(br)
+		if line != "" {
+			next := parseParagraph(sanitizeLine(line))
+			switch {
+			case cur == nil:
+				cur = next
+			case cur.continuedBy(next):
+				cur.join(next)
+			default:
+				cur.write(bw)
+				cur = next
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if cur != nil {
+		cur.write(bw)
+	}
+	return bw.Flush()
+}
+
+// readChunk reads a line, or at most wrapBufferSize-1 characters of it.
+func readChunk(br *bufio.Reader) (string, error) {
+	var line strings.Builder
+	for n := 0; n < wrapBufferSize-1; n++ {
+		c, _, err := br.ReadRune()
+		if err != nil {
+			return line.String(), err
+		}
+		line.WriteRune(c)
+		if c == '\n' {
+			break
+		}
+	}
+	return line.String(), nil
+}
+
+type paragraph struct {
+	// quotes prefix, including trailing space
+	quotes string
+	// spaces to insert before continuation lines
+	indent string
+	// text without quotes, with list item prefix and indent
+	text       []rune
+	proseRatio int
+	flowed     bool
+	listItem   bool
+}
+
+// sanitizeLine trims the line ending and replaces tabs with spaces.
+func sanitizeLine(line string) []rune {
+	if i := strings.IndexAny(line, "\r\n"); i >= 0 {
+		line = line[:i]
+	}
+	line = strings.ReplaceAll(line, "\t", strings.Repeat(" ", spacesPerTab))
+	return []rune(line)
+}
+
+func at(buf []rune, i int) rune {
+	if i < len(buf) {
+		return buf[i]
+	}
+	return 0
+}
+
+// parseParagraph finds the relevant positions in a line:
+//
+//	'> > > >       2)       blah blah blah blah    '
+//	 ^       ^              ^                  ^
+//	 0       q              t                  e
+//	 <------><------------->
+//	  quotes     indent
+//	         <-------------------------------->
+//	                       text
+func parseParagraph(buf []rune) *paragraph {
+	q := 0
+	for at(buf, q) == '>' {
+		q++
+		if at(buf, q) == ' ' {
+			q++
+		}
+	}
+	t := q
+	for t < len(buf) && unicode.IsSpace(buf[t]) {
+		t++
+	}
+	i := listItemOffset(buf[t:])
+	t += i
+	for t < len(buf) && unicode.IsSpace(buf[t]) {
+		t++
+	}
+
+	letters := 0
+	for _, c := range buf[t:] {
+		if unicode.IsLetter(c) || isCJK(c, true) {
+			letters++
+		}
+	}
+	// strip trailing whitespace unless it is a signature delimiter
+	e := len(buf)
+	flowed := false
+	if string(buf[q:]) != "-- " {
+		for e > q && unicode.IsSpace(buf[e-1]) {
+			e--
+			flowed = true
+		}
+	}
+	textLen := e - q
+	if textLen == 0 {
+		textLen = 1
+	}
+	return &paragraph{
+		quotes:     string(buf[:q]),
+		indent:     strings.Repeat(" ", t-q),
+		text:       buf[q:e:e],
+		proseRatio: 100 * letters / textLen,
+		flowed:     flowed,
+		listItem:   i != 0,
+	}
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+// listItemOffset returns the length of the list item prefix at the start of
+// buf, or 0 if there is none.
+func listItemOffset(buf []rune) int {
+	i := 0
+	switch c := at(buf, 0); {
+	case c == '-' || c == '*' || c == '.':
+		// bullet list
+		i++
+	case isDigit(c):
+		// numbered list
+		i++
+		if isDigit(at(buf, i)) {
+			i++
+		}
+	case unicode.IsLetter(c):
+		// lettered list
+		c = unicode.ToLower(c)
+		i++
+		if c == 'i' || c == 'v' {
+			// roman i. ii. iii. iv. ...
+			c = unicode.ToLower(at(buf, i))
+			for i < 4 && (c == 'i' || c == 'v') {
+				i++
+				c = unicode.ToLower(at(buf, i))
+			}
+		}
+	default:
+		return 0
+	}
+	if c := at(buf, 0); isDigit(c) || unicode.IsLetter(c) {
+		switch at(buf, i) {
+		case ')', '/', '.':
+			i++
+		default:
+			return 0
+		}
+	}
+	if at(buf, i) != ' ' {
+		return 0
+	}
+	return i + 1
+}
+
+func isEmpty(s []rune) bool {
+	for _, c := range s {
+		if !unicode.IsSpace(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// continuedBy returns true if next should be joined to the paragraph.
+func (p *paragraph) continuedBy(next *paragraph) bool {
+	switch {
+	case next.listItem:
+		// new list items always start a new paragraph
+		return false
+	case next.proseRatio < wrapProseRatio || p.proseRatio < wrapProseRatio:
+		// does not look like prose, maybe ascii art
+		return false
+	case next.quotes != p.quotes:
+		return false
+	case next.indent != p.indent:
+		return false
+	case isEmpty(next.text):
+		return false
+	}
+	if text := string(p.text); text == "--" || text == "-- " {
+		// never join anything with signature start
+		return false
+	}
+	// trailing space indicates format=flowed
+	return p.flowed
+}
+
+func (p *paragraph) join(next *paragraph) {
+	appended := next.text
+	for len(appended) > 0 && unicode.IsSpace(appended[0]) {
+		appended = appended[1:]
+	}
+	if len(p.text) > 0 {
+		p.text = append(p.text, ' ')
+	}
+	p.text = append(p.text, appended...)
+	p.proseRatio = (p.proseRatio + next.proseRatio) / 2
+	p.flowed = next.flowed
+}
+
+func runesWidth(s []rune) int {
+	w := 0
+	for _, c := range s {
+		w += runewidth.RuneWidth(c)
+	}
+	return w
+}
+
+// isSplitPoint returns true if a line can be split at the given character.
+func isSplitPoint(c rune) bool {
+	return unicode.IsSpace(c) || isCJK(c, false)
+}
+
+// write prints the paragraph, wrapping at word boundaries. Only text which
+// looks like prose is wrapped.
+func (p *paragraph) write(w *bufio.Writer) {
+	quotesWidth := runewidth.StringWidth(p.quotes)
+	remain := runesWidth(p.text)
+	indent := ""
+	text := p.text
+	for more := true; more; indent = p.indent {
+		width := quotesWidth + runewidth.StringWidth(indent)
+		line := text
+		more = false
+		if width+remain > wrapMargin && p.proseRatio >= wrapProseRatio {
+			// find split point, preferably before margin
+			split := -1
+			lineWidth := 0
+			for i, c := range text {
+				lineWidth += runewidth.RuneWidth(c)
+				if width+lineWidth > wrapMargin && split != -1 {
+					break
+				}
+				if isSplitPoint(c) {
+					split = i
+				}
+			}
+			if split != -1 {
+				line = text[:split]
+				// find start of next word
+				for split < len(text) && unicode.IsSpace(text[split]) {
+					split++
+				}
+				if split < len(text) {
+					remain -= runesWidth(text[:split])
+					text = text[split:]
+					more = true
+				}
+			}
+		}
+		w.WriteString(p.quotes)
+		w.WriteString(indent)
+		w.WriteString(string(line))
+		w.WriteByte('\n')
+	}
+}
+
+func isCJK(c rune, syllables bool) bool {
+	switch {
+	case c >= 0x2e80 && c <= 0x2fd5: // CJK Radicals Supplement
+	case c >= 0x3300 && c <= 0x33ff: // CJK Compatibility
+	case c >= 0x3400 && c <= 0x4db5: // CJK Unified Ideographs Extension A
+	case c >= 0x4e00 && c <= 0x9fcb: // CJK Unified Ideographs
+	case c >= 0xf900 && c <= 0xfa6a: // CJK Compatibility Ideographs
+	case c >= 0x1100 && c <= 0x11ff: // Hangul Jamo
+	case c >= 0x3130 && c <= 0x318f: // Hangul Compatibility Jamo
+	case c >= 0xa960 && c <= 0xa97f: // Hangul Jamo Extended-A
+	case c >= 0xd7b0 && c <= 0xd7ff: // Hangul Jamo Extended-B
+	case !syllables:
+		return false
+	case c >= 0x3040 && c <= 0x309f: // Japanese Hiragana
+	case c >= 0x30a0 && c <= 0x30ff: // Japanese Katakana
+	case c >= 0xac00 && c <= 0xd7af: // Hangul Syllables
+	default:
+		return false
+	}
+	return true
+}
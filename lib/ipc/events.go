@@ -0,0 +1,91 @@
+package ipc
+
+import (
+	"sync"
+	"time"
+)
+
+// Events sent to subscribed clients.
+const (
+	// A new message was received. Message is set.
+	EventNewMail = "new-mail"
+	// The flags of messages were changed. Flag is set.
+	EventFlagChanged = "flag-changed"
+	// Another folder was opened.
+	EventFolderChanged = "folder-changed"
+	// A message was sent, or sending it failed. Subject is set and Error
+	// is set on failure.
+	EventMailSent = "mail-sent"
+)
+
+var Events = []string{
+	EventNewMail,
+	EventFlagChanged,
+	EventFolderChanged,
+	EventMailSent,
+}
+
+type Event struct {
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Account string    `json:"account,omitempty"`
+	Folder  string    `json:"folder,omitempty"`
+	Message *Message  `json:"message,omitempty"`
+	Flag    string    `json:"flag,omitempty"`
+	Subject string    `json:"subject,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+type subscriber struct {
+	events map[string]bool
+	ch     chan *Event
+}
+
+var (
+	subscribersLock sync.Mutex
+	subscribers     = make(map[*subscriber]struct{})
+)
+
+// subscribe registers a new event subscriber. Without event types, all events
+// are sent.
+func subscribe(types []string) *subscriber {
+	sub := &subscriber{
+		events: make(map[string]bool),
+		ch:     make(chan *Event, 64),
+	}
+	for _, t := range types {
+		sub.events[t] = true
+	}
+	subscribersLock.Lock()
+	subscribers[sub] = struct{}{}
+	subscribersLock.Unlock()
+	return sub
+}
+
+func (sub *subscriber) close() {
+	subscribersLock.Lock()
+	defer subscribersLock.Unlock()
+	if _, ok := subscribers[sub]; ok {
+		delete(subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// Publish sends an event to all subscribed clients. Events are dropped for
+// clients which do not read them fast enough.
+func Publish(event *Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	subscribersLock.Lock()
+	defer subscribersLock.Unlock()
+	for sub := range subscribers {
+		if len(sub.events) > 0 && !sub.events[event.Type] {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+		}
+	}
+}
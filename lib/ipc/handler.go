@@ -1,5 +1,21 @@
 package ipc
 
+import "encoding/json"
+
 type Handler interface {
 	Command(args []string) error
+	// Query answers the JSON-RPC methods which read the state of aerc.
+	// Unknown methods must return an *RPCError with CodeMethodNotFound.
+	Query(method string, params json.RawMessage) (any, error)
+}
+
+// Attacher is implemented by handlers which run without a user interface and
+// can display it on the terminal of a client.
+type Attacher interface {
+	// Attach displays the user interface on the given terminal device. The
+	// returned channel is closed when the user interface is detached by
+	// aerc. The detach function must be called when the client is gone.
+	Attach(tty string) (detached <-chan struct{}, detach func(), err error)
+	// Resize is called when the terminal of the client is resized.
+	Resize()
 }
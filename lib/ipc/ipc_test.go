@@ -0,0 +1,247 @@
+package ipc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/lib/xdg"
+)
+
+type testHandler struct {
+	commands chan []string
+}
+
+func (h *testHandler) Command(args []string) error {
+	h.commands <- args
+	if len(args) > 0 && args[0] == "fail" {
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func (h *testHandler) Query(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case MethodAccounts:
+		return []*Account{{Name: "a", Connected: true, Selected: true}}, nil
+	case MethodMessage:
+		return nil, nil
+	}
+	return nil, &RPCError{Code: CodeMethodNotFound, Message: method}
+}
+
+func startTestServer(t *testing.T) *testHandler {
+	t.Helper()
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	h := &testHandler{commands: make(chan []string, 10)}
+	startup, done := context.WithCancel(context.Background())
+	done()
+	as, err := StartServer(h, startup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(as.Close)
+	return h
+}
+
+func TestLegacyCommand(t *testing.T) {
+	h := startTestServer(t)
+	resp, err := ConnectAndExec([]string{":quit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != "" {
+		t.Errorf("unexpected error %q", resp.Error)
+	}
+	if args := <-h.commands; !reflect.DeepEqual(args, []string{":quit"}) {
+		t.Errorf("unexpected arguments %v", args)
+	}
+}
+
+func TestCall(t *testing.T) {
+	h := startTestServer(t)
+
+	var version VersionInfo
+	if err := Call(MethodVersion, nil, &version); err != nil {
+		t.Fatal(err)
+	}
+	if version.API != Version {
+		t.Errorf("unexpected version %d", version.API)
+	}
+
+	var accounts []*Account
+	if err := Call(MethodAccounts, nil, &accounts); err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0].Name != "a" {
+		t.Errorf("unexpected accounts %+v", accounts)
+	}
+
+	var msg *Message
+	if err := Call(MethodMessage, nil, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg != nil {
+		t.Errorf("expected no message, got %+v", msg)
+	}
+
+	err := Call(MethodCommand, &CommandParams{Arguments: []string{"fail"}}, nil)
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeFailed {
+		t.Errorf("expected command failure, got %v", err)
+	}
+	<-h.commands
+
+	err = Call("bogus", nil, nil)
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeMethodNotFound {
+		t.Errorf("expected unknown method, got %v", err)
+	}
+
+	err = Call(MethodSubscribe, &SubscribeParams{Events: []string{"bogus"}}, nil)
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeInvalidParams {
+		t.Errorf("expected invalid params, got %v", err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	startTestServer(t)
+	conn, err := net.Dial("unix", xdg.RuntimePath("aerc.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	err = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = conn.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"subscribe",` +
+		`"params":{"events":["new-mail"]}}` + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(conn)
+	if !scanner.Scan() {
+		t.Fatal("no response")
+	}
+	var resp struct {
+		Result []string `json:"result"`
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resp.Result, []string{EventNewMail}) {
+		t.Fatalf("unexpected subscription %v", resp.Result)
+	}
+
+	// filtered out
+	Publish(&Event{Type: EventMailSent, Subject: "sent"})
+	Publish(&Event{Type: EventNewMail, Account: "a"})
+
+	if !scanner.Scan() {
+		t.Fatal("no event")
+	}
+	var n Notification
+	if err := json.Unmarshal(scanner.Bytes(), &n); err != nil {
+		t.Fatal(err)
+	}
+	if n.Method != MethodEvent || n.Params == nil ||
+		n.Params.Type != EventNewMail || n.Params.Account != "a" {
+		t.Errorf("unexpected notification %s", scanner.Text())
+	}
+}
+
+type testAttacher struct {
+	testHandler
+	tty      chan string
+	resized  chan struct{}
+	detached chan struct{}
+	gone     chan struct{}
+}
+
+func (a *testAttacher) Attach(tty string) (<-chan struct{}, func(), error) {
+	a.tty <- tty
+	return a.detached, func() { close(a.gone) }, nil
+}
+
+func (a *testAttacher) Resize() {
+	a.resized <- struct{}{}
+}
+
+func testTTY() (string, error) {
+	return "/dev/pts/42", nil
+}
+
+func TestAttachNoSocket(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	err := Attach(func() (string, error) {
+		t.Error("tty resolved without a daemon")
+		return "", nil
+	}, nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestAttachNoDaemon(t *testing.T) {
+	startTestServer(t)
+	err := Attach(testTTY, nil)
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeMethodNotFound {
+		t.Fatalf("expected unknown method, got %v", err)
+	}
+}
+
+func TestAttach(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	a := &testAttacher{
+		tty:      make(chan string, 1),
+		resized:  make(chan struct{}, 1),
+		detached: make(chan struct{}),
+		gone:     make(chan struct{}),
+	}
+	startup, done := context.WithCancel(context.Background())
+	done()
+	as, err := StartServer(a, startup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer as.Close()
+
+	resize := make(chan os.Signal, 1)
+	res := make(chan error, 1)
+	go func() {
+		res <- Attach(testTTY, resize)
+	}()
+	if tty := <-a.tty; tty != "/dev/pts/42" {
+		t.Errorf("unexpected tty %q", tty)
+	}
+	resize <- syscall.SIGWINCH
+	select {
+	case <-a.resized:
+	case <-time.After(5 * time.Second):
+		t.Fatal("resize not received")
+	}
+
+	close(a.detached)
+	select {
+	case err := <-res:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("client still attached")
+	}
+	select {
+	case <-a.gone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("detach not called")
+	}
+}
@@ -4,10 +4,19 @@ import "encoding/json"
 
 // Request constains all parameters needed for the main instance to respond to
 // a request.
+//
+// Requests which have a Method follow the JSON-RPC 2.0 format and are answered
+// with an RPCResponse. Other requests are legacy commands answered with a
+// Response.
 type Request struct {
 	// Arguments contains the commandline arguments. The detection of what
 	// action to take is left to the receiver.
-	Arguments []string `json:"arguments"`
+	Arguments []string `json:"arguments,omitempty"`
+
+	JSONRPC string          `json:"jsonrpc,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
 }
 
 // Response is used to report the results of a command.
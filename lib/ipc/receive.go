@@ -3,9 +3,13 @@ package ipc
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net"
 	"os"
+	"slices"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -58,41 +62,162 @@ func (as *AercServer) Serve() {
 			continue
 		}
 
-		defer conn.Close()
 		clientId := atomic.AddInt64(&lastId, 1)
 		log.Debugf("unix:%d accepted connection", clientId)
-		scanner := bufio.NewScanner(conn)
-		err = conn.SetDeadline(time.Now().Add(1 * time.Minute))
+		go as.serveConn(conn, clientId)
+	}
+}
+
+func (as *AercServer) serveConn(conn net.Conn, clientId int64) {
+	defer log.PanicHandler()
+	defer conn.Close()
+
+	// events are written concurrently with responses
+	var writeLock sync.Mutex
+	write := func(v any) error {
+		b, err := json.Marshal(v)
 		if err != nil {
-			log.Errorf("unix:%d failed to set deadline: %v", clientId, err)
+			return err
+		}
+		writeLock.Lock()
+		defer writeLock.Unlock()
+		_, err = conn.Write(append(b, '\n'))
+		return err
+	}
+
+	var sub *subscriber
+	var detach func()
+	defer func() {
+		if sub != nil {
+			sub.close()
 		}
-		for scanner.Scan() {
+		if detach != nil {
+			detach()
+		}
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	err := conn.SetDeadline(time.Now().Add(1 * time.Minute))
+	if err != nil {
+		log.Errorf("unix:%d failed to set deadline: %v", clientId, err)
+	}
+	for scanner.Scan() {
+		if sub == nil && detach == nil {
 			// allow up to 1 minute between commands
 			err = conn.SetDeadline(time.Now().Add(1 * time.Minute))
 			if err != nil {
 				log.Errorf("unix:%d failed to update deadline: %v", clientId, err)
 			}
-			msg, err := DecodeRequest(scanner.Bytes())
-			log.Tracef("unix:%d got message %s", clientId, scanner.Text())
+		}
+		msg, err := DecodeRequest(scanner.Bytes())
+		log.Tracef("unix:%d got message %s", clientId, scanner.Text())
+		if err != nil {
+			log.Errorf("unix:%d failed to parse request: %v", clientId, err)
+			err = write(&RPCResponse{
+				JSONRPC: "2.0",
+				ID:      json.RawMessage("null"),
+				Error:   &RPCError{Code: CodeParseError, Message: err.Error()},
+			})
 			if err != nil {
-				log.Errorf("unix:%d failed to parse request: %v", clientId, err)
-				continue
+				break
 			}
+			continue
+		}
 
-			response := as.handleMessage(msg)
-			result, err := response.Encode()
-			if err != nil {
-				log.Errorf("unix:%d failed to encode result: %v", clientId, err)
-				continue
+		var response any
+		var subscribed *subscriber
+		var detached <-chan struct{}
+		var attachedDetach func()
+		switch msg.Method {
+		case "":
+			response = as.handleMessage(msg)
+		case MethodSubscribe:
+			var resp *RPCResponse
+			resp, subscribed = as.handleSubscribe(msg)
+			response = resp
+		case MethodUnsubscribe:
+			if sub != nil {
+				sub.close()
+				sub = nil
+			}
+			response = rpcResult(msg, true)
+		case MethodAttach:
+			if detach != nil {
+				response = rpcError(msg, errors.New("already attached"))
+				break
 			}
-			_, err = conn.Write(append(result, '\n'))
+			var resp *RPCResponse
+			resp, detached, attachedDetach = as.handleAttach(msg)
+			response = resp
+		case MethodResize:
+			if a, ok := as.handler.(Attacher); ok && detach != nil {
+				a.Resize()
+			}
+			response = rpcResult(msg, true)
+		default:
+			response = as.handleCall(msg)
+		}
+
+		if msg.Method != "" && msg.ID == nil {
+			// JSON-RPC notifications are not answered
+			response = nil
+		}
+		if response != nil {
+			err = write(response)
 			if err != nil {
 				log.Errorf("unix:%d failed to send response: %v", clientId, err)
+				if subscribed != nil {
+					subscribed.close()
+				}
+				if attachedDetach != nil {
+					attachedDetach()
+				}
 				break
 			}
 		}
-		log.Tracef("unix:%d closed connection", clientId)
+
+		if subscribed != nil {
+			if sub != nil {
+				sub.close()
+			}
+			sub = subscribed
+			// subscribed clients stay connected
+			err = conn.SetDeadline(time.Time{})
+			if err != nil {
+				log.Errorf("unix:%d failed to clear deadline: %v", clientId, err)
+			}
+			go func(sub *subscriber) {
+				defer log.PanicHandler()
+				for event := range sub.ch {
+					err := write(&Notification{
+						JSONRPC: "2.0",
+						Method:  MethodEvent,
+						Params:  event,
+					})
+					if err != nil {
+						log.Debugf("unix:%d failed to send event: %v", clientId, err)
+						conn.Close()
+						return
+					}
+				}
+			}(sub)
+		}
+
+		if detached != nil {
+			detach = attachedDetach
+			// the client stays connected until the terminal is detached
+			err = conn.SetDeadline(time.Time{})
+			if err != nil {
+				log.Errorf("unix:%d failed to clear deadline: %v", clientId, err)
+			}
+			go func() {
+				defer log.PanicHandler()
+				<-detached
+				conn.Close()
+			}()
+		}
 	}
+	log.Tracef("unix:%d closed connection", clientId)
 }
 
 func (as *AercServer) handleMessage(req *Request) *Response {
@@ -102,3 +227,85 @@ func (as *AercServer) handleMessage(req *Request) *Response {
 	}
 	return &Response{}
 }
+
+func rpcResult(req *Request, result any) *RPCResponse {
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	return &RPCResponse{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+func rpcError(req *Request, err error) *RPCResponse {
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		rpcErr = &RPCError{Code: CodeFailed, Message: err.Error()}
+	}
+	return &RPCResponse{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
+}
+
+func invalidParams(err error) error {
+	return &RPCError{Code: CodeInvalidParams, Message: err.Error()}
+}
+
+func (as *AercServer) handleCall(req *Request) *RPCResponse {
+	switch req.Method {
+	case MethodVersion:
+		return rpcResult(req, &VersionInfo{API: Version, Aerc: log.BuildInfo})
+	case MethodCommand:
+		var params CommandParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return rpcError(req, invalidParams(err))
+		}
+		if err := as.handler.Command(params.Arguments); err != nil {
+			return rpcError(req, err)
+		}
+		return rpcResult(req, true)
+	}
+	result, err := as.handler.Query(req.Method, req.Params)
+	if err != nil {
+		return rpcError(req, err)
+	}
+	return rpcResult(req, result)
+}
+
+func (as *AercServer) handleSubscribe(req *Request) (*RPCResponse, *subscriber) {
+	var params SubscribeParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return rpcError(req, invalidParams(err)), nil
+		}
+	}
+	for _, event := range params.Events {
+		if !slices.Contains(Events, event) {
+			return rpcError(req, invalidParams(
+				fmt.Errorf("unknown event %q", event))), nil
+		}
+	}
+	events := params.Events
+	if len(events) == 0 {
+		events = Events
+	}
+	return rpcResult(req, events), subscribe(params.Events)
+}
+
+func (as *AercServer) handleAttach(req *Request) (*RPCResponse, <-chan struct{}, func()) {
+	attacher, ok := as.handler.(Attacher)
+	if !ok {
+		return rpcError(req, &RPCError{
+			Code:    CodeMethodNotFound,
+			Message: "aerc is not running as a daemon",
+		}), nil, nil
+	}
+	var params AttachParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return rpcError(req, invalidParams(err)), nil, nil
+	}
+	if params.TTY == "" {
+		return rpcError(req, invalidParams(errors.New("tty is required"))), nil, nil
+	}
+	detached, detach, err := attacher.Attach(params.TTY)
+	if err != nil {
+		return rpcError(req, err), nil, nil
+	}
+	return rpcResult(req, true), detached, detach
+}
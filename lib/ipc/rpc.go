@@ -0,0 +1,135 @@
+package ipc
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Version of the JSON-RPC API. It is increased when incompatible changes are
+// made to the methods, their parameters, results or to the events.
+const Version = 1
+
+// Methods of the JSON-RPC API.
+const (
+	// Returns VersionInfo.
+	MethodVersion = "version"
+	// Runs a command. Params is CommandParams, returns nothing.
+	MethodCommand = "command"
+	// Returns []Account.
+	MethodAccounts = "accounts"
+	// Params is FoldersParams, returns []Folder.
+	MethodFolders = "folders"
+	// Returns the selected Message, or nothing.
+	MethodMessage = "message"
+	// Returns []Tab.
+	MethodTabs = "tabs"
+	// Params is SubscribeParams. Returns the subscribed events and then
+	// sends them as notifications on the same connection.
+	MethodSubscribe = "subscribe"
+	// Stops sending events on the connection.
+	MethodUnsubscribe = "unsubscribe"
+	// Method of the event notifications. Params is an Event.
+	MethodEvent = "event"
+	// Params is AttachParams. Displays the user interface of an aerc
+	// daemon on a terminal until the connection is closed.
+	MethodAttach = "attach"
+	// Notification sent by attached clients when their terminal is
+	// resized.
+	MethodResize = "resize"
+)
+
+// Standard JSON-RPC error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	// Error returned by a command or a query.
+	CodeFailed = 1
+)
+
+// RPCResponse is the response to a JSON-RPC request.
+type RPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// Notification is a JSON-RPC request without ID sent to subscribed clients.
+type Notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  *Event `json:"params"`
+}
+
+type VersionInfo struct {
+	API  int    `json:"api"`
+	Aerc string `json:"aerc"`
+}
+
+type CommandParams struct {
+	Arguments []string `json:"arguments"`
+}
+
+type FoldersParams struct {
+	// Defaults to the selected account.
+	Account string `json:"account,omitempty"`
+}
+
+type SubscribeParams struct {
+	// Defaults to all events.
+	Events []string `json:"events,omitempty"`
+}
+
+type AttachParams struct {
+	// Path of the terminal device.
+	TTY string `json:"tty"`
+}
+
+type Account struct {
+	Name      string `json:"name"`
+	Backend   string `json:"backend"`
+	Connected bool   `json:"connected"`
+	Folder    string `json:"folder"`
+	Selected  bool   `json:"selected"`
+}
+
+type Folder struct {
+	Name     string `json:"name"`
+	Role     string `json:"role,omitempty"`
+	Exists   int    `json:"exists"`
+	Unseen   int    `json:"unseen"`
+	Recent   int    `json:"recent"`
+	Selected bool   `json:"selected"`
+}
+
+type Message struct {
+	Account   string    `json:"account"`
+	Folder    string    `json:"folder"`
+	UID       string    `json:"uid"`
+	MessageID string    `json:"message_id"`
+	Date      time.Time `json:"date"`
+	Subject   string    `json:"subject"`
+	From      []string  `json:"from"`
+	To        []string  `json:"to"`
+	Cc        []string  `json:"cc,omitempty"`
+	Flags     []string  `json:"flags"`
+	Labels    []string  `json:"labels,omitempty"`
+}
+
+type Tab struct {
+	Title    string `json:"title"`
+	Kind     string `json:"kind"`
+	Selected bool   `json:"selected"`
+}
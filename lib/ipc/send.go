@@ -2,10 +2,13 @@ package ipc
 
 import (
 	"bufio"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
+	"os"
 
+	"git.sr.ht/~rjarry/aerc/lib/log"
 	"git.sr.ht/~rjarry/aerc/lib/xdg"
 )
 
@@ -37,3 +40,115 @@ func ConnectAndExec(args []string) (*Response, error) {
 
 	return resp, nil
 }
+
+// Call sends a JSON-RPC request to the running aerc instance and decodes its
+// result into result.
+func Call(method string, params any, result any) error {
+	sockpath := xdg.RuntimePath("aerc.sock")
+	conn, err := net.Dial("unix", sockpath)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	req := Request{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: method}
+	if params != nil {
+		req.Params, err = json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("failed to encode params: %w", err)
+		}
+	}
+	buf, err := req.Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	_, err = conn.Write(append(buf, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	scanner := bufio.NewScanner(conn)
+	if !scanner.Scan() {
+		return errors.New("No response from server")
+	}
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *RPCError       `json:"error"`
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result != nil {
+		return json.Unmarshal(resp.Result, result)
+	}
+	return nil
+}
+
+// Attach displays the user interface of the running aerc daemon on the
+// terminal device returned by tty. It returns once the user interface is
+// detached. The terminal device is only resolved when the daemon socket
+// accepts the connection. The daemon is notified for each signal received on
+// resize.
+func Attach(tty func() (string, error), resize <-chan os.Signal) error {
+	sockpath := xdg.RuntimePath("aerc.sock")
+	conn, err := net.Dial("unix", sockpath)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	path, err := tty()
+	if err != nil {
+		return err
+	}
+	req := Request{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: MethodAttach}
+	req.Params, err = json.Marshal(&AttachParams{TTY: path})
+	if err != nil {
+		return fmt.Errorf("failed to encode params: %w", err)
+	}
+	buf, err := req.Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	_, err = conn.Write(append(buf, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	scanner := bufio.NewScanner(conn)
+	if !scanner.Scan() {
+		return errors.New("No response from server")
+	}
+	var resp struct {
+		Error *RPCError `json:"error"`
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer log.PanicHandler()
+		notification := []byte(`{"jsonrpc":"2.0","method":"` + MethodResize + `"}` + "\n")
+		for {
+			select {
+			case <-resize:
+				if _, err := conn.Write(notification); err != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// the connection is closed when the user interface is detached
+	for scanner.Scan() {
+	}
+	return nil
+}
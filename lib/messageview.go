@@ -75,7 +75,9 @@ func NewMessageStoreView(messageInfo *models.MessageInfo, setSeen bool,
 	cb := func(msv MessageView, err error) {
 		if msv != nil && setSeen && err == nil &&
 			!messageInfo.Flags.Has(models.SeenFlag) {
-			store.Flag([]models.UID{messageInfo.Uid}, models.SeenFlag, true, nil)
+			// reading a message is not an operation worth undoing
+			store.flag([]models.UID{messageInfo.Uid}, models.SeenFlag,
+				true, false, nil)
 		}
 		innerCb(msv, err)
 	}
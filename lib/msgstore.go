@@ -36,6 +36,8 @@ type MessageStore struct {
 	// Visible UIDs
 	scrollOffset int
 	scrollLen    int
+	// Fetch the headers of new messages even if they are not visible
+	headless bool
 
 	selectedUid   models.UID
 	bodyCallbacks map[models.UID][]func(*types.FullMessage)
@@ -85,6 +87,8 @@ type MessageStore struct {
 
 	iterFactory iterator.Factory
 	onSelect    func(*models.MessageInfo)
+
+	undo *UndoJournal
 }
 
 const MagicUid = models.UID("")
@@ -159,6 +163,20 @@ func (store *MessageStore) UpdateScroll(offset, length int) {
 	store.scrollLen = length
 }
 
+// SetHeadless makes the store fetch the headers of the messages received after
+// the directory contents were loaded, even when no message list displays them.
+// This is required for the new mail hooks to be triggered without a user
+// interface.
+func (store *MessageStore) SetHeadless(headless bool) {
+	store.headless = headless
+}
+
+// post sends an action to the worker. It is performed in the folder of the
+// store, even if another folder is selected.
+func (store *MessageStore) post(msg types.WorkerMessage, cb func(types.WorkerMessage)) {
+	store.worker.PostAction(types.InFolder(msg, store.Name), cb)
+}
+
 func (store *MessageStore) FetchHeaders(uids []models.UID,
 	cb func(types.WorkerMessage),
 ) {
@@ -172,7 +190,7 @@ func (store *MessageStore) FetchHeaders(uids []models.UID,
 		}
 	}
 	if len(toFetch) > 0 {
-		store.worker.PostAction(&types.FetchMessageHeaders{
+		store.post(&types.FetchMessageHeaders{
 			Context: store.ctx,
 			Uids:    toFetch,
 		},
@@ -208,7 +226,7 @@ func (store *MessageStore) FetchFull(uids []models.UID, cb func(*types.FullMessa
 		}
 	}
 	if len(toFetch) > 0 {
-		store.worker.PostAction(&types.FetchFullMessages{
+		store.post(&types.FetchFullMessages{
 			Uids: toFetch,
 		}, func(msg types.WorkerMessage) {
 			if _, ok := msg.(*types.Error); ok {
@@ -222,7 +240,7 @@ func (store *MessageStore) FetchFull(uids []models.UID, cb func(*types.FullMessa
 }
 
 func (store *MessageStore) FetchBodyPart(uid models.UID, part []int, cb func(io.Reader)) {
-	store.worker.PostAction(&types.FetchMessageBodyPart{
+	store.post(&types.FetchMessageBodyPart{
 		Uid:  uid,
 		Part: part,
 	}, func(resp types.WorkerMessage) {
@@ -274,7 +292,8 @@ func (store *MessageStore) Update(msg types.WorkerMessage) {
 			} else {
 				newMap[uid] = nil
 				directoryChange = true
-				if i >= start && i < end {
+				if (i >= start && i < end) ||
+					(store.headless && directoryContentsWasLoaded) {
 					newUids = append(newUids, uid)
 				}
 			}
@@ -301,7 +320,8 @@ func (store *MessageStore) Update(msg types.WorkerMessage) {
 			} else {
 				newMap[uid] = nil
 				directoryChange = true
-				if i >= start && i < end {
+				if (i >= start && i < end) ||
+					(store.headless && directoryContentsWasLoaded) {
 					newUids = append(newUids, uid)
 				}
 			}
@@ -606,15 +626,19 @@ func (store *MessageStore) Delete(uids []models.UID, mfs *types.MultiFileStrateg
 		store.Deleted[uid] = nil
 	}
 
-	store.worker.PostAction(&types.DeleteMessages{Uids: uids, MultiFileStrategy: mfs},
+	entry := store.recordDelete(uids)
+	store.post(&types.DeleteMessages{Uids: uids, MultiFileStrategy: mfs},
 		func(msg types.WorkerMessage) {
 			if _, ok := msg.(*types.Error); ok {
 				store.revertDeleted(uids)
+				store.undo.Discard(entry)
 			}
 			if _, ok := msg.(*types.Unsupported); ok {
 				store.revertDeleted(uids)
+				store.undo.Discard(entry)
 			}
 			if _, ok := msg.(*types.Done); ok {
+				store.undo.Commit(entry)
 				store.triggerMailDeleted()
 			}
 			cb(msg)
@@ -637,7 +661,7 @@ func (store *MessageStore) Copy(uids []models.UID, dest string, createDest bool,
 		}, cb)
 	}
 
-	store.worker.PostAction(&types.CopyMessages{
+	store.post(&types.CopyMessages{
 		Destination:       dest,
 		Uids:              uids,
 		MultiFileStrategy: mfs,
@@ -663,7 +687,8 @@ func (store *MessageStore) Move(uids []models.UID, dest string, createDest bool,
 		}, nil) // quiet doesn't return an error, don't want the done cb here
 	}
 
-	store.worker.PostAction(&types.MoveMessages{
+	entry := store.recordMove(uids, dest)
+	store.post(&types.MoveMessages{
 		Destination:       dest,
 		Uids:              uids,
 		MultiFileStrategy: mfs,
@@ -671,8 +696,10 @@ func (store *MessageStore) Move(uids []models.UID, dest string, createDest bool,
 		switch msg.(type) {
 		case *types.Error:
 			store.revertDeleted(uids)
+			store.undo.Discard(entry)
 			cb(msg)
 		case *types.Done:
+			store.undo.Commit(entry)
 			store.triggerMailDeleted()
 			store.triggerMailAdded(dest)
 			cb(msg)
@@ -705,7 +732,19 @@ func (store *MessageStore) Append(dest string, flags models.Flags, date time.Tim
 func (store *MessageStore) Flag(uids []models.UID, flags models.Flags,
 	enable bool, cb func(msg types.WorkerMessage),
 ) {
-	store.worker.PostAction(&types.FlagMessages{
+	store.flag(uids, flags, enable, true, cb)
+}
+
+// flag changes the flags of messages. If journal is false, the operation is
+// not recorded in the undo journal.
+func (store *MessageStore) flag(uids []models.UID, flags models.Flags,
+	enable bool, journal bool, cb func(msg types.WorkerMessage),
+) {
+	var entry *UndoEntry
+	if journal {
+		entry = store.recordFlag(uids, flags, enable)
+	}
+	store.post(&types.FlagMessages{
 		Enable: enable,
 		Flags:  flags,
 		Uids:   uids,
@@ -723,8 +762,12 @@ func (store *MessageStore) Flag(uids []models.UID, flags models.Flags,
 		case models.DraftFlag:
 			flagName = "draft"
 		}
-		if _, ok := msg.(*types.Done); ok {
+		switch msg.(type) {
+		case *types.Done:
+			store.undo.Commit(entry)
 			store.triggerFlagChanged(flagName)
+		case *types.Error, *types.Unsupported:
+			store.undo.Discard(entry)
 		}
 		if cb != nil {
 			cb(msg)
@@ -735,7 +778,7 @@ func (store *MessageStore) Flag(uids []models.UID, flags models.Flags,
 func (store *MessageStore) Answered(uids []models.UID, answered bool,
 	cb func(msg types.WorkerMessage),
 ) {
-	store.worker.PostAction(&types.AnsweredMessages{
+	store.post(&types.AnsweredMessages{
 		Answered: answered,
 		Uids:     uids,
 	}, cb)
@@ -744,7 +787,7 @@ func (store *MessageStore) Answered(uids []models.UID, answered bool,
 func (store *MessageStore) Forwarded(uids []models.UID, forwarded bool,
 	cb func(msg types.WorkerMessage),
 ) {
-	store.worker.PostAction(&types.ForwardedMessages{
+	store.post(&types.ForwardedMessages{
 		Forwarded: forwarded,
 		Uids:      uids,
 	}, cb)
@@ -848,7 +891,7 @@ func (store *MessageStore) Prev() {
 }
 
 func (store *MessageStore) Search(terms *types.SearchCriteria, cb func([]models.UID)) {
-	store.worker.PostAction(&types.SearchDirectory{
+	store.post(&types.SearchDirectory{
 		Context:  store.ctx,
 		Criteria: terms,
 	}, func(msg types.WorkerMessage) {
@@ -941,13 +984,18 @@ func (store *MessageStore) PrevResult() {
 func (store *MessageStore) ModifyLabels(uids []models.UID, add, remove []string,
 	cb func(msg types.WorkerMessage),
 ) {
-	store.worker.PostAction(&types.ModifyLabels{
+	entry := store.recordLabels(uids, add, remove)
+	store.post(&types.ModifyLabels{
 		Uids:   uids,
 		Add:    add,
 		Remove: remove,
 	}, func(msg types.WorkerMessage) {
-		if _, ok := msg.(*types.Done); ok {
+		switch msg.(type) {
+		case *types.Done:
+			store.undo.Commit(entry)
 			store.triggerTagModified(add, remove)
+		case *types.Error, *types.Unsupported:
+			store.undo.Discard(entry)
 		}
 		cb(msg)
 	})
@@ -971,14 +1019,14 @@ func (store *MessageStore) Sort(criteria []*types.SortCriterion, cb func(types.W
 	}
 
 	if store.threadedView && !store.buildThreads {
-		store.worker.PostAction(&types.FetchDirectoryThreaded{
+		store.post(&types.FetchDirectoryThreaded{
 			Context:       store.ctx,
 			SortCriteria:  criteria,
 			Filter:        store.filter,
 			ThreadContext: store.threadContext,
 		}, handle_return)
 	} else {
-		store.worker.PostAction(&types.FetchDirectoryContents{
+		store.post(&types.FetchDirectoryContents{
 			Context:      store.ctx,
 			SortCriteria: criteria,
 			Filter:       store.filter,
@@ -1028,7 +1076,7 @@ func (store *MessageStore) fetchFlags() {
 	}
 	store.fetchFlagsDebounce = time.AfterFunc(store.fetchFlagsDelay, func() {
 		store.Lock()
-		store.worker.PostAction(&types.FetchMessageFlags{
+		store.post(&types.FetchMessageFlags{
 			Context: store.ctx,
 			Uids:    store.needsFlags,
 		}, nil)
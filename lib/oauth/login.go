@@ -0,0 +1,117 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"golang.org/x/oauth2"
+)
+
+// Prompt asks the user to visit uri in a browser. When code is not empty, it
+// must be entered on that page.
+type Prompt func(uri string, code string)
+
+// Login runs an interactive authorization flow and returns the token granted
+// by the user.
+//
+// The authorization code flow with PKCE is used when the authorization
+// endpoint is known. The browser is then redirected to a listener on the
+// loopback interface. Otherwise, or when device is true, the device
+// authorization flow is used.
+func Login(
+	ctx context.Context, conf *oauth2.Config, device bool, prompt Prompt,
+) (*oauth2.Token, error) {
+	if conf.Endpoint.AuthURL != "" && !device {
+		token, err := loopbackLogin(ctx, conf, prompt)
+		if err == nil || conf.Endpoint.DeviceAuthURL == "" ||
+			!errors.Is(err, errNoListener) {
+			return token, err
+		}
+		log.Warnf("oauth2: %v, falling back to device authorization", err)
+	}
+	if conf.Endpoint.DeviceAuthURL == "" {
+		return nil, errors.New(
+			"authorization_endpoint or device_authorization_endpoint required")
+	}
+	return deviceLogin(ctx, conf, prompt)
+}
+
+var errNoListener = errors.New("cannot listen on the loopback interface")
+
+func loopbackLogin(
+	ctx context.Context, conf *oauth2.Config, prompt Prompt,
+) (*oauth2.Token, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errNoListener, err)
+	}
+	c := *conf
+	c.RedirectURL = fmt.Sprintf("http://%s/", l.Addr())
+
+	verifier := oauth2.GenerateVerifier()
+	state := oauth2.GenerateVerifier()
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			if q.Get("state") != state {
+				http.Error(w, "invalid state", http.StatusBadRequest)
+				return
+			}
+			var res result
+			if e := q.Get("error"); e != "" {
+				res.err = fmt.Errorf("authorization denied: %s %s",
+					e, q.Get("error_description"))
+				fmt.Fprintln(w, "aerc: authorization failed.")
+			} else {
+				res.code = q.Get("code")
+				fmt.Fprintln(w, "aerc: authorization complete. "+
+					"You can close this window.")
+			}
+			select {
+			case results <- res:
+			default:
+			}
+		}),
+	}
+	go func() {
+		defer log.PanicHandler()
+		if err := server.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("oauth2: %v", err)
+		}
+	}()
+	defer server.Close()
+
+	prompt(c.AuthCodeURL(state, oauth2.AccessTypeOffline,
+		oauth2.S256ChallengeOption(verifier)), "")
+
+	select {
+	case res := <-results:
+		if res.err != nil {
+			return nil, res.err
+		}
+		return c.Exchange(ctx, res.code, oauth2.VerifierOption(verifier))
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func deviceLogin(
+	ctx context.Context, conf *oauth2.Config, prompt Prompt,
+) (*oauth2.Token, error) {
+	resp, err := conf.DeviceAuth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prompt(resp.VerificationURI, resp.UserCode)
+	return conf.DeviceAccessToken(ctx, resp)
+}
@@ -0,0 +1,219 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"git.sr.ht/~rjarry/aerc/lib/xdg"
+	"golang.org/x/oauth2"
+)
+
+// ErrNoToken is returned when no token is cached for an account and no
+// refresh token is configured.
+var ErrNoToken = errors.New("no oauth2 token, run :oauth-login")
+
+// Config returns the OAuth2 configuration from the query parameters of a
+// source or outgoing URL. Without token endpoint, the configuration is empty
+// and the password is used as access token.
+func Config(q url.Values) *oauth2.Config {
+	if q.Get("token_endpoint") == "" {
+		return &oauth2.Config{}
+	}
+	return &oauth2.Config{
+		ClientID:     q.Get("client_id"),
+		ClientSecret: q.Get("client_secret"),
+		Scopes:       []string{q.Get("scope")},
+		Endpoint: oauth2.Endpoint{
+			TokenURL:      q.Get("token_endpoint"),
+			AuthURL:       q.Get("authorization_endpoint"),
+			DeviceAuthURL: q.Get("device_authorization_endpoint"),
+		},
+	}
+}
+
+// IsOAuth returns true if the scheme of a source or outgoing URL uses an
+// OAuth2 authentication mechanism.
+func IsOAuth(scheme string) bool {
+	return strings.HasSuffix(scheme, "+oauthbearer") ||
+		strings.HasSuffix(scheme, "+xoauth2")
+}
+
+// cacheKey identifies the authorization server and client of a
+// configuration. The source and outgoing servers of an account may use
+// different ones.
+func cacheKey(conf *oauth2.Config) string {
+	sum := sha256.Sum256([]byte(conf.Endpoint.TokenURL + "\x00" + conf.ClientID))
+	return hex.EncodeToString(sum[:4])
+}
+
+func cachePath(account string, conf *oauth2.Config) string {
+	return xdg.CachePath("aerc", account+"-"+cacheKey(conf)+"-oauth2.json")
+}
+
+// cache shared by all the servers of an account by older versions
+func sharedCachePath(account string) string {
+	return xdg.CachePath("aerc", account+"-oauth2.json")
+}
+
+// legacy cache of the xoauth2 refresh tokens
+func legacyCachePath(account string) string {
+	return xdg.CachePath("aerc", account+"-xoauth2.token")
+}
+
+// cachedToken is the content of the token cache.
+type cachedToken struct {
+	oauth2.Token
+	// refresh token configured in accounts.conf when the token was cached
+	Configured string `json:"configured_refresh_token,omitempty"`
+}
+
+func load(account string, conf *oauth2.Config) (*cachedToken, error) {
+	path := cachePath(account, conf)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		path = sharedCachePath(account)
+		data, err = os.ReadFile(path)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		refresh, lerr := os.ReadFile(legacyCachePath(account))
+		if lerr != nil || len(refresh) == 0 {
+			return nil, err
+		}
+		return &cachedToken{Token: oauth2.Token{
+			RefreshToken: strings.TrimSpace(string(refresh)),
+			TokenType:    "Bearer",
+		}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var token cachedToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &token, nil
+}
+
+func save(account string, conf *oauth2.Config, token *cachedToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(xdg.CachePath("aerc"), 0o700)
+	if err != nil {
+		return err
+	}
+	path := cachePath(account, conf)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load returns the token cached for an account and an authorization server.
+func Load(account string, conf *oauth2.Config) (*oauth2.Token, error) {
+	token, err := load(account, conf)
+	if err != nil {
+		return nil, err
+	}
+	return &token.Token, nil
+}
+
+// Save stores the token of an account for an authorization server in the
+// cache. The file is only readable by the current user.
+func Save(account string, conf *oauth2.Config, token *oauth2.Token) error {
+	refreshLock.Lock()
+	defer refreshLock.Unlock()
+
+	cached := &cachedToken{Token: *token}
+	if prev, err := load(account, conf); err == nil {
+		cached.Configured = prev.Configured
+	}
+	return save(account, conf, cached)
+}
+
+// IMAP, SMTP and JMAP connections of the same account may need a token at the
+// same time
+var refreshLock sync.Mutex
+
+// AccessToken returns a valid access token for an account. The cached token
+// is used until it expires. It is then refreshed with the cached refresh
+// token, or with refreshToken when no token is cached or when refreshToken
+// was changed since the token was cached. Refreshed tokens are saved in the
+// cache.
+func AccessToken(
+	account string, conf *oauth2.Config, refreshToken string,
+) (string, error) {
+	token, err := accessToken(account, conf, refreshToken)
+	if err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+func accessToken(
+	account string, conf *oauth2.Config, refreshToken string,
+) (*oauth2.Token, error) {
+	refreshLock.Lock()
+	defer refreshLock.Unlock()
+
+	configured := &cachedToken{
+		Token:      oauth2.Token{RefreshToken: refreshToken, TokenType: "Bearer"},
+		Configured: refreshToken,
+	}
+	token, err := load(account, conf)
+	switch {
+	case err == nil && refreshToken != "" && token.Configured != "" &&
+		token.Configured != refreshToken:
+		// the configured refresh token was changed
+		token = configured
+	case err == nil:
+	case errors.Is(err, os.ErrNotExist) && refreshToken != "":
+		token = configured
+	case errors.Is(err, os.ErrNotExist):
+		return nil, ErrNoToken
+	default:
+		return nil, err
+	}
+
+	fresh, err := conf.TokenSource(context.Background(), &token.Token).Token()
+	if err != nil {
+		return nil, fmt.Errorf("oauth2 token refresh failed: %w", err)
+	}
+	if fresh.AccessToken != token.AccessToken ||
+		(refreshToken != "" && token.Configured != refreshToken) {
+		cached := &cachedToken{Token: *fresh, Configured: token.Configured}
+		if refreshToken != "" {
+			cached.Configured = refreshToken
+		}
+		if err := save(account, conf, cached); err != nil {
+			return nil, err
+		}
+	}
+	return fresh, nil
+}
+
+// TokenSource returns a source of access tokens for an account, see
+// AccessToken. Tokens are refreshed when they expire.
+func TokenSource(
+	account string, conf *oauth2.Config, refreshToken string,
+) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, tokenSource(func() (*oauth2.Token, error) {
+		return accessToken(account, conf, refreshToken)
+	}))
+}
+
+type tokenSource func() (*oauth2.Token, error)
+
+func (f tokenSource) Token() (*oauth2.Token, error) {
+	return f()
+}
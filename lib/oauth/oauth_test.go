@@ -0,0 +1,249 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// stand-in authorization server
+type testServer struct {
+	*httptest.Server
+	issued atomic.Int32
+	// code challenge of the last authorization request
+	challenge string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	s := &testServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		s.challenge = q.Get("code_challenge")
+		redirect := fmt.Sprintf("%s?code=authcode&state=%s",
+			q.Get("redirect_uri"), url.QueryEscape(q.Get("state")))
+		http.Redirect(w, r, redirect, http.StatusFound)
+	})
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"device_code":"devcode","user_code":"ABCD",`+
+			`"verification_uri":"https://example.com/device",`+
+			`"expires_in":60,"interval":1}`)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			if r.Form.Get("code") != "authcode" ||
+				r.Form.Get("code_verifier") == "" {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+		case "refresh_token":
+			if r.Form.Get("refresh_token") != "refresh" {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+		case "urn:ietf:params:oauth:grant-type:device_code":
+			if r.Form.Get("device_code") != "devcode" {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, `{"error":"unsupported_grant_type"}`, http.StatusBadRequest)
+			return
+		}
+		n := s.issued.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("access%d", n),
+			"refresh_token": "refresh",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+		})
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	return s
+}
+
+func (s *testServer) config() *oauth2.Config {
+	return Config(url.Values{
+		"client_id":                     {"aerc"},
+		"scope":                         {"mail"},
+		"token_endpoint":                {s.URL + "/token"},
+		"authorization_endpoint":        {s.URL + "/authorize"},
+		"device_authorization_endpoint": {s.URL + "/device"},
+	})
+}
+
+func TestConfig(t *testing.T) {
+	conf := Config(url.Values{"client_id": {"aerc"}})
+	if conf.Endpoint.TokenURL != "" || conf.ClientID != "" {
+		t.Errorf("expected empty config, got %+v", conf)
+	}
+}
+
+func TestAccessToken(t *testing.T) {
+	s := newTestServer(t)
+	conf := s.config()
+
+	_, err := AccessToken("test", conf, "")
+	if !errors.Is(err, ErrNoToken) {
+		t.Fatalf("expected ErrNoToken, got %v", err)
+	}
+
+	// configured refresh token
+	token, err := AccessToken("test", conf, "refresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "access1" {
+		t.Errorf("unexpected token %q", token)
+	}
+
+	// cached access token
+	token, err = AccessToken("test", conf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "access1" || s.issued.Load() != 1 {
+		t.Errorf("cached token not used: %q", token)
+	}
+
+	// expired access token, refreshed with the cached refresh token
+	cached, err := Load("test", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached.Expiry = time.Now().Add(-time.Minute)
+	if err := Save("test", conf, cached); err != nil {
+		t.Fatal(err)
+	}
+	token, err = AccessToken("test", conf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "access2" {
+		t.Errorf("unexpected token %q", token)
+	}
+
+	// other client of the same account
+	other := s.config()
+	other.ClientID = "other"
+	_, err = AccessToken("test", other, "")
+	if !errors.Is(err, ErrNoToken) {
+		t.Fatalf("expected ErrNoToken for another client, got %v", err)
+	}
+
+	// changed refresh token, which is rejected by the server
+	_, err = AccessToken("test", conf, "revoked")
+	if err == nil {
+		t.Error("configured refresh token not used after being changed")
+	}
+	token, err = AccessToken("test", conf, "refresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "access2" {
+		t.Errorf("unexpected token %q", token)
+	}
+}
+
+func TestTokenSource(t *testing.T) {
+	s := newTestServer(t)
+	conf := s.config()
+
+	src := TokenSource("test", conf, "refresh")
+	token, err := src.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "access1" {
+		t.Errorf("unexpected token %q", token.AccessToken)
+	}
+
+	// expired access token, refreshed by the source
+	cached, err := Load("test", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached.Expiry = time.Now().Add(-time.Minute)
+	if err := Save("test", conf, cached); err != nil {
+		t.Fatal(err)
+	}
+	src = TokenSource("test", conf, "refresh")
+	token, err = src.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "access2" {
+		t.Errorf("unexpected token %q", token.AccessToken)
+	}
+}
+
+func TestLoginLoopback(t *testing.T) {
+	s := newTestServer(t)
+
+	// the browser follows the redirection to the loopback listener
+	prompt := func(uri string, code string) {
+		if code != "" {
+			t.Errorf("unexpected user code %q", code)
+		}
+		go func() {
+			resp, err := http.Get(uri)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	token, err := Login(ctx, s.config(), false, prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "access1" || token.RefreshToken != "refresh" {
+		t.Errorf("unexpected token %+v", token)
+	}
+	if s.challenge == "" {
+		t.Error("no PKCE code challenge")
+	}
+}
+
+func TestLoginDevice(t *testing.T) {
+	s := newTestServer(t)
+
+	var uri, code string
+	prompt := func(u string, c string) {
+		uri, code = u, c
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	token, err := Login(ctx, s.config(), true, prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri != "https://example.com/device" || code != "ABCD" {
+		t.Errorf("unexpected prompt %q %q", uri, code)
+	}
+	if token.AccessToken != "access1" {
+		t.Errorf("unexpected token %+v", token)
+	}
+}
@@ -1,9 +1,9 @@
 package lib
 
 import (
-	"context"
 	"fmt"
 
+	"git.sr.ht/~rjarry/aerc/lib/oauth"
 	"github.com/emersion/go-imap/client"
 	"github.com/emersion/go-sasl"
 	"golang.org/x/oauth2"
@@ -14,24 +14,22 @@ type OAuthBearer struct {
 	Enabled bool
 }
 
-func (c *OAuthBearer) ExchangeRefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := new(oauth2.Token)
-	token.RefreshToken = refreshToken
-	token.TokenType = "Bearer"
-	return c.OAuth2.TokenSource(context.TODO(), token).Token()
-}
-
-func (c *OAuthBearer) Authenticate(username string, password string, client *client.Client) error {
+func (c *OAuthBearer) Authenticate(
+	username string,
+	password string,
+	account string,
+	client *client.Client,
+) error {
 	if ok, err := client.SupportAuth(sasl.OAuthBearer); err != nil || !ok {
 		return fmt.Errorf("OAuthBearer not supported %w", err)
 	}
 
 	if c.OAuth2.Endpoint.TokenURL != "" {
-		token, err := c.ExchangeRefreshToken(password)
+		token, err := oauth.AccessToken(account, c.OAuth2, password)
 		if err != nil {
 			return err
 		}
-		password = token.AccessToken
+		password = token
 	}
 
 	saslClient := sasl.NewOAuthBearerClient(&sasl.OAuthBearerOptions{
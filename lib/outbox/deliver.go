@@ -0,0 +1,149 @@
+package outbox
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-message"
+	"github.com/emersion/go-message/mail"
+	"github.com/emersion/go-message/textproto"
+
+	"git.sr.ht/~rjarry/aerc/config"
+	"git.sr.ht/~rjarry/aerc/lib/send"
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+// Deliver sends a queued message with the outgoing transport of the given
+// account. A copy of the message is saved to the item CopyTo folders. The
+// returned header is the one of the sent message. The item is not removed
+// from the outbox.
+func Deliver(
+	item *Item, acct *config.AccountConfig, worker *types.Worker,
+) (*mail.Header, error) {
+	data, err := Message(item.ID)
+	if err != nil {
+		return nil, err
+	}
+	if item.Scheduled {
+		now := time.Now()
+		if acct.SendAsUTC {
+			now = now.UTC()
+		}
+		data, err = setDate(data, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+	outgoing, err := acct.Outgoing.ConnectionString()
+	if err != nil {
+		return nil, fmt.Errorf("ReadCredentials(outgoing): %w", err)
+	}
+	if outgoing == "" {
+		return nil, errors.New(
+			"No outgoing mail transport configured for this account")
+	}
+	uri, err := url.Parse(outgoing)
+	if err != nil {
+		return nil, fmt.Errorf("url.Parse(outgoing): %w", err)
+	}
+	from, err := mail.ParseAddress(item.From)
+	if err != nil {
+		return nil, fmt.Errorf("from: %w", err)
+	}
+	rcpts := make([]*mail.Address, 0, len(item.Rcpts))
+	for _, r := range item.Rcpts {
+		addr, err := mail.ParseAddress(r)
+		if err != nil {
+			return nil, fmt.Errorf("rcpt: %w", err)
+		}
+		rcpts = append(rcpts, addr)
+	}
+	header, err := readHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sender, err := send.NewSender(worker, uri, acct.Params["smtp-domain"],
+		from, rcpts, item.CopyTo)
+	if err != nil {
+		return nil, fmt.Errorf("send: %w", err)
+	}
+	if _, err := sender.Write(data); err != nil {
+		sender.Close()
+		return nil, err
+	}
+	if err := sender.Close(); err != nil {
+		return nil, err
+	}
+
+	// the JMAP sender takes care of the copies
+	if strings.HasPrefix(uri.Scheme, "jmap") {
+		return header, nil
+	}
+	for _, folder := range item.CopyTo {
+		if err := appendMessage(worker, folder, data); err != nil {
+			return header, fmt.Errorf(
+				"message sent, but copying to %v failed: %w",
+				folder, err)
+		}
+	}
+	return header, nil
+}
+
+func appendMessage(worker *types.Worker, folder string, data []byte) error {
+	done := make(chan error, 1)
+	worker.PostAction(&types.CreateDirectory{
+		Directory: folder,
+		Quiet:     true,
+	}, nil)
+	worker.PostAction(&types.AppendMessage{
+		Destination: folder,
+		Flags:       models.SeenFlag,
+		Date:        time.Now(),
+		Reader:      bytes.NewReader(data),
+		Length:      len(data),
+	}, func(msg types.WorkerMessage) {
+		switch msg := msg.(type) {
+		case *types.Done:
+			done <- nil
+		case *types.Error:
+			done <- msg.Error
+		}
+	})
+	return <-done
+}
+
+// setDate replaces the Date header of a message.
+func setDate(data []byte, date time.Time) ([]byte, error) {
+	r := bufio.NewReader(bytes.NewReader(data))
+	h, err := textproto.ReadHeader(r)
+	if err != nil {
+		return nil, fmt.Errorf("textproto.ReadHeader: %w", err)
+	}
+	header := mail.Header{Header: message.Header{Header: h}}
+	header.SetDate(date)
+
+	var buf bytes.Buffer
+	if err := textproto.WriteHeader(&buf, header.Header.Header); err != nil {
+		return nil, err
+	}
+	if _, err := r.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func readHeader(data []byte) (*mail.Header, error) {
+	r, err := mail.CreateReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("mail.CreateReader: %w", err)
+	}
+	defer r.Close()
+	return &r.Header, nil
+}
@@ -0,0 +1,246 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/lib/xdg"
+)
+
+const (
+	// delay before the first retry of a failed message
+	retryMin = 1 * time.Minute
+	// maximum delay between two retries
+	retryMax = 1 * time.Hour
+)
+
+// Item holds the metadata of a message waiting in the outbox. The message
+// contents are stored in a separate file next to the metadata.
+type Item struct {
+	ID          string    `json:"-"`
+	Account     string    `json:"account"`
+	Subject     string    `json:"subject"`
+	From        string    `json:"from"`
+	Rcpts       []string  `json:"rcpts"`
+	CopyTo      []string  `json:"copy-to,omitempty"`
+	Queued      time.Time `json:"queued"`
+	NextAttempt time.Time `json:"next-attempt"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last-error,omitempty"`
+	// Scheduled items have their Date header updated when sent.
+	Scheduled bool `json:"scheduled"`
+	// Held items are never retried automatically.
+	Held bool `json:"held"`
+}
+
+// ErrNotFound is returned when no item matches a given ID.
+var ErrNotFound = errors.New("no such message in outbox")
+
+// serialize all file operations on the outbox directory
+var lock sync.Mutex
+
+// Dir returns the directory where queued messages are stored.
+func Dir() string {
+	return xdg.DataPath("aerc", "outbox")
+}
+
+func metaPath(id string) string {
+	return filepath.Join(Dir(), id+".json")
+}
+
+func emlPath(id string) string {
+	return filepath.Join(Dir(), id+".eml")
+}
+
+func newID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36) +
+		fmt.Sprintf("%04x", rand.Intn(0x10000))
+}
+
+// writeFile writes data to a temporary file and renames it to path so that
+// readers never see partially written files.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Enqueue stores a message in the outbox. The item ID is assigned by this
+// function. If the item has no NextAttempt time, the message is due
+// immediately.
+func Enqueue(item *Item, msg []byte) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := os.MkdirAll(Dir(), 0o700); err != nil {
+		return err
+	}
+	item.ID = newID()
+	if item.Queued.IsZero() {
+		item.Queued = time.Now()
+	}
+	if item.NextAttempt.IsZero() {
+		item.NextAttempt = item.Queued
+	}
+	if err := writeFile(emlPath(item.ID), msg); err != nil {
+		return err
+	}
+	if err := save(item); err != nil {
+		os.Remove(emlPath(item.ID))
+		return err
+	}
+	return nil
+}
+
+func save(item *Item) error {
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(metaPath(item.ID), data)
+}
+
+// Update overwrites the metadata of an item that is already queued.
+func Update(item *Item) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := os.Stat(metaPath(item.ID)); err != nil {
+		return ErrNotFound
+	}
+	return save(item)
+}
+
+func load(id string) (*Item, error) {
+	data, err := os.ReadFile(metaPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	var item Item
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, fmt.Errorf("%s: %w", metaPath(id), err)
+	}
+	item.ID = id
+	return &item, nil
+}
+
+// Get returns the item with the given ID.
+func Get(id string) (*Item, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	return load(id)
+}
+
+// Message returns the raw contents of a queued message.
+func Message(id string) ([]byte, error) {
+	data, err := os.ReadFile(emlPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Remove deletes a message from the outbox.
+func Remove(id string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	err := os.Remove(metaPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	return os.Remove(emlPath(id))
+}
+
+// List returns all queued items sorted by queue time. If account is not
+// empty, only the items of that account are returned.
+func List(account string) ([]*Item, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	entries, err := os.ReadDir(Dir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var items []*Item
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		item, err := load(id)
+		if err != nil {
+			return nil, err
+		}
+		if account != "" && item.Account != account {
+			continue
+		}
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Queued.Before(items[j].Queued)
+	})
+	return items, nil
+}
+
+// Due returns true if the item should be sent at the given time.
+func (item *Item) Due(now time.Time) bool {
+	return !item.Held && !now.Before(item.NextAttempt)
+}
+
+// Failed records a delivery failure and schedules the next attempt with an
+// exponential backoff. If permanent is true, the item is held in the outbox
+// until a retry is explicitly requested.
+func (item *Item) Failed(err error, permanent bool) {
+	delay := retryMin
+	for i := 0; i < item.Attempts && delay < retryMax; i++ {
+		delay *= 2
+	}
+	if delay > retryMax {
+		delay = retryMax
+	}
+	item.Attempts++
+	item.LastError = err.Error()
+	item.NextAttempt = time.Now().Add(delay)
+	item.Held = permanent
+}
+
+// Retry makes the item due for immediate delivery.
+func (item *Item) Retry() {
+	item.Held = false
+	item.NextAttempt = time.Now()
+}
+
+// NextDue returns the earliest time at which one of the given items will be
+// due. The returned boolean is false if none of them will ever be.
+func NextDue(items []*Item) (time.Time, bool) {
+	var next time.Time
+	found := false
+	for _, item := range items {
+		if item.Held {
+			continue
+		}
+		if !found || item.NextAttempt.Before(next) {
+			next = item.NextAttempt
+			found = true
+		}
+	}
+	return next, found
+}
@@ -0,0 +1,100 @@
+package outbox_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/lib/outbox"
+)
+
+func TestQueue(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	items, err := outbox.List("")
+	if err != nil || len(items) != 0 {
+		t.Fatalf("empty outbox: %v %v", items, err)
+	}
+
+	first := &outbox.Item{Account: "work", Subject: "first"}
+	if err := outbox.Enqueue(first, []byte("Subject: first\r\n\r\nhi\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	second := &outbox.Item{Account: "home", Subject: "second"}
+	if err := outbox.Enqueue(second, []byte("Subject: second\r\n\r\nho\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if first.ID == "" || first.ID == second.ID {
+		t.Fatalf("invalid ids: %q %q", first.ID, second.ID)
+	}
+
+	items, err = outbox.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].ID != first.ID || items[1].ID != second.ID {
+		t.Fatalf("unexpected items: %v", items)
+	}
+	items, err = outbox.List("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Subject != "first" {
+		t.Fatalf("unexpected items: %v", items)
+	}
+
+	data, err := outbox.Message(second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "Subject: second\r\n\r\nho\r\n" {
+		t.Errorf("unexpected message: %q", data)
+	}
+
+	if err := outbox.Remove(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := outbox.Get(first.ID); !errors.Is(err, outbox.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := outbox.Remove(first.ID); !errors.Is(err, outbox.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestFailed(t *testing.T) {
+	item := &outbox.Item{}
+	var delays []time.Duration
+	for i := 0; i < 9; i++ {
+		item.Failed(errors.New("boom"), false)
+		delays = append(delays, time.Until(item.NextAttempt).Round(time.Minute))
+	}
+	expected := []time.Duration{
+		1 * time.Minute, 2 * time.Minute, 4 * time.Minute,
+		8 * time.Minute, 16 * time.Minute, 32 * time.Minute,
+		time.Hour, time.Hour, time.Hour,
+	}
+	for i := range expected {
+		if delays[i] != expected[i] {
+			t.Errorf("attempt %d: expected %s got %s", i+1, expected[i], delays[i])
+		}
+	}
+	if item.Attempts != 9 || item.LastError != "boom" {
+		t.Errorf("unexpected item state: %+v", item)
+	}
+	if item.Due(time.Now()) {
+		t.Error("item should not be due")
+	}
+
+	item.Failed(errors.New("rejected"), true)
+	if item.Due(item.NextAttempt.Add(time.Second)) {
+		t.Error("held item should never be due")
+	}
+	if _, ok := outbox.NextDue([]*outbox.Item{item}); ok {
+		t.Error("held item should not be scheduled")
+	}
+	item.Retry()
+	if !item.Due(time.Now()) {
+		t.Error("retried item should be due")
+	}
+}
@@ -97,9 +97,10 @@ func makeUnique(s string) string {
 // ApplyUpdate is called after the commits have been applied with the
 // ApplyCmd(). It will determine the additional commits from the commitID (last
 // HEAD position), assign the patch tag to those commits and store them in
-// project p.
+// project p. The series information is recorded on the commits matched to a
+// message id.
 func (m PatchManager) ApplyUpdate(p models.Project, patch, commitID string,
-	kv map[string]string,
+	kv map[string]string, info models.SeriesInfo,
 ) (models.Project, error) {
 	rc, err := m.rc(p.RevctrlID, p.Root)
 	if err != nil {
@@ -130,9 +131,85 @@ func (m PatchManager) ApplyUpdate(p models.Project, patch, commitID string,
 				nc.MessageId = msgid
 			}
 		}
+		if nc.MessageId != "" {
+			nc.Series = info.Series
+			nc.Version = info.Version
+			nc.Trailers = info.Trailers[nc.MessageId]
+		}
 		p.Commits = append(p.Commits, nc)
 	}
 
 	err = m.store().StoreProject(p, true)
 	return p, storeErr(err)
 }
+
+// RangeDiff compares the commits of an applied patch with a new version of
+// the patches.
+func (m PatchManager) RangeDiff(p models.Project, patch string,
+	patches []models.RangePatch,
+) (string, error) {
+	rc, err := m.rc(p.RevctrlID, p.Root)
+	if err != nil {
+		return "", revErr(err)
+	}
+	var applied []models.RangePatch
+	for _, c := range p.Commits {
+		if c.Tag != patch {
+			continue
+		}
+		diff, err := rc.Diff(c.ID)
+		if err != nil {
+			return "", revErr(err)
+		}
+		applied = append(applied, models.RangePatch{
+			ID:    c.ID,
+			Title: c.Subject,
+			Diff:  diff,
+		})
+	}
+	if len(applied) == 0 {
+		return "", fmt.Errorf("no commits found for patch %s", patch)
+	}
+	return models.RangeDiff(applied, patches), nil
+}
+
+// FormatPatches formats commits as patch emails. The commits are those of
+// the patch tag, or the commits since the rev commit-ish.
+func (m PatchManager) FormatPatches(p models.Project, rev string,
+	opts models.FormatOptions,
+) ([]models.PatchEmail, error) {
+	rc, err := m.rc(p.RevctrlID, p.Root)
+	if err != nil {
+		return nil, revErr(err)
+	}
+	var ids []string
+	if models.Commits(p.Commits).HasTag(rev) {
+		for _, c := range p.Commits {
+			if c.Tag == rev {
+				ids = append(ids, c.ID)
+			}
+		}
+	} else {
+		ids, err = rc.History(rev)
+		if err != nil {
+			return nil, revErr(err)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no commits found for %s", rev)
+	}
+
+	var commits []models.CommitPatch
+	for _, id := range ids {
+		diff, err := rc.Diff(id)
+		if err != nil {
+			return nil, revErr(err)
+		}
+		commits = append(commits, models.CommitPatch{
+			Author:  rc.Author(id),
+			Message: rc.Message(id),
+			Diff:    diff,
+		})
+	}
+	return models.FormatSeries(commits, opts), nil
+}
@@ -0,0 +1,104 @@
+package pama_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~rjarry/aerc/lib/pama/models"
+)
+
+func TestPatchmgmt_ApplyUpdate(t *testing.T) {
+	p := models.Project{
+		Name:    "project1",
+		Commits: []models.Commit{newCommit("1", "a", "patch1")},
+	}
+	mgr, _, _ := newTestManager(
+		[]string{"0", "1", "2", "3"},
+		[]string{"0", "a", "b", "c"},
+		map[string]models.Project{p.Name: p}, p.Name,
+	)
+
+	msgs := map[string]string{
+		"b@example.com": "[PATCH v2 1/2] b",
+		"c@example.com": "[PATCH v2 2/2] c",
+	}
+	info := models.SeriesInfo{
+		Series:  "b",
+		Version: 2,
+		Trailers: map[string][]string{
+			"c@example.com": {"Reviewed-by: Jane Doe <jane@example.com>"},
+		},
+	}
+	p, err := mgr.ApplyUpdate(p, "patch2", "1", msgs, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.Commit{
+		newCommit("1", "a", "patch1"),
+		{
+			ID: "2", Subject: "b", Tag: "patch2", MessageId: "b@example.com",
+			Series: "b", Version: 2,
+		},
+		{
+			ID: "3", Subject: "c", Tag: "patch2", MessageId: "c@example.com",
+			Series: "b", Version: 2,
+			Trailers: []string{"Reviewed-by: Jane Doe <jane@example.com>"},
+		},
+	}
+	if !reflect.DeepEqual(p.Commits, want) {
+		t.Errorf("got %+v, want %+v", p.Commits, want)
+	}
+
+	diff, err := mgr.RangeDiff(p, "patch2", []models.RangePatch{
+		{Title: "b", Diff: "diff --git a/file b/file\n+b"},
+		{Title: "c", Diff: "diff --git a/file b/file\n+d"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, " 1:  2        =  1:  -------- b\n") ||
+		!strings.Contains(diff, " 2:  3        !  2:  -------- c\n") {
+		t.Errorf("unexpected range-diff:\n%s", diff)
+	}
+	if _, err := mgr.RangeDiff(p, "unknown", nil); err == nil {
+		t.Error("expected an error for an unknown patch")
+	}
+}
+
+func TestPatchmgmt_FormatPatches(t *testing.T) {
+	p := models.Project{
+		Name:    "project1",
+		Commits: []models.Commit{newCommit("2", "b", "patch1")},
+	}
+	mgr, _, _ := newTestManager(
+		[]string{"0", "1", "2"},
+		[]string{"0", "a", "b"},
+		map[string]models.Project{p.Name: p}, p.Name,
+	)
+
+	emails, err := mgr.FormatPatches(p, "patch1", models.FormatOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(emails) != 1 || emails[0].Subject != "[PATCH] b" {
+		t.Errorf("unexpected patch emails %+v", emails)
+	}
+
+	emails, err = mgr.FormatPatches(p, "0", models.FormatOptions{Cover: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subjects []string
+	for _, e := range emails {
+		subjects = append(subjects, e.Subject)
+	}
+	want := []string{
+		"[PATCH 0/2] " + models.CoverSubject,
+		"[PATCH 1/2] a",
+		"[PATCH 2/2] b",
+	}
+	if !reflect.DeepEqual(subjects, want) {
+		t.Errorf("got %v, want %v", subjects, want)
+	}
+}
@@ -40,6 +40,10 @@ func (c Commit) Info() string {
 	if c.MessageId != "" {
 		s = append(s, "<"+c.MessageId+">")
 	}
+	if c.Version > 0 {
+		s = append(s, fmt.Sprintf("v%d", c.Version))
+	}
+	s = append(s, c.Trailers...)
 	return strings.Join(s, ", ")
 }
 
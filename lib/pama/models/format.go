@@ -0,0 +1,195 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FormatOptions control how commits are formatted as patch emails.
+type FormatOptions struct {
+	// Prefix of the subject, "PATCH" if empty.
+	Prefix string
+	// Version of the series, omitted from the subject if lower than 2.
+	Version int
+	// Cover adds a cover letter.
+	Cover bool
+}
+
+// CommitPatch is the content of a commit to format.
+type CommitPatch struct {
+	Author  string
+	Message string
+	Diff    string
+}
+
+// PatchEmail is a commit or a cover letter formatted as an email.
+type PatchEmail struct {
+	Subject string
+	Body    string
+}
+
+const (
+	CoverSubject = "*** SUBJECT HERE ***"
+	CoverBlurb   = "*** BLURB HERE ***"
+)
+
+// FormatSeries formats the commits as the emails of a patch series, similar
+// to git format-patch. The cover letter comes first.
+func FormatSeries(commits []CommitPatch, opts FormatOptions) []PatchEmail {
+	var emails []PatchEmail
+	total := len(commits)
+	numbered := total > 1 || opts.Cover
+
+	if opts.Cover {
+		var diffs []string
+		var b strings.Builder
+		b.WriteString(CoverBlurb + "\n\n")
+		author := ""
+		for i, c := range commits {
+			if i == 0 || c.Author != author {
+				author = c.Author
+				n := 1
+				for _, next := range commits[i+1:] {
+					if next.Author != author {
+						break
+					}
+					n++
+				}
+				if i > 0 {
+					b.WriteString("\n")
+				}
+				fmt.Fprintf(&b, "%s (%d):\n", author, n)
+			}
+			title, _ := splitMessage(c.Message)
+			fmt.Fprintf(&b, "  %s\n", title)
+			diffs = append(diffs, c.Diff)
+		}
+		b.WriteString("\n" + DiffStat(diffs...))
+		emails = append(emails, PatchEmail{
+			Subject: subjectPrefix(opts, 0, total, numbered) + CoverSubject,
+			Body:    b.String(),
+		})
+	}
+
+	for i, c := range commits {
+		title, body := splitMessage(c.Message)
+		var b strings.Builder
+		if body != "" {
+			b.WriteString(body + "\n")
+		}
+		b.WriteString("---\n")
+		b.WriteString(DiffStat(c.Diff))
+		b.WriteString("\n")
+		b.WriteString(strings.TrimRight(c.Diff, "\n") + "\n")
+		emails = append(emails, PatchEmail{
+			Subject: subjectPrefix(opts, i+1, total, numbered) + title,
+			Body:    b.String(),
+		})
+	}
+	return emails
+}
+
+func subjectPrefix(opts FormatOptions, index, total int, numbered bool) string {
+	prefix := opts.Prefix
+	if prefix == "" {
+		prefix = "PATCH"
+	}
+	if opts.Version > 1 {
+		prefix += fmt.Sprintf(" v%d", opts.Version)
+	}
+	if numbered {
+		width := len(fmt.Sprint(total))
+		prefix += fmt.Sprintf(" %0*d/%d", width, index, total)
+	}
+	return "[" + prefix + "] "
+}
+
+// splitMessage returns the subject line and the body of a commit message.
+func splitMessage(message string) (string, string) {
+	title, body, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return strings.TrimSpace(title), strings.Trim(body, "\n")
+}
+
+// DiffStat summarizes the changes of diffs, similar to git diff --stat.
+func DiffStat(diffs ...string) string {
+	type stat struct {
+		name     string
+		add, del int
+		binary   bool
+	}
+	var stats []*stat
+	var current *stat
+	inHunk := false
+	for _, diff := range diffs {
+		for _, line := range strings.Split(diff, "\n") {
+			switch {
+			case strings.HasPrefix(line, "diff "):
+				name := line
+				if i := strings.LastIndex(line, " b/"); i >= 0 {
+					name = line[i+3:]
+				}
+				current = &stat{name: name}
+				stats = append(stats, current)
+				inHunk = false
+			case current == nil:
+			case strings.HasPrefix(line, "@@"):
+				inHunk = true
+			case strings.HasPrefix(line, "Binary files"):
+				current.binary = true
+			case inHunk && strings.HasPrefix(line, "+"):
+				current.add++
+			case inHunk && strings.HasPrefix(line, "-"):
+				current.del++
+			}
+		}
+	}
+	if len(stats) == 0 {
+		return ""
+	}
+
+	nameWidth, maxChanges, adds, dels := 0, 0, 0, 0
+	for _, s := range stats {
+		nameWidth = max(nameWidth, len(s.name))
+		maxChanges = max(maxChanges, s.add+s.del)
+		adds += s.add
+		dels += s.del
+	}
+	countWidth := len(fmt.Sprint(maxChanges))
+	// scale the graph to keep the lines short
+	const graphWidth = 50
+	scale := func(n int) int {
+		if maxChanges <= graphWidth || n == 0 {
+			return n
+		}
+		return max(1, n*graphWidth/maxChanges)
+	}
+
+	var b strings.Builder
+	for _, s := range stats {
+		if s.binary {
+			fmt.Fprintf(&b, " %-*s | %*s\n", nameWidth, s.name, countWidth, "Bin")
+			continue
+		}
+		line := fmt.Sprintf(" %-*s | %*d %s%s", nameWidth, s.name,
+			countWidth, s.add+s.del,
+			strings.Repeat("+", scale(s.add)),
+			strings.Repeat("-", scale(s.del)))
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	summary := []string{plural(len(stats), "file changed", "files changed")}
+	if adds > 0 || dels == 0 {
+		summary = append(summary, plural(adds, "insertion(+)", "insertions(+)"))
+	}
+	if dels > 0 {
+		summary = append(summary, plural(dels, "deletion(-)", "deletions(-)"))
+	}
+	fmt.Fprintf(&b, " %s\n", strings.Join(summary, ", "))
+	return b.String()
+}
+
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
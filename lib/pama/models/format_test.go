@@ -0,0 +1,77 @@
+package models_test
+
+import (
+	"reflect"
+	"testing"
+
+	"git.sr.ht/~rjarry/aerc/lib/pama/models"
+)
+
+const diffB = `diff --git a/other b/other
+new file mode 100644
+index 0000000..1111111
+--- /dev/null
++++ b/other
+@@ -0,0 +1,2 @@
++one
++two
+`
+
+func TestFormatSeries(t *testing.T) {
+	commits := []models.CommitPatch{
+		{
+			Author:  "John Doe",
+			Message: "imap: fix the idler\n\nThe idler did not stop.\n\nSigned-off-by: John Doe <john@example.com>\n",
+			Diff:    diffA,
+		},
+		{
+			Author:  "John Doe",
+			Message: "imap: add other",
+			Diff:    diffB,
+		},
+	}
+
+	emails := models.FormatSeries(commits, models.FormatOptions{Version: 2, Cover: true})
+	want := []models.PatchEmail{
+		{
+			Subject: "[PATCH v2 0/2] " + models.CoverSubject,
+			Body: models.CoverBlurb + `
+
+John Doe (2):
+  imap: fix the idler
+  imap: add other
+
+ file  | 2 +-
+ other | 2 ++
+ 2 files changed, 3 insertions(+), 1 deletion(-)
+`,
+		},
+		{
+			Subject: "[PATCH v2 1/2] imap: fix the idler",
+			Body: `The idler did not stop.
+
+Signed-off-by: John Doe <john@example.com>
+---
+ file | 2 +-
+ 1 file changed, 1 insertion(+), 1 deletion(-)
+
+` + diffA,
+		},
+		{
+			Subject: "[PATCH v2 2/2] imap: add other",
+			Body: `---
+ other | 2 ++
+ 1 file changed, 2 insertions(+)
+
+` + diffB,
+		},
+	}
+	if !reflect.DeepEqual(emails, want) {
+		t.Errorf("got %#v\nwant %#v", emails, want)
+	}
+
+	emails = models.FormatSeries(commits[1:], models.FormatOptions{Prefix: "PATCH aerc"})
+	if len(emails) != 1 || emails[0].Subject != "[PATCH aerc] imap: add other" {
+		t.Errorf("unexpected single patch %#v", emails)
+	}
+}
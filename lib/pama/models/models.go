@@ -18,6 +18,13 @@ type Commit struct {
 	// creates a logical connection between a group of commits to represent
 	// a patch set.
 	Tag string
+	// Series is the name of the patch series the commit was applied from.
+	Series string
+	// Version of the patch series.
+	Version int
+	// Trailers are the review trailers collected from the replies to the
+	// patch email.
+	Trailers []string
 }
 
 // WorktreeParent stores the name and repo location for the base project in the
@@ -66,10 +73,16 @@ type RevisionController interface {
 	Exists(string) bool
 	// Subject returns the subject line for the provided commit hash.
 	Subject(string) string
+	// Message returns the full commit message for the provided commit
+	// hash.
+	Message(string) string
 	// Author returns the author for the provided commit hash.
 	Author(string) string
 	// Date returns the date for the provided commit hash.
 	Date(string) string
+	// Diff returns the changes of the provided commit hash in the unified
+	// diff format.
+	Diff(string) (string, error)
 	// Drop removes the commit with the provided commit hash from the
 	// repository.
 	Drop(string) error
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// RangePatch is a patch of a range-diff comparison.
+type RangePatch struct {
+	// ID is the commit hash, or empty for patches that are not applied.
+	ID    string
+	Title string
+	Diff  string
+}
+
+// RangeDiff compares two versions of a patch series. Patches are paired by
+// title first, then by order if they modify the same files. The output mimics
+// git range-diff: each line shows a pair of patches with "=" when the diffs
+// are identical and "!" when they differ, followed by the differences. "<"
+// marks dropped patches and ">" added patches.
+func RangeDiff(old, new []RangePatch) string {
+	pairs := make([]int, len(new))
+	paired := make([]bool, len(old))
+	for i := range pairs {
+		pairs[i] = -1
+	}
+	for i, n := range new {
+		for j, o := range old {
+			if !paired[j] && o.Title == n.Title {
+				pairs[i] = j
+				paired[j] = true
+				break
+			}
+		}
+	}
+	j := 0
+	for i := range new {
+		if pairs[i] >= 0 {
+			continue
+		}
+		for j < len(old) && paired[j] {
+			j++
+		}
+		if j == len(old) {
+			break
+		}
+		if diffFiles(old[j].Diff) == diffFiles(new[i].Diff) {
+			pairs[i] = j
+			paired[j] = true
+		}
+	}
+
+	var b strings.Builder
+	written := make([]bool, len(old))
+	writeDropped := func(until int) {
+		for k := 0; k < until; k++ {
+			if !paired[k] && !written[k] {
+				written[k] = true
+				fmt.Fprintf(&b, "%s <  -:  -------- %s\n",
+					rangeLabel(k, old[k]), old[k].Title)
+			}
+		}
+	}
+	for i, n := range new {
+		k := pairs[i]
+		if k < 0 {
+			writeDropped(min(i+1, len(old)))
+			fmt.Fprintf(&b, " -:  -------- > %s %s\n",
+				rangeLabel(i, n), n.Title)
+			continue
+		}
+		writeDropped(k)
+		o := old[k]
+		a, c := normalizeDiff(o.Diff), normalizeDiff(n.Diff)
+		if a == c && o.Title == n.Title {
+			fmt.Fprintf(&b, "%s = %s %s\n",
+				rangeLabel(k, o), rangeLabel(i, n), n.Title)
+			continue
+		}
+		fmt.Fprintf(&b, "%s ! %s %s\n",
+			rangeLabel(k, o), rangeLabel(i, n), n.Title)
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:       difflib.SplitLines(o.Title + "\n\n" + strings.TrimSuffix(a, "\n")),
+			B:       difflib.SplitLines(n.Title + "\n\n" + strings.TrimSuffix(c, "\n")),
+			Context: 3,
+		})
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+			fmt.Fprintf(&b, "    %s\n", line)
+		}
+	}
+	writeDropped(len(old))
+	return b.String()
+}
+
+func rangeLabel(i int, p RangePatch) string {
+	id := p.ID
+	if id == "" {
+		id = "--------"
+	}
+	return fmt.Sprintf("%2d:  %-8.8s", i+1, id)
+}
+
+var hunkLineNumbers = regexp.MustCompile(`^@@ -[0-9,]+ \+[0-9,]+ @@`)
+
+// normalizeDiff removes the parts of a diff that change when the patch is
+// rebased: the blob hashes and the line numbers.
+func normalizeDiff(diff string) string {
+	var lines []string
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "index ") {
+			continue
+		}
+		lines = append(lines, hunkLineNumbers.ReplaceAllString(line, "@@"))
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n") + "\n"
+}
+
+// diffFiles returns the headers of the files modified by a diff.
+func diffFiles(diff string) string {
+	var files []string
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "diff ") {
+			files = append(files, line)
+		}
+	}
+	return strings.Join(files, "\n")
+}
+
+// PatchDiff extracts the diff from a patch email body.
+func PatchDiff(body string) string {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	start := strings.Index(body, "\ndiff ")
+	if start < 0 {
+		return ""
+	}
+	body = body[start+1:]
+	if end := strings.Index(body, "\n-- \n"); end >= 0 {
+		body = body[:end+1]
+	}
+	return body
+}
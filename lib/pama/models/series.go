@@ -0,0 +1,183 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatchSubject is the parsed subject of a patch email such as
+// "[PATCH aerc v2 1/3] imap: fix the idler".
+type PatchSubject struct {
+	// Version of the series, 1 if not specified.
+	Version int
+	// Index of the patch in the series, 0 for the cover letter.
+	Index int
+	// Total number of patches in the series.
+	Total int
+	// Title is the subject without the prefix.
+	Title string
+}
+
+// ParseSubject parses the subject of a patch email. It returns false if the
+// subject has no [PATCH] or [RFC] prefix.
+func ParseSubject(subject string) (PatchSubject, bool) {
+	s := PatchSubject{Version: 1, Index: 1, Total: 1}
+
+	subject = strings.TrimSpace(subject)
+	if !strings.HasPrefix(subject, "[") {
+		return s, false
+	}
+	prefix, title, found := strings.Cut(subject[1:], "]")
+	if !found {
+		return s, false
+	}
+	isPatch := false
+	for _, word := range strings.Fields(prefix) {
+		lower := strings.ToLower(word)
+		switch {
+		case lower == "patch" || lower == "rfc":
+			isPatch = true
+		case strings.HasPrefix(lower, "v"):
+			if v, err := strconv.Atoi(lower[1:]); err == nil && v > 0 {
+				s.Version = v
+			}
+		case strings.Contains(lower, "/"):
+			index, total, _ := strings.Cut(lower, "/")
+			i, err := strconv.Atoi(index)
+			if err != nil {
+				continue
+			}
+			t, err := strconv.Atoi(total)
+			if err != nil {
+				continue
+			}
+			s.Index, s.Total = i, t
+		}
+	}
+	s.Title = strings.TrimSpace(title)
+	return s, isPatch
+}
+
+// PatchMessage is an email of a patch series.
+type PatchMessage struct {
+	MessageId string
+	InReplyTo string
+	Subject   PatchSubject
+}
+
+// Posting is one version of a patch series, as sent to the list.
+type Posting struct {
+	Version int
+	// Cover is the cover letter, if any.
+	Cover *PatchMessage
+	// Patches are sorted by index.
+	Patches []PatchMessage
+}
+
+// Series groups the versions of the same patch series.
+type Series struct {
+	// Name is the name of the first posting.
+	Name string
+	// Postings are sorted by version.
+	Postings []Posting
+}
+
+// Latest returns the posting with the highest version.
+func (s Series) Latest() Posting {
+	return s.Postings[len(s.Postings)-1]
+}
+
+// GroupSeries groups patch emails into series. The emails of a posting are
+// threaded under the cover letter, or under the first patch. Postings with
+// the same name are considered as versions of the same series.
+func GroupSeries(messages []PatchMessage) []Series {
+	byId := make(map[string]*PatchMessage)
+	for i := range messages {
+		byId[messages[i].MessageId] = &messages[i]
+	}
+	// follow the replies up to the first message of the same version,
+	// the message it replies to identifies the posting if not known
+	postingKey := func(msg *PatchMessage) string {
+		seen := make(map[string]bool)
+		for !seen[msg.MessageId] {
+			seen[msg.MessageId] = true
+			parent, ok := byId[msg.InReplyTo]
+			if !ok || parent.Subject.Version != msg.Subject.Version {
+				break
+			}
+			msg = parent
+		}
+		key := msg.MessageId
+		if _, ok := byId[msg.InReplyTo]; !ok && msg.InReplyTo != "" {
+			key = msg.InReplyTo
+		}
+		return fmt.Sprintf("%s v%d", key, msg.Subject.Version)
+	}
+
+	postings := make(map[string]*Posting)
+	var keys []string
+	for i := range messages {
+		msg := &messages[i]
+		key := postingKey(msg)
+		posting, ok := postings[key]
+		if !ok {
+			posting = &Posting{Version: msg.Subject.Version}
+			postings[key] = posting
+			keys = append(keys, key)
+		}
+		if msg.Subject.Index == 0 {
+			posting.Cover = msg
+		} else {
+			posting.Patches = append(posting.Patches, *msg)
+		}
+	}
+
+	var series []Series
+	index := make(map[string]int)
+	for _, key := range keys {
+		posting := postings[key]
+		sort.SliceStable(posting.Patches, func(i, j int) bool {
+			return posting.Patches[i].Subject.Index < posting.Patches[j].Subject.Index
+		})
+		name := posting.Name()
+		i, ok := index[name]
+		if !ok {
+			i = len(series)
+			index[name] = i
+			series = append(series, Series{Name: name})
+		}
+		series[i].Postings = append(series[i].Postings, *posting)
+	}
+	for _, s := range series {
+		sort.SliceStable(s.Postings, func(i, j int) bool {
+			return s.Postings[i].Version < s.Postings[j].Version
+		})
+	}
+	sort.SliceStable(series, func(i, j int) bool {
+		return series[i].Name < series[j].Name
+	})
+	return series
+}
+
+// Name returns the title of the cover letter, or of the first patch when
+// there is no cover letter.
+func (p Posting) Name() string {
+	if p.Cover != nil {
+		return p.Cover.Subject.Title
+	}
+	if len(p.Patches) > 0 {
+		return p.Patches[0].Subject.Title
+	}
+	return ""
+}
+
+// SeriesInfo describes the patches applied from a posting.
+type SeriesInfo struct {
+	// Series is the name of the series.
+	Series  string
+	Version int
+	// Trailers are the review trailers to record, by message id.
+	Trailers map[string][]string
+}
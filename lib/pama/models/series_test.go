@@ -0,0 +1,217 @@
+package models_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~rjarry/aerc/lib/pama/models"
+)
+
+func TestParseSubject(t *testing.T) {
+	tests := []struct {
+		subject string
+		want    models.PatchSubject
+		isPatch bool
+	}{
+		{
+			subject: "[PATCH] imap: fix the idler",
+			want:    models.PatchSubject{Version: 1, Index: 1, Total: 1, Title: "imap: fix the idler"},
+			isPatch: true,
+		},
+		{
+			subject: "[PATCH aerc v2 0/3] imap: support acls",
+			want:    models.PatchSubject{Version: 2, Index: 0, Total: 3, Title: "imap: support acls"},
+			isPatch: true,
+		},
+		{
+			subject: "[RFC PATCH v3 2/3] imap: add myrights",
+			want:    models.PatchSubject{Version: 3, Index: 2, Total: 3, Title: "imap: add myrights"},
+			isPatch: true,
+		},
+		{
+			subject: "Re: [PATCH] imap: fix the idler",
+			isPatch: false,
+		},
+		{
+			subject: "[aerc] release",
+			isPatch: false,
+		},
+	}
+	for _, test := range tests {
+		got, isPatch := models.ParseSubject(test.subject)
+		if isPatch != test.isPatch {
+			t.Errorf("%q: expected patch %v", test.subject, test.isPatch)
+			continue
+		}
+		if isPatch && got != test.want {
+			t.Errorf("%q: got %+v, want %+v", test.subject, got, test.want)
+		}
+	}
+}
+
+func patchMessage(id, parent, subject string) models.PatchMessage {
+	s, _ := models.ParseSubject(subject)
+	return models.PatchMessage{MessageId: id, InReplyTo: parent, Subject: s}
+}
+
+func TestGroupSeries(t *testing.T) {
+	messages := []models.PatchMessage{
+		patchMessage("v2-2", "v2-0", "[PATCH v2 2/2] second"),
+		patchMessage("v1-1", "", "[PATCH 1/2] first"),
+		patchMessage("v1-2", "v1-1", "[PATCH 2/2] second"),
+		patchMessage("v2-0", "v1-1", "[PATCH v2 0/2] first"),
+		patchMessage("v2-1", "v2-0", "[PATCH v2 1/2] first"),
+		patchMessage("other", "", "[PATCH] other"),
+		// the cover letter of this posting is missing
+		patchMessage("v3-2", "v3-0", "[PATCH v3 2/2] second"),
+		patchMessage("v3-1", "v3-0", "[PATCH v3 1/2] first"),
+	}
+	series := models.GroupSeries(messages)
+	if len(series) != 2 {
+		t.Fatalf("expected 2 series, got %+v", series)
+	}
+	if series[0].Name != "first" || series[1].Name != "other" {
+		t.Fatalf("unexpected series: %q, %q", series[0].Name, series[1].Name)
+	}
+
+	var got [][]string
+	for _, posting := range series[0].Postings {
+		var ids []string
+		if posting.Cover != nil {
+			ids = append(ids, posting.Cover.MessageId)
+		}
+		for _, patch := range posting.Patches {
+			ids = append(ids, patch.MessageId)
+		}
+		got = append(got, ids)
+	}
+	want := [][]string{
+		{"v1-1", "v1-2"},
+		{"v2-0", "v2-1", "v2-2"},
+		{"v3-1", "v3-2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if v := series[0].Latest().Version; v != 3 {
+		t.Errorf("unexpected latest version %d", v)
+	}
+}
+
+func TestParseTrailers(t *testing.T) {
+	body := `On Mon, John Doe wrote:
+> Reviewed-by: Quoted Person <quoted@example.com>
+> diff --git a/file b/file
+
+Looks good to me.
+
+reviewed-by: Jane Doe <jane@example.com>
+Tested-by: Jane Doe <jane@example.com>
+Reviewed-by: Jane Doe <jane@example.com>
+Signed-off-by: Jane Doe <jane@example.com>
+`
+	got := models.ParseTrailers(body)
+	want := []string{
+		"Reviewed-by: Jane Doe <jane@example.com>",
+		"Tested-by: Jane Doe <jane@example.com>",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestAddTrailers(t *testing.T) {
+	patch := `Subject: [PATCH] imap: fix the idler
+Message-ID: <1@example.com>
+
+The idler did not stop.
+
+Signed-off-by: John Doe <john@example.com>
+---
+ worker/imap/idler.go | 2 +-
+ 1 file changed, 1 insertion(+), 1 deletion(-)
+`
+	trailers := []string{
+		"Reviewed-by: Jane Doe <jane@example.com>",
+		"Signed-off-by: John Doe <john@example.com>",
+	}
+	want := `Subject: [PATCH] imap: fix the idler
+Message-ID: <1@example.com>
+
+The idler did not stop.
+
+Signed-off-by: John Doe <john@example.com>
+Reviewed-by: Jane Doe <jane@example.com>
+---
+ worker/imap/idler.go | 2 +-
+ 1 file changed, 1 insertion(+), 1 deletion(-)
+`
+	if got := models.AddTrailers(patch, trailers); got != want {
+		t.Errorf("unexpected patch:\n%s", got)
+	}
+
+	crlf := strings.ReplaceAll(patch, "\n", "\r\n")
+	if got := models.AddTrailers(crlf, trailers); got != strings.ReplaceAll(want, "\n", "\r\n") {
+		t.Errorf("unexpected CRLF patch:\n%q", got)
+	}
+
+	noTrailers := strings.Replace(patch, "Signed-off-by: John Doe <john@example.com>\n", "", 1)
+	got := models.AddTrailers(noTrailers, trailers[:1])
+	if !strings.Contains(got, "did not stop.\n\nReviewed-by: Jane Doe <jane@example.com>\n---\n") {
+		t.Errorf("unexpected patch without trailers:\n%s", got)
+	}
+}
+
+const diffA = `diff --git a/file b/file
+index 1111111..2222222 100644
+--- a/file
++++ b/file
+@@ -1,3 +1,3 @@
+ one
+-two
++deux
+ three
+`
+
+func TestRangeDiff(t *testing.T) {
+	rebased := strings.NewReplacer(
+		"1111111..2222222", "3333333..4444444",
+		"@@ -1,3 +1,3 @@", "@@ -10,3 +10,3 @@",
+	).Replace(diffA)
+	changed := strings.Replace(diffA, "+deux", "+zwei", 1)
+
+	old := []models.RangePatch{
+		{ID: "aaaaaaaa", Title: "first", Diff: diffA},
+		{ID: "bbbbbbbb", Title: "second", Diff: diffA},
+		{ID: "cccccccc", Title: "dropped", Diff: diffA},
+	}
+	new := []models.RangePatch{
+		{Title: "first", Diff: rebased},
+		{Title: "second", Diff: changed},
+		{Title: "added", Diff: strings.ReplaceAll(diffA, "file", "other")},
+	}
+	got := models.RangeDiff(old, new)
+	want := ` 1:  aaaaaaaa =  1:  -------- first
+ 2:  bbbbbbbb !  2:  -------- second
+    @@ -6,5 +6,5 @@
+     @@
+      one
+     -two
+    -+deux
+    ++zwei
+      three
+ 3:  cccccccc <  -:  -------- dropped
+ -:  -------- >  3:  -------- added
+`
+	if got != want {
+		t.Errorf("unexpected range-diff:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPatchDiff(t *testing.T) {
+	body := "Fix it.\n---\n file | 2 +-\n\n" + diffA + "-- \n2.45.0\n"
+	if got := models.PatchDiff(body); got != diffA {
+		t.Errorf("unexpected diff:\n%s", got)
+	}
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"strings"
+)
+
+// review trailers collected from the replies to patches
+var reviewTrailers = []string{"Acked-by", "Reviewed-by", "Tested-by"}
+
+// ParseTrailers returns the review trailers found in the body of a reply.
+// Quoted lines are ignored.
+func ParseTrailers(body string) []string {
+	var trailers []string
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, ">") {
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		for _, t := range reviewTrailers {
+			if strings.EqualFold(strings.TrimSpace(key), t) {
+				trailer := t + ": " + value
+				if !contains(trailers, trailer) {
+					trailers = append(trailers, trailer)
+				}
+			}
+		}
+	}
+	return trailers
+}
+
+// AddTrailers appends the trailers to the commit message of a patch email.
+// They are inserted before the "---" separator which precedes the diffstat,
+// after the existing trailers. Trailers already present are skipped.
+func AddTrailers(patch string, trailers []string) string {
+	eol := "\n"
+	if strings.Contains(patch, "\r\n") {
+		eol = "\r\n"
+	}
+	lines := strings.Split(patch, eol)
+
+	// skip the headers
+	start := 0
+	for i, line := range lines {
+		if line == "" {
+			start = i + 1
+			break
+		}
+	}
+	end := -1
+	for i := start; i < len(lines); i++ {
+		if lines[i] == "---" {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return patch
+	}
+
+	var missing []string
+	for _, trailer := range trailers {
+		if !contains(lines[start:end], trailer) && !contains(missing, trailer) {
+			missing = append(missing, trailer)
+		}
+	}
+	if len(missing) == 0 {
+		return patch
+	}
+
+	// trailers go right after the last non empty line of the message
+	last := end
+	for last > start && strings.TrimSpace(lines[last-1]) == "" {
+		last--
+	}
+	var result []string
+	result = append(result, lines[:last]...)
+	if last > start && !isTrailer(lines[last-1]) {
+		result = append(result, "")
+	}
+	result = append(result, missing...)
+	result = append(result, lines[end:]...)
+	return strings.Join(result, eol)
+}
+
+func isTrailer(line string) bool {
+	key, _, found := strings.Cut(line, ": ")
+	return found && key != "" && !strings.ContainsAny(key, " \t")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -88,6 +88,10 @@ func (c *mockRevctrl) Subject(commit string) string {
 	return ""
 }
 
+func (c *mockRevctrl) Message(commit string) string {
+	return c.Subject(commit)
+}
+
 func (c *mockRevctrl) Author(commit string) string {
 	return ""
 }
@@ -96,6 +100,15 @@ func (c *mockRevctrl) Date(commit string) string {
 	return ""
 }
 
+func (c *mockRevctrl) Diff(commit string) (string, error) {
+	for i, s := range c.commitIDs {
+		if s == commit {
+			return "diff --git a/file b/file\n+" + c.titles[i], nil
+		}
+	}
+	return "", errNotFound
+}
+
 func (c *mockRevctrl) Drop(commit string) error {
 	for i, s := range c.commitIDs {
 		if s == commit {
@@ -13,7 +13,7 @@ import (
 )
 
 func init() {
-	register("git", newGit)
+	register("git", 0, newGit)
 }
 
 func newGit(s string) models.RevisionController {
@@ -52,6 +52,14 @@ func (g git) Subject(commit string) string {
 	return s
 }
 
+func (g git) Message(commit string) string {
+	s, exitcode, err := g.do("log", "-1", "--pretty=%B", commit)
+	if exitcode > 0 || err != nil {
+		return ""
+	}
+	return s
+}
+
 func (g git) Author(commit string) string {
 	s, exitcode, err := g.do("log", "-1", "--pretty=%an", commit)
 	if exitcode > 0 || err != nil {
@@ -68,6 +76,14 @@ func (g git) Date(commit string) string {
 	return s
 }
 
+func (g git) Diff(commit string) (string, error) {
+	s, exitcode, err := g.do("show", "--format=", commit)
+	if exitcode > 0 {
+		return "", fmt.Errorf("failed to show commit %s", commit)
+	}
+	return s, err
+}
+
 func (g git) Drop(commit string) error {
 	_, exitcode, err := g.do("rebase", "--onto", commit+"^", commit)
 	if exitcode > 0 {
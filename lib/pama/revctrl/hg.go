@@ -0,0 +1,161 @@
+package revctrl
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/lib/pama/models"
+	"git.sr.ht/~rjarry/go-opt/v2"
+)
+
+func init() {
+	register("hg", 0, newHg)
+}
+
+func newHg(s string) models.RevisionController {
+	return &hg{path: strings.TrimSpace(s)}
+}
+
+type hg struct {
+	path string
+}
+
+func (h hg) Support() bool {
+	_, exitcode, err := h.do("root")
+	return exitcode == 0 && err == nil
+}
+
+func (h hg) Root() (string, error) {
+	s, _, err := h.do("root")
+	return s, err
+}
+
+func (h hg) Head() (string, error) {
+	s, _, err := h.do("log", "-r", ".", "-T", "{node}")
+	return s, err
+}
+
+func (h hg) History(commit string) ([]string, error) {
+	revset := fmt.Sprintf("sort(only(., %s), rev)", commit)
+	s, _, err := h.do("log", "-r", revset, "-T", "{node}\n")
+	return strings.Fields(s), err
+}
+
+func (h hg) Subject(commit string) string {
+	return h.template(commit, "{desc|firstline}")
+}
+
+func (h hg) Message(commit string) string {
+	return h.template(commit, "{desc}")
+}
+
+func (h hg) Author(commit string) string {
+	return h.template(commit, "{author|person}")
+}
+
+func (h hg) Date(commit string) string {
+	return h.template(commit, "{date|shortdate}")
+}
+
+func (h hg) template(commit, template string) string {
+	s, exitcode, err := h.do("log", "-r", commit, "-T", template)
+	if exitcode > 0 || err != nil {
+		return ""
+	}
+	return s
+}
+
+func (h hg) Diff(commit string) (string, error) {
+	s, exitcode, err := h.do("diff", "--git", "-c", commit)
+	if exitcode > 0 {
+		return "", fmt.Errorf("failed to show commit %s", commit)
+	}
+	return s, err
+}
+
+func (h hg) Drop(commit string) error {
+	// move the descendants onto the parent first since strip removes them
+	children := h.template(fmt.Sprintf("children(%s)", commit), "{node}")
+	if children != "" {
+		_, exitcode, err := h.do("--config", "extensions.rebase=",
+			"rebase", "-s", fmt.Sprintf("children(%s)", commit),
+			"-d", fmt.Sprintf("p1(%s)", commit))
+		if exitcode > 0 || err != nil {
+			return fmt.Errorf("failed to drop commit %s", commit)
+		}
+	}
+	_, exitcode, err := h.do("--config", "extensions.strip=",
+		"strip", "-r", commit)
+	if exitcode > 0 {
+		return fmt.Errorf("failed to drop commit %s", commit)
+	}
+	return err
+}
+
+func (h hg) Exists(commit string) bool {
+	s, exitcode, err := h.do("log", "-r", fmt.Sprintf("%s and ::.", commit),
+		"-T", "{node}")
+	return s != "" && exitcode == 0 && err == nil
+}
+
+func (h hg) Clean() bool {
+	// is a rebase in progress?
+	root, err := h.Root()
+	if err != nil {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(root, ".hg", "rebasestate")); !os.IsNotExist(err) {
+		log.Errorf("rebasestate exists: another rebase in progress..")
+		return false
+	}
+	// are there uncommitted changes?
+	s, exitcode, err := h.do("status", "-mard")
+	return len(s) == 0 && exitcode == 0 && err == nil
+}
+
+func (h hg) CreateWorktree(target, commit string) error {
+	root, err := h.Root()
+	if err != nil {
+		return err
+	}
+	_, exitcode, err := h.do("--config", "extensions.share=",
+		"share", "-U", root, target)
+	if exitcode > 0 {
+		return fmt.Errorf("failed to create worktree in %s: %w", target, err)
+	}
+	if err != nil {
+		return err
+	}
+	_, exitcode, err = hg{path: target}.do("update", "-r", commit)
+	if exitcode > 0 {
+		return fmt.Errorf("failed to update worktree in %s: %w", target, err)
+	}
+	return err
+}
+
+func (h hg) DeleteWorktree(target string) error {
+	// shares are plain directories pointing to the base repository
+	_, err := os.Stat(filepath.Join(target, ".hg", "sharedpath"))
+	if err != nil {
+		return fmt.Errorf("failed to delete worktree in %s: %w", target, err)
+	}
+	return os.RemoveAll(target)
+}
+
+func (h hg) ApplyCmd() string {
+	return fmt.Sprintf("hg --cwd %s import -", opt.QuoteArg(h.path))
+}
+
+func (h hg) do(args ...string) (string, int, error) {
+	proc := exec.Command("hg", "--cwd", h.path)
+	proc.Args = append(proc.Args, args...)
+	// ignore the user aliases and output settings
+	proc.Env = append(os.Environ(), "HGPLAIN=1")
+	result, err := proc.Output()
+	return string(bytes.TrimSpace(result)), proc.ProcessState.ExitCode(), err
+}
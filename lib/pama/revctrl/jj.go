@@ -0,0 +1,149 @@
+package revctrl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"git.sr.ht/~rjarry/aerc/lib/pama/models"
+	"git.sr.ht/~rjarry/go-opt/v2"
+)
+
+func init() {
+	// jj repositories are often colocated with git, detect them first
+	register("jj", 10, newJJ)
+}
+
+func newJJ(s string) models.RevisionController {
+	return &jj{path: strings.TrimSpace(s)}
+}
+
+// jj is a Jujutsu repository. The working copy commit @ is expected to be
+// empty and its parent is considered as the head. Only repositories colocated
+// with git are supported since patches are applied with git.
+type jj struct {
+	path string
+}
+
+func (j jj) Support() bool {
+	root, exitcode, err := j.do("root")
+	return exitcode == 0 && err == nil && colocated(root)
+}
+
+// colocated returns true if the git repository backing a jj repository is
+// in its root directory.
+func colocated(root string) bool {
+	_, err := os.Stat(filepath.Join(root, ".git"))
+	return err == nil
+}
+
+func (j jj) Root() (string, error) {
+	s, _, err := j.do("root")
+	return s, err
+}
+
+func (j jj) Head() (string, error) {
+	s, _, err := j.log("@-", "commit_id ++ \"\\n\"")
+	// keep the first parent of merges
+	head, _, _ := strings.Cut(s, "\n")
+	return head, err
+}
+
+func (j jj) History(commit string) ([]string, error) {
+	s, _, err := j.do("log", "--no-graph", "--reversed",
+		"-r", fmt.Sprintf("%s..@-", commit),
+		"-T", "commit_id ++ \"\\n\"")
+	return strings.Fields(s), err
+}
+
+func (j jj) Subject(commit string) string {
+	return j.template(commit, "description.first_line()")
+}
+
+func (j jj) Message(commit string) string {
+	return j.template(commit, "description")
+}
+
+func (j jj) Author(commit string) string {
+	return j.template(commit, "author.name()")
+}
+
+func (j jj) Date(commit string) string {
+	return j.template(commit, "author.timestamp().format(\"%Y-%m-%d\")")
+}
+
+func (j jj) template(commit, template string) string {
+	s, exitcode, err := j.log(commit, template)
+	if exitcode > 0 || err != nil {
+		return ""
+	}
+	return s
+}
+
+func (j jj) Diff(commit string) (string, error) {
+	s, exitcode, err := j.do("diff", "--git", "-r", commit)
+	if exitcode > 0 {
+		return "", fmt.Errorf("failed to show commit %s", commit)
+	}
+	return s, err
+}
+
+func (j jj) Drop(commit string) error {
+	// the descendants are rebased onto the parent of the abandoned commit
+	_, exitcode, err := j.do("abandon", commit)
+	if exitcode > 0 {
+		return fmt.Errorf("failed to drop commit %s", commit)
+	}
+	return err
+}
+
+func (j jj) Exists(commit string) bool {
+	s, exitcode, err := j.log(fmt.Sprintf("present(%s) & ::@-", commit), "commit_id")
+	return s != "" && exitcode == 0 && err == nil
+}
+
+func (j jj) Clean() bool {
+	// are there changes in the working copy commit?
+	s, exitcode, err := j.do("diff", "-r", "@", "--summary")
+	return len(s) == 0 && exitcode == 0 && err == nil
+}
+
+func (j jj) CreateWorktree(_, _ string) error {
+	// patches could not be applied with git in the new workspace
+	return errors.New("worktrees are not supported with jj: " +
+		"workspaces are not colocated with git")
+}
+
+func (j jj) DeleteWorktree(target string) error {
+	_, exitcode, err := j.do("workspace", "forget", filepath.Base(target))
+	if exitcode > 0 {
+		return fmt.Errorf("failed to delete worktree in %s: %w", target, err)
+	}
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(target)
+}
+
+func (j jj) ApplyCmd() string {
+	// jj cannot import patches, the commits are created by git in
+	// colocated repositories and imported by the next jj command
+	return fmt.Sprintf("git -C %s am -3 --empty drop", opt.QuoteArg(j.path))
+}
+
+func (j jj) log(revset, template string) (string, int, error) {
+	return j.do("log", "--no-graph", "-r", revset, "-T", template)
+}
+
+func (j jj) do(args ...string) (string, int, error) {
+	proc := exec.Command("jj", "--no-pager", "--color", "never")
+	proc.Args = append(proc.Args, args...)
+	proc.Dir = j.path
+	proc.Env = os.Environ()
+	result, err := proc.Output()
+	return string(bytes.TrimSpace(result)), proc.ProcessState.ExitCode(), err
+}
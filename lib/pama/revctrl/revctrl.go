@@ -3,6 +3,7 @@ package revctrl
 import (
 	"errors"
 	"fmt"
+	"sort"
 
 	"git.sr.ht/~rjarry/aerc/lib/log"
 	"git.sr.ht/~rjarry/aerc/lib/pama/models"
@@ -12,18 +13,29 @@ var ErrUnsupported = errors.New("unsupported")
 
 type factoryFunc func(string) models.RevisionController
 
-var controllers = map[string]factoryFunc{}
+type controller struct {
+	id       string
+	priority int
+	factory  factoryFunc
+}
+
+// registered controllers, by decreasing detection priority
+var controllers []controller
 
-func register(controllerID string, fn factoryFunc) {
-	controllers[controllerID] = fn
+func register(controllerID string, priority int, fn factoryFunc) {
+	controllers = append(controllers, controller{controllerID, priority, fn})
+	sort.SliceStable(controllers, func(i, j int) bool {
+		return controllers[i].priority > controllers[j].priority
+	})
 }
 
 func New(controllerID string, path string) (models.RevisionController, error) {
-	factoryFunc, ok := controllers[controllerID]
-	if !ok {
-		return nil, errors.New("cannot create revision control instance")
+	for _, c := range controllers {
+		if c.id == controllerID {
+			return c.factory(path), nil
+		}
 	}
-	return factoryFunc(path), nil
+	return nil, errors.New("cannot create revision control instance")
 }
 
 type detector interface {
@@ -32,16 +44,16 @@ type detector interface {
 }
 
 func Detect(path string) (string, string, error) {
-	for controllerID, factoryFunc := range controllers {
-		rc, ok := factoryFunc(path).(detector)
+	for _, c := range controllers {
+		rc, ok := c.factory(path).(detector)
 		if ok && rc.Support() {
-			log.Tracef("support found for %v", controllerID)
+			log.Tracef("support found for %v", c.id)
 			root, err := rc.Root()
 			if err != nil {
 				continue
 			}
 			log.Tracef("root found in %s", root)
-			return controllerID, root, nil
+			return c.id, root, nil
 		}
 	}
 	return "", "", fmt.Errorf("no supported repository found in %s", path)
@@ -0,0 +1,192 @@
+package revctrl
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// commands creating a repository and committing all the changes, and the
+// revision three commits before the head
+var setups = map[string]struct {
+	init   [][]string
+	commit func(subject string) [][]string
+	base   string
+}{
+	"git": {
+		base: "HEAD~3",
+		init: [][]string{
+			{"git", "init", "-q"},
+			{"git", "config", "user.name", "John Doe"},
+			{"git", "config", "user.email", "john@example.com"},
+		},
+		commit: func(subject string) [][]string {
+			return [][]string{
+				{"git", "add", "-A"},
+				{"git", "commit", "-q", "-m", subject},
+			}
+		},
+	},
+	"hg": {
+		base: ".~3",
+		init: [][]string{{"hg", "init"}},
+		commit: func(subject string) [][]string {
+			return [][]string{
+				{"hg", "commit", "-A", "-u", "John Doe <john@example.com>", "-m", subject},
+			}
+		},
+	},
+	"jj": {
+		base: "@----",
+		init: [][]string{
+			{"jj", "git", "init", "--colocate"},
+			{"jj", "config", "set", "--repo", "user.name", "John Doe"},
+			{"jj", "config", "set", "--repo", "user.email", "john@example.com"},
+			// the working copy commit was created with the default author
+			{"jj", "describe", "--reset-author", "--no-edit"},
+		},
+		commit: func(subject string) [][]string {
+			return [][]string{{"jj", "commit", "-m", subject}}
+		},
+	},
+}
+
+func run(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "HGPLAIN=1", "JJ_CONFIG=/dev/null")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%v: %v: %s", args, err, out)
+	}
+}
+
+func TestControllers(t *testing.T) {
+	for id, setup := range setups {
+		t.Run(id, func(t *testing.T) {
+			if _, err := exec.LookPath(id); err != nil {
+				t.Skipf("%s is not installed", id)
+			}
+			dir := t.TempDir()
+			for _, args := range setup.init {
+				run(t, dir, args...)
+			}
+			for _, name := range []string{"base", "first", "second", "third"} {
+				err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, args := range setup.commit(name) {
+					run(t, dir, args...)
+				}
+			}
+
+			sub := filepath.Join(dir, "sub")
+			if err := os.Mkdir(sub, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			detected, root, err := Detect(sub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if detected != id {
+				t.Fatalf("detected %s instead of %s", detected, id)
+			}
+			realDir, _ := filepath.EvalSymlinks(dir)
+			if root != dir && root != realDir {
+				t.Errorf("unexpected root %s", root)
+			}
+
+			rc, err := New(id, root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			head, err := rc.Head()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := rc.Subject(head); s != "third" {
+				t.Errorf("unexpected head subject %q", s)
+			}
+			if m := rc.Message(head); m != "third" {
+				t.Errorf("unexpected head message %q", m)
+			}
+			if a := rc.Author(head); a != "John Doe" {
+				t.Errorf("unexpected author %q", a)
+			}
+			if d := rc.Date(head); !regexp.MustCompile(`^\d{4}-\d\d-\d\d$`).MatchString(d) {
+				t.Errorf("unexpected date %q", d)
+			}
+			if !rc.Clean() {
+				t.Error("repository is not clean")
+			}
+			diff, err := rc.Diff(head)
+			if err != nil || !strings.Contains(diff, "+++ b/third") {
+				t.Errorf("unexpected diff %q, %v", diff, err)
+			}
+
+			history, err := rc.History(head)
+			if err != nil || len(history) != 0 {
+				t.Fatalf("unexpected history since head: %v, %v", history, err)
+			}
+			if !rc.Exists(setup.base) {
+				t.Fatal("base commit not found")
+			}
+			history, err = rc.History(setup.base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(history) != 3 || history[2] != head {
+				t.Fatalf("unexpected history: %v", history)
+			}
+			if s := rc.Subject(history[0]); s != "first" {
+				t.Errorf("unexpected subject %q", s)
+			}
+
+			if err := rc.Drop(history[1]); err != nil {
+				t.Fatal(err)
+			}
+			if rc.Exists(history[1]) {
+				t.Error("dropped commit still exists")
+			}
+			after, err := rc.History(history[0])
+			if err != nil || len(after) != 1 || rc.Subject(after[0]) != "third" {
+				t.Errorf("unexpected history after drop: %v, %v", after, err)
+			}
+		})
+	}
+}
+
+func TestColocated(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, ".jj"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if colocated(dir) {
+		t.Error("repository without git detected as colocated")
+	}
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if !colocated(dir) {
+		t.Error("colocated repository not detected")
+	}
+}
+
+func TestApplyCmdQuoting(t *testing.T) {
+	for id, expected := range map[string]string{
+		"hg": `hg --cwd '/tmp/my repo' import -`,
+		"jj": `git -C '/tmp/my repo' am -3 --empty drop`,
+	} {
+		rc, err := New(id, "/tmp/my repo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cmd := rc.ApplyCmd(); cmd != expected {
+			t.Errorf("%s: expected %q, got %q", id, expected, cmd)
+		}
+	}
+}
@@ -8,10 +8,14 @@ import (
 	"git.sr.ht/~rjarry/aerc/lib/log"
 )
 
-const dateFmt = "2006-01-02"
+const (
+	dateFmt  = "2006-01-02"
+	monthFmt = "2006-01"
+)
 
 // ParseDateRange parses a date range into a start and end date. Dates are
-// expected to be in the YYYY-MM-DD format.
+// expected to be in the YYYY-MM-DD format. The YYYY-MM format designates a
+// whole month.
 //
 // Start and end dates are connected by the range operator ".." where end date
 // is not included in the date range.
@@ -21,6 +25,16 @@ const dateFmt = "2006-01-02"
 //
 // Relative date terms (such as "1 week 1 day" or "1w 1d") can be used, too.
 func DateRange(s string) (start, end time.Time, err error) {
+	return dateRange(s, false)
+}
+
+// InclusiveDateRange is like DateRange, but a YYYY-MM-DD end date is included
+// in the date range, e.g. 2022-11-01..2022-11-05 ends on 2022-11-06.
+func InclusiveDateRange(s string) (start, end time.Time, err error) {
+	return dateRange(s, true)
+}
+
+func dateRange(s string, inclusive bool) (start, end time.Time, err error) {
 	s = cleanInput(s)
 	s = ensureRangeOp(s)
 	i := strings.Index(s, "..")
@@ -32,7 +46,11 @@ func DateRange(s string) (start, end time.Time, err error) {
 			err = fmt.Errorf("failed to parse date: %w", err)
 			return
 		}
-		end = start.AddDate(0, 0, 1)
+		if isMonth(s) {
+			end = start.AddDate(0, 1, 0)
+		} else {
+			end = start.AddDate(0, 0, 1)
+		}
 
 	case i == 0:
 		// end date only
@@ -40,7 +58,7 @@ func DateRange(s string) (start, end time.Time, err error) {
 			err = fmt.Errorf("no date found")
 			return
 		}
-		end, err = translate(s[2:])
+		end, err = translateEnd(s[2:], inclusive)
 		if err != nil {
 			err = fmt.Errorf("failed to parse date: %w", err)
 			return
@@ -57,7 +75,7 @@ func DateRange(s string) (start, end time.Time, err error) {
 			return
 		}
 		// and end dates if available
-		end, err = translate(s[(i + 2):])
+		end, err = translateEnd(s[(i+2):], inclusive)
 		if err != nil {
 			err = fmt.Errorf("failed to parse date: %w", err)
 			return
@@ -320,9 +338,36 @@ func translate(s string) (time.Time, error) {
 
 	// this is a regular date, parse it in the normal format
 	log.Infof("parse: translates %s to regular format", s0)
+	if isMonth(s) {
+		return time.Parse(monthFmt, s)
+	}
 	return time.Parse(dateFmt, s)
 }
 
+// translateEnd translates the end date of a range. A YYYY-MM month is
+// included in the range, and so is a YYYY-MM-DD day if inclusive is set.
+func translateEnd(s string, inclusive bool) (time.Time, error) {
+	end, err := translate(s)
+	switch {
+	case err != nil:
+	case isMonth(s):
+		end = end.AddDate(0, 1, 0)
+	case inclusive && isDay(s):
+		end = end.AddDate(0, 0, 1)
+	}
+	return end, err
+}
+
+func isDay(s string) bool {
+	_, err := time.Parse(dateFmt, s)
+	return err == nil
+}
+
+func isMonth(s string) bool {
+	_, err := time.Parse(monthFmt, s)
+	return err == nil
+}
+
 // bod returns the begin of the day
 func bod(t time.Time) time.Time {
 	y, m, d := t.Date()
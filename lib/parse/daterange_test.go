@@ -34,6 +34,20 @@ func TestParseDateRange(t *testing.T) {
 			start: date("2022-11-01"),
 			end:   date("2022-11-05"),
 		},
+		{
+			s:     "2022-11",
+			start: date("2022-11-01"),
+			end:   date("2022-12-01"),
+		},
+		{
+			s:     "2022-01..2022-03",
+			start: date("2022-01-01"),
+			end:   date("2022-04-01"),
+		},
+		{
+			s:   "..2022-03",
+			end: date("2022-04-01"),
+		},
 	}
 
 	for _, test := range tests {
@@ -55,6 +69,47 @@ func TestParseDateRange(t *testing.T) {
 	}
 }
 
+func TestParseInclusiveDateRange(t *testing.T) {
+	dateFmt := "2006-01-02"
+	date := func(s string) time.Time { d, _ := time.Parse(dateFmt, s); return d }
+	tests := []struct {
+		s     string
+		start time.Time
+		end   time.Time
+	}{
+		{
+			s:     "2024-01..2024-03",
+			start: date("2024-01-01"),
+			end:   date("2024-04-01"),
+		},
+		{
+			s:     "2022-11-01..2022-11-05",
+			start: date("2022-11-01"),
+			end:   date("2022-11-06"),
+		},
+		{
+			s:   "..2022-11-05",
+			end: date("2022-11-06"),
+		},
+		{
+			s:     "2022-11-01",
+			start: date("2022-11-01"),
+			end:   date("2022-11-02"),
+		},
+	}
+
+	for _, test := range tests {
+		start, end, err := parse.InclusiveDateRange(test.s)
+		if err != nil {
+			t.Errorf("%s: %v", test.s, err)
+		}
+		if !start.Equal(test.start) || !end.Equal(test.end) {
+			t.Errorf("%s: expected %v..%v, got %v..%v", test.s,
+				test.start, test.end, start, end)
+		}
+	}
+}
+
 func TestParseRelativeDate(t *testing.T) {
 	tests := []struct {
 		s    string
@@ -0,0 +1,102 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const clockFmt = "15:04"
+
+// DateTime parses a point in time in the future.
+//
+// It accepts an optional day followed by an optional HH:MM time of day. The
+// day can be a YYYY-MM-DD date, "today", "tomorrow" or a week day name (which
+// refers to the next occurrence of that day). If no time of day is given,
+// midnight is assumed. If only a time of day is given and it has already
+// passed, it refers to the next day.
+//
+// Relative offsets from now are also accepted when prefixed with "+", using
+// either Go duration units (e.g. "+2h30m") or the relative date units from
+// RelativeDate (e.g. "+1d", "+2 weeks").
+func DateTime(s string) (time.Time, error) {
+	now := time.Now()
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return now, fmt.Errorf("empty string")
+	}
+
+	if rel, ok := strings.CutPrefix(s, "+"); ok {
+		rel = cleanInput(rel)
+		if d, err := time.ParseDuration(rel); err == nil {
+			return now.Add(d), nil
+		}
+		r, err := RelativeDate(rel)
+		if err != nil {
+			return now, err
+		}
+		return now.AddDate(int(r.Year), int(r.Month), int(r.Day)), nil
+	}
+
+	var day time.Time
+	var hour, minute int
+	hasDay, hasClock := false, false
+
+	for _, f := range strings.Fields(s) {
+		if t, err := time.Parse(clockFmt, f); err == nil && !hasClock {
+			hour, minute = t.Hour(), t.Minute()
+			hasClock = true
+			continue
+		}
+		if hasDay {
+			return now, fmt.Errorf("unexpected term: %s", f)
+		}
+		d, err := futureDay(f, now)
+		if err != nil {
+			return now, err
+		}
+		day = d
+		hasDay = true
+	}
+
+	// the time of day is not a duration on days when the clocks change
+	at := func(day time.Time) time.Time {
+		y, m, d := day.Date()
+		return time.Date(y, m, d, hour, minute, 0, 0, day.Location())
+	}
+	if !hasDay {
+		day = bod(now)
+		if !at(day).After(now) {
+			day = day.AddDate(0, 0, 1)
+		}
+	}
+	t := at(day)
+	if !t.After(now) {
+		return now, fmt.Errorf("%s is in the past", t.Format("2006-01-02 15:04"))
+	}
+	return t, nil
+}
+
+// futureDay returns the beginning of the day designated by s.
+func futureDay(s string, now time.Time) (time.Time, error) {
+	today := bod(now)
+	switch s {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	}
+	if len(s) >= 3 {
+		for wd := time.Sunday; wd <= time.Saturday; wd++ {
+			name := strings.ToLower(wd.String())
+			if strings.HasPrefix(name, s) {
+				diff := (int(wd) - int(now.Weekday()) + 7) % 7
+				if diff == 0 {
+					diff = 7
+				}
+				return today.AddDate(0, 0, diff), nil
+			}
+		}
+	}
+	return time.ParseInLocation(dateFmt, s, time.Local)
+}
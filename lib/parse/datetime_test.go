@@ -0,0 +1,87 @@
+package parse_test
+
+import (
+	"testing"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/lib/parse"
+)
+
+func TestParseDateTime(t *testing.T) {
+	now := time.Now()
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		s        string
+		expected time.Time
+		delta    time.Duration
+	}{
+		{s: "+2h30m", expected: now.Add(150 * time.Minute), delta: time.Minute},
+		{s: "+1d", expected: now.AddDate(0, 0, 1), delta: time.Minute},
+		{s: "+2 weeks", expected: now.AddDate(0, 0, 14), delta: time.Minute},
+		{s: "tomorrow", expected: today.AddDate(0, 0, 1)},
+		{s: "tomorrow 08:30", expected: time.Date(y, m, d+1, 8, 30, 0, 0, time.Local)},
+		{s: "2099-12-24 18:00", expected: time.Date(2099, 12, 24, 18, 0, 0, 0, time.Local)},
+		{s: "18:00 2099-12-24", expected: time.Date(2099, 12, 24, 18, 0, 0, 0, time.Local)},
+		{s: "2099-01-02", expected: time.Date(2099, 1, 2, 0, 0, 0, 0, time.Local)},
+	}
+	for _, test := range tests {
+		t.Run(test.s, func(t *testing.T) {
+			got, err := parse.DateTime(test.s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			diff := got.Sub(test.expected)
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff > test.delta {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+
+	got, err := parse.DateTime("fri")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Weekday() != time.Friday || !got.After(now) ||
+		got.After(now.AddDate(0, 0, 7)) {
+		t.Errorf("unexpected date for friday: %v", got)
+	}
+
+	got, err = parse.DateTime("12:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Hour() != 12 || !got.After(now) || got.After(now.AddDate(0, 0, 1)) {
+		t.Errorf("unexpected date for 12:00: %v", got)
+	}
+
+	for _, s := range []string{"", "2001-01-01", "today", "next week", "+foo"} {
+		if _, err := parse.DateTime(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestParseDateTimeDST(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip(err)
+	}
+	local := time.Local
+	time.Local = loc
+	defer func() { time.Local = local }()
+
+	// clocks go forward and backward on these days
+	for _, s := range []string{"2099-03-29 08:30", "2099-10-25 08:30"} {
+		got, err := parse.DateTime(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Hour() != 8 || got.Minute() != 30 {
+			t.Errorf("%s: got %v", s, got)
+		}
+	}
+}
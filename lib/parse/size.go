@@ -0,0 +1,33 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Size parses a size in bytes with an optional K, M or G suffix (powers of
+// 1024), e.g. "500K" or "1M".
+func Size(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	mult := uint64(1)
+	if s != "" {
+		switch unicode.ToUpper(rune(s[len(s)-1])) {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		}
+		if mult != 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
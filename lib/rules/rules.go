@@ -0,0 +1,123 @@
+// Package rules matches incoming messages against the client side rules
+// defined in rules.conf.
+package rules
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"git.sr.ht/~rjarry/aerc/config"
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/lib"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+	"github.com/emersion/go-message/textproto"
+)
+
+// Select returns the rules that apply to a folder of an account, in order.
+func Select(rules []*config.RuleConfig, account, folder string) []*config.RuleConfig {
+	var selected []*config.RuleConfig
+	for _, rule := range rules {
+		if rule.Matches(account, folder) {
+			selected = append(selected, rule)
+		}
+	}
+	return selected
+}
+
+// NeedsBody returns true if the message body is required to evaluate the
+// rule conditions.
+func NeedsBody(rule *config.RuleConfig) bool {
+	return rule.Body != "" || rule.Text != ""
+}
+
+// Criteria converts the rule conditions into search criteria.
+func Criteria(rule *config.RuleConfig) *types.SearchCriteria {
+	criteria := &types.SearchCriteria{}
+	if rule.From != "" {
+		criteria.From = []string{rule.From}
+	}
+	if rule.To != "" {
+		criteria.To = []string{rule.To}
+	}
+	if rule.Cc != "" {
+		criteria.Cc = []string{rule.Cc}
+	}
+	criteria.PrepareHeader()
+	if rule.Subject != "" {
+		criteria.Headers.Add("Subject", rule.Subject)
+	}
+	for _, h := range rule.Headers {
+		criteria.Headers.Add(h.Name, h.Value)
+	}
+	switch {
+	case rule.Body != "":
+		criteria.SearchBody = true
+		criteria.Terms = []string{rule.Body}
+	case rule.Text != "":
+		criteria.SearchAll = true
+		criteria.Terms = []string{rule.Text}
+	}
+	return criteria
+}
+
+// Match returns true if the message matches all the rule conditions. The
+// data must contain at least the message headers, and the full message if
+// NeedsBody returns true.
+func Match(rule *config.RuleConfig, uid models.UID, data []byte) (bool, error) {
+	criteria := Criteria(rule)
+	msg := &message{uid: uid, data: data}
+	return lib.SearchMessage(msg, criteria, lib.GetRequiredParts(criteria))
+}
+
+// Tags splits the rule tags into labels to add and labels to remove.
+// Tags without a + or - prefix are added.
+func Tags(rule *config.RuleConfig) (add []string, remove []string) {
+	for _, tag := range rule.Tag {
+		switch {
+		case strings.HasPrefix(tag, "-"):
+			remove = append(remove, tag[1:])
+		case strings.HasPrefix(tag, "+"):
+			add = append(add, tag[1:])
+		default:
+			add = append(add, tag)
+		}
+	}
+	return add, remove
+}
+
+// Headers serializes the headers of a message so that it can be matched
+// without fetching its body.
+func Headers(info *models.MessageInfo) ([]byte, error) {
+	var buf bytes.Buffer
+	if info.RFC822Headers != nil {
+		err := textproto.WriteHeader(&buf, info.RFC822Headers.Header.Header)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes(), nil
+}
+
+type message struct {
+	uid  models.UID
+	data []byte
+}
+
+func (m *message) NewReader() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(m.data)), nil
+}
+
+func (m *message) ModelFlags() (models.Flags, error) {
+	return 0, nil
+}
+
+func (m *message) Labels() ([]string, error) {
+	return nil, nil
+}
+
+func (m *message) UID() models.UID {
+	return m.uid
+}
@@ -0,0 +1,83 @@
+package rules_test
+
+import (
+	"strings"
+	"testing"
+
+	"git.sr.ht/~rjarry/aerc/config"
+	"git.sr.ht/~rjarry/aerc/lib/rules"
+)
+
+const testMessage = "From: Joe <joe@example.org>\r\n" +
+	"To: aerc-devel@lists.sr.ht\r\n" +
+	"Subject: [PATCH] rules: fix stuff\r\n" +
+	"List-Id: <~rjarry/aerc-devel.lists.sr.ht>\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"This fixes the frobnicator.\r\n"
+
+func TestMatch(t *testing.T) {
+	headers, _, _ := strings.Cut(testMessage, "\r\n\r\n")
+	headers += "\r\n\r\n"
+
+	tests := []struct {
+		name  string
+		rule  config.RuleConfig
+		match bool
+	}{
+		{"from", config.RuleConfig{From: "joe@example"}, true},
+		{"from mismatch", config.RuleConfig{From: "bob"}, false},
+		{"subject", config.RuleConfig{Subject: "[patch]"}, true},
+		{"to and subject", config.RuleConfig{
+			To: "aerc-devel", Subject: "ignored",
+		}, false},
+		{"header", config.RuleConfig{Headers: []config.RuleHeader{
+			{Name: "List-Id", Value: "aerc-devel.lists"},
+		}}, true},
+		{"body", config.RuleConfig{Body: "frobnicator"}, true},
+		{"body mismatch", config.RuleConfig{Body: "nothing"}, false},
+		{"text", config.RuleConfig{Text: "List-Id"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := testMessage
+			if !rules.NeedsBody(&test.rule) {
+				data = headers
+			}
+			match, err := rules.Match(&test.rule, "1", []byte(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match != test.match {
+				t.Errorf("expected match=%v, got %v", test.match, match)
+			}
+		})
+	}
+}
+
+func TestTags(t *testing.T) {
+	add, remove := rules.Tags(&config.RuleConfig{
+		Tag: []string{"+lists", "-inbox", "patches"},
+	})
+	if strings.Join(add, ",") != "lists,patches" {
+		t.Errorf("unexpected added tags %v", add)
+	}
+	if strings.Join(remove, ",") != "inbox" {
+		t.Errorf("unexpected removed tags %v", remove)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	all := []*config.RuleConfig{
+		{Name: "any"},
+		{Name: "work", Accounts: []string{"work"}},
+		{Name: "inbox", Folders: []string{"INBOX"}},
+	}
+	var names []string
+	for _, rule := range rules.Select(all, "home", "INBOX") {
+		names = append(names, rule.Name)
+	}
+	if strings.Join(names, ",") != "any,inbox" {
+		t.Errorf("unexpected rules %v", names)
+	}
+}
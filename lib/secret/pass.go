@@ -0,0 +1,54 @@
+package secret
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// passStore keeps the passwords in a pass or gopass store, under
+// aerc/<account>/<service>.
+type passStore struct {
+	command string
+}
+
+func (s *passStore) entry(account, service string) string {
+	return path.Join("aerc", account, service)
+}
+
+func (s *passStore) LookupCmd(account, service string) *exec.Cmd {
+	return exec.Command(s.command, "show", s.entry(account, service))
+}
+
+func (s *passStore) Lookup(account, service string) (string, error) {
+	cmd := s.LookupCmd(account, service)
+	cmd.Stdin = os.Stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(stderr.String(), "not in the password store") ||
+			strings.Contains(stderr.String(), "not found") {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("%s: %s: %w", s.command,
+			strings.TrimSpace(stderr.String()), err)
+	}
+	password, _, _ := strings.Cut(string(out), "\n")
+	return password, nil
+}
+
+func (s *passStore) Save(account, service, password string) error {
+	cmd := exec.Command(s.command, "insert", "--multiline", "--force",
+		s.entry(account, service))
+	cmd.Stdin = strings.NewReader(password + "\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", s.command,
+			strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// Names of the supported stores, as used in the cred-store account option.
+const (
+	SecretService = "secret-service"
+	Pass          = "pass"
+	Gopass        = "gopass"
+)
+
+// Services of an account.
+const (
+	Source   = "source"
+	Outgoing = "outgoing"
+)
+
+// ErrNotFound is returned when no password is stored for an account.
+var ErrNotFound = errors.New("password not found")
+
+// Store is a password store shared with other applications.
+type Store interface {
+	// Lookup returns the password of an account for a service.
+	Lookup(account, service string) (string, error)
+	// Save stores the password of an account for a service, replacing
+	// any previous one.
+	Save(account, service, password string) error
+}
+
+// CmdStore is a store whose passwords are printed by an external command.
+// The first line of the output is the password. The command may need to ask
+// for a passphrase with pinentry.
+type CmdStore interface {
+	Store
+	LookupCmd(account, service string) *exec.Cmd
+}
+
+// New returns the store with the given name.
+func New(name string) (Store, error) {
+	switch name {
+	case SecretService:
+		return &secretService{}, nil
+	case Pass, Gopass:
+		return &passStore{command: name}, nil
+	}
+	return nil, fmt.Errorf("unknown credential store %q", name)
+}
+
+// Available returns the names of the stores that can be used on this
+// system.
+func Available() []string {
+	var names []string
+	if sessionBus() {
+		names = append(names, SecretService)
+	}
+	for _, name := range []string{Pass, Gopass} {
+		if _, err := exec.LookPath(name); err == nil {
+			names = append(names, name)
+		}
+	}
+	return names
+}
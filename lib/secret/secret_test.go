@@ -0,0 +1,306 @@
+package secret
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const fakePass = `#!/bin/sh
+case "$1" in
+show)
+	cat "$PASSWORD_STORE_DIR/$2" 2>/dev/null && exit 0
+	echo "Error: $2 is not in the password store." >&2
+	exit 1
+	;;
+insert)
+	mkdir -p "$(dirname "$PASSWORD_STORE_DIR/$4")"
+	cat > "$PASSWORD_STORE_DIR/$4"
+	;;
+esac
+`
+
+func TestPass(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PASSWORD_STORE_DIR", dir)
+	script := filepath.Join(dir, "pass")
+	if err := os.WriteFile(script, []byte(fakePass), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	store := &passStore{command: script}
+
+	if _, err := store.Lookup("test", Source); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := store.Save("test", Source, "s3cr3t"); err != nil {
+		t.Fatal(err)
+	}
+	password, err := store.Lookup("test", Source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password != "s3cr3t" {
+		t.Errorf("unexpected password %q", password)
+	}
+
+	// extra lines after the password
+	err = os.WriteFile(filepath.Join(dir, "aerc", "test", Outgoing),
+		[]byte("hunter2\nlogin: me\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, err = store.Lookup("test", Outgoing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password != "hunter2" {
+		t.Errorf("unexpected password %q", password)
+	}
+}
+
+const busConfig = `<!DOCTYPE busconfig PUBLIC
+ "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=%s</listen>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// startBus starts a private session bus.
+func startBus(t *testing.T) {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "bus.conf")
+	err = os.WriteFile(conf, []byte(fmt.Sprintf(busConfig, dir)), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(daemon, "--config-file="+conf, "--nofork",
+		"--print-address")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	address, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", strings.TrimSpace(address))
+}
+
+// stand-in secret service with a single collection, locked until the user
+// accepts a prompt
+type fakeService struct {
+	conn   *dbus.Conn
+	lock   sync.Mutex
+	locked bool
+	items  []map[string]string
+	values []string
+}
+
+const (
+	fakeCollection = dbus.ObjectPath("/org/freedesktop/secrets/collection/login")
+	fakeSession    = dbus.ObjectPath("/org/freedesktop/secrets/session/1")
+	fakePrompt     = dbus.ObjectPath("/org/freedesktop/secrets/prompt/1")
+)
+
+func itemPath(i int) dbus.ObjectPath {
+	return dbus.ObjectPath(fmt.Sprintf("%s/%d", fakeCollection, i))
+}
+
+func (s *fakeService) OpenSession(
+	algorithm string, input dbus.Variant,
+) (dbus.Variant, dbus.ObjectPath, *dbus.Error) {
+	return dbus.MakeVariant(""), fakeSession, nil
+}
+
+func (s *fakeService) SearchItems(
+	attrs map[string]string,
+) ([]dbus.ObjectPath, []dbus.ObjectPath, *dbus.Error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var unlocked, locked []dbus.ObjectPath
+	for i, item := range s.items {
+		match := true
+		for k, v := range attrs {
+			match = match && item[k] == v
+		}
+		switch {
+		case !match:
+		case s.locked:
+			locked = append(locked, itemPath(i))
+		default:
+			unlocked = append(unlocked, itemPath(i))
+		}
+	}
+	return unlocked, locked, nil
+}
+
+func (s *fakeService) Unlock(
+	objects []dbus.ObjectPath,
+) ([]dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.locked {
+		return objects, "/", nil
+	}
+	prompt := &fakePromptObject{service: s, objects: objects}
+	err := s.conn.Export(prompt, fakePrompt, promptIface)
+	if err != nil {
+		return nil, "/", dbus.MakeFailedError(err)
+	}
+	return nil, fakePrompt, nil
+}
+
+func (s *fakeService) ReadAlias(name string) (dbus.ObjectPath, *dbus.Error) {
+	return fakeCollection, nil
+}
+
+type fakePromptObject struct {
+	service *fakeService
+	objects []dbus.ObjectPath
+}
+
+func (p *fakePromptObject) Prompt(windowID string) *dbus.Error {
+	p.service.lock.Lock()
+	p.service.locked = false
+	p.service.lock.Unlock()
+	err := p.service.conn.Emit(fakePrompt, promptIface+".Completed",
+		false, dbus.MakeVariant(p.objects))
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+type fakeCollectionObject struct {
+	service *fakeService
+}
+
+func (c *fakeCollectionObject) CreateItem(
+	properties map[string]dbus.Variant, secret secretValue, replace bool,
+) (dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	s := c.service
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	attrs, ok := properties[itemIface+".Attributes"].Value().(map[string]string)
+	if !ok {
+		return "/", "/", dbus.MakeFailedError(errors.New("no attributes"))
+	}
+	s.items = append(s.items, attrs)
+	s.values = append(s.values, string(secret.Value))
+	i := len(s.items) - 1
+	err := s.conn.Export(&fakeItemObject{service: s, index: i},
+		itemPath(i), itemIface)
+	if err != nil {
+		return "/", "/", dbus.MakeFailedError(err)
+	}
+	return itemPath(i), "/", nil
+}
+
+type fakeItemObject struct {
+	service *fakeService
+	index   int
+}
+
+func (i *fakeItemObject) GetSecret(
+	session dbus.ObjectPath,
+) (secretValue, *dbus.Error) {
+	i.service.lock.Lock()
+	defer i.service.lock.Unlock()
+	return secretValue{
+		Session:     session,
+		Parameters:  []byte{},
+		Value:       []byte(i.service.values[i.index]),
+		ContentType: "text/plain",
+	}, nil
+}
+
+type fakeSessionObject struct{}
+
+func (fakeSessionObject) Close() *dbus.Error {
+	return nil
+}
+
+func startFakeService(t *testing.T) *fakeService {
+	t.Helper()
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	s := &fakeService{conn: conn, locked: true}
+	for _, e := range []error{
+		conn.Export(s, servicePath, serviceIface),
+		conn.Export(&fakeCollectionObject{service: s},
+			fakeCollection, collectionIface),
+		conn.Export(fakeSessionObject{}, fakeSession, sessionIface),
+	} {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	reply, err := conn.RequestName(serviceName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("cannot own %s: %v", serviceName, err)
+	}
+	return s
+}
+
+func TestSecretService(t *testing.T) {
+	startBus(t)
+	fake := startFakeService(t)
+	store := &secretService{}
+
+	if _, err := store.Lookup("test", Source); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// the collection is unlocked by the prompt
+	if err := store.Save("test", Source, "s3cr3t"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("test", Outgoing, "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.lock.Lock()
+	fake.locked = true
+	fake.lock.Unlock()
+
+	password, err := store.Lookup("test", Outgoing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password != "hunter2" {
+		t.Errorf("unexpected password %q", password)
+	}
+	if _, err := store.Lookup("other", Source); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
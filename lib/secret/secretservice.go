@@ -0,0 +1,215 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// freedesktop Secret Service API, implemented by gnome-keyring, KeePassXC or
+// KWallet. See https://specifications.freedesktop.org/secret-service-spec/
+const (
+	serviceName     = "org.freedesktop.secrets"
+	servicePath     = "/org/freedesktop/secrets"
+	serviceIface    = "org.freedesktop.Secret.Service"
+	collectionIface = "org.freedesktop.Secret.Collection"
+	itemIface       = "org.freedesktop.Secret.Item"
+	promptIface     = "org.freedesktop.Secret.Prompt"
+	sessionIface    = "org.freedesktop.Secret.Session"
+
+	noPrompt = dbus.ObjectPath("/")
+)
+
+// delay given to the user to unlock a collection
+const promptTimeout = 2 * time.Minute
+
+type secretValue struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+type secretService struct{}
+
+func sessionBus() bool {
+	return os.Getenv("DBUS_SESSION_BUS_ADDRESS") != ""
+}
+
+func attributes(account, service string) map[string]string {
+	return map[string]string{
+		"application": "aerc",
+		"account":     account,
+		"service":     service,
+	}
+}
+
+// open connects to the session bus and opens a session with the secret
+// service. The secrets are not encrypted since they do not leave the
+// session bus.
+func (s *secretService) open() (*dbus.Conn, dbus.ObjectPath, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, "", fmt.Errorf("secret-service: %w", err)
+	}
+	var output dbus.Variant
+	var session dbus.ObjectPath
+	err = conn.Object(serviceName, servicePath).
+		Call(serviceIface+".OpenSession", 0, "plain", dbus.MakeVariant("")).
+		Store(&output, &session)
+	if err != nil {
+		conn.Close()
+		return nil, "", fmt.Errorf("secret-service: %w", err)
+	}
+	return conn, session, nil
+}
+
+func (s *secretService) close(conn *dbus.Conn, session dbus.ObjectPath) {
+	conn.Object(serviceName, session).Call(sessionIface+".Close", 0)
+	conn.Close()
+}
+
+func (s *secretService) Lookup(account, service string) (string, error) {
+	conn, session, err := s.open()
+	if err != nil {
+		return "", err
+	}
+	defer s.close(conn, session)
+
+	var unlocked, locked []dbus.ObjectPath
+	err = conn.Object(serviceName, servicePath).
+		Call(serviceIface+".SearchItems", 0, attributes(account, service)).
+		Store(&unlocked, &locked)
+	if err != nil {
+		return "", fmt.Errorf("secret-service: %w", err)
+	}
+	if len(unlocked) == 0 && len(locked) > 0 {
+		unlocked, err = s.unlock(conn, locked)
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(unlocked) == 0 {
+		return "", ErrNotFound
+	}
+
+	var secret secretValue
+	err = conn.Object(serviceName, unlocked[0]).
+		Call(itemIface+".GetSecret", 0, session).Store(&secret)
+	if err != nil {
+		return "", fmt.Errorf("secret-service: %w", err)
+	}
+	return string(secret.Value), nil
+}
+
+func (s *secretService) Save(account, service, password string) error {
+	conn, session, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer s.close(conn, session)
+
+	var collection dbus.ObjectPath
+	err = conn.Object(serviceName, servicePath).
+		Call(serviceIface+".ReadAlias", 0, "default").Store(&collection)
+	if err != nil {
+		return fmt.Errorf("secret-service: %w", err)
+	}
+	if collection == noPrompt {
+		return errors.New("secret-service: no default collection")
+	}
+	if _, err := s.unlock(conn, []dbus.ObjectPath{collection}); err != nil {
+		return err
+	}
+
+	properties := map[string]dbus.Variant{
+		itemIface + ".Label": dbus.MakeVariant(
+			fmt.Sprintf("aerc: %s %s password", account, service)),
+		itemIface + ".Attributes": dbus.MakeVariant(
+			attributes(account, service)),
+	}
+	secret := secretValue{
+		Session:     session,
+		Parameters:  []byte{},
+		Value:       []byte(password),
+		ContentType: "text/plain",
+	}
+	var item, prompt dbus.ObjectPath
+	err = conn.Object(serviceName, collection).
+		Call(collectionIface+".CreateItem", 0, properties, secret, true).
+		Store(&item, &prompt)
+	if err != nil {
+		return fmt.Errorf("secret-service: %w", err)
+	}
+	if prompt != noPrompt {
+		_, err = s.prompt(conn, prompt)
+	}
+	return err
+}
+
+// unlock unlocks the given items or collections and returns the unlocked
+// ones. The user may be prompted for a password by the secret service.
+func (s *secretService) unlock(
+	conn *dbus.Conn, objects []dbus.ObjectPath,
+) ([]dbus.ObjectPath, error) {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	err := conn.Object(serviceName, servicePath).
+		Call(serviceIface+".Unlock", 0, objects).Store(&unlocked, &prompt)
+	if err != nil {
+		return nil, fmt.Errorf("secret-service: %w", err)
+	}
+	if prompt == noPrompt {
+		return unlocked, nil
+	}
+	result, err := s.prompt(conn, prompt)
+	if err != nil {
+		return nil, err
+	}
+	if paths, ok := result.Value().([]dbus.ObjectPath); ok {
+		unlocked = append(unlocked, paths...)
+	}
+	return unlocked, nil
+}
+
+// prompt shows a prompt of the secret service and waits for its result.
+func (s *secretService) prompt(
+	conn *dbus.Conn, prompt dbus.ObjectPath,
+) (dbus.Variant, error) {
+	err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(prompt),
+		dbus.WithMatchInterface(promptIface),
+		dbus.WithMatchMember("Completed"),
+	)
+	if err != nil {
+		return dbus.Variant{}, fmt.Errorf("secret-service: %w", err)
+	}
+	signals := make(chan *dbus.Signal, 1)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	err = conn.Object(serviceName, prompt).Call(promptIface+".Prompt", 0, "").Err
+	if err != nil {
+		return dbus.Variant{}, fmt.Errorf("secret-service: %w", err)
+	}
+	timeout := time.After(promptTimeout)
+	for {
+		select {
+		case sig := <-signals:
+			if sig.Path != prompt || sig.Name != promptIface+".Completed" ||
+				len(sig.Body) != 2 {
+				continue
+			}
+			if dismissed, _ := sig.Body[0].(bool); dismissed {
+				return dbus.Variant{}, errors.New("secret-service: prompt dismissed")
+			}
+			result, _ := sig.Body[1].(dbus.Variant)
+			return result, nil
+		case <-timeout:
+			return dbus.Variant{}, errors.New("secret-service: prompt timed out")
+		}
+	}
+}
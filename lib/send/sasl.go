@@ -5,12 +5,12 @@ import (
 	"net/url"
 
 	"github.com/emersion/go-sasl"
-	"golang.org/x/oauth2"
 
 	"git.sr.ht/~rjarry/aerc/lib"
+	"git.sr.ht/~rjarry/aerc/lib/oauth"
 )
 
-func newSaslClient(auth string, uri *url.URL) (sasl.Client, error) {
+func newSaslClient(auth string, uri *url.URL, account string) (sasl.Client, error) {
 	var saslClient sasl.Client
 	switch auth {
 	case "":
@@ -24,50 +24,18 @@ func newSaslClient(auth string, uri *url.URL) (sasl.Client, error) {
 		password, _ := uri.User.Password()
 		saslClient = sasl.NewPlainClient("", uri.User.Username(), password)
 	case "oauthbearer":
-		q := uri.Query()
-		oauth2 := &oauth2.Config{}
-		if q.Get("token_endpoint") != "" {
-			oauth2.ClientID = q.Get("client_id")
-			oauth2.ClientSecret = q.Get("client_secret")
-			oauth2.Scopes = []string{q.Get("scope")}
-			oauth2.Endpoint.TokenURL = q.Get("token_endpoint")
-		}
-		password, _ := uri.User.Password()
-		bearer := lib.OAuthBearer{
-			OAuth2:  oauth2,
-			Enabled: true,
-		}
-		if bearer.OAuth2.Endpoint.TokenURL != "" {
-			token, err := bearer.ExchangeRefreshToken(password)
-			if err != nil {
-				return nil, err
-			}
-			password = token.AccessToken
+		password, err := oauthToken(uri, account)
+		if err != nil {
+			return nil, err
 		}
 		saslClient = sasl.NewOAuthBearerClient(&sasl.OAuthBearerOptions{
 			Username: uri.User.Username(),
 			Token:    password,
 		})
 	case "xoauth2":
-		q := uri.Query()
-		oauth2 := &oauth2.Config{}
-		if q.Get("token_endpoint") != "" {
-			oauth2.ClientID = q.Get("client_id")
-			oauth2.ClientSecret = q.Get("client_secret")
-			oauth2.Scopes = []string{q.Get("scope")}
-			oauth2.Endpoint.TokenURL = q.Get("token_endpoint")
-		}
-		password, _ := uri.User.Password()
-		bearer := lib.Xoauth2{
-			OAuth2:  oauth2,
-			Enabled: true,
-		}
-		if bearer.OAuth2.Endpoint.TokenURL != "" {
-			token, err := bearer.ExchangeRefreshToken(password)
-			if err != nil {
-				return nil, err
-			}
-			password = token.AccessToken
+		password, err := oauthToken(uri, account)
+		if err != nil {
+			return nil, err
 		}
 		saslClient = lib.NewXoauth2Client(uri.User.Username(), password)
 	default:
@@ -75,3 +43,14 @@ func newSaslClient(auth string, uri *url.URL) (sasl.Client, error) {
 	}
 	return saslClient, nil
 }
+
+// oauthToken returns the access token to authenticate with. Without token
+// endpoint, the password is the access token.
+func oauthToken(uri *url.URL, account string) (string, error) {
+	password, _ := uri.User.Password()
+	conf := oauth.Config(uri.Query())
+	if conf.Endpoint.TokenURL == "" {
+		return password, nil
+	}
+	return oauth.AccessToken(account, conf, password)
+}
@@ -29,7 +29,8 @@ func NewSender(
 
 	switch protocol {
 	case "smtp", "smtp+insecure", "smtps":
-		w, err = newSmtpSender(protocol, auth, uri, domain, from, rcpts)
+		w, err = newSmtpSender(protocol, auth, uri, domain, from, rcpts,
+			worker.Name())
 	case "jmap":
 		w, err = newJmapSender(worker, from, rcpts, copyTo)
 	case "":
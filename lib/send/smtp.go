@@ -82,7 +82,7 @@ func (s *smtpSender) Close() error {
 
 func newSmtpSender(
 	protocol string, auth string, uri *url.URL, domain string,
-	from *mail.Address, rcpts []*mail.Address,
+	from *mail.Address, rcpts []*mail.Address, account string,
 ) (io.WriteCloser, error) {
 	var err error
 	var conn *smtp.Client
@@ -101,7 +101,7 @@ func newSmtpSender(
 		return nil, errors.Wrap(err, "Connection failed")
 	}
 
-	saslclient, err := newSaslClient(auth, uri)
+	saslclient, err := newSaslClient(auth, uri, account)
 	if err != nil {
 		conn.Close()
 		return nil, err
@@ -132,3 +132,13 @@ func newSmtpSender(
 	}
 	return s.w, nil
 }
+
+// IsPermanentError returns true if err holds a SMTP reply indicating that
+// the server will never accept the message as is (5xx status codes).
+func IsPermanentError(err error) bool {
+	var smtpErr *smtp.SMTPError
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 500
+	}
+	return false
+}
@@ -0,0 +1,463 @@
+// Package sieve implements a ManageSieve client as described in RFC 5804.
+package sieve
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-sasl"
+)
+
+// DefaultPort is the port registered for ManageSieve.
+const DefaultPort = "4190"
+
+// Script is a script stored on the server.
+type Script struct {
+	Name   string
+	Active bool
+}
+
+// Error is returned when the server replies with NO or BYE.
+type Error struct {
+	Status string
+	Code   string
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	s := "sieve: " + e.Status
+	if e.Code != "" {
+		s += " (" + e.Code + ")"
+	}
+	if e.Msg != "" {
+		s += ": " + e.Msg
+	}
+	return s
+}
+
+// Client is a ManageSieve client connection.
+type Client struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+	caps map[string]string
+	tls  bool
+}
+
+// NewClient creates a client from an established connection and reads the
+// server greeting.
+func NewClient(conn net.Conn) (*Client, error) {
+	c := &Client{conn: conn}
+	_, c.tls = conn.(*tls.Conn)
+	c.setConn(conn)
+	if err := c.readCapabilities(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) setConn(conn net.Conn) {
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+	c.w = bufio.NewWriter(conn)
+}
+
+// Capability returns the value of a capability advertised by the server.
+func (c *Client) Capability(name string) (string, bool) {
+	v, ok := c.caps[strings.ToUpper(name)]
+	return v, ok
+}
+
+// Extensions returns the Sieve extensions supported by the server.
+func (c *Client) Extensions() []string {
+	return strings.Fields(c.caps["SIEVE"])
+}
+
+// StartTLS upgrades the connection to TLS.
+func (c *Client) StartTLS(config *tls.Config) error {
+	if c.tls {
+		return errors.New("sieve: TLS already active")
+	}
+	if _, ok := c.Capability("STARTTLS"); !ok {
+		return errors.New("sieve: server does not support STARTTLS")
+	}
+	if _, err := c.cmd("STARTTLS"); err != nil {
+		return err
+	}
+	conn := tls.Client(c.conn, config)
+	if err := conn.Handshake(); err != nil {
+		return err
+	}
+	c.setConn(conn)
+	c.tls = true
+	// the server sends its capabilities again after the handshake
+	return c.readCapabilities()
+}
+
+// Authenticate logs in with the given SASL mechanism.
+func (c *Client) Authenticate(auth sasl.Client) error {
+	mech, ir, err := auth.Start()
+	if err != nil {
+		return err
+	}
+	mechs, _ := c.Capability("SASL")
+	if !containsFold(strings.Fields(mechs), mech) {
+		return fmt.Errorf("sieve: server does not support %s authentication", mech)
+	}
+	cmd := "AUTHENTICATE " + quote(mech)
+	if ir != nil {
+		cmd += " " + quote(base64.StdEncoding.EncodeToString(ir))
+	}
+	if err := c.writeLine(cmd); err != nil {
+		return err
+	}
+	for {
+		tokens, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		if len(tokens) > 0 && tokens[0].atom {
+			if err := status(tokens); err != nil {
+				return err
+			}
+			// capabilities may have changed (e.g. SASL security layer)
+			return nil
+		}
+		// server challenge
+		if len(tokens) != 1 {
+			return errors.New("sieve: invalid authentication challenge")
+		}
+		challenge, err := base64.StdEncoding.DecodeString(tokens[0].s)
+		if err != nil {
+			return fmt.Errorf("sieve: invalid challenge: %w", err)
+		}
+		resp, err := auth.Next(challenge)
+		if err != nil {
+			// abort the exchange
+			_ = c.writeLine(quote("*"))
+			_, _ = c.readResponse()
+			return err
+		}
+		if err := c.writeLine(quote(base64.StdEncoding.EncodeToString(resp))); err != nil {
+			return err
+		}
+	}
+}
+
+// ListScripts returns the scripts stored on the server.
+func (c *Client) ListScripts() ([]Script, error) {
+	lines, err := c.cmd("LISTSCRIPTS")
+	if err != nil {
+		return nil, err
+	}
+	scripts := make([]Script, 0, len(lines))
+	for _, line := range lines {
+		if len(line) == 0 || line[0].atom {
+			continue
+		}
+		s := Script{Name: line[0].s}
+		if len(line) > 1 && strings.EqualFold(line[1].s, "ACTIVE") {
+			s.Active = true
+		}
+		scripts = append(scripts, s)
+	}
+	return scripts, nil
+}
+
+// GetScript returns the contents of a script.
+func (c *Client) GetScript(name string) (string, error) {
+	lines, err := c.cmd("GETSCRIPT " + quote(name))
+	if err != nil {
+		return "", err
+	}
+	if len(lines) == 0 || len(lines[0]) == 0 {
+		return "", errors.New("sieve: empty GETSCRIPT response")
+	}
+	return lines[0][0].s, nil
+}
+
+// PutScript stores a script on the server, replacing any script with the
+// same name. The server checks the script validity before storing it.
+func (c *Client) PutScript(name, content string) error {
+	_, err := c.cmd("PUTSCRIPT " + quote(name) + " " + literal(content))
+	return err
+}
+
+// CheckScript verifies a script without storing it.
+func (c *Client) CheckScript(content string) error {
+	_, err := c.cmd("CHECKSCRIPT " + literal(content))
+	return err
+}
+
+// SetActive makes a script the active one. An empty name deactivates all
+// scripts.
+func (c *Client) SetActive(name string) error {
+	_, err := c.cmd("SETACTIVE " + quote(name))
+	return err
+}
+
+// DeleteScript removes a script from the server.
+func (c *Client) DeleteScript(name string) error {
+	_, err := c.cmd("DELETESCRIPT " + quote(name))
+	return err
+}
+
+// Logout terminates the session and closes the connection.
+func (c *Client) Logout() error {
+	_, err := c.cmd("LOGOUT")
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Close closes the connection without logging out.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) readCapabilities() error {
+	lines, err := c.readResponse()
+	if err != nil {
+		return err
+	}
+	c.caps = make(map[string]string)
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		var value string
+		if len(line) > 1 {
+			value = line[1].s
+		}
+		c.caps[strings.ToUpper(line[0].s)] = value
+	}
+	return nil
+}
+
+// cmd sends a command and returns the data lines of the response.
+func (c *Client) cmd(command string) ([][]token, error) {
+	if err := c.writeLine(command); err != nil {
+		return nil, err
+	}
+	return c.readResponse()
+}
+
+func (c *Client) writeLine(line string) error {
+	if _, err := c.w.WriteString(line + "\r\n"); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// readResponse reads lines until the status line and returns the data lines.
+func (c *Client) readResponse() ([][]token, error) {
+	var lines [][]token
+	for {
+		tokens, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(tokens) > 0 && tokens[0].atom {
+			switch strings.ToUpper(tokens[0].s) {
+			case "OK", "NO", "BYE":
+				return lines, status(tokens)
+			}
+		}
+		lines = append(lines, tokens)
+	}
+}
+
+// status returns an error unless the status line is OK.
+func status(tokens []token) error {
+	e := &Error{Status: strings.ToUpper(tokens[0].s)}
+	for _, t := range tokens[1:] {
+		if t.code {
+			e.Code = t.s
+		} else {
+			e.Msg = t.s
+		}
+	}
+	if e.Status == "OK" {
+		return nil
+	}
+	return e
+}
+
+type token struct {
+	s string
+	// unquoted word
+	atom bool
+	// parenthesized response code
+	code bool
+}
+
+// readLine reads one line of tokens. Literals are read in full and returned
+// as a single token.
+func (c *Client) readLine() ([]token, error) {
+	var tokens []token
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch b {
+		case ' ':
+		case '\r':
+			if b, err = c.r.ReadByte(); err != nil {
+				return nil, err
+			}
+			if b != '\n' {
+				return nil, errors.New("sieve: expected LF after CR")
+			}
+			return tokens, nil
+		case '\n':
+			return tokens, nil
+		case '"':
+			s, err := c.readQuoted()
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{s: s})
+		case '{':
+			s, err := c.readLiteral()
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{s: s})
+		case '(':
+			s, err := c.readCode()
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{s: s, code: true})
+		default:
+			if err := c.r.UnreadByte(); err != nil {
+				return nil, err
+			}
+			s, err := c.readAtom()
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{s: s, atom: true})
+		}
+	}
+}
+
+func (c *Client) readQuoted() (string, error) {
+	var sb strings.Builder
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			if b, err = c.r.ReadByte(); err != nil {
+				return "", err
+			}
+		case '\r', '\n':
+			return "", errors.New("sieve: unterminated quoted string")
+		}
+		sb.WriteByte(b)
+	}
+}
+
+func (c *Client) readLiteral() (string, error) {
+	spec, err := c.r.ReadString('}')
+	if err != nil {
+		return "", err
+	}
+	spec = strings.TrimSuffix(strings.TrimSuffix(spec, "}"), "+")
+	n, err := strconv.Atoi(spec)
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("sieve: invalid literal size %q", spec)
+	}
+	eol, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimRight(eol, "\r\n") != "" {
+		return "", errors.New("sieve: expected CRLF after literal size")
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(c.r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func (c *Client) readCode() (string, error) {
+	var sb strings.Builder
+	depth := 1
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return sb.String(), nil
+			}
+		case '"':
+			s, err := c.readQuoted()
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(quote(s))
+			continue
+		case '\r', '\n':
+			return "", errors.New("sieve: unterminated response code")
+		}
+		sb.WriteByte(b)
+	}
+}
+
+func (c *Client) readAtom() (string, error) {
+	var sb strings.Builder
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case ' ', '\r', '\n', '(', '"', '{':
+			return sb.String(), c.r.UnreadByte()
+		}
+		sb.WriteByte(b)
+	}
+}
+
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// literal encodes s as a non-synchronizing literal.
+func literal(s string) string {
+	return fmt.Sprintf("{%d+}\r\n%s", len(s), s)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
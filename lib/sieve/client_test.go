@@ -0,0 +1,251 @@
+package sieve_test
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-sasl"
+
+	"git.sr.ht/~rjarry/aerc/lib/sieve"
+)
+
+// fakeServer is a minimal in-memory ManageSieve server.
+type fakeServer struct {
+	t       *testing.T
+	r       *bufio.Reader
+	w       io.Writer
+	authed  bool
+	scripts map[string]string
+	active  string
+}
+
+func (s *fakeServer) reply(format string, args ...any) {
+	fmt.Fprintf(s.w, format+"\r\n", args...)
+}
+
+func (s *fakeServer) capabilities() {
+	s.reply(`"IMPLEMENTATION" "fake"`)
+	s.reply(`"SASL" "PLAIN"`)
+	s.reply(`"SIEVE" "fileinto reject"`)
+	s.reply(`"VERSION" "1.0"`)
+	s.reply(`OK "ready"`)
+}
+
+// args splits a command line into words, reading literals when needed.
+func (s *fakeServer) args(line string) []string {
+	var args []string
+	for line != "" {
+		line = strings.TrimLeft(line, " ")
+		switch {
+		case strings.HasPrefix(line, `"`):
+			end := strings.Index(line[1:], `"`) + 1
+			args = append(args, line[1:end])
+			line = line[end+1:]
+		case strings.HasPrefix(line, "{"):
+			end := strings.Index(line, "}")
+			n, _ := strconv.Atoi(strings.TrimSuffix(line[1:end], "+"))
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(s.r, buf); err != nil {
+				s.t.Error(err)
+			}
+			args = append(args, string(buf))
+			rest, _ := s.r.ReadString('\n')
+			line = strings.TrimRight(rest, "\r\n")
+		default:
+			word, rest, _ := strings.Cut(line, " ")
+			args = append(args, word)
+			line = rest
+		}
+	}
+	return args
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	s.r = bufio.NewReader(conn)
+	s.w = conn
+	s.capabilities()
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := s.args(strings.TrimRight(line, "\r\n"))
+		cmd := strings.ToUpper(args[0])
+		if !s.authed && cmd != "AUTHENTICATE" && cmd != "LOGOUT" {
+			s.reply(`NO "not authenticated"`)
+			continue
+		}
+		switch cmd {
+		case "AUTHENTICATE":
+			ir, _ := base64.StdEncoding.DecodeString(args[2])
+			if args[1] != "PLAIN" || string(ir) != "\x00user\x00secret" {
+				s.reply(`NO "bad credentials"`)
+				continue
+			}
+			s.authed = true
+			s.reply("OK")
+		case "LISTSCRIPTS":
+			for name := range s.scripts {
+				if name == s.active {
+					s.reply("%q ACTIVE", name)
+				} else {
+					s.reply("%q", name)
+				}
+			}
+			s.reply("OK")
+		case "GETSCRIPT":
+			content, ok := s.scripts[args[1]]
+			if !ok {
+				s.reply(`NO (NONEXISTENT) "no such script"`)
+				continue
+			}
+			s.reply("{%d}\r\n%s", len(content), content)
+			s.reply("OK")
+		case "PUTSCRIPT":
+			if strings.Contains(args[2], "syntax error") {
+				s.reply("NO {12}\r\nline 1: oops")
+				continue
+			}
+			s.scripts[args[1]] = args[2]
+			s.reply("OK")
+		case "SETACTIVE":
+			s.active = args[1]
+			s.reply("OK")
+		case "DELETESCRIPT":
+			if args[1] == s.active {
+				s.reply(`NO (ACTIVE) "script is active"`)
+				continue
+			}
+			delete(s.scripts, args[1])
+			s.reply("OK")
+		case "LOGOUT":
+			s.reply(`OK "bye"`)
+			return
+		default:
+			s.reply(`NO "unknown command"`)
+		}
+	}
+}
+
+func newClient(t *testing.T) *sieve.Client {
+	t.Helper()
+	server, client := net.Pipe()
+	fake := &fakeServer{t: t, scripts: make(map[string]string)}
+	go fake.serve(server)
+	c, err := sieve.NewClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClient(t *testing.T) {
+	c := newClient(t)
+
+	if v, _ := c.Capability("implementation"); v != "fake" {
+		t.Errorf("unexpected implementation %q", v)
+	}
+	if ext := c.Extensions(); len(ext) != 2 || ext[0] != "fileinto" {
+		t.Errorf("unexpected extensions %v", ext)
+	}
+
+	if _, err := c.ListScripts(); err == nil {
+		t.Fatal("commands should fail before authentication")
+	}
+	err := c.Authenticate(sasl.NewPlainClient("", "user", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script := "require \"fileinto\";\r\nif header :contains \"subject\" \"\\\"x\\\"\" {\n\tfileinto \"x\";\n}\n"
+	if err := c.PutScript("my script", script); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetActive("my script"); err != nil {
+		t.Fatal(err)
+	}
+	scripts, err := c.ListScripts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) != 1 || scripts[0].Name != "my script" || !scripts[0].Active {
+		t.Errorf("unexpected scripts %v", scripts)
+	}
+	content, err := c.GetScript("my script")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != script {
+		t.Errorf("script mismatch: %q", content)
+	}
+
+	_, err = c.GetScript("missing")
+	var serr *sieve.Error
+	if !errors.As(err, &serr) || serr.Status != "NO" || serr.Code != "NONEXISTENT" {
+		t.Errorf("unexpected error %#v", err)
+	}
+	err = c.PutScript("bad", "syntax error")
+	if !errors.As(err, &serr) || serr.Msg != "line 1: oops" {
+		t.Errorf("unexpected error %#v", err)
+	}
+	err = c.DeleteScript("my script")
+	if !errors.As(err, &serr) || serr.Code != "ACTIVE" {
+		t.Errorf("unexpected error %#v", err)
+	}
+	if err := c.SetActive(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteScript("my script"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Logout(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRule(t *testing.T) {
+	if id := sieve.ListID("aerc <~rjarry/aerc-devel.lists.sr.ht>"); id != "~rjarry/aerc-devel.lists.sr.ht" {
+		t.Errorf("unexpected list id %q", id)
+	}
+
+	rule, err := sieve.Rule("joe@example.org", "", `Lists/"x"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# messages from joe@example.org\n" +
+		"if address :is \"from\" \"joe@example.org\" {\n" +
+		"\tfileinto \"Lists/\\\"x\\\"\";\n\tstop;\n}\n"
+	if rule != expected {
+		t.Errorf("unexpected rule:\n%s", rule)
+	}
+
+	rule, err = sieve.Rule("joe@example.org", "dev.example.org", "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rule, `header :contains "list-id" "<dev.example.org>"`) {
+		t.Errorf("list id should take precedence:\n%s", rule)
+	}
+
+	script := sieve.AddRule("", rule)
+	if !strings.HasPrefix(script, "require \"fileinto\";\n") {
+		t.Errorf("fileinto not required:\n%s", script)
+	}
+	existing := "require [\"fileinto\", \"reject\"];\nkeep;"
+	script = sieve.AddRule(existing, rule)
+	if script != existing+"\n\n"+rule {
+		t.Errorf("unexpected script:\n%s", script)
+	}
+
+	if _, err := sieve.Rule("", "", "x"); err == nil {
+		t.Error("expected error without sender nor list")
+	}
+}
@@ -0,0 +1,71 @@
+package sieve
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-sasl"
+)
+
+const dialTimeout = 30 * time.Second
+
+// Dial connects to the server designated by a sieve:// URL. STARTTLS is
+// used unless the scheme is sieve+insecure. The sieves:// scheme connects
+// with TLS directly.
+func Dial(u *url.URL) (*Client, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), DefaultPort)
+	}
+	tlsConfig := &tls.Config{ServerName: u.Hostname()}
+	dialer := &net.Dialer{Timeout: dialTimeout}
+
+	switch strings.ToLower(u.Scheme) {
+	case "sieve", "sieve+insecure":
+		conn, err := dialer.Dial("tcp", host)
+		if err != nil {
+			return nil, err
+		}
+		c, err := NewClient(conn)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(u.Scheme, "+insecure") {
+			return c, nil
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			c.Close()
+			return nil, err
+		}
+		return c, nil
+	case "sieves":
+		conn, err := tls.DialWithDialer(dialer, "tcp", host, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		return NewClient(conn)
+	}
+	return nil, fmt.Errorf("sieve: unsupported scheme %q", u.Scheme)
+}
+
+// Connect dials the server and authenticates. If auth is nil, the PLAIN
+// mechanism is used with the URL credentials.
+func Connect(u *url.URL, auth sasl.Client) (*Client, error) {
+	c, err := Dial(u)
+	if err != nil {
+		return nil, err
+	}
+	if auth == nil {
+		password, _ := u.User.Password()
+		auth = sasl.NewPlainClient("", u.User.Username(), password)
+	}
+	if err := c.Authenticate(auth); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,63 @@
+package sieve
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ListID extracts the list identifier from a List-Id header value, e.g.
+// "aerc <~rjarry/aerc-devel.lists.sr.ht>" gives
+// "~rjarry/aerc-devel.lists.sr.ht".
+func ListID(header string) string {
+	header = strings.TrimSpace(header)
+	if i := strings.LastIndex(header, "<"); i >= 0 {
+		if j := strings.Index(header[i:], ">"); j > 0 {
+			return header[i+1 : i+j]
+		}
+	}
+	return header
+}
+
+// Rule returns a rule that files the messages coming from a mailing list or
+// from a sender address into a folder. If listID is not empty, it takes
+// precedence over from.
+func Rule(from, listID, folder string) (string, error) {
+	if folder == "" {
+		return "", errors.New("no folder specified")
+	}
+	var test, comment string
+	switch {
+	case listID != "":
+		test = fmt.Sprintf("header :contains \"list-id\" %s",
+			String("<"+listID+">"))
+		comment = "messages from list " + listID
+	case from != "":
+		test = fmt.Sprintf("address :is \"from\" %s", String(from))
+		comment = "messages from " + from
+	default:
+		return "", errors.New("no sender nor list to filter on")
+	}
+	return fmt.Sprintf("# %s\nif %s {\n\tfileinto %s;\n\tstop;\n}\n",
+		comment, test, String(folder)), nil
+}
+
+var requireRe = regexp.MustCompile(`(?m)^\s*require\s[^;]*"fileinto"`)
+
+// AddRule appends a rule to a script, requiring the fileinto extension if
+// needed.
+func AddRule(script, rule string) string {
+	if !requireRe.MatchString(script) {
+		script = "require \"fileinto\";\n" + script
+	}
+	if script != "" && !strings.HasSuffix(script, "\n") {
+		script += "\n"
+	}
+	return script + "\n" + rule
+}
+
+// String encodes s as a Sieve quoted string.
+func String(s string) string {
+	return quote(s)
+}
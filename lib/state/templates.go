@@ -109,7 +109,7 @@ func (d *templateData) SetThreading(info ThreadInfo) {
 func (d *templateData) SetAccount(acct *config.AccountConfig) {
 	d.account = acct
 	d.myAddresses = make(map[string]bool)
-	if acct != nil {
+	if acct != nil && acct.From != nil {
 		d.myAddresses[acct.From.Address] = true
 		for _, addr := range acct.Aliases {
 			d.myAddresses[addr.Address] = true
@@ -538,6 +538,13 @@ func (d *templateData) OriginalMIMEType() string {
 	return d.parent.MIMEType
 }
 
+func (d *templateData) OriginalComments() map[int]string {
+	if d.parent == nil {
+		return nil
+	}
+	return d.parent.Comments
+}
+
 func (d *templateData) OriginalHeader(name string) string {
 	if d.parent == nil || d.parent.RFC822Headers == nil {
 		return ""
@@ -109,6 +109,121 @@ func quote(text string) string {
 	return quoted.String()
 }
 
+// review quotes the blocks of text that have comments, with the comments
+// interleaved after the lines they refer to. comments are indexed by line
+// number. Blocks are paragraphs or, in patches, diff hunks which are preceded
+// by the header of their file. A block is quoted up to its last commented
+// line. A comment on a hunk header line refers to the whole hunk.
+func review(text string, comments map[int]string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.TrimRight(text, "\n")
+	lines := strings.Split(text, "\n")
+
+	type block struct {
+		start, end int
+		// index of the file header block of a hunk, -1 otherwise
+		header int
+		file   bool
+	}
+	var blocks []block
+	header := -1
+	for i := 0; i < len(lines); {
+		start := i
+		switch {
+		case strings.HasPrefix(lines[i], "diff "):
+			for i++; i < len(lines) && !strings.HasPrefix(lines[i], "@@"); i++ {
+				if strings.HasPrefix(lines[i], "diff ") {
+					break
+				}
+			}
+			header = len(blocks)
+			blocks = append(blocks, block{start, i, -1, true})
+		case header >= 0 && strings.HasPrefix(lines[i], "@@"):
+			for i++; i < len(lines) && isHunkLine(lines[i]); i++ {
+			}
+			blocks = append(blocks, block{start, i, header, false})
+		case lines[i] == "":
+			i++
+		default:
+			header = -1
+			for i++; i < len(lines) && lines[i] != ""; i++ {
+				if strings.HasPrefix(lines[i], "diff ") {
+					break
+				}
+			}
+			blocks = append(blocks, block{start, i, -1, false})
+		}
+	}
+
+	// comments on hunk headers are moved to the end of the hunk
+	anchors := make(map[int][]string)
+	for _, b := range blocks {
+		for i := b.start; i < b.end; i++ {
+			comment := strings.TrimSpace(comments[i])
+			if comment == "" {
+				continue
+			}
+			anchor := i
+			if b.header >= 0 && i == b.start {
+				anchor = b.end - 1
+			}
+			anchors[anchor] = append(anchors[anchor], comment)
+		}
+	}
+
+	var out strings.Builder
+	emit := func(start, end int, whole bool) {
+		var pending []string
+		for i := start; i < end; i++ {
+			pending = append(pending, lines[i])
+			if c, ok := anchors[i]; ok {
+				out.WriteString(quote(strings.Join(pending, "\n")))
+				out.WriteString("\n" + strings.Join(c, "\n\n") + "\n\n")
+				pending = nil
+			}
+		}
+		if whole && len(pending) > 0 {
+			out.WriteString(quote(strings.Join(pending, "\n")))
+		}
+	}
+	commented := func(b block) bool {
+		for i := b.start; i < b.end; i++ {
+			if _, ok := anchors[i]; ok {
+				return true
+			}
+		}
+		return false
+	}
+	emitted := make(map[int]bool)
+	for n, b := range blocks {
+		if !commented(b) {
+			continue
+		}
+		if b.header >= 0 && !emitted[b.header] {
+			h := blocks[b.header]
+			emit(h.start, h.end, true)
+			emitted[b.header] = true
+		}
+		if !emitted[n] {
+			emit(b.start, b.end, b.file)
+			emitted[n] = true
+		}
+	}
+	return strings.TrimRight(out.String(), "\n") + "\n"
+}
+
+// isHunkLine returns true if line is part of the content of a diff hunk
+func isHunkLine(line string) bool {
+	if line == "" {
+		return true
+	}
+	switch line[0] {
+	case ' ', '+', '-', '\\':
+		return line != "-- "
+	}
+	return false
+}
+
 // cmd allow to parse reply by shell command
 // text have to be passed by cmd param
 // if there is error, original string is returned
@@ -372,6 +487,7 @@ func tail(n uint, s string) string {
 
 var templateFuncs = template.FuncMap{
 	"quote":         quote,
+	"review":        review,
 	"wrapText":      wrapText,
 	"wrap":          wrap,
 	"now":           time.Now,
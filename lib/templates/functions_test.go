@@ -155,3 +155,82 @@ func TestTemplates_Tail(t *testing.T) {
 		assert.Equal(t, c.output, out)
 	}
 }
+
+func TestTemplates_Review(t *testing.T) {
+	patch := `The idler did not stop.
+
+Signed-off-by: John Doe <john@example.com>
+---
+ file | 4 ++--
+ 1 file changed, 2 insertions(+), 2 deletions(-)
+
+diff --git a/file b/file
+index 1111111..2222222 100644
+--- a/file
++++ b/file
+@@ -1,3 +1,3 @@
+ one
+-two
++deux
+ three
+@@ -10,3 +10,3 @@
+ ten
+-eleven
++onze
+ twelve
+-- 
+2.43.0
+`
+	cases := []struct {
+		comments map[int]string
+		output   string
+	}{
+		{
+			comments: map[int]string{0: "Why?"},
+			output: `> The idler did not stop.
+
+Why?
+`,
+		},
+		{
+			comments: map[int]string{14: "Typo.", 19: "Same here."},
+			output: `> diff --git a/file b/file
+> index 1111111..2222222 100644
+> --- a/file
+> +++ b/file
+> @@ -1,3 +1,3 @@
+>  one
+> -two
+> +deux
+
+Typo.
+
+> @@ -10,3 +10,3 @@
+>  ten
+> -eleven
+> +onze
+
+Same here.
+`,
+		},
+		{
+			comments: map[int]string{16: "Looks good.", 18: " "},
+			output: `> diff --git a/file b/file
+> index 1111111..2222222 100644
+> --- a/file
+> +++ b/file
+> @@ -10,3 +10,3 @@
+>  ten
+> -eleven
+> +onze
+>  twelve
+
+Looks good.
+`,
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.output, review(patch, c.comments))
+	}
+}
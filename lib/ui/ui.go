@@ -1,8 +1,10 @@
 package ui
 
 import (
+	"errors"
 	"os"
 	"os/signal"
+	"sync"
 	"sync/atomic"
 	"syscall"
 
@@ -46,13 +48,65 @@ var state struct {
 	// == 1 if suspend is pending
 	suspending uint32
 	refresh    uint32 // == 1 if a refresh has been queued
+	// closed when the terminal is detached
+	detached chan struct{}
+	attached bool
 }
 
 func Initialize(content DrawableInteractive) error {
+	return initialize(content, "/dev/tty")
+}
+
+// Attach displays the content on the terminal device of another process, when
+// aerc was started without a user interface. The terminal must be released
+// with Detach before attaching another one.
+func Attach(content DrawableInteractive, tty string) error {
+	if state.vx != nil {
+		return errors.New("a terminal is already attached")
+	}
+	if err := initialize(content, tty); err != nil {
+		return err
+	}
+	state.attached = true
+	return nil
+}
+
+// Detach releases the terminal used by Attach. The content is kept and can be
+// attached again later.
+func Detach() {
+	if !state.attached {
+		return
+	}
+	if beeper, ok := state.content.(DrawableInteractiveBeeper); ok {
+		beeper.OnBeep(nil)
+	}
+	state.content.Focus(false)
+	close(state.detached)
+	state.vx.Close()
+	state.vx = nil
+	state.ctx = nil
+	state.popover = nil
+	state.attached = false
+}
+
+// Attached returns true if the content is displayed with Attach.
+func Attached() bool {
+	return state.attached
+}
+
+// Resize makes the terminal size to be read again. It is used when the
+// terminal was attached and SIGWINCH is received by another process.
+func Resize() {
+	if state.vx != nil {
+		state.vx.Resize()
+	}
+}
+
+func initialize(content DrawableInteractive, tty string) error {
 	opts := vaxis.Options{
 		DisableMouse: !config.Ui.MouseEnabled,
 		CSIuBitMask:  vaxis.CSIuDisambiguate,
-		WithTTY:      "/dev/tty",
+		WithTTY:      tty,
 	}
 	vx, err := vaxis.New(opts)
 	if err != nil {
@@ -65,6 +119,7 @@ func Initialize(content DrawableInteractive) error {
 	state.content = content
 	state.vx = vx
 	state.ctx = NewContext(state.vx, onPopover)
+	state.detached = make(chan struct{})
 	vx.SetTitle("aerc")
 
 	Invalidate()
@@ -73,12 +128,21 @@ func Initialize(content DrawableInteractive) error {
 	}
 	content.Focus(true)
 
-	go func() {
+	go func(detached chan struct{}) {
 		defer log.PanicHandler()
-		for event := range vx.Events() {
-			Events <- event
+		for {
+			select {
+			case event := <-vx.Events():
+				select {
+				case Events <- event:
+				case <-detached:
+					return
+				}
+			case <-detached:
+				return
+			}
 		}
-	}()
+	}(state.detached)
 
 	return nil
 }
@@ -87,8 +151,10 @@ func onPopover(p *Popover) {
 	state.popover = p
 }
 
+var exitOnce sync.Once
+
 func Exit() {
-	close(Quit)
+	exitOnce.Do(func() { close(Quit) })
 }
 
 var SuspendQueue = make(chan bool, 1)
@@ -101,10 +167,16 @@ func QueueSuspend() {
 
 // SuspendScreen should be called from the main thread.
 func SuspendScreen() {
+	if state.vx == nil {
+		return
+	}
 	_ = state.vx.Suspend()
 }
 
 func ResumeScreen() {
+	if state.vx == nil {
+		return
+	}
 	err := state.vx.Resume()
 	if err != nil {
 		log.Errorf("ui: cannot resume after suspend: %v", err)
@@ -115,6 +187,10 @@ func ResumeScreen() {
 func Suspend() error {
 	var err error
 	if atomic.SwapUint32(&state.suspending, 0) != 0 {
+		if state.vx == nil || state.attached {
+			// the terminal belongs to another process group
+			return errors.New("cannot suspend without a terminal")
+		}
 		err = state.vx.Suspend()
 		if err == nil {
 			sigcont := make(chan os.Signal, 1)
@@ -133,6 +209,9 @@ func Suspend() error {
 }
 
 func Close() {
+	if state.vx == nil {
+		return
+	}
 	state.vx.Close()
 }
 
@@ -143,7 +222,7 @@ func QueueRefresh() {
 }
 
 func Render() {
-	if atomic.SwapUint32(&state.dirty, 0) != 0 {
+	if atomic.SwapUint32(&state.dirty, 0) != 0 && state.vx != nil {
 		state.vx.Window().Clear()
 		// reset popover for the next Draw
 		state.popover = nil
@@ -163,6 +242,10 @@ func Render() {
 }
 
 func HandleEvent(event vaxis.Event) {
+	if state.vx == nil {
+		// the terminal was detached
+		return
+	}
 	switch event := event.(type) {
 	case vaxis.Resize:
 		state.ctx = NewContext(state.vx, onPopover)
@@ -0,0 +1,393 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+// maximum number of operations kept in an undo journal
+const undoDepth = 32
+
+// maximum size of the deleted messages kept in an undo journal
+const undoMaxBytes = 32 << 20
+
+type UndoOp int
+
+const (
+	UndoMove UndoOp = iota
+	UndoDelete
+	UndoFlag
+	UndoLabels
+)
+
+// DeletedMessage holds a copy of a deleted message so that it can be appended
+// back to its folder.
+type DeletedMessage struct {
+	Flags models.Flags
+	Date  time.Time
+	Data  []byte
+}
+
+// LabelChange holds the labels to add and remove on a message.
+type LabelChange struct {
+	Add    []string
+	Remove []string
+}
+
+// UndoEntry describes how to revert an operation done on messages.
+type UndoEntry struct {
+	Op    UndoOp
+	Batch int
+	// Folder in which the operation was done
+	Directory string
+
+	// UndoMove: folder where the messages were moved and their Message-Id
+	Destination string
+	MessageIds  []string
+
+	// UndoDelete: copies of the deleted messages
+	Messages map[models.UID]*DeletedMessage
+	size     int
+
+	// UndoFlag: messages whose flags were changed by the operation
+	Uids   []models.UID
+	Flags  models.Flags
+	Enable bool
+
+	// UndoLabels: label changes that revert the operation
+	Labels map[models.UID]LabelChange
+
+	done bool
+}
+
+func (e *UndoEntry) String() string {
+	var n int
+	var what string
+	switch e.Op {
+	case UndoMove:
+		n = len(e.MessageIds)
+		what = "move to " + e.Destination
+	case UndoDelete:
+		n = len(e.Messages)
+		what = "deletion"
+	case UndoFlag:
+		n = len(e.Uids)
+		what = "flag change"
+	case UndoLabels:
+		n = len(e.Labels)
+		what = "label change"
+	}
+	if n == 1 {
+		return fmt.Sprintf("%s of 1 message", what)
+	}
+	return fmt.Sprintf("%s of %d messages", what, n)
+}
+
+// UndoJournal records the reversible operations done on the messages of an
+// account. All methods are safe to call on a nil journal.
+type UndoJournal struct {
+	sync.Mutex
+	// KeepDeleted allows undoing deletions by fetching a copy of the
+	// messages before they are deleted
+	KeepDeleted bool
+	entries     []*UndoEntry
+	batch       int
+	batching    bool
+}
+
+func NewUndoJournal() *UndoJournal {
+	return &UndoJournal{}
+}
+
+// Batch groups all operations recorded until the returned function is called
+// so that they are reverted together.
+func (j *UndoJournal) Batch() func() {
+	if j == nil {
+		return func() {}
+	}
+	j.Lock()
+	defer j.Unlock()
+	j.batch++
+	j.batching = true
+	return func() {
+		j.Lock()
+		j.batching = false
+		j.Unlock()
+	}
+}
+
+// Record adds a pending operation to the journal. It must be either
+// committed or discarded once the operation has completed.
+func (j *UndoJournal) Record(e *UndoEntry) {
+	if j == nil || e == nil {
+		return
+	}
+	j.Lock()
+	defer j.Unlock()
+	if !j.batching {
+		j.batch++
+	}
+	e.Batch = j.batch
+	j.entries = append(j.entries, e)
+	// drop whole batches to avoid partial reverts
+	for len(j.entries) > undoDepth {
+		oldest := j.entries[0].Batch
+		for len(j.entries) > 0 && j.entries[0].Batch == oldest {
+			j.entries = j.entries[1:]
+		}
+	}
+}
+
+// Commit marks an operation as successfully completed. The oldest
+// operations are dropped if the journal holds too many deleted messages.
+func (j *UndoJournal) Commit(e *UndoEntry) {
+	if j == nil || e == nil {
+		return
+	}
+	j.Lock()
+	defer j.Unlock()
+	e.done = true
+	if e.size > undoMaxBytes {
+		j.remove(e)
+		return
+	}
+	total := 0
+	for _, entry := range j.entries {
+		total += entry.size
+	}
+	for total > undoMaxBytes && j.entries[0].Batch != e.Batch {
+		oldest := j.entries[0].Batch
+		for len(j.entries) > 0 && j.entries[0].Batch == oldest {
+			total -= j.entries[0].size
+			j.entries = j.entries[1:]
+		}
+	}
+}
+
+// Hold accounts for n bytes of message data kept by an operation. It returns
+// false if the operation exceeds the size limit of the journal, in which case
+// it will be dropped when committed.
+func (j *UndoJournal) Hold(e *UndoEntry, n int) bool {
+	if j == nil || e == nil {
+		return false
+	}
+	j.Lock()
+	defer j.Unlock()
+	e.size += n
+	return e.size <= undoMaxBytes
+}
+
+// Discard removes a failed operation from the journal.
+func (j *UndoJournal) Discard(e *UndoEntry) {
+	if j == nil || e == nil {
+		return
+	}
+	j.Lock()
+	defer j.Unlock()
+	j.remove(e)
+}
+
+func (j *UndoJournal) remove(e *UndoEntry) {
+	for i, entry := range j.entries {
+		if entry == e {
+			j.entries = append(j.entries[:i], j.entries[i+1:]...)
+			break
+		}
+	}
+}
+
+// Len returns the number of recorded operations.
+func (j *UndoJournal) Len() int {
+	if j == nil {
+		return 0
+	}
+	j.Lock()
+	defer j.Unlock()
+	return len(j.entries)
+}
+
+// Pop removes the operations of the last batch from the journal and returns
+// them in the order they were recorded.
+func (j *UndoJournal) Pop() ([]*UndoEntry, error) {
+	if j == nil {
+		return nil, errors.New("Nothing to undo")
+	}
+	j.Lock()
+	defer j.Unlock()
+	if len(j.entries) == 0 {
+		return nil, errors.New("Nothing to undo")
+	}
+	last := j.entries[len(j.entries)-1].Batch
+	i := len(j.entries)
+	for i > 0 && j.entries[i-1].Batch == last {
+		i--
+	}
+	for _, e := range j.entries[i:] {
+		if !e.done {
+			return nil, errors.New("Operation still in progress")
+		}
+	}
+	entries := j.entries[i:]
+	j.entries = j.entries[:i:i]
+	return entries, nil
+}
+
+// SetUndoJournal sets the journal in which the operations done on the
+// messages of this store are recorded.
+func (store *MessageStore) SetUndoJournal(j *UndoJournal) {
+	store.undo = j
+}
+
+func (store *MessageStore) UndoJournal() *UndoJournal {
+	return store.undo
+}
+
+func (store *MessageStore) recordDelete(uids []models.UID) *UndoEntry {
+	if store.undo == nil || !store.undo.KeepDeleted {
+		return nil
+	}
+	entry := &UndoEntry{
+		Op:        UndoDelete,
+		Directory: store.Name,
+		Messages:  make(map[models.UID]*DeletedMessage, len(uids)),
+	}
+	size := 0
+	for _, uid := range uids {
+		m := &DeletedMessage{Date: time.Now()}
+		if info, ok := store.Messages[uid]; ok && info != nil {
+			m.Flags = info.Flags
+			if info.Envelope != nil && !info.Envelope.Date.IsZero() {
+				m.Date = info.Envelope.Date
+			}
+			size += int(info.Size)
+		}
+		entry.Messages[uid] = m
+	}
+	if size > undoMaxBytes {
+		log.Debugf("deletion of %d messages (%d bytes) cannot be undone",
+			len(uids), size)
+		return nil
+	}
+	// Keep a copy of the messages so that they can be appended back. The
+	// worker handles actions in order so this is done before they are
+	// deleted.
+	store.post(&types.FetchFullMessages{Uids: uids},
+		func(msg types.WorkerMessage) {
+			full, ok := msg.(*types.FullMessage)
+			if !ok {
+				return
+			}
+			m, ok := entry.Messages[full.Content.Uid]
+			if !ok || entry.size > undoMaxBytes {
+				return
+			}
+			data, err := io.ReadAll(full.Content.Reader)
+			if err != nil {
+				return
+			}
+			full.Content.Reader = bytes.NewReader(data)
+			if !store.undo.Hold(entry, len(data)) {
+				log.Debugf("deletion of %d messages cannot be undone: too large",
+					len(uids))
+				for _, m := range entry.Messages {
+					m.Data = nil
+				}
+				return
+			}
+			m.Data = data
+		})
+	store.undo.Record(entry)
+	return entry
+}
+
+func (store *MessageStore) recordMove(uids []models.UID, dest string) *UndoEntry {
+	if store.undo == nil {
+		return nil
+	}
+	entry := &UndoEntry{
+		Op:          UndoMove,
+		Directory:   store.Name,
+		Destination: dest,
+	}
+	// messages are looked up by Message-Id in the destination folder
+	for _, uid := range uids {
+		info, ok := store.Messages[uid]
+		if ok && info != nil && info.Envelope != nil &&
+			info.Envelope.MessageId != "" {
+			entry.MessageIds = append(entry.MessageIds,
+				info.Envelope.MessageId)
+		}
+	}
+	store.undo.Record(entry)
+	return entry
+}
+
+func (store *MessageStore) recordFlag(
+	uids []models.UID, flags models.Flags, enable bool,
+) *UndoEntry {
+	if store.undo == nil {
+		return nil
+	}
+	entry := &UndoEntry{
+		Op:        UndoFlag,
+		Directory: store.Name,
+		Flags:     flags,
+		Enable:    enable,
+	}
+	for _, uid := range uids {
+		info, ok := store.Messages[uid]
+		if ok && info != nil && info.Flags.Has(flags) == enable {
+			// unchanged
+			continue
+		}
+		entry.Uids = append(entry.Uids, uid)
+	}
+	store.undo.Record(entry)
+	return entry
+}
+
+func (store *MessageStore) recordLabels(
+	uids []models.UID, add, remove []string,
+) *UndoEntry {
+	if store.undo == nil {
+		return nil
+	}
+	entry := &UndoEntry{
+		Op:        UndoLabels,
+		Directory: store.Name,
+		Labels:    make(map[models.UID]LabelChange, len(uids)),
+	}
+	for _, uid := range uids {
+		var labels []string
+		known := false
+		if info, ok := store.Messages[uid]; ok && info != nil {
+			labels = info.Labels
+			known = true
+		}
+		var revert LabelChange
+		for _, l := range add {
+			if !known || !slices.Contains(labels, l) {
+				revert.Remove = append(revert.Remove, l)
+			}
+		}
+		for _, l := range remove {
+			if !known || slices.Contains(labels, l) {
+				revert.Add = append(revert.Add, l)
+			}
+		}
+		if len(revert.Add) > 0 || len(revert.Remove) > 0 {
+			entry.Labels[uid] = revert
+		}
+	}
+	store.undo.Record(entry)
+	return entry
+}
@@ -0,0 +1,104 @@
+package lib_test
+
+import (
+	"testing"
+
+	"git.sr.ht/~rjarry/aerc/lib"
+)
+
+func TestUndoJournal(t *testing.T) {
+	j := lib.NewUndoJournal()
+	if _, err := j.Pop(); err == nil {
+		t.Fatal("empty journal should have nothing to undo")
+	}
+
+	move := &lib.UndoEntry{Op: lib.UndoMove, Destination: "Archive"}
+	j.Record(move)
+	if _, err := j.Pop(); err == nil {
+		t.Fatal("pending operations should not be undone")
+	}
+	j.Commit(move)
+
+	failed := &lib.UndoEntry{Op: lib.UndoDelete}
+	j.Record(failed)
+	j.Discard(failed)
+
+	end := j.Batch()
+	seen := &lib.UndoEntry{Op: lib.UndoFlag}
+	unseen := &lib.UndoEntry{Op: lib.UndoFlag}
+	j.Record(seen)
+	j.Record(unseen)
+	end()
+	j.Commit(seen)
+	j.Commit(unseen)
+
+	if j.Len() != 3 {
+		t.Fatalf("expected 3 entries, got %d", j.Len())
+	}
+
+	entries, err := j.Pop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0] != seen || entries[1] != unseen {
+		t.Errorf("batch not undone at once: %v", entries)
+	}
+	entries, err = j.Pop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0] != move {
+		t.Errorf("expected move entry, got %v", entries)
+	}
+	if j.Len() != 0 {
+		t.Errorf("journal should be empty, got %d entries", j.Len())
+	}
+}
+
+func TestUndoJournalDepth(t *testing.T) {
+	j := lib.NewUndoJournal()
+	for i := 0; i < 100; i++ {
+		e := &lib.UndoEntry{Op: lib.UndoFlag}
+		j.Record(e)
+		j.Commit(e)
+	}
+	if j.Len() != 32 {
+		t.Errorf("expected 32 entries, got %d", j.Len())
+	}
+}
+
+func TestUndoJournalSize(t *testing.T) {
+	j := lib.NewUndoJournal()
+
+	old := &lib.UndoEntry{Op: lib.UndoDelete}
+	j.Record(old)
+	if !j.Hold(old, 20<<20) {
+		t.Fatal("message data should fit in the journal")
+	}
+	j.Commit(old)
+
+	recent := &lib.UndoEntry{Op: lib.UndoDelete}
+	j.Record(recent)
+	if !j.Hold(recent, 20<<20) {
+		t.Fatal("message data should fit in the journal")
+	}
+	j.Commit(recent)
+	if j.Len() != 1 {
+		t.Fatalf("expected oldest deletion to be dropped, got %d entries", j.Len())
+	}
+
+	large := &lib.UndoEntry{Op: lib.UndoDelete}
+	j.Record(large)
+	j.Hold(large, 20<<20)
+	if j.Hold(large, 20<<20) {
+		t.Fatal("message data should not fit in the journal")
+	}
+	j.Commit(large)
+	entries, err := j.Pop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0] != recent {
+		t.Errorf("expected too large deletion to be dropped, got %v", entries)
+	}
+}
@@ -9,12 +9,10 @@
 package lib
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 
-	"git.sr.ht/~rjarry/aerc/lib/xdg"
+	"git.sr.ht/~rjarry/aerc/lib/oauth"
 	"github.com/emersion/go-imap/client"
 	"github.com/emersion/go-sasl"
 	"golang.org/x/oauth2"
@@ -64,29 +62,6 @@ type Xoauth2 struct {
 	Enabled bool
 }
 
-func (c *Xoauth2) ExchangeRefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := new(oauth2.Token)
-	token.RefreshToken = refreshToken
-	token.TokenType = "Bearer"
-	return c.OAuth2.TokenSource(context.TODO(), token).Token()
-}
-
-func SaveRefreshToken(refreshToken string, acct string) error {
-	p := xdg.CachePath("aerc", acct+"-xoauth2.token")
-	_ = os.MkdirAll(xdg.CachePath("aerc"), 0o700)
-
-	return os.WriteFile(
-		p,
-		[]byte(refreshToken),
-		0o600,
-	)
-}
-
-func GetRefreshToken(acct string) ([]byte, error) {
-	p := xdg.CachePath("aerc", acct+"-xoauth2.token")
-	return os.ReadFile(p)
-}
-
 func (c *Xoauth2) Authenticate(
 	username string,
 	password string,
@@ -98,23 +73,11 @@ func (c *Xoauth2) Authenticate(
 	}
 
 	if c.OAuth2.Endpoint.TokenURL != "" {
-		usedCache := false
-		if r, err := GetRefreshToken(account); err == nil && len(r) > 0 {
-			password = string(r)
-			usedCache = true
-		}
-
-		token, err := c.ExchangeRefreshToken(password)
+		token, err := oauth.AccessToken(account, c.OAuth2, password)
 		if err != nil {
-			if usedCache {
-				return fmt.Errorf("try removing cached refresh token. %w", err)
-			}
-			return err
-		}
-		password = token.AccessToken
-		if err := SaveRefreshToken(token.RefreshToken, account); err != nil {
 			return err
 		}
+		password = token
 	}
 
 	saslClient := NewXoauth2Client(username, password)
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime"
 	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"git.sr.ht/~rjarry/go-opt/v2"
@@ -28,10 +30,13 @@ import (
 	"git.sr.ht/~rjarry/aerc/worker/types"
 
 	_ "git.sr.ht/~rjarry/aerc/commands/account"
+	_ "git.sr.ht/~rjarry/aerc/commands/acl"
 	_ "git.sr.ht/~rjarry/aerc/commands/compose"
 	_ "git.sr.ht/~rjarry/aerc/commands/msg"
 	_ "git.sr.ht/~rjarry/aerc/commands/msgview"
+	_ "git.sr.ht/~rjarry/aerc/commands/outbox"
 	_ "git.sr.ht/~rjarry/aerc/commands/patch"
+	_ "git.sr.ht/~rjarry/aerc/commands/sieve"
 )
 
 func execCommand(
@@ -44,7 +49,10 @@ func execCommand(
 	}
 	err = commands.ExecuteCommand(cmd, cmdline)
 	if errors.As(err, new(commands.ErrorExit)) {
-		ui.Exit()
+		// quitting an attached user interface leaves the daemon running
+		if !app.Detach() {
+			ui.Exit()
+		}
 		return nil
 	}
 	return err
@@ -117,6 +125,7 @@ type Opts struct {
 	ConfAccounts string   `opt:"-A,--accounts-conf" metavar:"<file>"`
 	ConfBinds    string   `opt:"-B,--binds-conf" metavar:"<file>"`
 	NoIPC        bool     `opt:"-I,--no-ipc"`
+	Daemon       bool     `opt:"-D,--daemon"`
 	Command      []string `opt:"..." required:"false" metavar:"mailto:<address> | mbox:<file> | :<command...>"`
 }
 
@@ -142,6 +151,9 @@ Options:
                      Path to configuration file to be used instead of the default.
   -I, --no-ipc       Run any commands in this aerc instance, and don't create a
                      socket for other aerc instances to communicate with this one.
+  -D, --daemon       Run without a user interface. Accounts, hooks and IPC
+                     keep working in the background. Running aerc without
+                     arguments displays the user interface of the daemon.
   mailto:<address>   Open the composer with the address(es) in the To field.
                      If aerc is already running, the composer is started in
                      this instance, otherwise aerc will be started.
@@ -163,6 +175,28 @@ func (o *Opts) ParseAccounts(arg string) error {
 	return nil
 }
 
+// stdinTTY returns the path of the terminal device connected to the standard
+// input of this process.
+func stdinTTY() (string, error) {
+	path, err := os.Readlink("/proc/self/fd/0")
+	if err != nil {
+		return "", fmt.Errorf("not a terminal: %w", err)
+	}
+	if !strings.HasPrefix(path, "/dev/pts/") && !strings.HasPrefix(path, "/dev/tty") {
+		return "", fmt.Errorf("not a terminal: %s", path)
+	}
+	return path, nil
+}
+
+// attach displays the user interface of a running aerc daemon on the terminal
+// of this process.
+func attach() error {
+	resize := make(chan os.Signal, 1)
+	signal.Notify(resize, syscall.SIGWINCH)
+	defer signal.Stop(resize)
+	return ipc.Attach(stdinTTY, resize)
+}
+
 func die(format string, args ...any) {
 	fmt.Fprintf(os.Stderr, "error: "+format+"\n", args...)
 	os.Exit(1)
@@ -199,6 +233,25 @@ func main() {
 
 	noIPC := opts.NoIPC || config.General.DisableIPC
 
+	if opts.Daemon {
+		if noIPC {
+			die("--daemon cannot be used when IPC is disabled")
+		}
+		if len(opts.Command) > 0 {
+			die("--daemon cannot be used with a command")
+		}
+	} else if len(opts.Command) == 0 && !noIPC {
+		err := attach()
+		if err == nil {
+			return // the daemon user interface was detached
+		}
+		var rpcErr *ipc.RPCError
+		if errors.As(err, &rpcErr) && rpcErr.Code != ipc.CodeMethodNotFound {
+			die("%s", err)
+		}
+		// no daemon is running, start a new aerc instance
+	}
+
 	if len(opts.Command) > 0 && !noIPC &&
 		!(config.General.DisableIPCMailto && strings.HasPrefix(opts.Command[0], "mailto:")) &&
 		!(config.General.DisableIPCMbox && strings.HasPrefix(opts.Command[0], "mbox:")) {
@@ -224,11 +277,25 @@ func main() {
 	}
 	defer c.Close()
 
+	if opts.Daemon {
+		app.SetDaemon()
+	}
 	app.Init(c, execCommand, getCompletions, &commands.CmdHistory, deferLoop)
 
-	err = ui.Initialize(app.Drawable())
-	if err != nil {
-		panic(err)
+	if opts.Daemon {
+		go func() {
+			defer log.PanicHandler()
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+			sig := <-sigs
+			log.Infof("received %v, shutting down", sig)
+			ui.Exit()
+		}()
+	} else {
+		err = ui.Initialize(app.Drawable())
+		if err != nil {
+			panic(err)
+		}
 	}
 	defer ui.Close()
 	log.UICleanup = func() {
@@ -244,7 +311,9 @@ func main() {
 
 	if !noIPC {
 		as, err := ipc.StartServer(app.IPCHandler(), startup)
-		if err != nil {
+		if err != nil && opts.Daemon {
+			die("failed to start Unix server: %v", err)
+		} else if err != nil {
 			log.Warnf("Failed to start Unix server: %v", err)
 		} else {
 			defer as.Close()
@@ -70,6 +70,30 @@ const (
 	VirtualRole Role = "virtual"
 )
 
+// Namespace of a folder, see RFC 2342
+type Namespace string
+
+const (
+	PersonalNamespace   Namespace = ""
+	OtherUsersNamespace Namespace = "other-users"
+	SharedNamespace     Namespace = "shared"
+)
+
+// Rights of a user on a folder, see RFC 4314 section 2.1
+const (
+	LookupRight     = 'l'
+	ReadRight       = 'r'
+	SeenRight       = 's'
+	WriteRight      = 'w'
+	InsertRight     = 'i'
+	PostRight       = 'p'
+	CreateRight     = 'k'
+	DeleteRight     = 'x'
+	DeleteMsgRight  = 't'
+	ExpungeRight    = 'e'
+	AdministerRight = 'a'
+)
+
 type Directory struct {
 	Name string
 	// Exists messages in the Directory
@@ -80,6 +104,24 @@ type Directory struct {
 	Unseen int
 	// IANA role
 	Role Role
+	// Personal, shared or other users folder
+	Namespace Namespace
+	// Rights of the user on the folder, empty if unknown
+	Rights string
+}
+
+// Allows returns true unless the folder rights are known and do not include
+// all the given rights.
+func (d *Directory) Allows(rights ...rune) bool {
+	if d.Rights == "" {
+		return true
+	}
+	for _, r := range rights {
+		if !strings.ContainsRune(d.Rights, r) {
+			return false
+		}
+	}
+	return true
 }
 
 type DirectoryInfo struct {
@@ -148,6 +190,9 @@ type MessageInfo struct {
 	Size          uint32
 	Uid           UID
 	Error         error
+	// Account is the name of the account that the message was merged
+	// from in a unified account.
+	Account string
 }
 
 func (mi *MessageInfo) MsgId() (msgid string, err error) {
@@ -286,6 +331,8 @@ type OriginalMail struct {
 	MIMEType      string
 	RFC822Headers *mail.Header
 	Folder        string
+	// review comments by line number of Text
+	Comments map[int]string
 }
 
 type SignatureValidity int32
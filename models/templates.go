@@ -51,6 +51,7 @@ type TemplateData interface {
 	OriginalFrom() []*mail.Address
 	OriginalMIMEType() string
 	OriginalHeader(name string) string
+	OriginalComments() map[int]string
 	Recent(folders ...string) int
 	Unread(folders ...string) int
 	Exists(folders ...string) int
@@ -0,0 +1,79 @@
+package imap
+
+import (
+	"strings"
+
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+// listNamespaces records the prefixes of the shared and other users
+// namespaces.
+func (w *IMAPWorker) listNamespaces() {
+	_, other, shared, err := w.client.namespace.Namespaces()
+	if err != nil {
+		w.worker.Warnf("cannot list namespaces: %v", err)
+		return
+	}
+	w.namespaces = make(map[string]models.Namespace)
+	for _, ns := range other {
+		w.namespaces[ns.Prefix] = models.OtherUsersNamespace
+	}
+	for _, ns := range shared {
+		w.namespaces[ns.Prefix] = models.SharedNamespace
+	}
+}
+
+// namespaceOf returns the namespace of a folder from the longest matching
+// namespace prefix.
+func (w *IMAPWorker) namespaceOf(name string) models.Namespace {
+	namespace := models.PersonalNamespace
+	longest := 0
+	for prefix, ns := range w.namespaces {
+		if prefix != "" && len(prefix) > longest && strings.HasPrefix(name, prefix) {
+			namespace = ns
+			longest = len(prefix)
+		}
+	}
+	return namespace
+}
+
+func (w *IMAPWorker) handleListACL(msg *types.ListACL) error {
+	if !w.acl {
+		return errUnsupported
+	}
+	rights, err := w.client.acl.GetACL(msg.Directory)
+	if err != nil {
+		return err
+	}
+	w.worker.PostMessage(&types.DirectoryACL{
+		Message:   types.RespondTo(msg),
+		Directory: msg.Directory,
+		Rights:    rights,
+	}, nil)
+	w.worker.PostMessage(&types.Done{Message: types.RespondTo(msg)}, nil)
+	return nil
+}
+
+func (w *IMAPWorker) handleSetACL(msg *types.SetACL) error {
+	if !w.acl {
+		return errUnsupported
+	}
+	err := w.client.acl.SetACL(msg.Directory, msg.Identifier, msg.Rights)
+	if err != nil {
+		return err
+	}
+	w.worker.PostMessage(&types.Done{Message: types.RespondTo(msg)}, nil)
+	return nil
+}
+
+func (w *IMAPWorker) handleDeleteACL(msg *types.DeleteACL) error {
+	if !w.acl {
+		return errUnsupported
+	}
+	if err := w.client.acl.DeleteACL(msg.Directory, msg.Identifier); err != nil {
+		return err
+	}
+	w.worker.PostMessage(&types.Done{Message: types.RespondTo(msg)}, nil)
+	return nil
+}
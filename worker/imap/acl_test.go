@@ -0,0 +1,66 @@
+package imap
+
+import (
+	"reflect"
+	"testing"
+
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/imap/extensions"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+func TestSharedFolders(t *testing.T) {
+	s := &stubServer{folders: []string{"INBOX", "Shared/team", "Other/bob/INBOX"}}
+	w := newStubWorker(t, s)
+	w.client.acl = extensions.NewACLClient(w.client.Client)
+	w.client.namespace = extensions.NewNamespaceClient(w.client.Client)
+	w.acl = true
+	messages := make(chan types.WorkerMessage, 100)
+	w.worker.(*types.Worker).SetMessages(messages)
+
+	w.listNamespaces()
+	w.handleListDirectories(&types.ListDirectories{})
+
+	dirs := make(map[string]models.Directory)
+	for len(messages) > 0 {
+		if msg, ok := (<-messages).(*types.Directory); ok {
+			if _, dup := dirs[msg.Dir.Name]; dup {
+				t.Errorf("%s listed twice", msg.Dir.Name)
+			}
+			dirs[msg.Dir.Name] = *msg.Dir
+		}
+	}
+	expected := map[string]models.Directory{
+		"INBOX": {Name: "INBOX", Role: models.InboxRole},
+		"Shared/team": {
+			Name:      "Shared/team",
+			Namespace: models.SharedNamespace,
+			Rights:    "lrs",
+		},
+		"Other/bob/INBOX": {
+			Name:      "Other/bob/INBOX",
+			Namespace: models.OtherUsersNamespace,
+			Rights:    "lrs",
+		},
+	}
+	if !reflect.DeepEqual(dirs, expected) {
+		t.Errorf("unexpected directories: %+v", dirs)
+	}
+	team := dirs["Shared/team"]
+	if team.Allows(models.DeleteMsgRight) || !team.Allows(models.SeenRight) {
+		t.Errorf("unexpected rights on Shared/team: %q", team.Rights)
+	}
+
+	err := w.handleListACL(&types.ListACL{Directory: "Shared/team"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, ok := (<-messages).(*types.DirectoryACL)
+	if !ok {
+		t.Fatal("no access control list")
+	}
+	acl := map[string]string{"alice": "lrswipkxte", "bob": "lrs"}
+	if !reflect.DeepEqual(msg.Rights, acl) {
+		t.Errorf("unexpected access control list: %v", msg.Rights)
+	}
+}
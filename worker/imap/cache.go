@@ -18,6 +18,7 @@ import (
 	"github.com/emersion/go-message/mail"
 	"github.com/emersion/go-message/textproto"
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 type CachedHeader struct {
@@ -138,11 +139,16 @@ func (w *IMAPWorker) getCachedHeaders(msg *types.FetchMessageHeaders) []models.U
 			continue
 		}
 
+		flags, upToDate := w.cachedFlags(uid)
+		if !upToDate {
+			// Always return a SEEN flag until the flags are fetched
+			flags = models.SeenFlag
+		}
 		hdr := &mail.Header{Header: message.Header{Header: textprotoHeader}}
 		mi := &models.MessageInfo{
 			BodyStructure: &ch.BodyStructure,
 			Envelope:      &ch.Envelope,
-			Flags:         models.SeenFlag, // Always return a SEEN flag
+			Flags:         flags,
 			Uid:           ch.Uid,
 			RFC822Headers: hdr,
 			Refs:          parse.MsgIDList(hdr, "references"),
@@ -151,8 +157,9 @@ func (w *IMAPWorker) getCachedHeaders(msg *types.FetchMessageHeaders) []models.U
 		w.worker.PostMessage(&types.MessageInfo{
 			Message:    types.RespondTo(msg),
 			Info:       mi,
-			NeedsFlags: true,
+			NeedsFlags: !upToDate,
 		}, nil)
+		w.indexHeader(ch.Uid, textprotoHeader)
 	}
 	return need
 }
@@ -168,45 +175,115 @@ func (w *IMAPWorker) cleanCache(path string) {
 	defer log.PanicHandler()
 	start := time.Now()
 	var scanned, removed int
-	iter := w.cache.NewIterator(nil, nil)
-	for iter.Next() {
-		if bytes.Equal(iter.Key(), cacheTagKey) {
-			continue
-		}
-		data := iter.Value()
-		ch := &CachedHeader{}
-		dec := gob.NewDecoder(bytes.NewReader(data))
-		err := dec.Decode(ch)
-		if err != nil {
-			w.worker.Errorf("cannot clean database %d: %v",
-				w.selected.UidValidity, err)
-			continue
-		}
-		exp := ch.Created.Add(w.config.cacheMaxAge)
-		if exp.Before(time.Now()) {
-			err = w.cache.Delete(iter.Key(), nil)
+	for _, prefix := range []string{"header.", "body."} {
+		iter := w.cache.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+		for iter.Next() {
+			data := iter.Value()
+			// both cached headers and bodies have a creation date
+			var entry struct{ Created time.Time }
+			dec := gob.NewDecoder(bytes.NewReader(data))
+			err := dec.Decode(&entry)
 			if err != nil {
 				w.worker.Errorf("cannot clean database %d: %v",
 					w.selected.UidValidity, err)
 				continue
 			}
-			removed++
+			exp := entry.Created.Add(w.config.cacheMaxAge)
+			if exp.Before(time.Now()) {
+				err = w.cache.Delete(iter.Key(), nil)
+				if err != nil {
+					w.worker.Errorf("cannot clean database %d: %v",
+						w.selected.UidValidity, err)
+					continue
+				}
+				removed++
+			}
+			scanned++
 		}
-		scanned++
+		iter.Release()
 	}
-	iter.Release()
 	elapsed := time.Since(start)
 	w.worker.Debugf("%s: removed %d/%d expired entries in %s",
 		path, removed, scanned, elapsed)
 }
 
-// clearCache clears the entire cache
+// clearCache clears the entire cache, except for the changes made offline
+// which have not been sent to the server yet
 func (w *IMAPWorker) clearCache() {
 	iter := w.cache.NewIterator(nil, nil)
 	for iter.Next() {
+		if bytes.HasPrefix(iter.Key(), journalPrefix) {
+			continue
+		}
 		if err := w.cache.Delete(iter.Key(), nil); err != nil {
 			w.worker.Errorf("error clearing cache: %v", err)
 		}
 	}
 	iter.Release()
 }
+
+func (w *IMAPWorker) bodyCacheEnabled() bool {
+	return w.config.cacheBodies && w.cache != nil
+}
+
+type CachedBody struct {
+	Data    []byte
+	Created time.Time
+}
+
+func (w *IMAPWorker) bodyKey(uid models.UID) []byte {
+	key := fmt.Sprintf("body.%s.%d.%s",
+		w.selected.Name, w.selected.UidValidity, uid)
+	return []byte(key)
+}
+
+// cacheBody stores the full content of a message, unless it is larger than
+// cache-bodies-max-size.
+func (w *IMAPWorker) cacheBody(uid models.UID, data []byte) {
+	if uint64(len(data)) > w.config.cacheBodiesMaxSize {
+		return
+	}
+	key := w.bodyKey(uid)
+	w.worker.Debugf("caching body for message %s", key)
+	buf := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buf).Encode(&CachedBody{
+		Data:    data,
+		Created: time.Now(),
+	})
+	if err != nil {
+		w.worker.Errorf("cannot encode body %s: %v", key, err)
+		return
+	}
+	if err := w.cache.Put(key, buf.Bytes(), nil); err != nil {
+		w.worker.Errorf("cannot write body for message %s: %v", key, err)
+	}
+}
+
+// getCachedBody returns the full content of a message if it was cached.
+func (w *IMAPWorker) getCachedBody(uid models.UID) []byte {
+	key := w.bodyKey(uid)
+	data, err := w.cache.Get(key, nil)
+	if err != nil {
+		return nil
+	}
+	cb := &CachedBody{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(cb); err != nil {
+		w.worker.Errorf("cannot decode cached body %s: %v", key, err)
+		return nil
+	}
+	return cb.Data
+}
+
+// cachedSize returns the size of a message from its cached header, or zero
+// if it is unknown.
+func (w *IMAPWorker) cachedSize(uid models.UID) uint32 {
+	data, err := w.cache.Get(w.headerKey(uid), nil)
+	if err != nil {
+		return 0
+	}
+	ch := &CachedHeader{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(ch); err != nil {
+		return 0
+	}
+	return ch.Size
+}
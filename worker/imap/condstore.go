@@ -0,0 +1,396 @@
+package imap
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/imap/extensions"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+// MailboxState is the synchronization state of a mailbox whose flags are
+// tracked in the cache. All flag changes up to HighestModSeq are reflected
+// in the cached flags.
+type MailboxState struct {
+	UidValidity   uint32
+	HighestModSeq uint64
+}
+
+type CachedFlags struct {
+	Flags  models.Flags
+	ModSeq uint64
+}
+
+// useCondstore returns true if the flags of the selected mailbox can be
+// tracked with CONDSTORE (RFC 7162).
+func (w *IMAPWorker) useCondstore() bool {
+	return w.condstore && w.config.cacheEnabled && w.cache != nil
+}
+
+// enableQresync enables QRESYNC (RFC 7162) on a new connection, before any
+// mailbox is selected. It is only useful when the flags are cached.
+func (w *IMAPWorker) enableQresync(c *client.Client) {
+	w.qresync = false
+	if !w.config.cacheEnabled {
+		return
+	}
+	qresync := extensions.NewQresyncClient(c)
+	if ok, err := qresync.SupportQresync(); err != nil || !ok {
+		return
+	}
+	if err := qresync.Enable(w.handleVanished); err != nil {
+		w.worker.Warnf("could not enable QRESYNC: %v", err)
+		return
+	}
+	w.qresync = true
+}
+
+// handleVanished removes the messages reported as expunged with QRESYNC. It is
+// called while the responses are read. Like their EXPUNGE responses, the
+// messages of the folders selected temporarily to perform an action are
+// ignored.
+func (w *IMAPWorker) handleVanished(set *imap.SeqSet) {
+	uids := w.seqMap.Remove(set)
+	if len(uids) == 0 {
+		return
+	}
+	w.worker.PostMessage(&types.MessagesDeleted{
+		Uids: models.Uint32ToUidList(uids),
+	}, nil)
+}
+
+// mailboxChanges are the changes reported by the server when a mailbox is
+// selected with QRESYNC.
+type mailboxChanges struct {
+	messages []*imap.Message
+	vanished *imap.SeqSet
+}
+
+// selectMailbox selects a mailbox, with CONDSTORE if its flags can be tracked
+// in the cache. The highest modification sequence of the mailbox is returned.
+// With QRESYNC, the changes since the mailbox was last opened are returned as
+// well.
+func (w *IMAPWorker) selectMailbox(
+	name string,
+) (*imap.MailboxStatus, uint64, *mailboxChanges, error) {
+	if !w.useCondstore() {
+		sel, err := w.client.Select(name, false)
+		return sel, 0, nil, err
+	}
+	state, err := w.mailboxState(name)
+	if err != nil || state == nil || !w.qresync {
+		sel, highestModSeq, err := w.client.condstore.Select(name, false)
+		return sel, highestModSeq, nil, err
+	}
+
+	changes := &mailboxChanges{}
+	messages := make(chan *imap.Message)
+	done := make(chan struct{})
+	go func() {
+		defer log.PanicHandler()
+		for msg := range messages {
+			changes.messages = append(changes.messages, msg)
+		}
+		close(done)
+	}()
+	sel, highestModSeq, vanished, err := w.client.qresync.Select(
+		name, false, state.UidValidity, state.HighestModSeq, messages)
+	<-done
+	changes.vanished = vanished
+	return sel, highestModSeq, changes, err
+}
+
+// resync brings the cached flags of the selected mailbox up to date with
+// the messages that changed since it was last opened. The changes are fetched
+// unless they were reported by the server with QRESYNC. Without QRESYNC,
+// messages which were expunged meanwhile are dropped when listing the mailbox
+// contents.
+func (w *IMAPWorker) resync(highestModSeq uint64, changes *mailboxChanges) error {
+	w.highestModSeq = 0
+	if highestModSeq == 0 {
+		// the mailbox does not support persistent modification sequences
+		return nil
+	}
+
+	state, err := w.mailboxState(w.selected.Name)
+	if err != nil {
+		return err
+	}
+	switch {
+	case state == nil:
+		w.worker.Debugf("%s: no flags in cache", w.selected.Name)
+	case state.UidValidity != w.selected.UidValidity:
+		w.worker.Debugf("%s: uid validity changed, clearing flags",
+			w.selected.Name)
+		w.deleteKeys(util.BytesPrefix(w.flagsPrefix(state.UidValidity)))
+	case state.HighestModSeq == highestModSeq:
+		w.worker.Debugf("%s: no changes since modseq %d",
+			w.selected.Name, highestModSeq)
+	case changes != nil:
+		w.worker.Debugf("%s: %d messages changed, %s expunged since modseq %d",
+			w.selected.Name, len(changes.messages), changes.vanished,
+			state.HighestModSeq)
+		for _, msg := range changes.messages {
+			w.updateFlags(msg)
+		}
+		w.dropFlags(changes.vanished)
+	default:
+		w.worker.Debugf("%s: fetching changes since modseq %d",
+			w.selected.Name, state.HighestModSeq)
+		if err := w.fetchChanges(state.HighestModSeq); err != nil {
+			return err
+		}
+	}
+
+	state = &MailboxState{
+		UidValidity:   w.selected.UidValidity,
+		HighestModSeq: highestModSeq,
+	}
+	data := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(data).Encode(state); err != nil {
+		return err
+	}
+	if err := w.cache.Put(w.stateKey(w.selected.Name), data.Bytes(), nil); err != nil {
+		return err
+	}
+	w.highestModSeq = highestModSeq
+	return nil
+}
+
+func (w *IMAPWorker) mailboxState(name string) (*MailboxState, error) {
+	data, err := w.cache.Get(w.stateKey(name), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &MailboxState{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(state); err != nil {
+		w.worker.Errorf("cannot decode mailbox state: %v", err)
+		return nil, nil
+	}
+	return state, nil
+}
+
+// fetchChanges updates the cached flags of the messages that changed since
+// the given modification sequence. The new flags of these messages are also
+// sent to the UI in case they were already loaded.
+func (w *IMAPWorker) fetchChanges(modSeq uint64) error {
+	messages := make(chan *imap.Message)
+	done := make(chan struct{})
+
+	go func() {
+		defer log.PanicHandler()
+		for msg := range messages {
+			w.updateFlags(msg)
+		}
+		close(done)
+	}()
+
+	set := new(imap.SeqSet)
+	set.AddRange(1, 0)
+	items := []imap.FetchItem{imap.FetchUid, imap.FetchFlags}
+	err := w.client.condstore.UidFetchChangedSince(set, items, modSeq, messages)
+	<-done
+	return err
+}
+
+// updateFlags records the flags of a message which changed since the
+// selected mailbox was last opened. They are also sent to the UI in case the
+// message was already loaded.
+func (w *IMAPWorker) updateFlags(msg *imap.Message) {
+	w.storeFlags(msg.Uid, translateImapFlags(msg.Flags), extensions.ModSeq(msg))
+	w.worker.PostMessage(&types.MessageInfo{
+		Info: &models.MessageInfo{
+			Flags: translateImapFlags(msg.Flags),
+			Uid:   models.Uint32ToUid(msg.Uid),
+		},
+	}, nil)
+}
+
+// flagsCached returns true if the flags of the selected mailbox are kept in
+// the cache, either to resync them with CONDSTORE or to use them offline.
+func (w *IMAPWorker) flagsCached() bool {
+	return w.highestModSeq != 0 || w.offlineEnabled()
+}
+
+// cacheFlags records the flags of a message from a FETCH response.
+func (w *IMAPWorker) cacheFlags(msg *imap.Message) {
+	if !w.flagsCached() || msg.Uid == 0 {
+		return
+	}
+	if _, ok := msg.Items[imap.FetchFlags]; !ok {
+		return
+	}
+	w.storeFlags(msg.Uid, translateImapFlags(msg.Flags), extensions.ModSeq(msg))
+}
+
+func (w *IMAPWorker) storeFlags(uid uint32, flags models.Flags, modSeq uint64) {
+	key := w.flagsKey(models.Uint32ToUid(uid))
+	data := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(data).Encode(&CachedFlags{Flags: flags, ModSeq: modSeq})
+	if err != nil {
+		w.worker.Errorf("cannot encode flags %s: %v", key, err)
+		return
+	}
+	if err := w.cache.Put(key, data.Bytes(), nil); err != nil {
+		w.worker.Errorf("cannot write flags %s: %v", key, err)
+	}
+}
+
+// cachedFlags returns the flags of a message if they are up to date in the
+// cache. When offline, the last known flags are returned.
+func (w *IMAPWorker) cachedFlags(uid models.UID) (models.Flags, bool) {
+	if w.highestModSeq == 0 && !w.isOffline() {
+		return 0, false
+	}
+	return w.readFlags(uid)
+}
+
+func (w *IMAPWorker) readFlags(uid models.UID) (models.Flags, bool) {
+	cf := w.readCachedFlags(uid)
+	if cf == nil {
+		return 0, false
+	}
+	return cf.Flags, true
+}
+
+func (w *IMAPWorker) readCachedFlags(uid models.UID) *CachedFlags {
+	key := w.flagsKey(uid)
+	data, err := w.cache.Get(key, nil)
+	if err != nil {
+		return nil
+	}
+	cf := &CachedFlags{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(cf); err != nil {
+		w.worker.Errorf("cannot decode cached flags %s: %v", key, err)
+		return nil
+	}
+	return cf
+}
+
+// unchangedSince groups the given messages by the modification sequence up
+// to which their flags are known. It returns nil if the flags of the selected
+// mailbox are not tracked with CONDSTORE. All changes until the mailbox was
+// opened are known, as well as the later ones received from the server.
+func (w *IMAPWorker) unchangedSince(uids []models.UID) map[uint64][]models.UID {
+	if w.highestModSeq == 0 {
+		return nil
+	}
+	groups := make(map[uint64][]models.UID)
+	for _, uid := range uids {
+		modSeq := w.highestModSeq
+		if cf := w.readCachedFlags(uid); cf != nil && cf.ModSeq > modSeq {
+			modSeq = cf.ModSeq
+		}
+		groups[modSeq] = append(groups[modSeq], uid)
+	}
+	return groups
+}
+
+// fetchFlags updates the cached flags of the given messages and sends them
+// to the UI.
+func (w *IMAPWorker) fetchFlags(set *imap.SeqSet) error {
+	messages := make(chan *imap.Message)
+	done := make(chan struct{})
+
+	go func() {
+		defer log.PanicHandler()
+		for msg := range messages {
+			w.cacheFlags(msg)
+			w.worker.PostMessage(&types.MessageInfo{
+				Info: &models.MessageInfo{
+					Flags: translateImapFlags(msg.Flags),
+					Uid:   models.Uint32ToUid(msg.Uid),
+				},
+			}, nil)
+		}
+		close(done)
+	}()
+
+	items := []imap.FetchItem{imap.FetchUid, imap.FetchFlags}
+	err := w.client.UidFetch(set, items, messages)
+	<-done
+	return err
+}
+
+// pruneFlags removes the cached flags of the messages which are no longer in
+// the selected mailbox.
+func (w *IMAPWorker) pruneFlags(uids []uint32) {
+	if !w.flagsCached() {
+		return
+	}
+	exists := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		exists[string(w.flagsKey(models.Uint32ToUid(uid)))] = true
+	}
+	var stale [][]byte
+	prefix := w.flagsPrefix(w.selected.UidValidity)
+	iter := w.cache.NewIterator(util.BytesPrefix(prefix), nil)
+	for iter.Next() {
+		if !exists[string(iter.Key())] {
+			stale = append(stale, append([]byte(nil), iter.Key()...))
+		}
+	}
+	iter.Release()
+	for _, key := range stale {
+		if err := w.cache.Delete(key, nil); err != nil {
+			w.worker.Errorf("cannot remove flags %s: %v", key, err)
+		}
+	}
+}
+
+// dropFlags removes the cached flags of the messages which were expunged from
+// the selected mailbox.
+func (w *IMAPWorker) dropFlags(set *imap.SeqSet) {
+	if set.Empty() {
+		return
+	}
+	prefix := w.flagsPrefix(w.selected.UidValidity)
+	var stale [][]byte
+	iter := w.cache.NewIterator(util.BytesPrefix(prefix), nil)
+	for iter.Next() {
+		uid := models.UidToUint32(models.UID(iter.Key()[len(prefix):]))
+		if set.Contains(uid) {
+			stale = append(stale, append([]byte(nil), iter.Key()...))
+		}
+	}
+	iter.Release()
+	for _, key := range stale {
+		if err := w.cache.Delete(key, nil); err != nil {
+			w.worker.Errorf("cannot remove flags %s: %v", key, err)
+		}
+	}
+}
+
+func (w *IMAPWorker) deleteKeys(r *util.Range) {
+	iter := w.cache.NewIterator(r, nil)
+	for iter.Next() {
+		if err := w.cache.Delete(iter.Key(), nil); err != nil {
+			w.worker.Errorf("cannot remove %s: %v", iter.Key(), err)
+		}
+	}
+	iter.Release()
+}
+
+func (w *IMAPWorker) stateKey(name string) []byte {
+	return []byte("modseq." + name)
+}
+
+func (w *IMAPWorker) flagsPrefix(uidValidity uint32) []byte {
+	return []byte(fmt.Sprintf("flags.%s.%d.", w.selected.Name, uidValidity))
+}
+
+func (w *IMAPWorker) flagsKey(uid models.UID) []byte {
+	return append(w.flagsPrefix(w.selected.UidValidity), uid...)
+}
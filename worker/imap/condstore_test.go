@@ -0,0 +1,175 @@
+package imap
+
+import (
+	"testing"
+
+	"github.com/emersion/go-imap"
+
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+func TestCondstoreResync(t *testing.T) {
+	s := &stubServer{}
+	w := newStubWorker(t, s)
+
+	s.reset(42, 100)
+	openInbox(t, w)
+	if cmds := s.received(); len(cmds) != 1 || cmds[0] != "SELECT INBOX (CONDSTORE)" {
+		t.Fatalf("unexpected commands on first open: %q", cmds)
+	}
+	if _, ok := w.cachedFlags(models.Uint32ToUid(11)); ok {
+		t.Errorf("flags cached before being fetched")
+	}
+	// flags learned while the mailbox is open
+	w.storeFlags(11, models.SeenFlag, 99)
+	w.storeFlags(12, 0, 100)
+
+	s.reset(42, 105, `2 FETCH (UID 12 FLAGS (\Seen \Flagged) MODSEQ (105))`)
+	openInbox(t, w)
+	cmds := s.received()
+	if len(cmds) != 2 || cmds[1] != "UID FETCH 1:* (UID FLAGS) (CHANGEDSINCE 100)" {
+		t.Fatalf("unexpected commands on resync: %q", cmds)
+	}
+	for uid, expected := range map[models.UID]models.Flags{
+		models.Uint32ToUid(11): models.SeenFlag,
+		models.Uint32ToUid(12): models.SeenFlag | models.FlaggedFlag,
+	} {
+		flags, ok := w.cachedFlags(uid)
+		if !ok || flags != expected {
+			t.Errorf("uid %s: expected flags %v, got %v (%v)",
+				uid, expected, flags, ok)
+		}
+	}
+
+	s.reset(42, 105)
+	openInbox(t, w)
+	if cmds := s.received(); len(cmds) != 1 {
+		t.Errorf("unexpected commands without changes: %q", cmds)
+	}
+
+	w.pruneFlags([]uint32{12})
+	if _, ok := w.cachedFlags(models.Uint32ToUid(11)); ok {
+		t.Errorf("flags of expunged message not pruned")
+	}
+
+	s.reset(43, 110)
+	openInbox(t, w)
+	if cmds := s.received(); len(cmds) != 1 {
+		t.Errorf("unexpected commands after uid validity change: %q", cmds)
+	}
+	if _, ok := w.cachedFlags(models.Uint32ToUid(12)); ok {
+		t.Errorf("flags kept after uid validity change")
+	}
+}
+
+func TestCondstoreNoModSeq(t *testing.T) {
+	s := &stubServer{}
+	w := newStubWorker(t, s)
+
+	s.reset(42, 0)
+	openInbox(t, w)
+	w.cacheFlags(&imap.Message{
+		Uid:   11,
+		Flags: []string{imap.SeenFlag},
+		Items: map[imap.FetchItem]interface{}{imap.FetchFlags: nil},
+	})
+	if _, ok := w.cachedFlags(models.Uint32ToUid(11)); ok {
+		t.Errorf("flags cached for a mailbox without modification sequences")
+	}
+	if _, err := w.cache.Get(w.stateKey("INBOX"), nil); err == nil {
+		t.Errorf("state saved for a mailbox without modification sequences")
+	}
+}
+
+func TestQresync(t *testing.T) {
+	s := &stubServer{qresync: true}
+	w := newStubWorker(t, s)
+	w.enableQresync(w.client.Client)
+	if !w.qresync {
+		t.Fatal("QRESYNC not enabled")
+	}
+
+	s.reset(42, 100)
+	openInbox(t, w)
+	if cmds := s.received(); len(cmds) != 1 || cmds[0] != "SELECT INBOX (CONDSTORE)" {
+		t.Fatalf("unexpected commands on first open: %q", cmds)
+	}
+	w.storeFlags(11, models.SeenFlag, 99)
+	w.storeFlags(12, 0, 100)
+
+	s.reset(42, 105, `2 FETCH (UID 12 FLAGS (\Seen \Flagged) MODSEQ (105))`)
+	s.vanished = "11"
+	openInbox(t, w)
+	if cmds := s.received(); len(cmds) != 1 || cmds[0] != "SELECT INBOX (QRESYNC (42 100))" {
+		t.Fatalf("unexpected commands on resync: %q", cmds)
+	}
+	if _, ok := w.cachedFlags(models.Uint32ToUid(11)); ok {
+		t.Errorf("flags of vanished message not removed")
+	}
+	flags, ok := w.cachedFlags(models.Uint32ToUid(12))
+	if !ok || flags != models.SeenFlag|models.FlaggedFlag {
+		t.Errorf("flags of changed message not updated: %v", flags)
+	}
+
+	// expunged messages are reported with VANISHED instead of EXPUNGE
+	messages := make(chan types.WorkerMessage, 100)
+	w.worker.(*types.Worker).SetMessages(messages)
+	w.seqMap.Initialize([]uint32{12, 13, 14})
+	s.reset(42, 105)
+	s.vanished = "13:14"
+	if err := w.client.Expunge(nil); err != nil {
+		t.Fatal(err)
+	}
+	if uid, ok := w.seqMap.Get(1); w.seqMap.Size() != 1 || !ok || uid != 12 {
+		t.Errorf("vanished messages not removed")
+	}
+	var deleted []models.UID
+	for len(messages) > 0 {
+		if msg, ok := (<-messages).(*types.MessagesDeleted); ok {
+			deleted = append(deleted, msg.Uids...)
+		}
+	}
+	if len(deleted) != 2 {
+		t.Errorf("unexpected deleted messages: %v", deleted)
+	}
+}
+
+func TestCondstoreStore(t *testing.T) {
+	s := &stubServer{}
+	w := newStubWorker(t, s)
+
+	s.reset(42, 100)
+	openInbox(t, w)
+	w.storeFlags(4, 0, 110)
+	messages := make(chan types.WorkerMessage, 100)
+	w.worker.(*types.Worker).SetMessages(messages)
+
+	s.reset(42, 120, `2 FETCH (UID 4 FLAGS (\Flagged) MODSEQ (120))`)
+	s.modified = "4"
+	w.handleFlagMessages(&types.FlagMessages{
+		Enable: true,
+		Flags:  models.SeenFlag,
+		Uids:   []models.UID{models.Uint32ToUid(3), models.Uint32ToUid(4)},
+	})
+	cmds := s.received()
+	if len(cmds) != 3 ||
+		cmds[0] != `UID STORE 3 (UNCHANGEDSINCE 100) +FLAGS (\Seen)` ||
+		cmds[1] != `UID STORE 4 (UNCHANGEDSINCE 110) +FLAGS (\Seen)` ||
+		cmds[2] != "UID FETCH 4 (UID FLAGS)" {
+		t.Fatalf("unexpected commands: %q", cmds)
+	}
+	if flags, ok := w.cachedFlags(models.Uint32ToUid(4)); !ok ||
+		flags != models.FlaggedFlag {
+		t.Errorf("flags of modified message not updated: %v", flags)
+	}
+	var failed bool
+	for len(messages) > 0 {
+		if _, ok := (<-messages).(*types.Error); ok {
+			failed = true
+		}
+	}
+	if !failed {
+		t.Errorf("no error for modified messages")
+	}
+}
@@ -9,11 +9,12 @@ import (
 	"strings"
 	"time"
 
+	"git.sr.ht/~rjarry/aerc/lib/oauth"
+	"git.sr.ht/~rjarry/aerc/lib/parse"
 	"git.sr.ht/~rjarry/aerc/lib/xdg"
 	"git.sr.ht/~rjarry/aerc/worker/lib"
 	"git.sr.ht/~rjarry/aerc/worker/middleware"
 	"git.sr.ht/~rjarry/aerc/worker/types"
-	"golang.org/x/oauth2"
 )
 
 func (w *IMAPWorker) handleConfigure(msg *types.Configure) error {
@@ -32,31 +33,13 @@ func (w *IMAPWorker) handleConfigure(msg *types.Configure) error {
 	if strings.HasSuffix(w.config.scheme, "+oauthbearer") {
 		w.config.scheme = strings.TrimSuffix(w.config.scheme, "+oauthbearer")
 		w.config.oauthBearer.Enabled = true
-		q := u.Query()
-
-		oauth2 := &oauth2.Config{}
-		if q.Get("token_endpoint") != "" {
-			oauth2.ClientID = q.Get("client_id")
-			oauth2.ClientSecret = q.Get("client_secret")
-			oauth2.Scopes = []string{q.Get("scope")}
-			oauth2.Endpoint.TokenURL = q.Get("token_endpoint")
-		}
-		w.config.oauthBearer.OAuth2 = oauth2
+		w.config.oauthBearer.OAuth2 = oauth.Config(u.Query())
 	}
 
 	if strings.HasSuffix(w.config.scheme, "+xoauth2") {
 		w.config.scheme = strings.TrimSuffix(w.config.scheme, "+xoauth2")
 		w.config.xoauth2.Enabled = true
-		q := u.Query()
-
-		oauth2 := &oauth2.Config{}
-		if q.Get("token_endpoint") != "" {
-			oauth2.ClientID = q.Get("client_id")
-			oauth2.ClientSecret = q.Get("client_secret")
-			oauth2.Scopes = []string{q.Get("scope")}
-			oauth2.Endpoint.TokenURL = q.Get("token_endpoint")
-		}
-		w.config.xoauth2.OAuth2 = oauth2
+		w.config.xoauth2.OAuth2 = oauth.Config(u.Query())
 	}
 
 	w.config.addr = u.Host
@@ -81,6 +64,8 @@ func (w *IMAPWorker) handleConfigure(msg *types.Configure) error {
 
 	w.config.cacheEnabled = false
 	w.config.cacheMaxAge = 30 * 24 * time.Hour // 30 days
+	w.config.cacheBodiesMaxSize = 10 << 20
+	cacheBodies := ""
 
 	for key, value := range msg.Config.Params {
 		switch key {
@@ -155,6 +140,42 @@ func (w *IMAPWorker) handleConfigure(msg *types.Configure) error {
 				return fmt.Errorf("invalid cache-max-age value %v: %w", value, err)
 			}
 			w.config.cacheMaxAge = val
+		case "cache-bodies":
+			if _, err := strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("invalid cache-bodies value %v: %w", value, err)
+			}
+			cacheBodies = value
+		case "cache-bodies-max-size":
+			val, err := parse.Size(value)
+			if err != nil {
+				return fmt.Errorf("invalid cache-bodies-max-size value %v: %w", value, err)
+			}
+			w.config.cacheBodiesMaxSize = val
+		case "offline":
+			val, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid offline value %v: %w", value, err)
+			}
+			w.config.offline = val
+		case "watch-folders":
+			for _, folder := range strings.Split(value, ",") {
+				folder = strings.TrimSpace(folder)
+				if folder != "" {
+					w.config.watchFolders = append(w.config.watchFolders, folder)
+				}
+			}
+		case "full-text-index":
+			val, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid full-text-index value %v: %w", value, err)
+			}
+			w.config.fullTextIndex = val
+		case "use-full-text-index":
+			val, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid use-full-text-index value %v: %w", value, err)
+			}
+			w.config.useIndex = val
 		case "use-gmail-ext":
 			val, err := strconv.ParseBool(value)
 			if err != nil {
@@ -163,11 +184,28 @@ func (w *IMAPWorker) handleConfigure(msg *types.Configure) error {
 			w.config.useXGMEXT = val
 		}
 	}
-	if w.config.cacheEnabled {
+	if w.config.offline {
+		// nothing can be displayed offline without the cached headers
+		w.config.cacheEnabled = true
+		if cacheBodies == "" {
+			cacheBodies = "true"
+		}
+	}
+	w.config.cacheBodies, _ = strconv.ParseBool(cacheBodies)
+	if w.config.cacheEnabled || w.config.cacheBodies {
 		w.initCacheDb(msg.Config.Name)
 	}
+	if w.config.fullTextIndex || w.config.useIndex {
+		w.initIndex(msg.Config.Name)
+	}
 	w.idler = newIdler(w.config, w.worker, w.executeIdle)
 	w.observer = newObserver(w.config, w.worker)
+	if len(w.config.watchFolders) > 0 {
+		w.watcher = newWatcher(w.config, w.worker, w.dial)
+	}
+	if w.offlineEnabled() {
+		w.observer.lost = w.lost
+	}
 
 	if name, ok := msg.Config.Params["folder-map"]; ok {
 		file := xdg.ExpandHome(name)
@@ -183,5 +221,14 @@ func (w *IMAPWorker) handleConfigure(msg *types.Configure) error {
 		w.worker = middleware.NewFolderMapper(w.worker, fmap, order)
 	}
 
+	if file := msg.Config.Searches; file != "" {
+		searches, err := middleware.NewSavedSearches(w.worker, file,
+			msg.Config.Default)
+		if err != nil {
+			return err
+		}
+		w.worker = searches
+	}
+
 	return nil
 }
@@ -16,6 +16,36 @@ import (
 // selects the default inbox. If no error is returned, the imap client will be
 // in the imap.SelectedState.
 func (w *IMAPWorker) connect() (*client.Client, error) {
+	c, err := w.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	// QRESYNC can only be enabled before selecting a mailbox
+	w.enableQresync(c)
+
+	if _, err := c.Select(imap.InboxName, false); err != nil {
+		return nil, err
+	}
+
+	info := make(chan *imap.MailboxInfo, 1)
+	if err := c.List("", "", info); err != nil {
+		return nil, fmt.Errorf("failed to retrieve delimiter: %w", err)
+	}
+	if mailboxinfo := <-info; mailboxinfo != nil {
+		w.delimiter = mailboxinfo.Delimiter
+	}
+	if w.delimiter == "" {
+		// just in case some implementation does not follow standards
+		w.delimiter = "/"
+	}
+
+	return c, nil
+}
+
+// dial establishes a new tcp connection to the imap server and logs in. If no
+// error is returned, the imap client will be in the imap.AuthenticatedState.
+func (w *IMAPWorker) dial() (*client.Client, error) {
 	var (
 		conn *net.TCPConn
 		err  error
@@ -80,7 +110,7 @@ func (w *IMAPWorker) connect() (*client.Client, error) {
 
 		if w.config.oauthBearer.Enabled {
 			if err := w.config.oauthBearer.Authenticate(
-				username, password, c); err != nil {
+				username, password, w.config.name, c); err != nil {
 				return nil, err
 			}
 		} else if w.config.xoauth2.Enabled {
@@ -93,22 +123,6 @@ func (w *IMAPWorker) connect() (*client.Client, error) {
 		}
 	}
 
-	if _, err := c.Select(imap.InboxName, false); err != nil {
-		return nil, err
-	}
-
-	info := make(chan *imap.MailboxInfo, 1)
-	if err := c.List("", "", info); err != nil {
-		return nil, fmt.Errorf("failed to retrieve delimiter: %w", err)
-	}
-	if mailboxinfo := <-info; mailboxinfo != nil {
-		w.delimiter = mailboxinfo.Delimiter
-	}
-	if w.delimiter == "" {
-		// just in case some implementation does not follow standards
-		w.delimiter = "/"
-	}
-
 	return c, nil
 }
 
@@ -1,11 +1,30 @@
 package imap
 
 import (
+	"fmt"
+
+	"git.sr.ht/~rjarry/aerc/models"
 	"git.sr.ht/~rjarry/aerc/worker/types"
 )
 
+// special-use attributes of RFC 6154 for the directory roles
+var specialUses = map[models.Role]string{
+	models.AllRole:     `\All`,
+	models.ArchiveRole: `\Archive`,
+	models.DraftsRole:  `\Drafts`,
+	models.JunkRole:    `\Junk`,
+	models.SentRole:    `\Sent`,
+	models.TrashRole:   `\Trash`,
+}
+
 func (imapw *IMAPWorker) handleCreateDirectory(msg *types.CreateDirectory) {
-	if err := imapw.client.Create(msg.Directory); err != nil {
+	var err error
+	if msg.Role == "" {
+		err = imapw.client.Create(msg.Directory)
+	} else {
+		err = imapw.createSpecialUse(msg.Directory, msg.Role)
+	}
+	if err != nil {
 		if msg.Quiet {
 			return
 		}
@@ -17,3 +36,14 @@ func (imapw *IMAPWorker) handleCreateDirectory(msg *types.CreateDirectory) {
 		imapw.worker.PostMessage(&types.Done{Message: types.RespondTo(msg)}, nil)
 	}
 }
+
+func (imapw *IMAPWorker) createSpecialUse(name string, role models.Role) error {
+	use, ok := specialUses[role]
+	if !ok {
+		return fmt.Errorf("%s is not a special use", role)
+	}
+	if !imapw.specialuse {
+		return fmt.Errorf("the server does not support CREATE-SPECIAL-USE")
+	}
+	return imapw.client.specialuse.Create(name, use)
+}
@@ -0,0 +1,121 @@
+package extensions
+
+import (
+	"errors"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/responses"
+	"github.com/emersion/go-imap/utf7"
+)
+
+// An ACL client, see RFC 4314
+type ACLClient struct {
+	c *client.Client
+}
+
+func NewACLClient(c *client.Client) *ACLClient {
+	return &ACLClient{c}
+}
+
+// SupportACL checks if the server supports the ACL extension.
+func (c *ACLClient) SupportACL() (bool, error) {
+	return c.c.Support("ACL")
+}
+
+// MyRights returns the rights of the user on a mailbox.
+func (c *ACLClient) MyRights(mailbox string) (string, error) {
+	res := &aclResponse{name: "MYRIGHTS"}
+	if err := execute(c.c, &MailboxCommand{Name: "MYRIGHTS", Mailbox: mailbox}, res); err != nil {
+		return "", err
+	}
+	if len(res.fields) < 2 {
+		return "", errors.New("missing rights in MYRIGHTS response")
+	}
+	return imap.ParseString(res.fields[1])
+}
+
+// GetACL returns the rights of all the identifiers on a mailbox.
+func (c *ACLClient) GetACL(mailbox string) (map[string]string, error) {
+	res := &aclResponse{name: "ACL"}
+	if err := execute(c.c, &MailboxCommand{Name: "GETACL", Mailbox: mailbox}, res); err != nil {
+		return nil, err
+	}
+	acl := make(map[string]string)
+	for i := 1; i+1 < len(res.fields); i += 2 {
+		identifier, err := imap.ParseString(res.fields[i])
+		if err != nil {
+			return nil, err
+		}
+		rights, err := imap.ParseString(res.fields[i+1])
+		if err != nil {
+			return nil, err
+		}
+		acl[identifier] = rights
+	}
+	return acl, nil
+}
+
+// SetACL changes the rights of an identifier on a mailbox. The rights may be
+// prefixed with + or - to add or remove rights instead of replacing them.
+func (c *ACLClient) SetACL(mailbox, identifier, rights string) error {
+	return execute(c.c, &MailboxCommand{
+		Name:      "SETACL",
+		Mailbox:   mailbox,
+		Arguments: []interface{}{identifier, rights},
+	}, nil)
+}
+
+// DeleteACL removes all the rights of an identifier on a mailbox.
+func (c *ACLClient) DeleteACL(mailbox, identifier string) error {
+	return execute(c.c, &MailboxCommand{
+		Name:      "DELETEACL",
+		Mailbox:   mailbox,
+		Arguments: []interface{}{identifier},
+	}, nil)
+}
+
+// execute runs a command which requires to be logged in and returns its
+// status error.
+func execute(c *client.Client, cmd imap.Commander, res responses.Handler) error {
+	if c.State() != imap.AuthenticatedState && c.State() != imap.SelectedState {
+		return client.ErrNotLoggedIn
+	}
+	status, err := c.Execute(cmd, res)
+	if err != nil {
+		return err
+	}
+	return status.Err()
+}
+
+// MailboxCommand is a command applying to a mailbox, such as the ones defined
+// in RFC 4314 section 3 and RFC 5464 section 4.
+type MailboxCommand struct {
+	Name      string
+	Mailbox   string
+	Arguments []interface{}
+}
+
+func (cmd *MailboxCommand) Command() *imap.Command {
+	mailbox, _ := utf7.Encoding.NewEncoder().String(cmd.Mailbox)
+	args := []interface{}{imap.FormatMailboxName(mailbox)}
+	return &imap.Command{
+		Name:      cmd.Name,
+		Arguments: append(args, cmd.Arguments...),
+	}
+}
+
+// an untagged response to an ACL command
+type aclResponse struct {
+	name   string
+	fields []interface{}
+}
+
+func (r *aclResponse) Handle(resp imap.Resp) error {
+	name, fields, ok := imap.ParseNamedResp(resp)
+	if !ok || name != r.name {
+		return responses.ErrUnhandled
+	}
+	r.fields = fields
+	return nil
+}
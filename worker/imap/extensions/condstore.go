@@ -0,0 +1,274 @@
+package extensions
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/commands"
+	"github.com/emersion/go-imap/responses"
+)
+
+// FetchModSeq is the message data item holding the modification sequence
+// of a message.
+const FetchModSeq imap.FetchItem = "MODSEQ"
+
+// A CONDSTORE client, see RFC 7162 section 3.1
+type CondstoreClient struct {
+	c *client.Client
+}
+
+func NewCondstoreClient(c *client.Client) *CondstoreClient {
+	return &CondstoreClient{c}
+}
+
+// SupportCondstore checks if the server supports the CONDSTORE extension.
+func (c *CondstoreClient) SupportCondstore() (bool, error) {
+	return c.c.Support("CONDSTORE")
+}
+
+// Select opens a mailbox and enables CONDSTORE for the session. It returns
+// the highest modification sequence of the mailbox, or zero if the mailbox
+// does not support persistent modification sequences.
+func (c *CondstoreClient) Select(
+	name string, readOnly bool,
+) (*imap.MailboxStatus, uint64, error) {
+	res := &condstoreSelect{}
+	mbox, err := selectModified(c.c, name, readOnly, res,
+		imap.RawString("CONDSTORE"))
+	if err != nil {
+		return nil, 0, err
+	}
+	return mbox, res.highestModSeq, nil
+}
+
+// selectModified performs a SELECT command with modifiers.
+func selectModified(
+	c *client.Client, name string, readOnly bool, res *condstoreSelect,
+	modifiers ...interface{},
+) (*imap.MailboxStatus, error) {
+	if c.State() != imap.AuthenticatedState && c.State() != imap.SelectedState {
+		return nil, client.ErrNotLoggedIn
+	}
+
+	cmd := &modifiedCommand{
+		Cmd:       &commands.Select{Mailbox: name, ReadOnly: readOnly},
+		Modifiers: modifiers,
+	}
+	mbox := &imap.MailboxStatus{
+		Name:  name,
+		Items: make(map[imap.StatusItem]interface{}),
+	}
+	res.Mailbox = mbox
+
+	// keep the state consistent with what the client does for SELECT, so
+	// that unilateral EXISTS responses update the mailbox
+	state := c.State()
+	c.SetState(state, mbox)
+
+	status, err := c.Execute(cmd, res)
+	if err == nil {
+		err = status.Err()
+	}
+	if err != nil {
+		c.SetState(imap.AuthenticatedState, nil)
+		return nil, err
+	}
+
+	mbox.ReadOnly = status.Code == imap.CodeReadOnly
+	c.SetState(imap.SelectedState, mbox)
+	return mbox, nil
+}
+
+// UidFetchChangedSince fetches the given items of the messages whose
+// modification sequence is greater than modSeq.
+func (c *CondstoreClient) UidFetchChangedSince(
+	seqset *imap.SeqSet, items []imap.FetchItem, modSeq uint64,
+	ch chan *imap.Message,
+) error {
+	defer close(ch)
+
+	if c.c.State() != imap.SelectedState {
+		return client.ErrNoMailboxSelected
+	}
+
+	cmd := &modifiedCommand{
+		Cmd: &commands.Uid{Cmd: &commands.Fetch{
+			SeqSet: seqset,
+			Items:  items,
+		}},
+		Modifiers: []interface{}{
+			imap.RawString("CHANGEDSINCE"),
+			imap.RawString(strconv.FormatUint(modSeq, 10)),
+		},
+	}
+	res := &responses.Fetch{Messages: ch, SeqSet: seqset, Uid: true}
+
+	status, err := c.c.Execute(cmd, res)
+	if err != nil {
+		return err
+	}
+	return status.Err()
+}
+
+// UidStoreUnchangedSince alters the flags of the messages whose modification
+// sequence is not greater than modSeq, see RFC 7162 section 3.1.3. The UIDs
+// of the messages which were modified meanwhile, and thus left untouched, are
+// returned.
+func (c *CondstoreClient) UidStoreUnchangedSince(
+	seqset *imap.SeqSet, item imap.StoreItem, value interface{}, modSeq uint64,
+	ch chan *imap.Message,
+) (*imap.SeqSet, error) {
+	defer close(ch)
+
+	if c.c.State() != imap.SelectedState {
+		return nil, client.ErrNoMailboxSelected
+	}
+
+	if fields, ok := value.([]interface{}); ok {
+		for i, field := range fields {
+			if s, ok := field.(string); ok {
+				fields[i] = imap.RawString(s)
+			}
+		}
+	}
+	cmd := &commands.Uid{Cmd: &unchangedSinceStore{
+		Store: commands.Store{
+			SeqSet: seqset,
+			Item:   item,
+			Value:  value,
+		},
+		modSeq: modSeq,
+	}}
+	res := &responses.Fetch{Messages: ch, SeqSet: seqset, Uid: true}
+
+	status, err := c.c.Execute(cmd, res)
+	if err != nil {
+		return nil, err
+	}
+	if err := status.Err(); err != nil {
+		return nil, err
+	}
+	modified := new(imap.SeqSet)
+	if status.Code == "MODIFIED" && len(status.Arguments) > 0 {
+		var set string
+		switch arg := status.Arguments[0].(type) {
+		case string:
+			set = arg
+		case imap.RawString:
+			set = string(arg)
+		}
+		modified, err = imap.ParseSeqSet(set)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MODIFIED response: %w", err)
+		}
+	}
+	return modified, nil
+}
+
+// ModSeq returns the modification sequence of a fetched message, or zero
+// if it was not part of the response.
+func ModSeq(msg *imap.Message) uint64 {
+	fields, ok := msg.Items[FetchModSeq].([]interface{})
+	if !ok || len(fields) != 1 {
+		return 0
+	}
+	modSeq, _ := parseModSeq(fields[0])
+	return modSeq
+}
+
+func parseModSeq(f interface{}) (uint64, error) {
+	switch f := f.(type) {
+	case string:
+		return strconv.ParseUint(f, 10, 64)
+	case imap.RawString:
+		return strconv.ParseUint(string(f), 10, 64)
+	case uint32:
+		return uint64(f), nil
+	}
+	return 0, fmt.Errorf("invalid modification sequence: %v", f)
+}
+
+// modifiedCommand appends a parenthesized list of modifiers to a command, as
+// defined by RFC 4466.
+type modifiedCommand struct {
+	Cmd       imap.Commander
+	Modifiers []interface{}
+}
+
+func (cmd *modifiedCommand) Command() *imap.Command {
+	c := cmd.Cmd.Command()
+	c.Arguments = append(c.Arguments, cmd.Modifiers)
+	return c
+}
+
+// A STORE command with the UNCHANGEDSINCE modifier, which goes before the
+// message data item name
+type unchangedSinceStore struct {
+	commands.Store
+	modSeq uint64
+}
+
+func (cmd *unchangedSinceStore) Command() *imap.Command {
+	c := cmd.Store.Command()
+	modifier := []interface{}{
+		imap.RawString("UNCHANGEDSINCE"),
+		imap.RawString(strconv.FormatUint(cmd.modSeq, 10)),
+	}
+	args := []interface{}{c.Arguments[0], modifier}
+	c.Arguments = append(args, c.Arguments[1:]...)
+	return c
+}
+
+// A SELECT response with the CONDSTORE response codes. With QRESYNC, the
+// messages which changed are sent to the messages channel and the expunged
+// ones are added to the vanished set.
+type condstoreSelect struct {
+	responses.Select
+	highestModSeq uint64
+	messages      chan *imap.Message
+	vanished      *imap.SeqSet
+}
+
+func (r *condstoreSelect) Handle(resp imap.Resp) error {
+	switch resp := resp.(type) {
+	case *imap.StatusResp:
+		switch resp.Code {
+		case "HIGHESTMODSEQ":
+			if len(resp.Arguments) < 1 {
+				return fmt.Errorf("missing HIGHESTMODSEQ value")
+			}
+			modSeq, err := parseModSeq(resp.Arguments[0])
+			if err != nil {
+				return err
+			}
+			r.highestModSeq = modSeq
+			return nil
+		case "NOMODSEQ":
+			r.highestModSeq = 0
+			return nil
+		}
+	case *imap.DataResp:
+		name, fields, ok := imap.ParseNamedResp(resp)
+		switch {
+		case !ok:
+		case name == "VANISHED" && r.vanished != nil:
+			set, earlier, err := parseVanished(fields)
+			if err != nil {
+				return err
+			}
+			// the other ones are passed on by the QRESYNC client
+			if earlier {
+				r.vanished.AddSet(set)
+			}
+			return nil
+		case name == "FETCH" && r.messages != nil:
+			all := new(imap.SeqSet)
+			all.AddRange(1, 0)
+			res := &responses.Fetch{Messages: r.messages, SeqSet: all, Uid: true}
+			return res.Handle(resp)
+		}
+	}
+	return r.Select.Handle(resp)
+}
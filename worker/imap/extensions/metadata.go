@@ -0,0 +1,122 @@
+package extensions
+
+import (
+	"fmt"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/responses"
+	"github.com/emersion/go-imap/utf7"
+)
+
+// A METADATA client, see RFC 5464
+type MetadataClient struct {
+	c *client.Client
+}
+
+func NewMetadataClient(c *client.Client) *MetadataClient {
+	return &MetadataClient{c}
+}
+
+// SupportMetadata checks if the server supports the METADATA extension.
+func (c *MetadataClient) SupportMetadata() (bool, error) {
+	return c.c.Support("METADATA")
+}
+
+// GetMetadata returns the values of the given entries on a mailbox. The
+// entries which do not exist are omitted.
+func (c *MetadataClient) GetMetadata(mailbox string, entries []string) (map[string]string, error) {
+	res := &metadataResponse{values: make(map[string]string)}
+	names := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry)
+	}
+	cmd := &MailboxCommand{
+		Name:      "GETMETADATA",
+		Mailbox:   mailbox,
+		Arguments: []interface{}{names},
+	}
+	if err := execute(c.c, cmd, res); err != nil {
+		return nil, err
+	}
+	return res.values, nil
+}
+
+// SetMetadata sets the value of an entry on a mailbox. An empty value
+// removes the entry.
+func (c *MetadataClient) SetMetadata(mailbox, entry, value string) error {
+	var v interface{}
+	if value != "" {
+		v = value
+	}
+	return execute(c.c, &MailboxCommand{
+		Name:      "SETMETADATA",
+		Mailbox:   mailbox,
+		Arguments: []interface{}{[]interface{}{entry, v}},
+	}, nil)
+}
+
+// an untagged METADATA response with entry values
+type metadataResponse struct {
+	values map[string]string
+}
+
+func (r *metadataResponse) Handle(resp imap.Resp) error {
+	name, fields, ok := imap.ParseNamedResp(resp)
+	if !ok || name != "METADATA" {
+		return responses.ErrUnhandled
+	}
+	if len(fields) < 2 {
+		return fmt.Errorf("METADATA response expects 2 fields")
+	}
+	list, ok := fields[1].([]interface{})
+	if !ok {
+		// unsolicited METADATA response with entry names only
+		return nil
+	}
+	for i := 0; i+1 < len(list); i += 2 {
+		entry, err := imap.ParseString(list[i])
+		if err != nil {
+			return err
+		}
+		if list[i+1] == nil {
+			continue
+		}
+		value, err := imap.ParseString(list[i+1])
+		if err != nil {
+			return err
+		}
+		r.values[entry] = value
+	}
+	return nil
+}
+
+// A CREATE-SPECIAL-USE client, see RFC 6154 section 3
+type SpecialUseClient struct {
+	c *client.Client
+}
+
+func NewSpecialUseClient(c *client.Client) *SpecialUseClient {
+	return &SpecialUseClient{c}
+}
+
+// SupportCreateSpecialUse checks if the server supports the
+// CREATE-SPECIAL-USE extension.
+func (c *SpecialUseClient) SupportCreateSpecialUse() (bool, error) {
+	return c.c.Support("CREATE-SPECIAL-USE")
+}
+
+// Create creates a mailbox with a special-use attribute such as \Sent.
+func (c *SpecialUseClient) Create(mailbox, use string) error {
+	mailbox, err := utf7.Encoding.NewEncoder().String(mailbox)
+	if err != nil {
+		return err
+	}
+	return execute(c.c, &imap.Command{
+		Name: "CREATE",
+		Arguments: []interface{}{
+			imap.FormatMailboxName(mailbox),
+			[]interface{}{imap.RawString("USE"), []interface{}{imap.RawString(use)}},
+		},
+	}, nil)
+}
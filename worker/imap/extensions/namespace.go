@@ -0,0 +1,98 @@
+package extensions
+
+import (
+	"fmt"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/responses"
+	"github.com/emersion/go-imap/utf7"
+)
+
+// A NAMESPACE client, see RFC 2342
+type NamespaceClient struct {
+	c *client.Client
+}
+
+func NewNamespaceClient(c *client.Client) *NamespaceClient {
+	return &NamespaceClient{c}
+}
+
+// SupportNamespace checks if the server supports the NAMESPACE extension.
+func (c *NamespaceClient) SupportNamespace() (bool, error) {
+	return c.c.Support("NAMESPACE")
+}
+
+// A namespace description
+type Namespace struct {
+	Prefix    string
+	Delimiter string
+}
+
+// Namespaces lists the personal, other users and shared namespaces.
+func (c *NamespaceClient) Namespaces() (personal, other, shared []Namespace, err error) {
+	if c.c.State() != imap.AuthenticatedState && c.c.State() != imap.SelectedState {
+		return nil, nil, nil, client.ErrNotLoggedIn
+	}
+
+	res := &namespaceResponse{}
+	status, err := c.c.Execute(&imap.Command{Name: "NAMESPACE"}, res)
+	if err == nil {
+		err = status.Err()
+	}
+	return res.personal, res.other, res.shared, err
+}
+
+type namespaceResponse struct {
+	personal, other, shared []Namespace
+}
+
+func (r *namespaceResponse) Handle(resp imap.Resp) error {
+	name, fields, ok := imap.ParseNamedResp(resp)
+	if !ok || name != "NAMESPACE" {
+		return responses.ErrUnhandled
+	}
+	if len(fields) < 3 {
+		return fmt.Errorf("NAMESPACE response expects 3 fields")
+	}
+	var err error
+	if r.personal, err = parseNamespaces(fields[0]); err != nil {
+		return err
+	}
+	if r.other, err = parseNamespaces(fields[1]); err != nil {
+		return err
+	}
+	r.shared, err = parseNamespaces(fields[2])
+	return err
+}
+
+// parses a list of namespace descriptions, or NIL
+func parseNamespaces(f interface{}) ([]Namespace, error) {
+	list, ok := f.([]interface{})
+	if !ok {
+		// NIL
+		return nil, nil
+	}
+	var namespaces []Namespace
+	for _, item := range list {
+		desc, ok := item.([]interface{})
+		if !ok || len(desc) < 2 {
+			return nil, fmt.Errorf("invalid namespace description: %v", item)
+		}
+		prefix, err := imap.ParseString(desc[0])
+		if err != nil {
+			return nil, err
+		}
+		prefix, err = utf7.Encoding.NewDecoder().String(prefix)
+		if err != nil {
+			return nil, err
+		}
+		// the delimiter is NIL for flat namespaces
+		delim, _ := imap.ParseString(desc[1])
+		namespaces = append(namespaces, Namespace{
+			Prefix:    prefix,
+			Delimiter: delim,
+		})
+	}
+	return namespaces, nil
+}
@@ -0,0 +1,130 @@
+package extensions
+
+import (
+	"errors"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/commands"
+	"github.com/emersion/go-imap/responses"
+	"github.com/emersion/go-imap/utf7"
+)
+
+// A NOTIFY client, see RFC 5465
+type NotifyClient struct {
+	c *client.Client
+}
+
+func NewNotifyClient(c *client.Client) *NotifyClient {
+	return &NotifyClient{c}
+}
+
+// SupportNotify checks if the server supports the NOTIFY extension.
+func (c *NotifyClient) SupportNotify() (bool, error) {
+	return c.c.Support("NOTIFY")
+}
+
+// SetStatus asks the server to report new and expunged messages in the given
+// mailboxes with STATUS responses. The current status of the mailboxes is
+// returned.
+func (c *NotifyClient) SetStatus(mailboxes []string) ([]*imap.MailboxStatus, error) {
+	if c.c.State() != imap.AuthenticatedState && c.c.State() != imap.SelectedState {
+		return nil, client.ErrNotLoggedIn
+	}
+
+	cmd := &NotifySetCommand{Status: true, Mailboxes: mailboxes}
+	res := &statusResponses{}
+
+	status, err := c.c.Execute(cmd, res)
+	if err != nil {
+		return nil, err
+	}
+	return res.statuses, status.Err()
+}
+
+// Idle is the same as client.Idle but the STATUS responses sent by the server
+// while idling are passed to the statuses channel. The responses which do not
+// fit in the channel are dropped. Unlike client.Idle, the IDLE command is not
+// restarted periodically.
+func (c *NotifyClient) Idle(
+	stop <-chan struct{}, statuses chan<- *imap.MailboxStatus,
+) error {
+	res := &notifyIdle{
+		Idle: &responses.Idle{
+			Stop:      stop,
+			RepliesCh: make(chan []byte, 10),
+		},
+		statuses: statuses,
+	}
+
+	status, err := c.c.Execute(&commands.Idle{}, res)
+	if err != nil {
+		return err
+	}
+	return status.Err()
+}
+
+// NotifySetCommand is a NOTIFY SET command, as defined in RFC 5465 section
+// 3.1, for new and expunged messages in a list of mailboxes.
+type NotifySetCommand struct {
+	Status    bool
+	Mailboxes []string
+}
+
+func (cmd *NotifySetCommand) Command() *imap.Command {
+	enc := utf7.Encoding.NewEncoder()
+	mailboxes := make([]interface{}, 0, len(cmd.Mailboxes))
+	for _, mailbox := range cmd.Mailboxes {
+		name, _ := enc.String(mailbox)
+		mailboxes = append(mailboxes, imap.FormatMailboxName(name))
+	}
+
+	args := []interface{}{imap.RawString("SET")}
+	if cmd.Status {
+		args = append(args, imap.RawString("STATUS"))
+	}
+	args = append(args, []interface{}{
+		imap.RawString("mailboxes"),
+		mailboxes,
+		[]interface{}{
+			imap.RawString("MessageNew"),
+			imap.RawString("MessageExpunge"),
+		},
+	})
+	return &imap.Command{Name: "NOTIFY", Arguments: args}
+}
+
+// collects the STATUS responses of a command
+type statusResponses struct {
+	statuses []*imap.MailboxStatus
+}
+
+func (r *statusResponses) Handle(resp imap.Resp) error {
+	res := &responses.Status{}
+	if err := res.Handle(resp); err != nil {
+		return err
+	}
+	r.statuses = append(r.statuses, res.Mailbox)
+	return nil
+}
+
+// An IDLE response passing on STATUS responses
+type notifyIdle struct {
+	*responses.Idle
+	statuses chan<- *imap.MailboxStatus
+}
+
+func (r *notifyIdle) Handle(resp imap.Resp) error {
+	if err := r.Idle.Handle(resp); !errors.Is(err, responses.ErrUnhandled) {
+		return err
+	}
+	res := &responses.Status{}
+	if err := res.Handle(resp); err != nil {
+		return err
+	}
+	select {
+	case r.statuses <- res.Mailbox:
+	default:
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package extensions
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/responses"
+)
+
+// A QRESYNC client, see RFC 7162 section 3.2
+type QresyncClient struct {
+	c *client.Client
+}
+
+func NewQresyncClient(c *client.Client) *QresyncClient {
+	return &QresyncClient{c}
+}
+
+// SupportQresync checks if the server supports the QRESYNC extension.
+func (c *QresyncClient) SupportQresync() (bool, error) {
+	return c.c.Support("QRESYNC")
+}
+
+// Enable enables QRESYNC for the session. It must be called before selecting
+// a mailbox.
+//
+// The server then reports expunged messages with VANISHED responses instead
+// of EXPUNGE responses. The client drops the responses it does not know of, so
+// the UIDs of the expunged messages are passed to the vanished function
+// instead of the Updates channel. The function is called from the goroutine
+// reading the responses and must not send commands.
+func (c *QresyncClient) Enable(vanished func(*imap.SeqSet)) error {
+	if c.c.State() != imap.AuthenticatedState {
+		return errors.New("ENABLE is only allowed before selecting a mailbox")
+	}
+
+	// watch the responses before the command is sent to not miss any
+	c.c.SetDebug(imap.NewDebugWriter(nil, &vanishedWatcher{vanished: vanished}))
+
+	res := &enabledResponse{}
+	status, err := c.c.Execute(&imap.Command{
+		Name:      "ENABLE",
+		Arguments: []interface{}{imap.RawString("QRESYNC")},
+	}, res)
+	if err == nil {
+		err = status.Err()
+	}
+	if err == nil && !res.enabled["QRESYNC"] {
+		err = errors.New("QRESYNC was not enabled")
+	}
+	if err != nil {
+		c.c.SetDebug(nil)
+		return err
+	}
+	return nil
+}
+
+// Select opens a mailbox whose state is known. The messages which changed
+// since the modSeq modification sequence are sent to ch, and the UIDs of the
+// expunged ones are returned along with the highest modification sequence of
+// the mailbox. If the UID validity of the mailbox changed, the server reports
+// no changes.
+func (c *QresyncClient) Select(
+	name string, readOnly bool, uidValidity uint32, modSeq uint64,
+	ch chan *imap.Message,
+) (*imap.MailboxStatus, uint64, *imap.SeqSet, error) {
+	defer close(ch)
+
+	res := &condstoreSelect{messages: ch, vanished: new(imap.SeqSet)}
+	mbox, err := selectModified(c.c, name, readOnly, res,
+		imap.RawString("QRESYNC"), []interface{}{
+			imap.RawString(strconv.FormatUint(uint64(uidValidity), 10)),
+			imap.RawString(strconv.FormatUint(modSeq, 10)),
+		})
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return mbox, res.highestModSeq, res.vanished, nil
+}
+
+// the untagged ENABLED responses to an ENABLE command, see RFC 5161
+type enabledResponse struct {
+	enabled map[string]bool
+}
+
+func (r *enabledResponse) Handle(resp imap.Resp) error {
+	name, fields, ok := imap.ParseNamedResp(resp)
+	if !ok || name != "ENABLED" {
+		return responses.ErrUnhandled
+	}
+	if r.enabled == nil {
+		r.enabled = make(map[string]bool)
+	}
+	for _, field := range fields {
+		if capability, err := imap.ParseString(field); err == nil {
+			r.enabled[strings.ToUpper(capability)] = true
+		}
+	}
+	return nil
+}
+
+// parseVanished parses the fields of a VANISHED response, see RFC 7162
+// section 3.2.10. earlier is true for the messages expunged before the
+// command which caused the response.
+func parseVanished(fields []interface{}) (*imap.SeqSet, bool, error) {
+	earlier := false
+	if len(fields) > 0 {
+		if tag, ok := fields[0].([]interface{}); ok {
+			if len(tag) != 1 {
+				return nil, false, errors.New("invalid VANISHED tag")
+			}
+			s, _ := imap.ParseString(tag[0])
+			earlier = strings.EqualFold(s, "EARLIER")
+			fields = fields[1:]
+		}
+	}
+	if len(fields) != 1 {
+		return nil, false, errors.New("missing UIDs in VANISHED response")
+	}
+	uids, err := imap.ParseString(fields[0])
+	if err != nil {
+		return nil, false, err
+	}
+	set, err := imap.ParseSeqSet(uids)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid VANISHED response: %w", err)
+	}
+	return set, earlier, nil
+}
+
+var literalSuffix = regexp.MustCompile(`\{(\d+)\}\r?\n$`)
+
+// vanishedWatcher reads the responses sent by the server to find the
+// VANISHED responses which are not sent in reply to a command with a QRESYNC
+// modifier. Literals are skipped since they may contain anything.
+type vanishedWatcher struct {
+	vanished func(*imap.SeqSet)
+	// the line being read
+	line []byte
+	// the line continues after a literal
+	continued bool
+	// remaining bytes of the literal being read
+	literal int
+}
+
+func (w *vanishedWatcher) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if w.literal > 0 {
+			skip := min(w.literal, len(p))
+			w.literal -= skip
+			p = p[skip:]
+			continue
+		}
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.line = append(w.line, p...)
+			break
+		}
+		w.line = append(w.line, p[:i+1]...)
+		p = p[i+1:]
+		w.parseLine()
+		w.line = w.line[:0]
+	}
+	return n, nil
+}
+
+func (w *vanishedWatcher) parseLine() {
+	const prefix = "* VANISHED "
+	if !w.continued && len(w.line) > len(prefix) &&
+		strings.EqualFold(string(w.line[:len(prefix)]), prefix) {
+		uids := strings.TrimSpace(string(w.line[len(prefix):]))
+		// VANISHED (EARLIER) responses are handled by the command
+		if set, err := imap.ParseSeqSet(uids); err == nil {
+			w.vanished(set)
+		}
+	}
+	w.continued = false
+	if m := literalSuffix.FindSubmatch(w.line); m != nil {
+		w.literal, _ = strconv.Atoi(string(m[1]))
+		w.continued = true
+	}
+}
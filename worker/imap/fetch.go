@@ -2,7 +2,10 @@ package imap
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"io"
+	"strings"
 
 	"github.com/emersion/go-imap"
 	"github.com/emersion/go-message"
@@ -12,6 +15,7 @@ import (
 
 	"git.sr.ht/~rjarry/aerc/lib/log"
 	"git.sr.ht/~rjarry/aerc/lib/parse"
+	"git.sr.ht/~rjarry/aerc/lib/rfc822"
 	"git.sr.ht/~rjarry/aerc/models"
 	"git.sr.ht/~rjarry/aerc/worker/types"
 )
@@ -91,6 +95,7 @@ func (imapw *IMAPWorker) handleFetchMessageHeaders(
 			if imapw.config.cacheEnabled && imapw.cache != nil {
 				imapw.cacheHeader(info)
 			}
+			imapw.indexHeader(info.Uid, textprotoHeader)
 			return nil
 		})
 }
@@ -100,6 +105,27 @@ func (imapw *IMAPWorker) handleFetchMessageBodyPart(
 ) {
 	imapw.worker.Tracef("Fetching message %d part: %v", msg.Uid, msg.Part)
 
+	if imapw.bodyCacheEnabled() {
+		if data := imapw.getCachedBody(msg.Uid); data != nil {
+			if err := imapw.postBodyPart(msg, data); err != nil {
+				imapw.worker.PostMessage(&types.Error{
+					Message: types.RespondTo(msg),
+					Error:   err,
+				}, nil)
+				return
+			}
+			imapw.worker.PostMessage(
+				&types.Done{Message: types.RespondTo(msg)}, nil)
+			return
+		}
+		size := imapw.cachedSize(msg.Uid)
+		if size > 0 && uint64(size) <= imapw.config.cacheBodiesMaxSize {
+			// fetch the whole message once to make it available offline
+			imapw.fetchBodyPartFromMessage(msg)
+			return
+		}
+	}
+
 	var partHeaderSection imap.BodySectionName
 	partHeaderSection.Peek = true
 	if len(msg.Part) > 0 {
@@ -150,10 +176,21 @@ func (imapw *IMAPWorker) handleFetchMessageBodyPart(
 				return fmt.Errorf("failed to create message reader: %w", err)
 			}
 
+			reader := part.Body
+			mime := strings.ToLower(h.Get("Content-Type"))
+			if imapw.index != nil && (mime == "" || strings.HasPrefix(mime, "text/")) {
+				data, err := io.ReadAll(part.Body)
+				if err != nil {
+					return fmt.Errorf("failed to read part: %w", err)
+				}
+				imapw.indexText(models.Uint32ToUid(_msg.Uid), data)
+				reader = bytes.NewReader(data)
+			}
+
 			imapw.worker.PostMessage(&types.MessageBodyPart{
 				Message: types.RespondTo(msg),
 				Part: &models.MessageBodyPart{
-					Reader: part.Body,
+					Reader: reader,
 					Uid:    models.Uint32ToUid(_msg.Uid),
 				},
 			}, nil)
@@ -169,10 +206,105 @@ func (imapw *IMAPWorker) handleFetchMessageBodyPart(
 		})
 }
 
+// fetchBodyPartFromMessage fetches a complete message, caches it and
+// extracts the requested part.
+func (imapw *IMAPWorker) fetchBodyPartFromMessage(msg *types.FetchMessageBodyPart) {
+	section := &imap.BodySectionName{
+		Peek: true,
+	}
+	items := []imap.FetchItem{
+		imap.FetchFlags,
+		imap.FetchUid,
+		section.FetchItem(),
+	}
+	imapw.handleFetchMessages(msg, []models.UID{msg.Uid}, items,
+		func(_msg *imap.Message) error {
+			if len(_msg.Body) == 0 {
+				// ignore duplicate messages with only flag updates
+				return nil
+			}
+			r := _msg.GetBody(section)
+			if r == nil {
+				return fmt.Errorf("could not get section %#v", section)
+			}
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return fmt.Errorf("failed to read message: %w", err)
+			}
+			uid := models.Uint32ToUid(_msg.Uid)
+			imapw.cacheBody(uid, data)
+			if imapw.index != nil {
+				imapw.indexMessage(uid, data)
+			}
+			if err := imapw.postBodyPart(msg, data); err != nil {
+				return err
+			}
+			// Update flags (to mark message as read)
+			imapw.worker.PostMessage(&types.MessageInfo{
+				Message: types.RespondTo(msg),
+				Info: &models.MessageInfo{
+					Flags: translateImapFlags(_msg.Flags),
+					Uid:   uid,
+				},
+			}, nil)
+			return nil
+		})
+}
+
+// postBodyPart extracts a part from the full content of a message.
+func (imapw *IMAPWorker) postBodyPart(msg *types.FetchMessageBodyPart, data []byte) error {
+	e, err := rfc822.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	r, err := rfc822.FetchEntityPartReader(e, msg.Part)
+	if err != nil {
+		return err
+	}
+	imapw.worker.PostMessage(&types.MessageBodyPart{
+		Message: types.RespondTo(msg),
+		Part: &models.MessageBodyPart{
+			Reader: r,
+			Uid:    msg.Uid,
+		},
+	}, nil)
+	return nil
+}
+
+// postCachedMessages sends the messages which are in the cache and returns
+// the ones that must be fetched.
+func (imapw *IMAPWorker) postCachedMessages(msg *types.FetchFullMessages) []models.UID {
+	var need []models.UID
+	for _, uid := range msg.Uids {
+		data := imapw.getCachedBody(uid)
+		if data == nil {
+			need = append(need, uid)
+			continue
+		}
+		imapw.worker.PostMessage(&types.FullMessage{
+			Message: types.RespondTo(msg),
+			Content: &models.FullMessage{
+				Reader: bufio.NewReader(bytes.NewReader(data)),
+				Uid:    uid,
+			},
+		}, nil)
+	}
+	return need
+}
+
 func (imapw *IMAPWorker) handleFetchFullMessages(
 	msg *types.FetchFullMessages,
 ) {
 	imapw.worker.Tracef("Fetching full messages: %v", msg.Uids)
+	toFetch := msg.Uids
+	if imapw.bodyCacheEnabled() {
+		toFetch = imapw.postCachedMessages(msg)
+	}
+	if len(toFetch) == 0 {
+		imapw.worker.PostMessage(&types.Done{Message: types.RespondTo(msg)},
+			nil)
+		return
+	}
 	section := &imap.BodySectionName{
 		Peek: true,
 	}
@@ -182,16 +314,30 @@ func (imapw *IMAPWorker) handleFetchFullMessages(
 		imap.FetchUid,
 		section.FetchItem(),
 	}
-	imapw.handleFetchMessages(msg, msg.Uids, items,
+	imapw.handleFetchMessages(msg, toFetch, items,
 		func(_msg *imap.Message) error {
 			if len(_msg.Body) == 0 {
 				// ignore duplicate messages with only flag updates
 				return nil
 			}
-			r := _msg.GetBody(section)
+			var r io.Reader = _msg.GetBody(section)
 			if r == nil {
 				return fmt.Errorf("could not get section %#v", section)
 			}
+			if imapw.index != nil || imapw.bodyCacheEnabled() {
+				data, err := io.ReadAll(r)
+				if err != nil {
+					return fmt.Errorf("failed to read message: %w", err)
+				}
+				uid := models.Uint32ToUid(_msg.Uid)
+				if imapw.index != nil {
+					imapw.indexMessage(uid, data)
+				}
+				if imapw.bodyCacheEnabled() {
+					imapw.cacheBody(uid, data)
+				}
+				r = bytes.NewReader(data)
+			}
 			imapw.worker.PostMessage(&types.FullMessage{
 				Message: types.RespondTo(msg),
 				Content: &models.FullMessage{
@@ -252,6 +398,7 @@ func (imapw *IMAPWorker) handleFetchMessages(
 
 		for _msg := range messages {
 			delete(missingUids, models.Uint32ToUid(_msg.Uid))
+			imapw.cacheFlags(_msg)
 			err := procFunc(_msg)
 			if err != nil {
 				log.Errorf("failed to process message <%d>: %v", _msg.Uid, err)
@@ -1,6 +1,9 @@
 package imap
 
 import (
+	"fmt"
+	"slices"
+
 	"github.com/emersion/go-imap"
 	"github.com/emersion/go-imap/client"
 
@@ -111,6 +114,62 @@ func (imapw *IMAPWorker) handleStoreOps(
 	msg types.WorkerMessage, uids []models.UID, item imap.StoreItem, flag interface{},
 	procFunc func(*imap.Message) error,
 ) {
+	emitErr := func(err error) {
+		imapw.worker.PostMessage(&types.Error{
+			Message: types.RespondTo(msg),
+			Error:   err,
+		}, nil)
+	}
+
+	modified := new(imap.SeqSet)
+	if groups := imapw.unchangedSince(uids); groups != nil {
+		// do not override changes made by other clients meanwhile, the
+		// messages whose flags are known up to different modification
+		// sequences are stored separately
+		modSeqs := make([]uint64, 0, len(groups))
+		for modSeq := range groups {
+			modSeqs = append(modSeqs, modSeq)
+		}
+		slices.Sort(modSeqs)
+		for _, modSeq := range modSeqs {
+			set, err := imapw.storeFlagsOp(toSeqSet(groups[modSeq]),
+				item, flag, modSeq, procFunc)
+			if err != nil {
+				emitErr(err)
+				return
+			}
+			modified.AddSet(set)
+		}
+	} else {
+		_, err := imapw.storeFlagsOp(toSeqSet(uids), item, flag, 0, procFunc)
+		if err != nil {
+			emitErr(err)
+			return
+		}
+	}
+	if !modified.Empty() {
+		if err := imapw.fetchFlags(modified); err != nil {
+			emitErr(err)
+			return
+		}
+		emitErr(fmt.Errorf("messages %s were changed by another client, "+
+			"their flags were not updated", modified))
+		return
+	}
+	imapw.worker.PostAction(&types.CheckMail{
+		Directories: []string{imapw.selected.Name},
+	}, nil)
+	imapw.worker.PostMessage(
+		&types.Done{Message: types.RespondTo(msg)}, nil)
+}
+
+// storeFlagsOp alters the flags of a set of messages. If modSeq is not zero,
+// only the messages which were not modified since then are altered and the
+// other ones are returned.
+func (imapw *IMAPWorker) storeFlagsOp(
+	set *imap.SeqSet, item imap.StoreItem, flag interface{}, modSeq uint64,
+	procFunc func(*imap.Message) error,
+) (*imap.SeqSet, error) {
 	messages := make(chan *imap.Message)
 	done := make(chan error)
 
@@ -119,6 +178,7 @@ func (imapw *IMAPWorker) handleStoreOps(
 
 		var reterr error
 		for _msg := range messages {
+			imapw.cacheFlags(_msg)
 			err := procFunc(_msg)
 			if err != nil {
 				if reterr == nil {
@@ -132,25 +192,20 @@ func (imapw *IMAPWorker) handleStoreOps(
 		done <- reterr
 	}()
 
-	emitErr := func(err error) {
-		imapw.worker.PostMessage(&types.Error{
-			Message: types.RespondTo(msg),
-			Error:   err,
-		}, nil)
+	var err error
+	modified := new(imap.SeqSet)
+	if modSeq != 0 {
+		modified, err = imapw.client.condstore.UidStoreUnchangedSince(
+			set, item, flag, modSeq, messages)
+	} else {
+		err = imapw.client.UidStore(set, item, flag, messages)
 	}
-
-	set := toSeqSet(uids)
-	if err := imapw.client.UidStore(set, item, flag, messages); err != nil {
-		emitErr(err)
-		return
+	// the channel is closed in all cases
+	if procErr := <-done; err == nil {
+		err = procErr
 	}
-	if err := <-done; err != nil {
-		emitErr(err)
-		return
+	if err != nil {
+		return nil, err
 	}
-	imapw.worker.PostAction(&types.CheckMail{
-		Directories: []string{imapw.selected.Name},
-	}, nil)
-	imapw.worker.PostMessage(
-		&types.Done{Message: types.RespondTo(msg)}, nil)
+	return modified, nil
 }
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
 	"github.com/emersion/go-message/mail"
 
 	"github.com/emersion/go-imap"
@@ -49,3 +50,19 @@ func TestTranslateEnvelope(t *testing.T) {
 	}
 	assert.Equal(t, &expected, translateEnvelope(&given))
 }
+
+func TestTranslateSearchQuery(t *testing.T) {
+	q, err := types.ParseQuery("from:alice (a OR b OR c) NOT is:read larger:1k")
+	assert.Nil(t, err)
+	c := translateSearch(&types.SearchCriteria{Query: q})
+
+	assert.Equal(t, []string{"alice"}, c.Header.Values("From"))
+	assert.Equal(t, uint32(1024), c.Larger)
+	assert.Len(t, c.Not, 1)
+	assert.Equal(t, []string{imap.SeenFlag}, c.Not[0].WithFlags)
+	// (a OR b) OR c
+	assert.Len(t, c.Or, 1)
+	assert.Equal(t, []string{"c"}, c.Or[0][1].Header.Values("Subject"))
+	assert.Len(t, c.Or[0][0].Or, 1)
+	assert.Equal(t, []string{"a"}, c.Or[0][0].Or[0][0].Header.Values("Subject"))
+}
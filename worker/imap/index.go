@@ -0,0 +1,119 @@
+package imap
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-message/textproto"
+
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/lib"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+// initIndex opens (or creates) the full text index. Messages are indexed as
+// their headers and bodies are fetched.
+func (w *IMAPWorker) initIndex(acct string) {
+	p := lib.TextIndexPath(acct)
+	index, err := lib.OpenTextIndex(p)
+	if err != nil {
+		w.worker.Errorf("failed opening full text index: %v", err)
+		return
+	}
+	w.index = index
+	w.worker.Debugf("full text index opened: %s", p)
+}
+
+// indexScope identifies the selected mailbox in the full text index. UIDs
+// are only valid for a given UIDVALIDITY.
+func (w *IMAPWorker) indexScope() string {
+	return fmt.Sprintf("%s.%d", w.selected.Name, w.selected.UidValidity)
+}
+
+func (w *IMAPWorker) indexHeader(uid models.UID, h textproto.Header) {
+	if w.index == nil || w.selected == nil {
+		return
+	}
+	if err := w.index.AddHeader(w.indexScope(), uid, h); err != nil {
+		w.worker.Errorf("cannot index header of %s: %v", uid, err)
+	}
+}
+
+func (w *IMAPWorker) indexMessage(uid models.UID, data []byte) {
+	if w.index == nil || w.selected == nil {
+		return
+	}
+	err := w.index.AddMessage(w.indexScope(), uid, bytes.NewReader(data))
+	if err != nil {
+		w.worker.Errorf("cannot index message %s: %v", uid, err)
+	}
+}
+
+func (w *IMAPWorker) indexText(uid models.UID, data []byte) {
+	if w.index == nil || w.selected == nil {
+		return
+	}
+	err := w.index.AddText(w.indexScope(), uid, bytes.NewReader(data))
+	if err != nil {
+		w.worker.Errorf("cannot index text of %s: %v", uid, err)
+	}
+}
+
+// searchCriteria translates search criteria to IMAP. When the full text
+// index is used, the messages whose body is indexed are narrowed down to the
+// ones which contain the text terms. The other messages are searched by the
+// server.
+func (w *IMAPWorker) searchCriteria(c *types.SearchCriteria) *imap.SearchCriteria {
+	if w.index == nil || w.selected == nil || c == nil ||
+		(!c.UseExtension && !w.config.useIndex) {
+		return translateSearch(c)
+	}
+	full := translateSearch(lib.ExtensionCriteria(c))
+	words, rest, ok := lib.IndexCriteria(c)
+	if !ok {
+		return full
+	}
+	complete, err := w.index.Complete(w.indexScope())
+	if err != nil {
+		w.worker.Errorf("full text index search failed: %v", err)
+		return full
+	}
+	if len(complete) == 0 {
+		return full
+	}
+	found, ok, err := w.index.Search(w.indexScope(), words)
+	if err != nil {
+		w.worker.Errorf("full text index search failed: %v", err)
+		return full
+	}
+	if !ok {
+		return full
+	}
+	indexed := translateSearch(rest)
+	indexed.Uid = new(imap.SeqSet)
+	matched := 0
+	for uid := range found {
+		if _, ok := complete[uid]; ok {
+			indexed.Uid.AddNum(models.UidToUint32(uid))
+			matched++
+		}
+	}
+	w.worker.Debugf("full text index matched %d of %d indexed messages",
+		matched, len(complete))
+	if indexed.Uid.Empty() {
+		// nothing matched, use an UID that cannot exist
+		indexed.Uid.AddNum(math.MaxUint32)
+	}
+	// messages which are not indexed yet are searched by the server
+	skip := &imap.SearchCriteria{Uid: new(imap.SeqSet)}
+	for uid := range complete {
+		skip.Uid.AddNum(models.UidToUint32(uid))
+	}
+	full.Not = append(full.Not, skip)
+
+	return &imap.SearchCriteria{
+		Or: [][2]*imap.SearchCriteria{{indexed, full}},
+	}
+}
@@ -0,0 +1,67 @@
+package imap
+
+import (
+	"testing"
+
+	"github.com/emersion/go-message/textproto"
+
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/lib"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+func TestSearchUnindexed(t *testing.T) {
+	s := &stubServer{}
+	w := newStubWorker(t, s)
+	s.reset(42, 100)
+	openInbox(t, w)
+
+	index, err := lib.OpenTextIndex(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+	w.index = index
+	w.config.useIndex = true
+
+	query, err := types.ParseQuery("body:release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	criteria := &types.SearchCriteria{Query: query}
+
+	// nothing indexed, the server searches everything
+	s.reset(42, 100)
+	_, _ = w.client.UidSearch(w.searchCriteria(criteria))
+	if cmds := s.received(); len(cmds) != 1 ||
+		cmds[0] != `UID SEARCH CHARSET UTF-8 BODY "release"` {
+		t.Errorf("unexpected commands: %q", cmds)
+	}
+
+	w.indexMessage(models.Uint32ToUid(1), []byte("Subject: hi\r\n\r\nrelease notes\r\n"))
+	w.indexMessage(models.Uint32ToUid(3), []byte("Subject: hi\r\n\r\nnothing\r\n"))
+	var h textproto.Header
+	h.Set("Subject", "release")
+	w.indexHeader(models.Uint32ToUid(2), h)
+
+	// messages whose body is not indexed are searched by the server, the
+	// indexed ones are narrowed down and must still match
+	s.reset(42, 100)
+	_, _ = w.client.UidSearch(w.searchCriteria(criteria))
+	expected := `UID SEARCH CHARSET UTF-8 OR (UID 1 BODY "release") (BODY "release" NOT (UID 1,3))`
+	if cmds := s.received(); len(cmds) != 1 || cmds[0] != expected {
+		t.Errorf("unexpected commands: %q", cmds)
+	}
+
+	// -e terms made of a single word are only looked up in the index
+	s.reset(42, 100)
+	_, _ = w.client.UidSearch(w.searchCriteria(&types.SearchCriteria{
+		Terms:        []string{"release v2.1"},
+		UseExtension: true,
+	}))
+	expected = `UID SEARCH CHARSET UTF-8 OR (UID 4294967295 TEXT "v2.1") ` +
+		`(TEXT "release" TEXT "v2.1" NOT (UID 1,3))`
+	if cmds := s.received(); len(cmds) != 1 || cmds[0] != expected {
+		t.Errorf("unexpected commands: %q", cmds)
+	}
+}
@@ -11,89 +11,122 @@ import (
 )
 
 func (imapw *IMAPWorker) handleListDirectories(msg *types.ListDirectories) {
-	mailboxes := make(chan *imap.MailboxInfo)
 	imapw.worker.Tracef("Listing mailboxes")
-	done := make(chan interface{})
-
-	go func() {
-		defer log.PanicHandler()
-
-		for mbox := range mailboxes {
-			if !canOpen(mbox) {
-				// no need to pass this to handlers if it can't be opened
-				continue
-			}
-			dir := &models.Directory{
-				Name: mbox.Name,
-			}
-			for _, attr := range mbox.Attributes {
-				attr = strings.TrimPrefix(attr, "\\")
-				attr = strings.ToLower(attr)
-				role, ok := models.Roles[attr]
-				if !ok {
+	var statuses []*imap.MailboxStatus
+	var dirs []models.Directory
+	listed := make(map[string]bool)
+
+	collect := func(list func(chan *imap.MailboxInfo) error) error {
+		mailboxes := make(chan *imap.MailboxInfo)
+		done := make(chan interface{})
+		go func() {
+			defer log.PanicHandler()
+
+			for mbox := range mailboxes {
+				if !canOpen(mbox) || listed[mbox.Name] {
+					// no need to pass this to handlers if it can't be opened
 					continue
 				}
-				dir.Role = role
-			}
-			if mbox.Name == "INBOX" {
-				dir.Role = models.InboxRole
+				listed[mbox.Name] = true
+				dirs = append(dirs, imapw.directory(mbox))
 			}
-			imapw.worker.PostMessage(&types.Directory{
-				Message: types.RespondTo(msg),
-				Dir:     dir,
-			}, nil)
-		}
-		done <- nil
-	}()
+			done <- nil
+		}()
+		err := list(mailboxes)
+		<-done
+		return err
+	}
 
-	switch {
-	case imapw.liststatus:
+	err := collect(func(mailboxes chan *imap.MailboxInfo) error {
+		if !imapw.liststatus {
+			return imapw.client.List("", "*", mailboxes)
+		}
 		items := []imap.StatusItem{
 			imap.StatusMessages,
 			imap.StatusRecent,
 			imap.StatusUnseen,
 		}
-		statuses, err := imapw.client.liststatus.ListStatus(
+		var err error
+		statuses, err = imapw.client.liststatus.ListStatus(
 			"",
 			"*",
 			items,
 			mailboxes,
 		)
-		if err != nil {
-			<-done
-			imapw.worker.PostMessage(&types.Error{
-				Message: types.RespondTo(msg),
-				Error:   err,
-			}, nil)
-			return
-
-		}
-		for _, status := range statuses {
-			imapw.worker.PostMessage(&types.DirectoryInfo{
-				Info: &models.DirectoryInfo{
-					Name:   status.Name,
-					Exists: int(status.Messages),
-					Recent: int(status.Recent),
-					Unseen: int(status.Unseen),
-				},
-			}, nil)
+		return err
+	})
+	if err != nil {
+		imapw.worker.PostMessage(&types.Error{
+			Message: types.RespondTo(msg),
+			Error:   err,
+		}, nil)
+		return
+	}
+	// servers are not required to include the other namespaces in *
+	for prefix := range imapw.namespaces {
+		if prefix == "" {
+			continue
 		}
-	default:
-		err := imapw.client.List("", "*", mailboxes)
+		err := collect(func(mailboxes chan *imap.MailboxInfo) error {
+			return imapw.client.List("", prefix+"*", mailboxes)
+		})
 		if err != nil {
-			<-done
-			imapw.worker.PostMessage(&types.Error{
-				Message: types.RespondTo(msg),
-				Error:   err,
-			}, nil)
-			return
+			imapw.worker.Warnf("cannot list %s folders: %v", prefix, err)
+		}
+	}
+
+	for i := range dirs {
+		if dirs[i].Namespace != models.PersonalNamespace && imapw.acl {
+			rights, err := imapw.client.acl.MyRights(dirs[i].Name)
+			if err != nil {
+				imapw.worker.Warnf("cannot get rights on %s: %v",
+					dirs[i].Name, err)
+			}
+			dirs[i].Rights = rights
 		}
+		dir := dirs[i]
+		imapw.worker.PostMessage(&types.Directory{
+			Message: types.RespondTo(msg),
+			Dir:     &dir,
+		}, nil)
+	}
+	for _, status := range statuses {
+		imapw.worker.PostMessage(&types.DirectoryInfo{
+			Info: &models.DirectoryInfo{
+				Name:   status.Name,
+				Exists: int(status.Messages),
+				Recent: int(status.Recent),
+				Unseen: int(status.Unseen),
+			},
+		}, nil)
+	}
+	if imapw.offlineEnabled() {
+		imapw.saveDirectories(dirs)
 	}
-	<-done
 	imapw.worker.PostMessage(
 		&types.Done{Message: types.RespondTo(msg)}, nil)
 }
 
+func (imapw *IMAPWorker) directory(mbox *imap.MailboxInfo) models.Directory {
+	dir := models.Directory{
+		Name:      mbox.Name,
+		Namespace: imapw.namespaceOf(mbox.Name),
+	}
+	for _, attr := range mbox.Attributes {
+		attr = strings.TrimPrefix(attr, "\\")
+		attr = strings.ToLower(attr)
+		role, ok := models.Roles[attr]
+		if !ok {
+			continue
+		}
+		dir.Role = role
+	}
+	if mbox.Name == "INBOX" {
+		dir.Role = models.InboxRole
+	}
+	return dir
+}
+
 const NonExistentAttr = "\\NonExistent"
 
 func canOpen(mbox *imap.MailboxInfo) bool {
@@ -115,7 +148,7 @@ func (imapw *IMAPWorker) handleSearchDirectory(msg *types.SearchDirectory) {
 	}
 
 	imapw.worker.Tracef("Executing search")
-	criteria := translateSearch(msg.Criteria)
+	criteria := imapw.searchCriteria(msg.Criteria)
 
 	if msg.Context.Err() != nil {
 		imapw.worker.PostMessage(&types.Cancelled{
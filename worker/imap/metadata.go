@@ -0,0 +1,34 @@
+package imap
+
+import (
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+func (w *IMAPWorker) handleGetMetadata(msg *types.GetMetadata) error {
+	if !w.metadata {
+		return errUnsupported
+	}
+	entries, err := w.client.metadata.GetMetadata(msg.Directory, msg.Entries)
+	if err != nil {
+		return err
+	}
+	w.worker.PostMessage(&types.DirectoryMetadata{
+		Message:   types.RespondTo(msg),
+		Directory: msg.Directory,
+		Entries:   entries,
+	}, nil)
+	w.worker.PostMessage(&types.Done{Message: types.RespondTo(msg)}, nil)
+	return nil
+}
+
+func (w *IMAPWorker) handleSetMetadata(msg *types.SetMetadata) error {
+	if !w.metadata {
+		return errUnsupported
+	}
+	err := w.client.metadata.SetMetadata(msg.Directory, msg.Entry, msg.Value)
+	if err != nil {
+		return err
+	}
+	w.worker.PostMessage(&types.Done{Message: types.RespondTo(msg)}, nil)
+	return nil
+}
@@ -0,0 +1,63 @@
+package imap
+
+import (
+	"reflect"
+	"testing"
+
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/imap/extensions"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+func TestMetadata(t *testing.T) {
+	s := &stubServer{}
+	w := newStubWorker(t, s)
+	w.client.metadata = extensions.NewMetadataClient(w.client.Client)
+	w.client.specialuse = extensions.NewSpecialUseClient(w.client.Client)
+	w.metadata = true
+	w.specialuse = true
+	messages := make(chan types.WorkerMessage, 100)
+	w.worker.(*types.Worker).SetMessages(messages)
+
+	err := w.handleGetMetadata(&types.GetMetadata{
+		Directory: "Team",
+		Entries:   []string{"/private/comment"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, ok := (<-messages).(*types.DirectoryMetadata)
+	if !ok {
+		t.Fatal("no metadata")
+	}
+	entries := map[string]string{"/private/comment": "team folder"}
+	if !reflect.DeepEqual(msg.Entries, entries) {
+		t.Errorf("unexpected metadata: %v", msg.Entries)
+	}
+	<-messages
+
+	err = w.handleSetMetadata(&types.SetMetadata{
+		Directory: "Team",
+		Entry:     "/private/comment",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.handleCreateDirectory(&types.CreateDirectory{
+		Directory: "Sent Items",
+		Role:      models.SentRole,
+	})
+	for len(messages) > 0 {
+		if msg, ok := (<-messages).(*types.Error); ok {
+			t.Fatal(msg.Error)
+		}
+	}
+	expected := []string{
+		`GETMETADATA "Team" ("/private/comment")`,
+		`SETMETADATA "Team" ("/private/comment" NIL)`,
+		`CREATE "Sent Items" (USE (\Sent))`,
+	}
+	if cmds := s.received(); !reflect.DeepEqual(cmds, expected) {
+		t.Errorf("unexpected commands: %q", cmds)
+	}
+}
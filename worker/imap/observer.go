@@ -23,6 +23,8 @@ type observer struct {
 	autoReconnect bool
 	retries       int
 	running       bool
+	// when set, connection errors are sent there instead of to the UI
+	lost chan<- error
 }
 
 func newObserver(cfg imapConfig, w types.WorkerInteractor) *observer {
@@ -135,6 +137,13 @@ func (o *observer) DelayedReconnect() error {
 }
 
 func (o *observer) emit(errMsg string) {
+	if o.lost != nil {
+		select {
+		case o.lost <- fmt.Errorf("%s", errMsg):
+		default:
+		}
+		return
+	}
 	o.worker.PostMessage(&types.Done{
 		Message: types.RespondTo(&types.Disconnect{}),
 	}, nil)
@@ -0,0 +1,501 @@
+package imap
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+var (
+	errOffline     = fmt.Errorf("not available offline")
+	journalPrefix  = []byte("journal.")
+	directoriesKey = []byte("directories")
+)
+
+// OfflineDirectories is the last known list of folders of the account.
+type OfflineDirectories struct {
+	Delimiter   string
+	Directories []models.Directory
+}
+
+// OfflineContents is the last known list of messages of a folder.
+type OfflineContents struct {
+	UidValidity uint32
+	Uids        []uint32
+}
+
+type journalOp int
+
+const (
+	opFlag journalOp = iota
+	opDelete
+	opMove
+	opCopy
+)
+
+// JournalEntry is a change made while offline which must be sent to the
+// server once it is reachable again.
+type JournalEntry struct {
+	Op          journalOp
+	Folder      string
+	UidValidity uint32
+	Uids        []uint32
+	Flags       models.Flags
+	Enable      bool
+	Destination string
+}
+
+func (e *JournalEntry) String() string {
+	var op string
+	switch e.Op {
+	case opFlag:
+		op = "flag change"
+	case opDelete:
+		op = "deletion"
+	case opMove:
+		op = "move to " + e.Destination
+	case opCopy:
+		op = "copy to " + e.Destination
+	}
+	return fmt.Sprintf("%s of %d message(s) in %s", op, len(e.Uids), e.Folder)
+}
+
+// offlineEnabled returns true if the account can be used from the cache when
+// the server cannot be reached.
+func (w *IMAPWorker) offlineEnabled() bool {
+	return w.config.offline && w.cache != nil
+}
+
+func (w *IMAPWorker) isOffline() bool {
+	return w.client == nil && w.offlineEnabled()
+}
+
+// workOffline is called when the server cannot be reached. The account
+// remains usable from the cache until a new connection succeeds.
+func (w *IMAPWorker) workOffline(err error) {
+	w.terminate()
+	w.worker.Warnf("working offline: %v", err)
+	w.worker.PostMessage(&types.Error{
+		Error: fmt.Errorf("working offline: %w", err),
+	}, nil)
+	w.offlineRetries = 0
+	w.scheduleReconnect()
+}
+
+func (w *IMAPWorker) scheduleReconnect() {
+	w.cancelReconnect()
+	wait := w.config.reconnect_maxwait
+	backoff := math.Pow(1.8, float64(w.offlineRetries))
+	if backoff < wait.Seconds() {
+		wait = time.Duration(backoff * float64(time.Second))
+	}
+	w.offlineRetries++
+	w.worker.Debugf("reconnecting in %s", wait)
+	w.reconnectTimer = time.AfterFunc(wait, func() {
+		defer log.PanicHandler()
+		select {
+		case w.reconnect <- struct{}{}:
+		default:
+		}
+	})
+}
+
+func (w *IMAPWorker) cancelReconnect() {
+	if w.reconnectTimer != nil {
+		w.reconnectTimer.Stop()
+		w.reconnectTimer = nil
+	}
+}
+
+// reconnectOffline tries to connect to the server again. On success, the
+// changes made offline are replayed and the UI is notified so that it
+// refreshes its folders.
+func (w *IMAPWorker) reconnectOffline() {
+	if w.client != nil || !w.observer.AutoReconnect() {
+		return
+	}
+	c, err := w.connect()
+	if err != nil {
+		w.worker.Debugf("still offline: %v", err)
+		w.scheduleReconnect()
+		return
+	}
+	w.newClient(c)
+	w.worker.Infof("connection restored")
+	w.worker.PostMessage(&types.Done{
+		Message: types.RespondTo(&types.Reconnect{}),
+	}, nil)
+}
+
+// replayJournal sends the changes made offline to the server, in the order
+// in which they were made. The changes which cannot be applied anymore are
+// discarded with an error.
+func (w *IMAPWorker) replayJournal() {
+	if w.cache == nil {
+		return
+	}
+	iter := w.cache.NewIterator(util.BytesPrefix(journalPrefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		entry := &JournalEntry{}
+		err := gob.NewDecoder(bytes.NewReader(iter.Value())).Decode(entry)
+		if err != nil {
+			w.worker.Errorf("cannot decode journal entry %s: %v",
+				iter.Key(), err)
+		} else if err := w.replay(entry); err != nil {
+			if w.client.State() == imap.LogoutState {
+				// connection lost, try again on the next one
+				w.worker.Errorf("cannot replay offline %s: %v", entry, err)
+				return
+			}
+			w.worker.PostMessage(&types.Error{
+				Error: fmt.Errorf("offline %s discarded: %w", entry, err),
+			}, nil)
+		} else {
+			w.worker.Debugf("replayed offline %s", entry)
+		}
+		if err := w.cache.Delete(iter.Key(), nil); err != nil {
+			w.worker.Errorf("cannot remove journal entry %s: %v",
+				iter.Key(), err)
+		}
+	}
+}
+
+func (w *IMAPWorker) replay(entry *JournalEntry) error {
+	sel, err := w.client.Select(entry.Folder, false)
+	if err != nil {
+		return err
+	}
+	if sel.UidValidity != entry.UidValidity {
+		return fmt.Errorf("%s was changed on the server (UIDVALIDITY %d != %d)",
+			entry.Folder, sel.UidValidity, entry.UidValidity)
+	}
+	set := new(imap.SeqSet)
+	set.AddNum(entry.Uids...)
+	switch entry.Op {
+	case opFlag:
+		var op imap.FlagsOp = imap.AddFlags
+		if !entry.Enable {
+			op = imap.RemoveFlags
+		}
+		var flags []interface{}
+		for _, flag := range translateFlags(entry.Flags) {
+			flags = append(flags, flag)
+		}
+		return w.client.UidStore(set, imap.FormatFlagsOp(op, true), flags, nil)
+	case opDelete:
+		item := imap.FormatFlagsOp(imap.AddFlags, true)
+		flags := []interface{}{imap.DeletedFlag}
+		if err := w.client.UidStore(set, item, flags, nil); err != nil {
+			return err
+		}
+		return w.client.Expunge(nil)
+	case opMove:
+		return w.client.UidMove(set, entry.Destination)
+	case opCopy:
+		return w.client.UidCopy(set, entry.Destination)
+	}
+	return fmt.Errorf("unknown operation %d", entry.Op)
+}
+
+func (w *IMAPWorker) addJournalEntry(entry *JournalEntry) error {
+	entry.Folder = w.selected.Name
+	entry.UidValidity = w.selected.UidValidity
+	data := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(data).Encode(entry); err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s%020d", journalPrefix, time.Now().UnixNano())
+	return w.cache.Put([]byte(key), data.Bytes(), nil)
+}
+
+func (w *IMAPWorker) saveDirectories(dirs []models.Directory) {
+	data := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(data).Encode(&OfflineDirectories{
+		Delimiter:   w.delimiter,
+		Directories: dirs,
+	})
+	if err == nil {
+		err = w.cache.Put(directoriesKey, data.Bytes(), nil)
+	}
+	if err != nil {
+		w.worker.Errorf("cannot cache directories: %v", err)
+	}
+}
+
+func (w *IMAPWorker) contentsKey(dir string) []byte {
+	return []byte("contents." + dir)
+}
+
+// saveContents records the list of messages of the selected folder.
+func (w *IMAPWorker) saveContents(uids []uint32) {
+	data := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(data).Encode(&OfflineContents{
+		UidValidity: w.selected.UidValidity,
+		Uids:        uids,
+	})
+	if err == nil {
+		err = w.cache.Put(w.contentsKey(w.selected.Name), data.Bytes(), nil)
+	}
+	if err != nil {
+		w.worker.Errorf("cannot cache %s contents: %v", w.selected.Name, err)
+	}
+}
+
+func (w *IMAPWorker) offlineContents(dir string) (*OfflineContents, error) {
+	data, err := w.cache.Get(w.contentsKey(dir), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, fmt.Errorf("%s: %w", dir, errOffline)
+	}
+	if err != nil {
+		return nil, err
+	}
+	contents := &OfflineContents{}
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(contents)
+	return contents, err
+}
+
+// offlineFlags returns the last known flags of a message.
+func (w *IMAPWorker) offlineFlags(uid models.UID) models.Flags {
+	flags, ok := w.readFlags(uid)
+	if !ok {
+		// same as for cached headers without flags
+		flags = models.SeenFlag
+	}
+	return flags
+}
+
+func (w *IMAPWorker) handleOfflineMessage(msg types.WorkerMessage) error {
+	var err error
+	switch msg := msg.(type) {
+	case *types.ListDirectories:
+		err = w.offlineListDirectories(msg)
+	case *types.OpenDirectory:
+		err = w.offlineOpenDirectory(msg)
+	case *types.FetchDirectoryContents:
+		err = w.offlineDirectoryContents(msg)
+	case *types.FetchDirectoryThreaded:
+		err = w.offlineDirectoryThreaded(msg)
+	case *types.FetchMessageHeaders:
+		for _, uid := range w.getCachedHeaders(msg) {
+			w.postOfflineError(msg, uid)
+		}
+	case *types.FetchMessageFlags:
+		for _, uid := range msg.Uids {
+			w.worker.PostMessage(&types.MessageInfo{
+				Message: types.RespondTo(msg),
+				Info: &models.MessageInfo{
+					Flags: w.offlineFlags(uid),
+					Uid:   uid,
+				},
+			}, nil)
+		}
+	case *types.FetchMessageBodyPart:
+		data := w.getCachedBody(msg.Uid)
+		if data == nil {
+			return fmt.Errorf("message %s: %w", msg.Uid, errOffline)
+		}
+		err = w.postBodyPart(msg, data)
+	case *types.FetchFullMessages:
+		for _, uid := range w.postCachedMessages(msg) {
+			w.postOfflineError(msg, uid)
+		}
+	case *types.FlagMessages:
+		err = w.offlineFlagMessages(msg, msg.Uids, msg.Flags, msg.Enable)
+	case *types.AnsweredMessages:
+		err = w.offlineFlagMessages(msg, msg.Uids, models.AnsweredFlag, msg.Answered)
+	case *types.DeleteMessages:
+		err = w.offlineRemoveMessages(msg, msg.Uids, &JournalEntry{
+			Op: opDelete,
+		})
+	case *types.MoveMessages:
+		err = w.offlineRemoveMessages(msg, msg.Uids, &JournalEntry{
+			Op:          opMove,
+			Destination: msg.Destination,
+		})
+		if err == nil {
+			w.worker.PostMessage(&types.MessagesMoved{
+				Message:     types.RespondTo(msg),
+				Destination: msg.Destination,
+				Uids:        msg.Uids,
+			}, nil)
+		}
+	case *types.CopyMessages:
+		err = w.addJournalEntry(&JournalEntry{
+			Op:          opCopy,
+			Uids:        models.UidToUint32List(msg.Uids),
+			Destination: msg.Destination,
+		})
+		if err == nil {
+			w.worker.PostMessage(&types.MessagesCopied{
+				Message:     types.RespondTo(msg),
+				Destination: msg.Destination,
+				Uids:        msg.Uids,
+			}, nil)
+		}
+	case *types.CheckMail:
+		// nothing to check until the server is reachable again
+	default:
+		return errOffline
+	}
+	if err != nil {
+		return err
+	}
+	w.worker.PostMessage(&types.Done{Message: types.RespondTo(msg)}, nil)
+	return nil
+}
+
+func (w *IMAPWorker) postOfflineError(msg types.WorkerMessage, uid models.UID) {
+	w.worker.PostMessage(&types.MessageInfo{
+		Message: types.RespondTo(msg),
+		Info: &models.MessageInfo{
+			Uid:   uid,
+			Error: errOffline,
+		},
+	}, nil)
+}
+
+func (w *IMAPWorker) offlineListDirectories(msg *types.ListDirectories) error {
+	data, err := w.cache.Get(directoriesKey, nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return fmt.Errorf("folder list: %w", errOffline)
+	}
+	if err != nil {
+		return err
+	}
+	dirs := &OfflineDirectories{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(dirs); err != nil {
+		return err
+	}
+	w.delimiter = dirs.Delimiter
+	for i := range dirs.Directories {
+		w.worker.PostMessage(&types.Directory{
+			Message: types.RespondTo(msg),
+			Dir:     &dirs.Directories[i],
+		}, nil)
+	}
+	return nil
+}
+
+func (w *IMAPWorker) offlineOpenDirectory(msg *types.OpenDirectory) error {
+	contents, err := w.offlineContents(msg.Directory)
+	if err != nil {
+		return err
+	}
+	w.selected = &imap.MailboxStatus{
+		Name:        msg.Directory,
+		UidValidity: contents.UidValidity,
+	}
+	w.highestModSeq = 0
+	return nil
+}
+
+func (w *IMAPWorker) offlineDirectoryContents(msg *types.FetchDirectoryContents) error {
+	if msg.Filter != nil {
+		return fmt.Errorf("filtering is %w", errOffline)
+	}
+	contents, err := w.offlineContents(w.selected.Name)
+	if err != nil {
+		return err
+	}
+	w.worker.PostMessage(&types.DirectoryContents{
+		Message: types.RespondTo(msg),
+		Uids:    models.Uint32ToUidList(contents.Uids),
+	}, nil)
+	return nil
+}
+
+func (w *IMAPWorker) offlineDirectoryThreaded(msg *types.FetchDirectoryThreaded) error {
+	if msg.Filter != nil {
+		return fmt.Errorf("filtering is %w", errOffline)
+	}
+	contents, err := w.offlineContents(w.selected.Name)
+	if err != nil {
+		return err
+	}
+	// the threads are not known, each message is on its own
+	threads := make([]*types.Thread, 0, len(contents.Uids))
+	for _, uid := range contents.Uids {
+		threads = append(threads, &types.Thread{Uid: models.Uint32ToUid(uid)})
+	}
+	sort.Sort(types.ByUID(threads))
+	w.worker.PostMessage(&types.DirectoryThreaded{
+		Message: types.RespondTo(msg),
+		Threads: threads,
+	}, nil)
+	return nil
+}
+
+func (w *IMAPWorker) offlineFlagMessages(
+	msg types.WorkerMessage, uids []models.UID, flag models.Flags, enable bool,
+) error {
+	err := w.addJournalEntry(&JournalEntry{
+		Op:     opFlag,
+		Uids:   models.UidToUint32List(uids),
+		Flags:  flag,
+		Enable: enable,
+	})
+	if err != nil {
+		return err
+	}
+	for _, uid := range uids {
+		flags := w.offlineFlags(uid)
+		if enable {
+			flags |= flag
+		} else {
+			flags &^= flag
+		}
+		w.storeFlags(models.UidToUint32(uid), flags, 0)
+		w.worker.PostMessage(&types.MessageInfo{
+			Message: types.RespondTo(msg),
+			Info: &models.MessageInfo{
+				Flags: flags,
+				Uid:   uid,
+			},
+		}, nil)
+	}
+	return nil
+}
+
+// offlineRemoveMessages records the deletion or the move of messages and
+// removes them from the selected folder.
+func (w *IMAPWorker) offlineRemoveMessages(
+	msg types.WorkerMessage, uids []models.UID, entry *JournalEntry,
+) error {
+	entry.Uids = models.UidToUint32List(uids)
+	if err := w.addJournalEntry(entry); err != nil {
+		return err
+	}
+	contents, err := w.offlineContents(w.selected.Name)
+	if err != nil {
+		return err
+	}
+	removed := make(map[uint32]bool, len(entry.Uids))
+	for _, uid := range entry.Uids {
+		removed[uid] = true
+	}
+	remaining := make([]uint32, 0, len(contents.Uids))
+	for _, uid := range contents.Uids {
+		if !removed[uid] {
+			remaining = append(remaining, uid)
+		}
+	}
+	w.saveContents(remaining)
+	w.worker.PostMessage(&types.MessagesDeleted{
+		Message: types.RespondTo(msg),
+		Uids:    uids,
+	}, nil)
+	return nil
+}
@@ -0,0 +1,102 @@
+package imap
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-imap"
+
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+func TestOfflineJournal(t *testing.T) {
+	s := &stubServer{}
+	w := newStubWorker(t, s)
+	w.config.offline = true
+	messages := make(chan types.WorkerMessage, 100)
+	w.worker.(*types.Worker).SetMessages(messages)
+
+	s.reset(42, 0)
+	openInbox(t, w)
+	w.saveContents([]uint32{11, 12, 13})
+
+	// connection lost
+	client := w.client
+	w.client = nil
+	for _, msg := range []types.WorkerMessage{
+		&types.OpenDirectory{Directory: "INBOX"},
+		&types.FlagMessages{
+			Uids:   []models.UID{models.Uint32ToUid(11)},
+			Flags:  models.FlaggedFlag,
+			Enable: true,
+		},
+		&types.DeleteMessages{
+			Uids: []models.UID{models.Uint32ToUid(12)},
+		},
+	} {
+		if err := w.handleOfflineMessage(msg); err != nil {
+			t.Fatalf("%T: %v", msg, err)
+		}
+	}
+	err := w.handleOfflineMessage(&types.CreateDirectory{Directory: "x"})
+	if !errors.Is(err, errOffline) {
+		t.Errorf("expected offline error, got %v", err)
+	}
+
+	flags := w.offlineFlags(models.Uint32ToUid(11))
+	if flags != models.SeenFlag|models.FlaggedFlag {
+		t.Errorf("unexpected offline flags: %v", flags)
+	}
+	contents, err := w.offlineContents("INBOX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(contents.Uids, []uint32{11, 13}) {
+		t.Errorf("deleted message still listed: %v", contents.Uids)
+	}
+
+	// the folder is recreated on the server before reconnecting
+	w.selected = &imap.MailboxStatus{Name: "INBOX", UidValidity: 41}
+	err = w.handleOfflineMessage(&types.CopyMessages{
+		Uids:        []models.UID{models.Uint32ToUid(13)},
+		Destination: "Archive",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.client = client
+	s.reset(42, 0)
+	for len(messages) > 0 {
+		<-messages
+	}
+	w.replayJournal()
+
+	expected := []string{
+		"SELECT INBOX",
+		`UID STORE 11 +FLAGS.SILENT (\Flagged)`,
+		"SELECT INBOX",
+		`UID STORE 12 +FLAGS.SILENT (\Deleted)`,
+		"EXPUNGE",
+		"SELECT INBOX",
+	}
+	if cmds := s.received(); !reflect.DeepEqual(cmds, expected) {
+		t.Errorf("unexpected commands on replay: %q", cmds)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expected one discarded entry, got %d messages", len(messages))
+	}
+	msg, ok := (<-messages).(*types.Error)
+	if !ok || !strings.Contains(msg.Error.Error(), "copy to Archive") {
+		t.Errorf("unexpected message: %v", msg)
+	}
+
+	s.reset(42, 0)
+	w.replayJournal()
+	if cmds := s.received(); len(cmds) != 0 {
+		t.Errorf("journal replayed twice: %q", cmds)
+	}
+}
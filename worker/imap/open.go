@@ -3,6 +3,7 @@ package imap
 import (
 	"sort"
 
+	"github.com/emersion/go-imap"
 	sortthread "github.com/emersion/go-imap-sortthread"
 
 	"git.sr.ht/~rjarry/aerc/models"
@@ -12,7 +13,7 @@ import (
 func (imapw *IMAPWorker) handleOpenDirectory(msg *types.OpenDirectory) {
 	imapw.worker.Debugf("Opening %s", msg.Directory)
 
-	sel, err := imapw.client.Select(msg.Directory, false)
+	sel, highestModSeq, changes, err := imapw.selectMailbox(msg.Directory)
 	if err != nil {
 		imapw.worker.PostMessage(&types.Error{
 			Message: types.RespondTo(msg),
@@ -25,10 +26,80 @@ func (imapw *IMAPWorker) handleOpenDirectory(msg *types.OpenDirectory) {
 		imapw.worker.PostMessage(&types.Cancelled{Message: types.RespondTo(msg)}, nil)
 	default:
 		imapw.selected = sel
+		if imapw.watcher != nil {
+			imapw.watcher.Select(sel.Name)
+		}
+		if imapw.useCondstore() {
+			if err := imapw.resync(highestModSeq, changes); err != nil {
+				imapw.worker.Warnf("%s: resync failed: %v",
+					msg.Directory, err)
+			}
+		}
 		imapw.worker.PostMessage(&types.Done{Message: types.RespondTo(msg)}, nil)
 	}
 }
 
+// enterFolder selects another mailbox than the one opened by the user in
+// order to perform an action in it. Unilateral updates are ignored while it is
+// selected. The returned function selects the opened mailbox again.
+func (imapw *IMAPWorker) enterFolder(name string) (func(), error) {
+	prev, modSeq := imapw.selected, imapw.highestModSeq
+	uids := imapw.seqMap.Swap(nil)
+	imapw.client.Updates = nil
+	sel, err := imapw.client.Select(name, false)
+	if err != nil {
+		// a failed SELECT also closes the previously selected mailbox
+		imapw.leaveFolder(prev, modSeq, uids)
+		return nil, err
+	}
+	imapw.selected = sel
+	imapw.highestModSeq = 0
+	return func() { imapw.leaveFolder(prev, modSeq, uids) }, nil
+}
+
+// leaveFolder selects the mailbox opened by the user again after an action
+// was performed in another one. If messages were added or expunged in the
+// meantime, the contents of the mailbox are refetched.
+func (imapw *IMAPWorker) leaveFolder(
+	prev *imap.MailboxStatus, modSeq uint64, uids []uint32,
+) {
+	defer func() {
+		if imapw.idler != nil {
+			imapw.client.Updates = imapw.updates
+		}
+	}()
+
+	imapw.selected = prev
+	imapw.highestModSeq = modSeq
+	imapw.seqMap.Swap(uids)
+	if prev.Name == "" {
+		return
+	}
+
+	sel, highestModSeq, changes, err := imapw.selectMailbox(prev.Name)
+	if err != nil {
+		imapw.worker.Errorf("%s: could not select again: %v", prev.Name, err)
+		return
+	}
+	imapw.selected = sel
+	if imapw.useCondstore() {
+		if err := imapw.resync(highestModSeq, changes); err != nil {
+			imapw.worker.Warnf("%s: resync failed: %v", prev.Name, err)
+		}
+	}
+	if sel.Messages != prev.Messages || sel.UidNext != prev.UidNext {
+		imapw.worker.PostMessage(&types.DirectoryInfo{
+			Info: &models.DirectoryInfo{
+				Name:   sel.Name,
+				Exists: int(sel.Messages),
+				Recent: int(sel.Recent),
+				Unseen: int(sel.Unseen),
+			},
+			Refetch: true,
+		}, nil)
+	}
+}
+
 func (imapw *IMAPWorker) handleFetchDirectoryContents(
 	msg *types.FetchDirectoryContents,
 ) {
@@ -40,7 +111,7 @@ func (imapw *IMAPWorker) handleFetchDirectoryContents(
 	}
 	imapw.worker.Tracef("Fetching UID list")
 
-	searchCriteria := translateSearch(msg.Filter)
+	searchCriteria := imapw.searchCriteria(msg.Filter)
 	sortCriteria := translateSortCriterions(msg.SortCriteria)
 	hasSortCriteria := len(sortCriteria) > 0
 
@@ -85,6 +156,10 @@ func (imapw *IMAPWorker) handleFetchDirectoryContents(
 	if msg.Filter == nil {
 		// Only initialize if we are not filtering
 		imapw.seqMap.Initialize(uids)
+		imapw.pruneFlags(uids)
+		if imapw.offlineEnabled() {
+			imapw.saveContents(uids)
+		}
 	}
 
 	imapw.worker.PostMessage(&types.DirectoryContents{
@@ -130,7 +205,7 @@ func (imapw *IMAPWorker) handleDirectoryThreaded(
 	}
 	imapw.worker.Tracef("Fetching threaded UID list")
 
-	searchCriteria := translateSearch(msg.Filter)
+	searchCriteria := imapw.searchCriteria(msg.Filter)
 	threads, err := imapw.client.thread.UidThread(imapw.threadAlgorithm,
 		searchCriteria)
 	if err != nil {
@@ -153,6 +228,9 @@ func (imapw *IMAPWorker) handleDirectoryThreaded(
 			})
 		}
 		imapw.seqMap.Initialize(uids)
+		if imapw.offlineEnabled() {
+			imapw.saveContents(uids)
+		}
 	}
 	if msg.Context.Err() != nil {
 		imapw.worker.PostMessage(&types.Cancelled{
@@ -0,0 +1,52 @@
+package imap
+
+import (
+	"testing"
+
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+func TestEnterFolder(t *testing.T) {
+	s := &stubServer{}
+	w := newStubWorker(t, s)
+
+	s.reset(42, 100)
+	openInbox(t, w)
+	w.seqMap.Initialize([]uint32{11, 12})
+
+	s.reset(42, 100)
+	err := w.handleMessage(types.InFolder(&types.FlagMessages{
+		Enable: true,
+		Flags:  models.SeenFlag,
+		Uids:   []models.UID{models.Uint32ToUid(3)},
+	}, "Archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmds := s.received()
+	if len(cmds) != 3 || cmds[0] != `SELECT "Archive"` ||
+		cmds[2] != "SELECT INBOX (CONDSTORE)" {
+		t.Fatalf("unexpected commands: %q", cmds)
+	}
+	if w.selected.Name != "INBOX" {
+		t.Errorf("expected INBOX to be selected, got %q", w.selected.Name)
+	}
+	if w.seqMap.Size() != 2 {
+		t.Errorf("seqmap of INBOX was not restored")
+	}
+
+	// actions in the selected folder do not select it again
+	s.reset(42, 100)
+	err = w.handleMessage(types.InFolder(&types.FlagMessages{
+		Enable: true,
+		Flags:  models.SeenFlag,
+		Uids:   []models.UID{models.Uint32ToUid(11)},
+	}, "INBOX"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmds := s.received(); len(cmds) != 1 {
+		t.Errorf("unexpected commands: %q", cmds)
+	}
+}
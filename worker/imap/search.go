@@ -1,6 +1,7 @@
 package imap
 
 import (
+	"math"
 	"strings"
 
 	"github.com/emersion/go-imap"
@@ -48,5 +49,114 @@ func translateSearch(c *types.SearchCriteria) *imap.SearchCriteria {
 			}
 		}
 	}
+	if c.Query != nil {
+		mergeSearch(criteria, translateQuery(c.Query, c))
+	}
+	return criteria
+}
+
+// translateQuery converts a query expression tree to IMAP search criteria.
+// Words without keys are matched according to the SearchBody and SearchAll
+// flags of c.
+func translateQuery(q *types.Query, c *types.SearchCriteria) *imap.SearchCriteria {
+	criteria := imap.NewSearchCriteria()
+	switch q.Op {
+	case types.QueryAnd:
+		for _, o := range q.Operands {
+			mergeSearch(criteria, translateQuery(o, c))
+		}
+	case types.QueryOr:
+		// IMAP OR only takes two operands, nest them as needed
+		for i, o := range q.Operands {
+			sub := translateQuery(o, c)
+			if i == 0 {
+				criteria = sub
+				continue
+			}
+			or := imap.NewSearchCriteria()
+			or.Or = [][2]*imap.SearchCriteria{{criteria, sub}}
+			criteria = or
+		}
+	case types.QueryNot:
+		for _, o := range q.Operands {
+			criteria.Not = append(criteria.Not, translateQuery(o, c))
+		}
+	case types.QueryTerm:
+		translateTerm(criteria, q, c)
+	}
 	return criteria
 }
+
+func translateTerm(criteria *imap.SearchCriteria, q *types.Query, c *types.SearchCriteria) {
+	switch q.Key {
+	case types.QueryWord:
+		switch {
+		case c.SearchAll:
+			criteria.Text = append(criteria.Text, q.Value)
+		case c.SearchBody:
+			criteria.Body = append(criteria.Body, q.Value)
+		default:
+			criteria.Header.Add("Subject", q.Value)
+		}
+	case types.QueryFrom:
+		criteria.Header.Add("From", q.Value)
+	case types.QueryTo:
+		criteria.Header.Add("To", q.Value)
+	case types.QueryCc:
+		criteria.Header.Add("Cc", q.Value)
+	case types.QuerySubject:
+		criteria.Header.Add("Subject", q.Value)
+	case types.QueryHeader:
+		criteria.Header.Add(q.Header, q.Value)
+	case types.QueryBody:
+		criteria.Body = append(criteria.Body, q.Value)
+	case types.QueryText:
+		criteria.Text = append(criteria.Text, q.Value)
+	case types.QueryFlag:
+		criteria.WithFlags = append(criteria.WithFlags, translateFlags(q.Flag)...)
+	case types.QueryTag:
+		criteria.WithFlags = append(criteria.WithFlags, q.Value)
+	case types.QueryAttachment:
+		// IMAP has no attachment criteria, this is the best approximation
+		criteria.Header.Add("Content-Type", "multipart/mixed")
+	case types.QueryDate:
+		criteria.SentSince = q.Start
+		criteria.SentBefore = q.End
+	case types.QueryLarger:
+		criteria.Larger = uint32(min(q.Size, math.MaxUint32))
+	case types.QuerySmaller:
+		criteria.Smaller = uint32(min(q.Size, math.MaxUint32))
+	}
+}
+
+// mergeSearch adds the conditions of src to dst, so that both must match.
+func mergeSearch(dst, src *imap.SearchCriteria) {
+	for k, v := range src.Header {
+		dst.Header[k] = append(dst.Header[k], v...)
+	}
+	dst.Body = append(dst.Body, src.Body...)
+	dst.Text = append(dst.Text, src.Text...)
+	dst.WithFlags = append(dst.WithFlags, src.WithFlags...)
+	dst.WithoutFlags = append(dst.WithoutFlags, src.WithoutFlags...)
+	dst.Not = append(dst.Not, src.Not...)
+	dst.Or = append(dst.Or, src.Or...)
+	if src.Since.After(dst.Since) {
+		dst.Since = src.Since
+	}
+	if !src.Before.IsZero() && (dst.Before.IsZero() || src.Before.Before(dst.Before)) {
+		dst.Before = src.Before
+	}
+	if src.SentSince.After(dst.SentSince) {
+		dst.SentSince = src.SentSince
+	}
+	if !src.SentBefore.IsZero() &&
+		(dst.SentBefore.IsZero() || src.SentBefore.Before(dst.SentBefore)) {
+		dst.SentBefore = src.SentBefore
+	}
+	if src.Larger > dst.Larger {
+		dst.Larger = src.Larger
+	}
+	if src.Smaller != 0 && (dst.Smaller == 0 || src.Smaller < dst.Smaller) {
+		dst.Smaller = src.Smaller
+	}
+}
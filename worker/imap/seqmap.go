@@ -1,8 +1,11 @@
 package imap
 
 import (
+	"slices"
 	"sort"
 	"sync"
+
+	"github.com/emersion/go-imap"
 )
 
 type SeqMap struct {
@@ -20,6 +23,15 @@ func (s *SeqMap) Initialize(uids []uint32) {
 	s.lock.Unlock()
 }
 
+// Swap replaces the seqmap and returns the previous one
+func (s *SeqMap) Swap(uids []uint32) []uint32 {
+	s.lock.Lock()
+	prev := s.m
+	s.m = uids
+	s.lock.Unlock()
+	return prev
+}
+
 func (s *SeqMap) Size() int {
 	s.lock.Lock()
 	size := len(s.m)
@@ -67,6 +79,21 @@ func (s *SeqMap) Pop(seqnum uint32) (uint32, bool) {
 	return uid, true
 }
 
+// Remove removes the given UIDs and returns the ones which were found
+func (s *SeqMap) Remove(set *imap.SeqSet) []uint32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var removed []uint32
+	s.m = slices.DeleteFunc(s.m, func(uid uint32) bool {
+		if set.Contains(uid) {
+			removed = append(removed, uid)
+			return true
+		}
+		return false
+	})
+	return removed
+}
+
 // sort sorts the slice in ascending UID order. See:
 // https://datatracker.ietf.org/doc/html/rfc3501#section-2.3.1.2
 func (s *SeqMap) sort() {
@@ -0,0 +1,217 @@
+package imap
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"git.sr.ht/~rjarry/aerc/worker/imap/extensions"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+// stand-in IMAP server with a single mailbox
+type stubServer struct {
+	lock          sync.Mutex
+	uidValidity   uint32
+	highestModSeq uint64
+	uidNext       uint32
+	changes       []string
+	modified      string
+	qresync       bool
+	vanished      string
+	statuses      []string
+	folders       []string
+	commands      []string
+}
+
+func (s *stubServer) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	caps := "IMAP4rev1 IDLE CONDSTORE NOTIFY ACL NAMESPACE METADATA CREATE-SPECIAL-USE"
+	s.lock.Lock()
+	if s.qresync {
+		caps += " QRESYNC"
+	}
+	s.lock.Unlock()
+	_ = text.PrintfLine("* OK [CAPABILITY %s] ready", caps)
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		tag, cmd, _ := strings.Cut(line, " ")
+		s.lock.Lock()
+		s.commands = append(s.commands, cmd)
+		switch {
+		case strings.HasPrefix(cmd, "SELECT"), strings.HasPrefix(cmd, "EXAMINE"):
+			_ = text.PrintfLine(`* FLAGS (\Seen \Flagged)`)
+			_ = text.PrintfLine("* 2 EXISTS")
+			_ = text.PrintfLine("* OK [UIDVALIDITY %d] ok", s.uidValidity)
+			_ = text.PrintfLine("* OK [UIDNEXT %d] ok", s.uidNext)
+			if s.highestModSeq == 0 {
+				_ = text.PrintfLine("* OK [NOMODSEQ] no persistent modseq")
+			} else {
+				_ = text.PrintfLine("* OK [HIGHESTMODSEQ %d] ok", s.highestModSeq)
+			}
+			if strings.Contains(cmd, "(QRESYNC ") {
+				for _, change := range s.changes {
+					_ = text.PrintfLine("* %s", change)
+				}
+				if s.vanished != "" {
+					_ = text.PrintfLine("* VANISHED (EARLIER) %s", s.vanished)
+				}
+			}
+			_ = text.PrintfLine("%s OK [READ-WRITE] selected", tag)
+		case strings.HasPrefix(cmd, "UID FETCH"):
+			for _, change := range s.changes {
+				_ = text.PrintfLine("* %s", change)
+			}
+			_ = text.PrintfLine("%s OK fetched", tag)
+		case strings.HasPrefix(cmd, "STATUS"):
+			name := strings.Fields(cmd)[1]
+			_ = text.PrintfLine("* STATUS %s (MESSAGES 3 RECENT 0 UNSEEN 1 UIDNEXT %d)",
+				name, s.uidNext)
+			_ = text.PrintfLine("%s OK status", tag)
+		case strings.HasPrefix(cmd, "NOTIFY"):
+			for _, status := range s.statuses {
+				_ = text.PrintfLine("* %s", status)
+			}
+			_ = text.PrintfLine("%s OK notifying", tag)
+		case strings.HasPrefix(cmd, "LIST"):
+			fields := strings.Fields(cmd)
+			prefix := strings.TrimSuffix(strings.Trim(fields[len(fields)-1], `"`), "*")
+			for _, folder := range s.folders {
+				if strings.HasPrefix(folder, prefix) {
+					_ = text.PrintfLine(`* LIST () "/" "%s"`, folder)
+				}
+			}
+			_ = text.PrintfLine("%s OK listed", tag)
+		case cmd == "ENABLE QRESYNC" && s.qresync:
+			_ = text.PrintfLine("* ENABLED QRESYNC")
+			_ = text.PrintfLine("%s OK enabled", tag)
+		case cmd == "NOOP":
+			_ = text.PrintfLine("%s OK noop", tag)
+		case cmd == "EXPUNGE" && s.vanished != "":
+			_ = text.PrintfLine("* VANISHED %s", s.vanished)
+			_ = text.PrintfLine("%s OK expunged", tag)
+		case cmd == "NAMESPACE":
+			_ = text.PrintfLine(`* NAMESPACE (("" "/")) (("Other/" "/")) (("Shared/" "/"))`)
+			_ = text.PrintfLine("%s OK namespaces", tag)
+		case strings.HasPrefix(cmd, "MYRIGHTS"):
+			name := strings.Fields(cmd)[1]
+			_ = text.PrintfLine("* MYRIGHTS %s lrs", name)
+			_ = text.PrintfLine("%s OK rights", tag)
+		case strings.HasPrefix(cmd, "GETACL"):
+			name := strings.Fields(cmd)[1]
+			_ = text.PrintfLine("* ACL %s alice lrswipkxte bob lrs", name)
+			_ = text.PrintfLine("%s OK acl", tag)
+		case strings.HasPrefix(cmd, "GETMETADATA"):
+			name := strings.Fields(cmd)[1]
+			_ = text.PrintfLine(`* METADATA %s ("/private/comment" "team folder")`, name)
+			_ = text.PrintfLine("%s OK metadata", tag)
+		case strings.HasPrefix(cmd, "SETMETADATA"), strings.HasPrefix(cmd, "CREATE"):
+			_ = text.PrintfLine("%s OK done", tag)
+		case cmd == "IDLE":
+			_ = text.PrintfLine("+ idling")
+			for _, status := range s.statuses {
+				_ = text.PrintfLine("* %s", status)
+			}
+			s.lock.Unlock()
+			_, _ = text.ReadLine()
+			s.lock.Lock()
+			_ = text.PrintfLine("%s OK idle done", tag)
+		case cmd == "LOGOUT":
+			_ = text.PrintfLine("* BYE logging out")
+			_ = text.PrintfLine("%s OK logged out", tag)
+			s.lock.Unlock()
+			return
+		case s.modified != "" &&
+			strings.HasPrefix(cmd, "UID STORE "+s.modified+" "):
+			_ = text.PrintfLine("%s OK [MODIFIED %s] conditional store", tag, s.modified)
+		case strings.HasPrefix(cmd, "UID STORE"),
+			strings.HasPrefix(cmd, "UID COPY"),
+			cmd == "EXPUNGE", cmd == "CLOSE":
+			_ = text.PrintfLine("%s OK done", tag)
+		default:
+			_ = text.PrintfLine("%s BAD unexpected command", tag)
+		}
+		s.lock.Unlock()
+	}
+}
+
+func (s *stubServer) reset(uidValidity uint32, modSeq uint64, changes ...string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.uidValidity = uidValidity
+	s.highestModSeq = modSeq
+	s.changes = changes
+	s.modified = ""
+	s.vanished = ""
+	s.commands = nil
+}
+
+func (s *stubServer) received() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.commands
+}
+
+// dial returns a client logged in the server.
+func (s *stubServer) dial() (*client.Client, error) {
+	serverConn, clientConn := net.Pipe()
+	go s.serve(serverConn)
+	c, err := client.New(clientConn)
+	if err != nil {
+		return nil, err
+	}
+	c.SetState(imap.AuthenticatedState, nil)
+	return c, nil
+}
+
+func newStubWorker(t *testing.T, s *stubServer) *IMAPWorker {
+	t.Helper()
+	c, err := s.dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Terminate() })
+
+	db, err := leveldb.OpenFile(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	worker := types.NewWorker("test")
+	worker.SetMessages(make(chan types.WorkerMessage, 100))
+	return &IMAPWorker{
+		config: imapConfig{cacheEnabled: true},
+		client: &imapClient{
+			Client:    c,
+			condstore: extensions.NewCondstoreClient(c),
+			qresync:   extensions.NewQresyncClient(c),
+		},
+		worker:    worker,
+		selected:  &imap.MailboxStatus{},
+		cache:     db,
+		condstore: true,
+	}
+}
+
+func openInbox(t *testing.T, w *IMAPWorker) {
+	t.Helper()
+	w.handleOpenDirectory(&types.OpenDirectory{
+		Context:   context.Background(),
+		Directory: "INBOX",
+	})
+	if w.selected.Name != "INBOX" {
+		t.Fatal("INBOX was not opened")
+	}
+}
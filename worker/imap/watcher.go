@@ -0,0 +1,355 @@
+package imap
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+
+	"git.sr.ht/~rjarry/aerc/lib/log"
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/imap/extensions"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+// RFC 2177 asks clients to restart IDLE at least every 29 minutes
+const watchIdleRestart = 25 * time.Minute
+
+var errIdleStopped = fmt.Errorf("idle stopped")
+
+// watcher follows the folders listed in watch-folders while they are not
+// selected: their counts are updated and their new messages are reported.
+// This is done on separate connections so that the selected folder is not
+// disturbed. If the server supports NOTIFY (RFC 5465), a single connection
+// is used for all folders. Otherwise, each folder is idling on its own
+// connection.
+type watcher struct {
+	sync.Mutex
+	config   imapConfig
+	worker   types.WorkerInteractor
+	dial     func() (*client.Client, error)
+	selected string
+	stop     chan struct{}
+}
+
+func newWatcher(
+	cfg imapConfig, w types.WorkerInteractor, dial func() (*client.Client, error),
+) *watcher {
+	return &watcher{config: cfg, worker: w, dial: dial}
+}
+
+func (wt *watcher) Start(notify bool) {
+	wt.Lock()
+	defer wt.Unlock()
+	if wt.stop != nil {
+		return
+	}
+	wt.stop = make(chan struct{})
+	if notify {
+		go wt.follow(wt.stop, wt.config.watchFolders, wt.notifySession)
+		return
+	}
+	for _, folder := range wt.config.watchFolders {
+		go wt.follow(wt.stop, []string{folder}, wt.idleSession)
+	}
+}
+
+func (wt *watcher) Stop() {
+	wt.Lock()
+	defer wt.Unlock()
+	if wt.stop != nil {
+		close(wt.stop)
+		wt.stop = nil
+	}
+}
+
+// Select records the folder which is followed by the main connection.
+func (wt *watcher) Select(name string) {
+	wt.Lock()
+	wt.selected = name
+	wt.Unlock()
+}
+
+func (wt *watcher) isSelected(name string) bool {
+	wt.Lock()
+	defer wt.Unlock()
+	return wt.selected == name
+}
+
+type watchSession func(
+	stop <-chan struct{}, folders []string, uidNext map[string]uint32,
+) (bool, error)
+
+// follow runs sessions until stopped, reconnecting with an exponential
+// back-off. The next expected UIDs are kept across sessions so that the
+// messages which arrived while disconnected are reported as well.
+func (wt *watcher) follow(stop <-chan struct{}, folders []string, session watchSession) {
+	defer log.PanicHandler()
+	uidNext := make(map[string]uint32)
+	retries := 0
+	for {
+		connected, err := session(stop, folders, uidNext)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if connected {
+			retries = 0
+		}
+		wait := wt.config.reconnect_maxwait
+		backoff := math.Pow(1.8, float64(retries))
+		if backoff < wait.Seconds() {
+			wait = time.Duration(backoff * float64(time.Second))
+		}
+		retries++
+		wt.worker.Debugf("watcher %v: %v, reconnecting in %s", folders, err, wait)
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// notifySession follows folders with STATUS responses sent by the server.
+func (wt *watcher) notifySession(
+	stop <-chan struct{}, folders []string, uidNext map[string]uint32,
+) (bool, error) {
+	c, err := wt.dial()
+	if err != nil {
+		return false, err
+	}
+	defer wt.logout(c)
+
+	notify := extensions.NewNotifyClient(c)
+	c.Timeout = wt.config.connection_timeout
+	statuses, err := notify.SetStatus(folders)
+	if err != nil {
+		return true, err
+	}
+	for _, status := range statuses {
+		if _, ok := uidNext[status.Name]; !ok {
+			uidNext[status.Name] = status.UidNext
+		}
+	}
+	// catch up with the changes made while disconnected
+	for _, folder := range folders {
+		if err := wt.check(c, folder, uidNext); err != nil {
+			return true, err
+		}
+	}
+
+	for {
+		c.Timeout = 0
+		changes := make(chan *imap.MailboxStatus, 16)
+		idleStop := make(chan struct{})
+		done := make(chan error, 1)
+		go func() {
+			defer log.PanicHandler()
+			done <- notify.Idle(idleStop, changes)
+		}()
+
+		changed := make(map[string]bool)
+		select {
+		case <-stop:
+			close(idleStop)
+			return true, <-done
+		case err := <-done:
+			if err == nil {
+				err = errIdleStopped
+			}
+			return true, err
+		case <-time.After(watchIdleRestart):
+			close(idleStop)
+		case status := <-changes:
+			changed[status.Name] = true
+			close(idleStop)
+		}
+		if err := <-done; err != nil {
+			return true, err
+		}
+		for len(changes) > 0 {
+			changed[(<-changes).Name] = true
+		}
+
+		c.Timeout = wt.config.connection_timeout
+		for folder := range changed {
+			if err := wt.check(c, folder, uidNext); err != nil {
+				return true, err
+			}
+		}
+	}
+}
+
+// idleSession follows a single folder by idling on it.
+func (wt *watcher) idleSession(
+	stop <-chan struct{}, folders []string, uidNext map[string]uint32,
+) (bool, error) {
+	c, err := wt.dial()
+	if err != nil {
+		return false, err
+	}
+	folder := folders[0]
+
+	// the updates must be consumed while checking the folder
+	updates := make(chan client.Update, 50)
+	changed := make(chan struct{}, 1)
+	c.Updates = updates
+	go func() {
+		defer log.PanicHandler()
+		for update := range updates {
+			switch update.(type) {
+			case *client.MailboxUpdate, *client.ExpungeUpdate:
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	defer func() {
+		wt.logout(c)
+		<-c.LoggedOut()
+		close(updates)
+	}()
+
+	c.Timeout = wt.config.connection_timeout
+	status, err := c.Select(folder, true)
+	if err != nil {
+		return true, err
+	}
+	if _, ok := uidNext[folder]; !ok {
+		uidNext[folder] = status.UidNext
+	}
+	if err := wt.check(c, folder, uidNext); err != nil {
+		return true, err
+	}
+
+	for {
+		c.Timeout = 0
+		idleStop := make(chan struct{})
+		done := make(chan error, 1)
+		go func() {
+			defer log.PanicHandler()
+			done <- c.Idle(idleStop, nil)
+		}()
+
+		select {
+		case <-stop:
+			close(idleStop)
+			return true, <-done
+		case err := <-done:
+			if err == nil {
+				err = errIdleStopped
+			}
+			return true, err
+		case <-changed:
+			close(idleStop)
+		}
+		if err := <-done; err != nil {
+			return true, err
+		}
+
+		c.Timeout = wt.config.connection_timeout
+		if err := wt.check(c, folder, uidNext); err != nil {
+			return true, err
+		}
+	}
+}
+
+func (wt *watcher) logout(c *client.Client) {
+	c.Timeout = wt.config.connection_timeout
+	if err := c.Logout(); err != nil {
+		_ = c.Terminate()
+	}
+}
+
+// check reports the counts of a folder and the messages which arrived since
+// it was last checked. Nothing is reported for the selected folder which is
+// followed by the main connection.
+func (wt *watcher) check(c *client.Client, folder string, uidNext map[string]uint32) error {
+	status, err := c.Status(folder, []imap.StatusItem{
+		imap.StatusMessages,
+		imap.StatusRecent,
+		imap.StatusUnseen,
+		imap.StatusUidNext,
+	})
+	if err != nil {
+		return err
+	}
+	last, known := uidNext[folder]
+	uidNext[folder] = status.UidNext
+	if wt.isSelected(folder) {
+		return nil
+	}
+
+	wt.worker.PostMessage(&types.DirectoryInfo{
+		Info: &models.DirectoryInfo{
+			Name:   folder,
+			Exists: int(status.Messages),
+			Recent: int(status.Recent),
+			Unseen: int(status.Unseen),
+		},
+	}, nil)
+	if !known || status.UidNext <= last {
+		return nil
+	}
+
+	if mbox := c.Mailbox(); mbox == nil || mbox.Name != folder {
+		if _, err := c.Select(folder, true); err != nil {
+			return err
+		}
+		// go back to the authenticated state to get STATUS responses
+		// for this folder again
+		defer func() {
+			if err := c.Close(); err != nil {
+				wt.worker.Debugf("watcher: cannot close %s: %v", folder, err)
+			}
+		}()
+	}
+	set := new(imap.SeqSet)
+	set.AddRange(last, 0)
+	items := []imap.FetchItem{
+		imap.FetchUid,
+		imap.FetchFlags,
+		imap.FetchEnvelope,
+		imap.FetchInternalDate,
+	}
+	messages := make(chan *imap.Message)
+	done := make(chan []*models.MessageInfo)
+	go func() {
+		defer log.PanicHandler()
+		var infos []*models.MessageInfo
+		for msg := range messages {
+			flags := translateImapFlags(msg.Flags)
+			// with last:*, the last message is returned even if
+			// older than last
+			if msg.Uid < last || flags.Has(models.SeenFlag) {
+				continue
+			}
+			infos = append(infos, &models.MessageInfo{
+				Envelope:     translateEnvelope(msg.Envelope),
+				Flags:        flags,
+				InternalDate: msg.InternalDate,
+				Uid:          models.Uint32ToUid(msg.Uid),
+			})
+		}
+		done <- infos
+	}()
+	err = c.UidFetch(set, items, messages)
+	infos := <-done
+	if err != nil {
+		return err
+	}
+	if len(infos) > 0 {
+		wt.worker.PostMessage(&types.NewMessages{
+			Directory: folder,
+			Infos:     infos,
+		}, nil)
+	}
+	return nil
+}
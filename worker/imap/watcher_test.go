@@ -0,0 +1,148 @@
+package imap
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap"
+
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/imap/extensions"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+func newStubWatcher(t *testing.T, s *stubServer) (*watcher, chan types.WorkerMessage) {
+	t.Helper()
+	messages := make(chan types.WorkerMessage, 100)
+	worker := types.NewWorker("test")
+	worker.SetMessages(messages)
+	cfg := imapConfig{watchFolders: []string{"Lists"}}
+	return newWatcher(cfg, worker, s.dial), messages
+}
+
+func TestWatcherNotify(t *testing.T) {
+	s := &stubServer{
+		uidNext:  12,
+		statuses: []string{"STATUS Lists (MESSAGES 2 UIDNEXT 10 UIDVALIDITY 1)"},
+		changes: []string{
+			`1 FETCH (UID 10 FLAGS () ENVELOPE (NIL "new" NIL NIL NIL NIL NIL NIL NIL "<10@x>"))`,
+			`2 FETCH (UID 11 FLAGS (\Seen) ENVELOPE (NIL "read" NIL NIL NIL NIL NIL NIL NIL "<11@x>"))`,
+		},
+	}
+	wt, messages := newStubWatcher(t, s)
+	c, err := s.dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.logout(c)
+
+	notify := extensions.NewNotifyClient(c)
+	statuses, err := notify.SetStatus(wt.config.watchFolders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].Name != "Lists" || statuses[0].UidNext != 10 {
+		t.Fatalf("unexpected statuses: %v", statuses)
+	}
+
+	changes := make(chan *imap.MailboxStatus, 1)
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() { done <- notify.Idle(stop, changes) }()
+	select {
+	case status := <-changes:
+		if status.Name != "Lists" {
+			t.Errorf("unexpected status while idling: %v", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no status while idling")
+	}
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	uidNext := map[string]uint32{"Lists": 10}
+	if err := wt.check(c, "Lists", uidNext); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		`NOTIFY SET STATUS (mailboxes ("Lists") (MessageNew MessageExpunge))`,
+		"IDLE",
+		`STATUS "Lists" (MESSAGES RECENT UNSEEN UIDNEXT)`,
+		`EXAMINE "Lists"`,
+		"UID FETCH 10:* (UID FLAGS ENVELOPE INTERNALDATE)",
+		"CLOSE",
+	}
+	if cmds := s.received(); !reflect.DeepEqual(cmds, expected) {
+		t.Errorf("unexpected commands: %q", cmds)
+	}
+	if uidNext["Lists"] != 12 {
+		t.Errorf("next uid not updated: %d", uidNext["Lists"])
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	info, ok := (<-messages).(*types.DirectoryInfo)
+	if !ok || info.Info.Name != "Lists" || info.Info.Exists != 3 || info.Info.Unseen != 1 {
+		t.Errorf("unexpected directory info: %v", info)
+	}
+	msg, ok := (<-messages).(*types.NewMessages)
+	if !ok || msg.Directory != "Lists" || len(msg.Infos) != 1 {
+		t.Fatalf("unexpected new messages: %v", msg)
+	}
+	if msg.Infos[0].Uid != models.Uint32ToUid(10) || msg.Infos[0].Envelope.Subject != "new" {
+		t.Errorf("unexpected new message: %v", msg.Infos[0])
+	}
+
+	// the selected folder is followed by the main connection
+	wt.Select("Lists")
+	s.reset(0, 0)
+	s.uidNext = 13
+	if err := wt.check(c, "Lists", uidNext); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 0 {
+		t.Errorf("selected folder reported")
+	}
+	if uidNext["Lists"] != 13 {
+		t.Errorf("next uid not updated for the selected folder")
+	}
+}
+
+func TestWatcherIdle(t *testing.T) {
+	s := &stubServer{
+		uidNext:  10,
+		statuses: []string{"3 EXISTS"},
+		changes: []string{
+			`3 FETCH (UID 10 FLAGS () ENVELOPE (NIL "new" NIL NIL NIL NIL NIL NIL NIL "<10@x>"))`,
+		},
+	}
+	wt, messages := newStubWatcher(t, s)
+	wt.Start(false)
+	defer wt.Stop()
+
+	// a message arrives after the first check
+	time.AfterFunc(100*time.Millisecond, func() {
+		s.lock.Lock()
+		s.uidNext = 11
+		s.lock.Unlock()
+	})
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-messages:
+			if msg, ok := msg.(*types.NewMessages); ok {
+				if len(msg.Infos) != 1 || msg.Infos[0].Uid != models.Uint32ToUid(10) {
+					t.Errorf("unexpected new messages: %v", msg.Infos)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no new messages, commands: %q", s.received())
+		}
+	}
+}
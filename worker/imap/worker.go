@@ -15,6 +15,7 @@ import (
 	"git.sr.ht/~rjarry/aerc/models"
 	"git.sr.ht/~rjarry/aerc/worker/handlers"
 	"git.sr.ht/~rjarry/aerc/worker/imap/extensions"
+	workerlib "git.sr.ht/~rjarry/aerc/worker/lib"
 	"git.sr.ht/~rjarry/aerc/worker/middleware"
 	"git.sr.ht/~rjarry/aerc/worker/types"
 )
@@ -36,6 +37,12 @@ type imapClient struct {
 	thread     *sortthread.ThreadClient
 	sort       *sortthread.SortClient
 	liststatus *extensions.ListStatusClient
+	condstore  *extensions.CondstoreClient
+	qresync    *extensions.QresyncClient
+	acl        *extensions.ACLClient
+	namespace  *extensions.NamespaceClient
+	metadata   *extensions.MetadataClient
+	specialuse *extensions.SpecialUseClient
 }
 
 type imapConfig struct {
@@ -59,7 +66,13 @@ type imapConfig struct {
 	keepalive_interval int
 	cacheEnabled       bool
 	cacheMaxAge        time.Duration
+	cacheBodies        bool
+	cacheBodiesMaxSize uint64
+	offline            bool
+	watchFolders       []string
 	useXGMEXT          bool
+	fullTextIndex      bool
+	useIndex           bool
 }
 
 type IMAPWorker struct {
@@ -74,14 +87,32 @@ type IMAPWorker struct {
 
 	idler    *idler
 	observer *observer
+	watcher  *watcher
 	cache    *leveldb.DB
+	index    *workerlib.TextIndex
 
 	caps *models.Capabilities
 
 	threadAlgorithm sortthread.ThreadAlgorithm
 	liststatus      bool
+	condstore       bool
+	qresync         bool
+	acl             bool
+	metadata        bool
+	specialuse      bool
+	// prefixes of the shared and other users namespaces
+	namespaces map[string]models.Namespace
+	// highest modification sequence of the selected mailbox, zero when
+	// its flags are not tracked in the cache
+	highestModSeq uint64
 
 	executeIdle chan struct{}
+
+	// connection lost while working offline
+	lost           chan error
+	reconnect      chan struct{}
+	reconnectTimer *time.Timer
+	offlineRetries int
 }
 
 func NewIMAPWorker(worker *types.Worker) (types.Backend, error) {
@@ -93,6 +124,8 @@ func NewIMAPWorker(worker *types.Worker) (types.Backend, error) {
 		observer:    nil, // will be set in configure()
 		caps:        &models.Capabilities{},
 		executeIdle: make(chan struct{}),
+		lost:        make(chan error, 1),
+		reconnect:   make(chan struct{}, 1),
 	}, nil
 }
 
@@ -103,7 +136,16 @@ func (w *IMAPWorker) newClient(c *client.Client) {
 		sortthread.NewThreadClient(c),
 		sortthread.NewSortClient(c),
 		extensions.NewListStatusClient(c),
+		extensions.NewCondstoreClient(c),
+		extensions.NewQresyncClient(c),
+		extensions.NewACLClient(c),
+		extensions.NewNamespaceClient(c),
+		extensions.NewMetadataClient(c),
+		extensions.NewSpecialUseClient(c),
 	}
+	// the updates of the folders selected to replay the offline changes
+	// must not reach the UI
+	w.replayJournal()
 	if w.idler != nil {
 		w.idler.SetClient(w.client)
 		c.Updates = w.updates
@@ -131,6 +173,39 @@ func (w *IMAPWorker) newClient(c *client.Client) {
 		w.caps.Extensions = append(w.caps.Extensions, "LIST-STATUS")
 		w.worker.Debugf("Server Capability found: LIST-STATUS")
 	}
+	condstore, err := w.client.condstore.SupportCondstore()
+	if err == nil && condstore {
+		w.condstore = true
+		w.caps.Extensions = append(w.caps.Extensions, "CONDSTORE")
+		w.worker.Debugf("Server Capability found: CONDSTORE")
+	}
+	if w.qresync {
+		w.caps.Extensions = append(w.caps.Extensions, "QRESYNC")
+		w.worker.Debugf("Server Capability found: QRESYNC")
+	}
+	acl, err := w.client.acl.SupportACL()
+	if err == nil && acl {
+		w.acl = true
+		w.caps.Extensions = append(w.caps.Extensions, "ACL")
+		w.worker.Debugf("Server Capability found: ACL")
+	}
+	namespace, err := w.client.namespace.SupportNamespace()
+	if err == nil && namespace {
+		w.worker.Debugf("Server Capability found: NAMESPACE")
+		w.listNamespaces()
+	}
+	metadata, err := w.client.metadata.SupportMetadata()
+	if err == nil && metadata {
+		w.metadata = true
+		w.caps.Extensions = append(w.caps.Extensions, "METADATA")
+		w.worker.Debugf("Server Capability found: METADATA")
+	}
+	specialuse, err := w.client.specialuse.SupportCreateSpecialUse()
+	if err == nil && specialuse {
+		w.specialuse = true
+		w.caps.Extensions = append(w.caps.Extensions, "CREATE-SPECIAL-USE")
+		w.worker.Debugf("Server Capability found: CREATE-SPECIAL-USE")
+	}
 	xgmext, err := w.client.Support("X-GM-EXT-1")
 	if err == nil && xgmext && w.config.useXGMEXT {
 		w.caps.Extensions = append(w.caps.Extensions, "X-GM-EXT-1")
@@ -140,6 +215,14 @@ func (w *IMAPWorker) newClient(c *client.Client) {
 	if err == nil && !xgmext && w.config.useXGMEXT {
 		w.worker.Infof("X-GM-EXT-1 requested, but it is not supported")
 	}
+	if w.watcher != nil {
+		notify, err := w.client.Support("NOTIFY")
+		if err == nil && notify {
+			w.caps.Extensions = append(w.caps.Extensions, "NOTIFY")
+			w.worker.Debugf("Server Capability found: NOTIFY")
+		}
+		w.watcher.Start(notify)
+	}
 }
 
 func (w *IMAPWorker) handleMessage(msg types.WorkerMessage) error {
@@ -150,10 +233,24 @@ func (w *IMAPWorker) handleMessage(msg types.WorkerMessage) error {
 		switch msg.(type) {
 		case *types.Connect, *types.Reconnect, *types.Disconnect, *types.Configure:
 		default:
+			if w.isOffline() {
+				if f := msg.Target(); f != "" && f != w.selected.Name {
+					return fmt.Errorf("%s: %w", f, errOffline)
+				}
+				return w.handleOfflineMessage(msg)
+			}
 			return errClientNotReady
 		}
 	}
 
+	if folder := msg.Target(); folder != "" && folder != w.selected.Name {
+		restore, err := w.enterFolder(folder)
+		if err != nil {
+			return err
+		}
+		defer restore()
+	}
+
 	// set connection timeout for calls to imap server
 	if w.client != nil {
 		w.client.Timeout = w.config.connection_timeout
@@ -176,6 +273,11 @@ func (w *IMAPWorker) handleMessage(msg types.WorkerMessage) error {
 
 		w.observer.SetAutoReconnect(true)
 		c, err := w.connect()
+		if err != nil && w.offlineEnabled() {
+			w.workOffline(err)
+			w.worker.PostMessage(&types.Done{Message: types.RespondTo(msg)}, nil)
+			break
+		}
 		if err != nil {
 			w.observer.EmitIfNotConnected()
 			reterr = err
@@ -191,6 +293,11 @@ func (w *IMAPWorker) handleMessage(msg types.WorkerMessage) error {
 			break
 		}
 		c, err := w.connect()
+		if err != nil && w.offlineEnabled() {
+			w.workOffline(err)
+			w.worker.PostMessage(&types.Done{Message: types.RespondTo(msg)}, nil)
+			break
+		}
 		if err != nil {
 			errReconnect := w.observer.DelayedReconnect()
 			reterr = errors.Wrap(errReconnect, err.Error())
@@ -203,7 +310,15 @@ func (w *IMAPWorker) handleMessage(msg types.WorkerMessage) error {
 	case *types.Disconnect:
 		w.observer.SetAutoReconnect(false)
 		w.observer.Stop()
+		w.cancelReconnect()
+		if w.watcher != nil {
+			w.watcher.Stop()
+		}
 
+		if w.isOffline() {
+			w.worker.PostMessage(&types.Done{Message: types.RespondTo(msg)}, nil)
+			break
+		}
 		if w.client == nil || (w.client != nil && w.client.State() != imap.SelectedState) {
 			reterr = errNotConnected
 			break
@@ -227,6 +342,16 @@ func (w *IMAPWorker) handleMessage(msg types.WorkerMessage) error {
 		w.handleCreateDirectory(msg)
 	case *types.RemoveDirectory:
 		w.handleRemoveDirectory(msg)
+	case *types.ListACL:
+		reterr = w.handleListACL(msg)
+	case *types.SetACL:
+		reterr = w.handleSetACL(msg)
+	case *types.DeleteACL:
+		reterr = w.handleDeleteACL(msg)
+	case *types.GetMetadata:
+		reterr = w.handleGetMetadata(msg)
+	case *types.SetMetadata:
+		reterr = w.handleSetMetadata(msg)
 	case *types.FetchMessageHeaders:
 		w.handleFetchMessageHeaders(msg)
 	case *types.FetchMessageBodyPart:
@@ -283,6 +408,7 @@ func (w *IMAPWorker) handleImapUpdate(update client.Update) {
 		if int(msg.SeqNum) > w.seqMap.Size() {
 			w.seqMap.Put(msg.Uid)
 		}
+		w.cacheFlags(msg)
 		w.worker.PostMessage(&types.MessageInfo{
 			Info: &models.MessageInfo{
 				BodyStructure: translateBodyStructure(msg.BodyStructure),
@@ -318,10 +444,15 @@ func (w *IMAPWorker) terminate() {
 
 	w.client = nil
 	w.selected = &imap.MailboxStatus{}
+	w.highestModSeq = 0
 
 	if w.idler != nil {
 		w.idler.SetClient(nil)
 	}
+
+	if w.watcher != nil {
+		w.watcher.Stop()
+	}
 }
 
 func (w *IMAPWorker) stopIdler() error {
@@ -381,6 +512,13 @@ func (w *IMAPWorker) Run() {
 
 		case <-w.executeIdle:
 			w.idler.Execute()
+
+		case err := <-w.lost:
+			w.workOffline(err)
+
+		case <-w.reconnect:
+			w.reconnectOffline()
+			w.startIdler()
 		}
 	}
 }
@@ -6,7 +6,9 @@ import (
 	"strings"
 	"time"
 
+	"git.sr.ht/~rjarry/aerc/lib/oauth"
 	"git.sr.ht/~rjarry/aerc/worker/jmap/cache"
+	"git.sr.ht/~rjarry/aerc/worker/middleware"
 	"git.sr.ht/~rjarry/aerc/worker/types"
 )
 
@@ -24,6 +26,7 @@ func (w *JMAPWorker) handleConfigure(msg *types.Configure) error {
 
 	if strings.HasSuffix(u.Scheme, "+oauthbearer") {
 		w.config.oauth = true
+		w.config.oauth2 = oauth.Config(u.Query())
 	} else {
 		if u.User == nil {
 			return fmt.Errorf("user:password not specified")
@@ -54,6 +57,15 @@ func (w *JMAPWorker) handleConfigure(msg *types.Configure) error {
 		w.config.serverPing = dur
 	}
 
+	if file := msg.Config.Searches; file != "" {
+		searches, err := middleware.NewSavedSearches(w.w, file,
+			msg.Config.Default)
+		if err != nil {
+			return err
+		}
+		w.w = searches
+	}
+
 	return nil
 }
 
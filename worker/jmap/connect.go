@@ -1,24 +1,36 @@
 package jmap
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"strings"
 	"sync/atomic"
 
+	"git.sr.ht/~rjarry/aerc/lib/oauth"
 	"git.sr.ht/~rjarry/aerc/worker/types"
 	"git.sr.ht/~rockorager/go-jmap"
 	"git.sr.ht/~rockorager/go-jmap/mail"
 	"git.sr.ht/~rockorager/go-jmap/mail/identity"
+	"golang.org/x/oauth2"
 )
 
 func (w *JMAPWorker) handleConnect(msg *types.Connect) error {
 	w.client = &jmap.Client{SessionEndpoint: w.config.endpoint}
 
-	if w.config.oauth {
+	switch {
+	case w.config.oauth && w.config.oauth2.Endpoint.TokenURL != "":
+		// the access token is refreshed when it expires
+		pass, _ := w.config.user.Password()
+		src := oauth.TokenSource(w.config.account.Name, w.config.oauth2, pass)
+		if _, err := src.Token(); err != nil {
+			return err
+		}
+		w.client.HttpClient = oauth2.NewClient(context.Background(), src)
+	case w.config.oauth:
 		pass, _ := w.config.user.Password()
 		w.client.WithAccessToken(pass)
-	} else {
+	default:
 		user := w.config.user.Username()
 		pass, _ := w.config.user.Password()
 		w.client.WithBasicAuth(user, pass)
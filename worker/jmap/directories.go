@@ -117,6 +117,22 @@ func (w *JMAPWorker) handleListDirectories(msg *types.ListDirectories) error {
 	return nil
 }
 
+// enterFolder selects another mailbox than the one opened by the user in
+// order to perform an action in it. The returned function restores the
+// previous selection.
+func (w *JMAPWorker) enterFolder(name string) (func(), error) {
+	if name == "" {
+		return func() {}, nil
+	}
+	id, ok := w.dir2mbox[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown directory: %s", name)
+	}
+	prev := w.selectedMbox
+	w.selectedMbox = id
+	return func() { w.selectedMbox = prev }, nil
+}
+
 func (w *JMAPWorker) handleOpenDirectory(msg *types.OpenDirectory) error {
 	id, ok := w.dir2mbox[msg.Directory]
 	if !ok {
@@ -137,9 +153,13 @@ func (w *JMAPWorker) handleFetchDirectoryContents(msg *types.FetchDirectoryConte
 	if contents.NeedsRefresh(msg.Filter, msg.SortCriteria) {
 		var req jmap.Request
 
+		filter, err := w.translateSearch(w.selectedMbox, msg.Filter)
+		if err != nil {
+			return err
+		}
 		req.Invoke(&email.Query{
 			Account: w.AccountId(),
-			Filter:  w.translateSearch(w.selectedMbox, msg.Filter),
+			Filter:  filter,
 			Sort:    translateSort(msg.SortCriteria),
 		})
 		resp, err := w.Do(&req)
@@ -189,9 +209,13 @@ func (w *JMAPWorker) handleFetchDirectoryContents(msg *types.FetchDirectoryConte
 func (w *JMAPWorker) handleSearchDirectory(msg *types.SearchDirectory) error {
 	var req jmap.Request
 
+	filter, err := w.translateSearch(w.selectedMbox, msg.Criteria)
+	if err != nil {
+		return err
+	}
 	req.Invoke(&email.Query{
 		Account: w.AccountId(),
-		Filter:  w.translateSearch(w.selectedMbox, msg.Criteria),
+		Filter:  filter,
 	})
 
 	resp, err := w.Do(&req)
@@ -1,8 +1,10 @@
 package jmap
 
 import (
+	"fmt"
 	"strings"
 
+	"git.sr.ht/~rjarry/aerc/models"
 	"git.sr.ht/~rjarry/aerc/worker/types"
 	"git.sr.ht/~rockorager/go-jmap"
 	"git.sr.ht/~rockorager/go-jmap/mail/email"
@@ -11,7 +13,7 @@ import (
 
 func (w *JMAPWorker) translateSearch(
 	mbox jmap.ID, criteria *types.SearchCriteria,
-) email.Filter {
+) (email.Filter, error) {
 	cond := new(email.FilterCondition)
 
 	if mbox == "" {
@@ -28,7 +30,7 @@ func (w *JMAPWorker) translateSearch(
 		cond.InMailbox = mbox
 	}
 	if criteria == nil {
-		return cond
+		return cond, nil
 	}
 
 	// dates
@@ -97,5 +99,106 @@ func (w *JMAPWorker) translateSearch(
 		filter.Conditions = append(filter.Conditions, headers)
 	}
 
-	return filter
+	// boolean query
+	if criteria.Query != nil {
+		query, err := w.translateQuery(criteria.Query, criteria)
+		if err != nil {
+			return nil, err
+		}
+		filter.Conditions = append(filter.Conditions, query)
+	}
+
+	return filter, nil
+}
+
+func (w *JMAPWorker) translateQuery(
+	q *types.Query, criteria *types.SearchCriteria,
+) (email.Filter, error) {
+	var op jmap.Operator
+	switch q.Op {
+	case types.QueryAnd:
+		op = jmap.OperatorAND
+	case types.QueryOr:
+		op = jmap.OperatorOR
+	case types.QueryNot:
+		op = jmap.OperatorNOT
+	default:
+		return w.translateTerm(q, criteria)
+	}
+	filter := &email.FilterOperator{Operator: op}
+	for _, o := range q.Operands {
+		cond, err := w.translateQuery(o, criteria)
+		if err != nil {
+			return nil, err
+		}
+		filter.Conditions = append(filter.Conditions, cond)
+	}
+	return filter, nil
+}
+
+func (w *JMAPWorker) translateTerm(
+	q *types.Query, criteria *types.SearchCriteria,
+) (email.Filter, error) {
+	cond := new(email.FilterCondition)
+	switch q.Key {
+	case types.QueryWord:
+		switch {
+		case criteria.SearchAll:
+			cond.Text = q.Value
+		case criteria.SearchBody:
+			cond.Body = q.Value
+		default:
+			cond.Subject = q.Value
+		}
+	case types.QueryFrom:
+		cond.From = q.Value
+	case types.QueryTo:
+		cond.To = q.Value
+	case types.QueryCc:
+		cond.Cc = q.Value
+	case types.QuerySubject:
+		cond.Subject = q.Value
+	case types.QueryBody:
+		cond.Body = q.Value
+	case types.QueryText:
+		cond.Text = q.Value
+	case types.QueryHeader:
+		cond.Header = []string{q.Header, q.Value}
+	case types.QueryFlag:
+		switch q.Flag {
+		case models.SeenFlag:
+			cond.HasKeyword = "$seen"
+		case models.AnsweredFlag:
+			cond.HasKeyword = "$answered"
+		case models.ForwardedFlag:
+			cond.HasKeyword = "$forwarded"
+		case models.FlaggedFlag:
+			cond.HasKeyword = "$flagged"
+		case models.DraftFlag:
+			cond.HasKeyword = "$draft"
+		default:
+			return nil, fmt.Errorf("unsupported flag in query: %d", q.Flag)
+		}
+	case types.QueryTag:
+		// labels are mailboxes
+		if id, ok := w.dir2mbox[q.Value]; ok {
+			cond.InMailbox = id
+		} else {
+			cond.HasKeyword = q.Value
+		}
+	case types.QueryAttachment:
+		cond.HasAttachment = true
+	case types.QueryDate:
+		if !q.Start.IsZero() {
+			cond.After = &q.Start
+		}
+		if !q.End.IsZero() {
+			cond.Before = &q.End
+		}
+	case types.QueryLarger:
+		cond.MinSize = q.Size + 1
+	case types.QuerySmaller:
+		cond.MaxSize = q.Size
+	}
+	return cond, nil
 }
@@ -13,6 +13,7 @@ import (
 	"git.sr.ht/~rockorager/go-jmap"
 	"git.sr.ht/~rockorager/go-jmap/mail/identity"
 	"git.sr.ht/~rockorager/go-jmap/mail/mailbox"
+	"golang.org/x/oauth2"
 )
 
 func init() {
@@ -29,6 +30,7 @@ type JMAPWorker struct {
 		account    *config.AccountConfig
 		endpoint   string
 		oauth      bool
+		oauth2     *oauth2.Config
 		user       *url.Userinfo
 		cacheState bool
 		cacheBlobs bool
@@ -37,7 +39,7 @@ type JMAPWorker struct {
 		allMail    string
 	}
 
-	w      *types.Worker
+	w      types.WorkerInteractor
 	client *jmap.Client
 	cache  *cache.JMAPCache
 
@@ -170,7 +172,11 @@ func (w *JMAPWorker) Run() {
 			}
 		case msg := <-w.w.Actions():
 			msg = w.w.ProcessAction(msg)
-			err := w.handleMessage(msg)
+			restore, err := w.enterFolder(msg.Target())
+			if err == nil {
+				err = w.handleMessage(msg)
+				restore()
+			}
 			switch {
 			case errors.Is(err, errNoop):
 				// Operation did not have any effect.
@@ -0,0 +1,350 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"git.sr.ht/~rjarry/aerc/lib/xdg"
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+	"github.com/emersion/go-message"
+	_ "github.com/emersion/go-message/charset"
+	"github.com/emersion/go-message/textproto"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	minTokenLen = 2
+	maxTokenLen = 40
+)
+
+// TextIndex is a local full text index of messages. Messages are identified
+// by their UID within a scope, usually a folder name.
+//
+// For each message, the index stores the list of its tokens and one posting
+// entry per token so that messages can be looked up by token prefix.
+type TextIndex struct {
+	db *leveldb.DB
+}
+
+// TextIndexPath returns the location of the full text index of an account.
+func TextIndexPath(account string) string {
+	return xdg.CachePath("aerc", account+"-index")
+}
+
+// OpenTextIndex opens (or creates) the full text index stored in path.
+func OpenTextIndex(path string) (*TextIndex, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &TextIndex{db: db}, nil
+}
+
+func (idx *TextIndex) Close() error {
+	return idx.db.Close()
+}
+
+func docKey(scope string, uid models.UID) []byte {
+	return []byte("d\x00" + scope + "\x00" + string(uid))
+}
+
+func completeKey(scope string, uid models.UID) []byte {
+	return []byte("c\x00" + scope + "\x00" + string(uid))
+}
+
+func postingKey(scope string, token string, uid models.UID) []byte {
+	return []byte("p\x00" + scope + "\x00" + token + "\x00" + string(uid))
+}
+
+// Indexed returns the UIDs of the messages indexed in scope.
+func (idx *TextIndex) Indexed(scope string) (map[models.UID]struct{}, error) {
+	return idx.uids([]byte("d\x00" + scope + "\x00"))
+}
+
+// Complete returns the UIDs of the messages of scope which were indexed with
+// AddMessage. Only the headers or some text parts of the other messages are
+// known.
+func (idx *TextIndex) Complete(scope string) (map[models.UID]struct{}, error) {
+	return idx.uids([]byte("c\x00" + scope + "\x00"))
+}
+
+func (idx *TextIndex) uids(prefix []byte) (map[models.UID]struct{}, error) {
+	uids := make(map[models.UID]struct{})
+	iter := idx.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		uids[models.UID(iter.Key()[len(prefix):])] = struct{}{}
+	}
+	return uids, iter.Error()
+}
+
+// AddMessage indexes the headers and text parts of a full RFC 822 message.
+func (idx *TextIndex) AddMessage(scope string, uid models.UID, r io.Reader) error {
+	entity, err := message.Read(r)
+	if err != nil && !message.IsUnknownCharset(err) &&
+		!message.IsUnknownEncoding(err) {
+		return err
+	}
+	tokens := make(map[string]struct{})
+	headerTokens(entity.Header.Header, tokens)
+	err = entity.Walk(func(_ []int, part *message.Entity, err error) error {
+		if err != nil {
+			// skip parts which cannot be decoded
+			return nil //nolint:nilerr // best effort
+		}
+		mime, _, _ := part.Header.ContentType()
+		if mime != "" && !strings.HasPrefix(mime, "text/") {
+			return nil
+		}
+		return readTokens(part.Body, tokens)
+	})
+	if err != nil {
+		return err
+	}
+	if err := idx.add(scope, uid, tokens); err != nil {
+		return err
+	}
+	return idx.db.Put(completeKey(scope, uid), nil, nil)
+}
+
+// AddHeader indexes the values of message headers.
+func (idx *TextIndex) AddHeader(scope string, uid models.UID, h textproto.Header) error {
+	tokens := make(map[string]struct{})
+	headerTokens(h, tokens)
+	return idx.add(scope, uid, tokens)
+}
+
+// AddText indexes decoded text, such as a message body part.
+func (idx *TextIndex) AddText(scope string, uid models.UID, r io.Reader) error {
+	tokens := make(map[string]struct{})
+	if err := readTokens(r, tokens); err != nil {
+		return err
+	}
+	return idx.add(scope, uid, tokens)
+}
+
+// add merges tokens into the indexed tokens of a message.
+func (idx *TextIndex) add(scope string, uid models.UID, tokens map[string]struct{}) error {
+	key := docKey(scope, uid)
+	doc, err := idx.db.Get(key, nil)
+	if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+		return err
+	}
+	for _, t := range splitDoc(doc) {
+		delete(tokens, t)
+	}
+	batch := new(leveldb.Batch)
+	var buf bytes.Buffer
+	buf.Write(doc)
+	for t := range tokens {
+		batch.Put(postingKey(scope, t, uid), nil)
+		if buf.Len() > 0 {
+			buf.WriteByte(0)
+		}
+		buf.WriteString(t)
+	}
+	// always store the document, even without tokens, so that the
+	// message is known as indexed
+	batch.Put(key, buf.Bytes())
+	return idx.db.Write(batch, nil)
+}
+
+func splitDoc(doc []byte) []string {
+	if len(doc) == 0 {
+		return nil
+	}
+	return strings.Split(string(doc), "\x00")
+}
+
+// Remove deletes messages from the index.
+func (idx *TextIndex) Remove(scope string, uids ...models.UID) error {
+	batch := new(leveldb.Batch)
+	for _, uid := range uids {
+		key := docKey(scope, uid)
+		batch.Delete(completeKey(scope, uid))
+		doc, err := idx.db.Get(key, nil)
+		if errors.Is(err, leveldb.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		for _, t := range splitDoc(doc) {
+			batch.Delete(postingKey(scope, t, uid))
+		}
+		batch.Delete(key)
+	}
+	return idx.db.Write(batch, nil)
+}
+
+// Search returns the UIDs of the messages in scope which contain all words.
+// A word matches all the tokens that start with it. If none of the words
+// are long enough to be looked up, ok is false.
+func (idx *TextIndex) Search(scope string, words []string) (map[models.UID]struct{}, bool, error) {
+	var result map[models.UID]struct{}
+	for _, word := range words {
+		for _, token := range Tokenize(word) {
+			prefix := []byte("p\x00" + scope + "\x00" + token)
+			uids := make(map[models.UID]struct{})
+			iter := idx.db.NewIterator(util.BytesPrefix(prefix), nil)
+			for iter.Next() {
+				k := iter.Key()
+				uid := k[bytes.LastIndexByte(k, 0)+1:]
+				if result == nil {
+					uids[models.UID(uid)] = struct{}{}
+				} else if _, ok := result[models.UID(uid)]; ok {
+					uids[models.UID(uid)] = struct{}{}
+				}
+			}
+			iter.Release()
+			if err := iter.Error(); err != nil {
+				return nil, false, err
+			}
+			result = uids
+		}
+	}
+	if result == nil {
+		return nil, false, nil
+	}
+	return result, true, nil
+}
+
+// Tokenize splits text into lower case words made of letters and digits.
+// Words that are too short are ignored and long words are truncated.
+func Tokenize(text string) []string {
+	var tokens []string
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if utf8.RuneCountInString(word) < minTokenLen {
+			continue
+		}
+		if utf8.RuneCountInString(word) > maxTokenLen {
+			word = string([]rune(word)[:maxTokenLen])
+		}
+		tokens = append(tokens, strings.ToLower(word))
+	}
+	return tokens
+}
+
+func headerTokens(h textproto.Header, tokens map[string]struct{}) {
+	mh := message.Header{Header: h}
+	fields := mh.Fields()
+	for fields.Next() {
+		value, err := fields.Text()
+		if err != nil {
+			value = fields.Value()
+		}
+		for _, t := range Tokenize(value) {
+			tokens[t] = struct{}{}
+		}
+	}
+}
+
+func readTokens(r io.Reader, tokens map[string]struct{}) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	for _, t := range Tokenize(string(b)) {
+		tokens[t] = struct{}{}
+	}
+	return nil
+}
+
+// ExtensionCriteria returns the criteria equivalent to the raw terms of
+// UseExtension criteria without an index: each term must be found in the text
+// of the messages. Other criteria are returned unchanged.
+func ExtensionCriteria(c *types.SearchCriteria) *types.SearchCriteria {
+	if c == nil || !c.UseExtension {
+		return c
+	}
+	r := *c
+	terms := []*types.Query{c.Query}
+	for _, term := range c.Terms {
+		for _, word := range strings.Fields(term) {
+			terms = append(terms, &types.Query{Key: types.QueryText, Value: word})
+		}
+	}
+	r.Terms = nil
+	r.UseExtension = false
+	r.SearchBody = false
+	r.SearchAll = false
+	r.Query = types.And(terms...)
+	return &r
+}
+
+// isToken returns true if word is looked up exactly in a TextIndex: it is
+// made of a single token which is not truncated.
+func isToken(word string) bool {
+	tokens := Tokenize(word)
+	return len(tokens) == 1 && tokens[0] == strings.ToLower(word)
+}
+
+// IndexCriteria splits search criteria between the words that can be looked
+// up in a TextIndex and the remaining criteria which must still be matched
+// against the messages found in the index.
+//
+// With UseExtension, the raw search terms match the words of the messages
+// which start with them. Terms which are made of a single token are only
+// looked up in the index. The other ones (e.g. "v2.1") narrow down the
+// messages found in the index and remain in the criteria.
+//
+// Otherwise, the text terms that must match (body:, text: and words when
+// searching the message body) only narrow down the messages to search. They
+// remain in the criteria since the index does not tell whether a term was
+// found in the body or in the headers.
+//
+// If there are no words to look up, ok is false.
+func IndexCriteria(c *types.SearchCriteria) (words []string, rest *types.SearchCriteria, ok bool) {
+	if c == nil {
+		return nil, nil, false
+	}
+	ext := c.UseExtension
+	c = ExtensionCriteria(c)
+	r := *c
+	text := c.SearchBody || c.SearchAll
+	if text {
+		words = append(words, c.Terms...)
+	}
+	indexable := func(q *types.Query) bool {
+		if q.Op != types.QueryTerm {
+			return false
+		}
+		switch q.Key {
+		case types.QueryWord:
+			return text
+		case types.QueryBody, types.QueryText:
+			return true
+		}
+		return false
+	}
+	if q := c.Query; q != nil {
+		operands := []*types.Query{q}
+		if q.Op == types.QueryAnd {
+			operands = q.Operands
+		}
+		var remaining []*types.Query
+		for _, o := range operands {
+			if indexable(o) {
+				words = append(words, o.Value)
+				if ext && o.Key == types.QueryText && isToken(o.Value) {
+					continue
+				}
+			}
+			remaining = append(remaining, o)
+		}
+		r.Query = types.And(remaining...)
+	}
+	// words which are too short to be indexed cannot narrow anything down
+	words = slices.DeleteFunc(words, func(w string) bool {
+		return len(Tokenize(w)) == 0
+	})
+	return words, &r, len(words) > 0
+}
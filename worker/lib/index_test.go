@@ -0,0 +1,170 @@
+package lib_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/lib"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+func sortedUids(uids map[models.UID]struct{}) string {
+	var list []string
+	for uid := range uids {
+		list = append(list, string(uid))
+	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
+
+func TestTextIndex(t *testing.T) {
+	idx, err := lib.OpenTextIndex(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+
+	if err := idx.AddMessage("INBOX", "1", strings.NewReader(searchMessage)); err != nil {
+		t.Fatal(err)
+	}
+	err = idx.AddText("INBOX", "2", strings.NewReader("Release candidate for the frobnicator"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.AddText("Archive", "3", strings.NewReader("Old release"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		words []string
+		uids  string
+	}{
+		{[]string{"release"}, "1,2"},
+		{[]string{"Release", "changelog"}, "1"},
+		{[]string{"frob"}, "2"},
+		{[]string{"alice@example.org"}, "1"},
+		{[]string{"fixed", "bugs"}, "1"},
+		{[]string{"nothing"}, ""},
+	}
+	for _, test := range tests {
+		uids, ok, err := idx.Search("INBOX", test.words)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Fatalf("%v: index not used", test.words)
+		}
+		if s := sortedUids(uids); s != test.uids {
+			t.Errorf("%v: expected %q, got %q", test.words, test.uids, s)
+		}
+	}
+
+	if _, ok, _ := idx.Search("INBOX", []string{"a"}); ok {
+		t.Error("expected short words to be ignored")
+	}
+
+	complete, err := idx.Complete("INBOX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := sortedUids(complete); s != "1" {
+		t.Errorf("unexpected complete messages %q", s)
+	}
+
+	if err := idx.Remove("INBOX", "1"); err != nil {
+		t.Fatal(err)
+	}
+	uids, _, _ := idx.Search("INBOX", []string{"release"})
+	if s := sortedUids(uids); s != "2" {
+		t.Errorf("expected removed message to be ignored, got %q", s)
+	}
+	indexed, err := idx.Indexed("INBOX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := sortedUids(indexed); s != "2" {
+		t.Errorf("unexpected indexed messages %q", s)
+	}
+	complete, _ = idx.Complete("INBOX")
+	if len(complete) != 0 {
+		t.Errorf("expected removed message to be incomplete, got %q",
+			sortedUids(complete))
+	}
+}
+
+func TestIndexCriteria(t *testing.T) {
+	parse := func(s string) *types.Query {
+		q, err := types.ParseQuery(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return q
+	}
+
+	words, rest, ok := lib.IndexCriteria(&types.SearchCriteria{
+		Query: parse("from:alice body:frob subject:release"),
+	})
+	if !ok || strings.Join(words, ",") != "frob" {
+		t.Errorf("unexpected words %v", words)
+	}
+	// the index matches word prefixes in the whole message, the text
+	// terms must still match as substrings of the body
+	if rest.Query.String() != `from:"alice" AND body:"frob" AND subject:"release"` {
+		t.Errorf("unexpected query %s", rest.Query)
+	}
+
+	words, rest, ok = lib.IndexCriteria(&types.SearchCriteria{
+		Query:      parse(`hello "C language" C`),
+		SearchBody: true,
+	})
+	if !ok || strings.Join(words, ",") != "hello,C language" {
+		t.Errorf("unexpected words %v", words)
+	}
+	if rest.Query.String() != `"hello" AND "C language" AND "C"` || !rest.SearchBody {
+		t.Errorf("unexpected remaining criteria %+v", rest)
+	}
+
+	_, _, ok = lib.IndexCriteria(&types.SearchCriteria{
+		Query: parse("hello OR body:world"),
+	})
+	if ok {
+		t.Error("expected OR query not to use the index")
+	}
+
+	words, rest, ok = lib.IndexCriteria(&types.SearchCriteria{
+		Terms:        []string{"foo bar"},
+		UseExtension: true,
+		WithFlags:    models.FlaggedFlag,
+	})
+	if !ok || strings.Join(words, ",") != "foo,bar" {
+		t.Errorf("unexpected words %v", words)
+	}
+	if rest.UseExtension || len(rest.Terms) > 0 || rest.Query != nil ||
+		rest.WithFlags != models.FlaggedFlag {
+		t.Errorf("unexpected remaining criteria %+v", rest)
+	}
+
+	// terms which are not a single token are still matched
+	words, rest, ok = lib.IndexCriteria(&types.SearchCriteria{
+		Terms:        []string{"v2.1 C"},
+		UseExtension: true,
+	})
+	if !ok || strings.Join(words, ",") != "v2.1" {
+		t.Errorf("unexpected words %v", words)
+	}
+	if rest.Query.String() != `text:"v2.1" AND text:"C"` {
+		t.Errorf("unexpected remaining criteria %+v", rest)
+	}
+
+	// nothing to look up in the index
+	_, rest, ok = lib.IndexCriteria(&types.SearchCriteria{
+		Terms:        []string{"C"},
+		UseExtension: true,
+	})
+	if ok || rest.Query.String() != `text:"C"` {
+		t.Errorf("expected C not to use the index, got %+v", rest)
+	}
+}
@@ -59,36 +59,15 @@ func SearchMessage(message rfc822.RawMessage, criteria *types.SearchCriteria,
 	}
 	switch {
 	case parts&BODY > 0:
-		path := lib.FindFirstNonMultipart(info.BodyStructure, nil)
-		reader, err := message.NewReader()
+		text, err = bodyText(message, info)
 		if err != nil {
 			return false, err
 		}
-		defer reader.Close()
-		msg, err := rfc822.ReadMessage(reader)
-		if err != nil {
-			return false, err
-		}
-		part, err := rfc822.FetchEntityPartReader(msg, path)
-		if err != nil {
-			return false, err
-		}
-		bytes, err := io.ReadAll(part)
-		if err != nil {
-			return false, err
-		}
-		text = string(bytes)
 	case parts&ALL > 0:
-		reader, err := message.NewReader()
+		text, err = allText(message)
 		if err != nil {
 			return false, err
 		}
-		defer reader.Close()
-		bytes, err := io.ReadAll(reader)
-		if err != nil {
-			return false, err
-		}
-		text = string(bytes)
 	default:
 		text = info.Envelope.Subject
 	}
@@ -138,9 +117,195 @@ func SearchMessage(message rfc822.RawMessage, criteria *types.SearchCriteria,
 			}
 		}
 	}
+	if criteria.Query != nil {
+		m := &queryMessage{raw: message, info: info, criteria: criteria}
+		return m.match(criteria.Query)
+	}
 	return true, nil
 }
 
+// bodyText returns the decoded contents of the first text part of a message.
+func bodyText(message rfc822.RawMessage, info *models.MessageInfo) (string, error) {
+	path := lib.FindFirstNonMultipart(info.BodyStructure, nil)
+	reader, err := message.NewReader()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	msg, err := rfc822.ReadMessage(reader)
+	if err != nil {
+		return "", err
+	}
+	part, err := rfc822.FetchEntityPartReader(msg, path)
+	if err != nil {
+		return "", err
+	}
+	bytes, err := io.ReadAll(part)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// allText returns the raw contents of a message, headers included.
+func allText(message rfc822.RawMessage) (string, error) {
+	reader, err := message.NewReader()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	bytes, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// queryMessage evaluates a query expression against a message. The parts
+// of the message are only read when a term requires them.
+type queryMessage struct {
+	raw      rfc822.RawMessage
+	info     *models.MessageInfo
+	criteria *types.SearchCriteria
+
+	body *string
+	all  *string
+}
+
+func (m *queryMessage) match(q *types.Query) (bool, error) {
+	switch q.Op {
+	case types.QueryAnd:
+		for _, o := range q.Operands {
+			if ok, err := m.match(o); err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case types.QueryOr:
+		for _, o := range q.Operands {
+			if ok, err := m.match(o); err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	case types.QueryNot:
+		for _, o := range q.Operands {
+			if ok, err := m.match(o); err != nil || ok {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	return m.matchTerm(q)
+}
+
+func (m *queryMessage) matchTerm(q *types.Query) (bool, error) {
+	header := m.info.RFC822Headers
+	switch q.Key {
+	case types.QueryWord:
+		var text string
+		var err error
+		switch {
+		case m.criteria.SearchAll:
+			text, err = m.allText()
+		case m.criteria.SearchBody:
+			text, err = m.bodyText()
+		default:
+			text = m.info.Envelope.Subject
+		}
+		return containsSmartCase(text, q.Value), err
+	case types.QueryFrom:
+		return containsSmartCase(header.Get("From"), q.Value), nil
+	case types.QueryTo:
+		return containsSmartCase(header.Get("To"), q.Value), nil
+	case types.QueryCc:
+		return containsSmartCase(header.Get("Cc"), q.Value), nil
+	case types.QuerySubject:
+		return containsSmartCase(header.Get("Subject"), q.Value), nil
+	case types.QueryHeader:
+		return containsSmartCase(header.Get(q.Header), q.Value), nil
+	case types.QueryBody:
+		text, err := m.bodyText()
+		return containsSmartCase(text, q.Value), err
+	case types.QueryText:
+		text, err := m.allText()
+		return containsSmartCase(text, q.Value), err
+	case types.QueryFlag:
+		flags, err := m.raw.ModelFlags()
+		return flags.Has(q.Flag), err
+	case types.QueryTag:
+		labels, err := m.raw.Labels()
+		for _, l := range labels {
+			if l == q.Value {
+				return true, err
+			}
+		}
+		return false, err
+	case types.QueryAttachment:
+		return hasAttachment(m.info.BodyStructure), nil
+	case types.QueryDate:
+		date, err := header.Date()
+		if err != nil {
+			log.Errorf("Failed to get date from header: %v", err)
+			return false, nil
+		}
+		if !q.Start.IsZero() && date.Before(q.Start) {
+			return false, nil
+		}
+		if !q.End.IsZero() && !date.Before(q.End) {
+			return false, nil
+		}
+		return true, nil
+	case types.QueryLarger, types.QuerySmaller:
+		text, err := m.allText()
+		if err != nil {
+			return false, err
+		}
+		if q.Key == types.QueryLarger {
+			return uint64(len(text)) > q.Size, nil
+		}
+		return uint64(len(text)) < q.Size, nil
+	}
+	return false, nil
+}
+
+func (m *queryMessage) bodyText() (string, error) {
+	if m.body == nil {
+		text, err := bodyText(m.raw, m.info)
+		if err != nil {
+			return "", err
+		}
+		m.body = &text
+	}
+	return *m.body, nil
+}
+
+func (m *queryMessage) allText() (string, error) {
+	if m.all == nil {
+		text, err := allText(m.raw)
+		if err != nil {
+			return "", err
+		}
+		m.all = &text
+	}
+	return *m.all, nil
+}
+
+func hasAttachment(bs *models.BodyStructure) bool {
+	if bs == nil {
+		return false
+	}
+	if strings.EqualFold(bs.Disposition, "attachment") {
+		return true
+	}
+	for _, part := range bs.Parts {
+		if hasAttachment(part) {
+			return true
+		}
+	}
+	return false
+}
+
 // containsSmartCase is a smarter version of strings.Contains for searching.
 // Is case-insensitive unless substr contains an upper case character
 func containsSmartCase(s string, substr string) bool {
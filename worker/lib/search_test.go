@@ -0,0 +1,88 @@
+package lib_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/lib"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+type rawMessage struct {
+	data   string
+	flags  models.Flags
+	labels []string
+}
+
+func (m *rawMessage) NewReader() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader([]byte(m.data))), nil
+}
+
+func (m *rawMessage) ModelFlags() (models.Flags, error) { return m.flags, nil }
+func (m *rawMessage) Labels() ([]string, error)         { return m.labels, nil }
+func (m *rawMessage) UID() models.UID                   { return "1" }
+
+const searchMessage = "From: Alice <alice@example.org>\r\n" +
+	"To: bob@example.org\r\n" +
+	"Subject: Release v1.0\r\n" +
+	"Date: Mon, 15 Jan 2024 10:00:00 +0000\r\n" +
+	"Content-Type: multipart/mixed; boundary=b\r\n" +
+	"\r\n" +
+	"--b\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"Changelog attached.\r\n" +
+	"--b\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"Content-Disposition: attachment; filename=changelog.txt\r\n" +
+	"\r\n" +
+	"- fixed bugs\r\n" +
+	"--b--\r\n"
+
+func TestSearchQuery(t *testing.T) {
+	msg := &rawMessage{
+		data:   searchMessage,
+		flags:  models.FlaggedFlag,
+		labels: []string{"releases"},
+	}
+	tests := []struct {
+		query string
+		body  bool
+		match bool
+	}{
+		{"release", false, true},
+		{"changelog", false, false},
+		{"changelog", true, true},
+		{"from:alice AND (subject:release OR has:attachment)", false, true},
+		{"from:alice NOT has:attachment", false, false},
+		{"from:carol OR to:bob", false, true},
+		{"is:flagged is:unread", false, true},
+		{"is:read", false, false},
+		{"tag:releases", false, true},
+		{"date:2024-01..2024-03", false, true},
+		{"date:2024-02..", false, false},
+		{"larger:100 smaller:1K", false, true},
+		{"larger:1M", false, false},
+		{`body:"Changelog attached"`, false, true},
+		{`text:"fixed bugs" header:to:example.org`, false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			q, err := types.ParseQuery(test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			criteria := &types.SearchCriteria{SearchBody: test.body, Query: q}
+			match, err := lib.SearchMessage(msg, criteria,
+				lib.GetRequiredParts(criteria))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match != test.match {
+				t.Errorf("expected match=%v, got %v", test.match, match)
+			}
+		})
+	}
+}
@@ -12,15 +12,22 @@ import (
 )
 
 func (w *Worker) search(ctx context.Context, criteria *types.SearchCriteria) ([]models.UID, error) {
-	criteria.PrepareHeader()
-	requiredParts := lib.GetRequiredParts(criteria)
-	w.worker.Debugf("Required parts bitmask for search: %b", requiredParts)
-
 	keys, err := w.c.UIDs(*w.selected)
 	if err != nil {
 		return nil, err
 	}
 
+	if w.index != nil && (criteria.UseExtension || w.useIndex) {
+		keys, criteria, err = w.indexSearch(ctx, keys, criteria)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	criteria.PrepareHeader()
+	requiredParts := lib.GetRequiredParts(criteria)
+	w.worker.Debugf("Required parts bitmask for search: %b", requiredParts)
+
 	var matchedUids []models.UID
 	mu := sync.Mutex{}
 	wg := sync.WaitGroup{}
@@ -65,3 +72,98 @@ func (w *Worker) searchKey(key models.UID, criteria *types.SearchCriteria,
 	}
 	return lib.SearchMessage(message, criteria, parts)
 }
+
+// indexSearch updates the full text index of the selected folder and narrows
+// the keys down to the messages which contain the indexed words. The returned
+// criteria must still be matched against these messages.
+func (w *Worker) indexSearch(ctx context.Context, keys []models.UID,
+	criteria *types.SearchCriteria,
+) ([]models.UID, *types.SearchCriteria, error) {
+	words, rest, ok := lib.IndexCriteria(criteria)
+	if !ok {
+		return keys, rest, nil
+	}
+	if err := w.updateIndex(ctx, keys); err != nil {
+		return nil, nil, err
+	}
+	found, ok, err := w.index.Search(w.selectedName, words)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return keys, rest, nil
+	}
+	var matched []models.UID
+	for _, key := range keys {
+		if _, ok := found[key]; ok {
+			matched = append(matched, key)
+		}
+	}
+	w.worker.Debugf("full text index matched %d of %d messages",
+		len(matched), len(keys))
+	return matched, rest, nil
+}
+
+// updateIndex adds the messages of the selected folder which are not indexed
+// yet and removes the ones which do not exist anymore.
+func (w *Worker) updateIndex(ctx context.Context, keys []models.UID) error {
+	indexed, err := w.index.Indexed(w.selectedName)
+	if err != nil {
+		return err
+	}
+	var missing []models.UID
+	for _, key := range keys {
+		if _, ok := indexed[key]; ok {
+			delete(indexed, key)
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	removed := make([]models.UID, 0, len(indexed))
+	for uid := range indexed {
+		removed = append(removed, uid)
+	}
+	if err := w.index.Remove(w.selectedName, removed...); err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	w.worker.Debugf("indexing %d messages in %s", len(missing), w.selectedName)
+
+	wg := sync.WaitGroup{}
+	limit := make(chan struct{}, runtime.NumCPU()*2)
+	for _, key := range missing {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return context.Canceled
+		default:
+			limit <- struct{}{}
+			wg.Add(1)
+			go func(key models.UID) {
+				defer log.PanicHandler()
+				defer wg.Done()
+				if err := w.indexKey(key); err != nil {
+					w.worker.Errorf("Failed to index key %s: %v", key, err)
+				}
+				<-limit
+			}(key)
+		}
+	}
+	wg.Wait()
+	return nil
+}
+
+func (w *Worker) indexKey(key models.UID) error {
+	message, err := w.c.Message(*w.selected, key)
+	if err != nil {
+		return err
+	}
+	r, err := message.NewReader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return w.index.AddMessage(w.selectedName, key, r)
+}
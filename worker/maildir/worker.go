@@ -13,6 +13,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -53,6 +54,8 @@ type Worker struct {
 	capabilities        *models.Capabilities
 	headers             []string
 	headersExclude      []string
+	index               *lib.TextIndex
+	useIndex            bool
 }
 
 // NewWorker creates a new maildir worker with the provided worker.
@@ -127,7 +130,11 @@ func (w *Worker) handleAction(action types.WorkerMessage) {
 		go w.handleCheckMail(msg)
 	default:
 		// Default handling, will be performed synchronously
-		err := w.handleMessage(msg)
+		restore, err := w.enterFolder(msg.Target())
+		if err == nil {
+			err = w.handleMessage(msg)
+			restore()
+		}
 		switch {
 		case errors.Is(err, errUnsupported):
 			w.worker.PostMessage(&types.Unsupported{
@@ -362,6 +369,30 @@ func (w *Worker) handleConfigure(msg *types.Configure) error {
 	w.headersExclude = msg.Config.HeadersExclude
 	w.worker.Debugf("configured base maildir: %s", dir)
 
+	var index bool
+	for key, value := range msg.Config.Params {
+		switch key {
+		case "full-text-index":
+			index, err = strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid full-text-index value %v: %w", value, err)
+			}
+		case "use-full-text-index":
+			w.useIndex, err = strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid use-full-text-index value %v: %w", value, err)
+			}
+		}
+	}
+	if index || w.useIndex {
+		path := lib.TextIndexPath(msg.Config.Name)
+		w.index, err = lib.OpenTextIndex(path)
+		if err != nil {
+			return fmt.Errorf("could not open full text index: %w", err)
+		}
+		w.worker.Debugf("full text index opened: %s", path)
+	}
+
 	if name, ok := msg.Config.Params["folder-map"]; ok {
 		file := xdg.ExpandHome(name)
 		f, err := os.Open(file)
@@ -376,6 +407,15 @@ func (w *Worker) handleConfigure(msg *types.Configure) error {
 		w.worker = middleware.NewFolderMapper(w.worker, fmap, order)
 	}
 
+	if file := msg.Config.Searches; file != "" {
+		searches, err := middleware.NewSavedSearches(w.worker, file,
+			msg.Config.Default)
+		if err != nil {
+			return err
+		}
+		w.worker = searches
+	}
+
 	return nil
 }
 
@@ -410,6 +450,24 @@ func (w *Worker) handleListDirectories(msg *types.ListDirectories) error {
 	return nil
 }
 
+// enterFolder selects another directory than the one opened by the user in
+// order to perform an action in it. The returned function restores the
+// previous selection.
+func (w *Worker) enterFolder(name string) (func(), error) {
+	if name == "" || name == w.selectedName {
+		return func() {}, nil
+	}
+	dir, err := w.c.OpenDirectory(name)
+	if err != nil {
+		return nil, err
+	}
+	selected, selectedName := w.selected, w.selectedName
+	w.selected, w.selectedName = &dir, name
+	return func() {
+		w.selected, w.selectedName = selected, selectedName
+	}, nil
+}
+
 func (w *Worker) handleOpenDirectory(msg *types.OpenDirectory) error {
 	w.worker.Debugf("opening %s", msg.Directory)
 
@@ -15,6 +15,7 @@ import (
 	"git.sr.ht/~rjarry/aerc/models"
 	"git.sr.ht/~rjarry/aerc/worker/handlers"
 	"git.sr.ht/~rjarry/aerc/worker/lib"
+	"git.sr.ht/~rjarry/aerc/worker/middleware"
 	"git.sr.ht/~rjarry/aerc/worker/types"
 )
 
@@ -28,7 +29,7 @@ type mboxWorker struct {
 	data   *mailboxContainer
 	name   string
 	folder *container
-	worker *types.Worker
+	worker types.WorkerInteractor
 
 	capabilities   *models.Capabilities
 	headers        []string
@@ -90,6 +91,15 @@ func (w *mboxWorker) handleMessage(msg types.WorkerMessage) error {
 		} else {
 			w.worker.Debugf("configured with mbox file %s", dir)
 		}
+		if file := msg.Config.Searches; file != "" {
+			searches, err := middleware.NewSavedSearches(w.worker, file,
+				msg.Config.Default)
+			if err != nil {
+				reterr = err
+				break
+			}
+			w.worker = searches
+		}
 
 	case *types.Connect, *types.Reconnect, *types.Disconnect:
 		w.worker.PostMessage(&types.Done{Message: types.RespondTo(msg)}, nil)
@@ -389,10 +399,33 @@ func (w *mboxWorker) handleMessage(msg types.WorkerMessage) error {
 	return reterr
 }
 
+// enterFolder selects another mailbox than the one opened by the user in
+// order to perform an action in it. The returned function restores the
+// previous selection.
+func (w *mboxWorker) enterFolder(name string) (func(), error) {
+	if name == "" || name == w.name {
+		return func() {}, nil
+	}
+	folder, ok := w.data.Mailbox(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown mailbox: %s", name)
+	}
+	prevName, prevFolder := w.name, w.folder
+	w.name, w.folder = name, folder
+	return func() {
+		w.name, w.folder = prevName, prevFolder
+	}, nil
+}
+
 func (w *mboxWorker) Run() {
 	for msg := range w.worker.Actions() {
 		msg = w.worker.ProcessAction(msg)
-		if err := w.handleMessage(msg); errors.Is(err, errUnsupported) {
+		restore, err := w.enterFolder(msg.Target())
+		if err == nil {
+			err = w.handleMessage(msg)
+			restore()
+		}
+		if errors.Is(err, errUnsupported) {
 			w.worker.PostMessage(&types.Unsupported{
 				Message: types.RespondTo(msg),
 			}, nil)
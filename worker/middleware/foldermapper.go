@@ -73,6 +73,9 @@ func (f *folderMapper) create(s string) (string, error) {
 }
 
 func (f *folderMapper) ProcessAction(msg types.WorkerMessage) types.WorkerMessage {
+	if target := msg.Target(); target != "" {
+		types.Retarget(msg, f.incoming(msg, target))
+	}
 	switch msg := msg.(type) {
 	case *types.CheckMail:
 		for i := range msg.Directories {
@@ -14,6 +14,8 @@ type gmailWorker struct {
 	types.WorkerInteractor
 	mu     sync.Mutex
 	client *client.Client
+	// folder opened in the backend, the extension commands run in it
+	selected string
 }
 
 // NewGmailWorker returns an IMAP middleware for the X-GM-EXT-1 extension
@@ -47,7 +49,12 @@ func (g *gmailWorker) reset(c *client.Client) error {
 }
 
 func (g *gmailWorker) ProcessAction(msg types.WorkerMessage) types.WorkerMessage {
+	if target := msg.Target(); target != "" && target != g.selected {
+		return g.WorkerInteractor.ProcessAction(msg)
+	}
 	switch msg := msg.(type) {
+	case *types.OpenDirectory:
+		g.selected = msg.Directory
 	case *types.FetchMessageHeaders:
 		handler := xgmext.NewHandler(g.client)
 
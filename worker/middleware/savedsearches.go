@@ -0,0 +1,353 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"git.sr.ht/~rjarry/aerc/lib/xdg"
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/lib"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+// savedSearch is a virtual folder which contains the messages of a backend
+// folder matching a query.
+type savedSearch struct {
+	name   string
+	folder string
+	query  *types.Query
+}
+
+func (s *savedSearch) criteria() *types.SearchCriteria {
+	return &types.SearchCriteria{Query: s.query}
+}
+
+// searchCount accumulates the results of the searches needed to compute the
+// counts of a saved search.
+type searchCount struct {
+	search  *savedSearch
+	exists  int
+	unseen  int
+	pending int
+	stale   bool
+}
+
+type countRequest struct {
+	count  *searchCount
+	unseen bool
+}
+
+type savedSearches struct {
+	sync.Mutex
+	types.WorkerInteractor
+	searches []*savedSearch
+	// backend folder currently opened
+	selected string
+	// saved search currently opened, if any
+	current *savedSearch
+	// saved searches being opened
+	opening map[types.WorkerMessage]*savedSearch
+	// searches posted to count the messages of saved searches
+	counting map[types.WorkerMessage]countRequest
+}
+
+// NewSavedSearches loads the saved searches defined in file and exposes them
+// as virtual folders. Saved searches without an explicit folder are based on
+// defaultFolder.
+func NewSavedSearches(base types.WorkerInteractor, file string,
+	defaultFolder string,
+) (types.WorkerInteractor, error) {
+	f, err := os.Open(xdg.ExpandHome(file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	searches, err := parseSavedSearches(bufio.NewReader(f), defaultFolder)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	base.Infof("loading worker middleware: savedsearches")
+	return newSavedSearches(base, searches), nil
+}
+
+func newSavedSearches(base types.WorkerInteractor, searches []*savedSearch) *savedSearches {
+	return &savedSearches{
+		WorkerInteractor: base,
+		searches:         searches,
+		opening:          make(map[types.WorkerMessage]*savedSearch),
+		counting:         make(map[types.WorkerMessage]countRequest),
+	}
+}
+
+// parseSavedSearches reads name = [folder:<folder>] <query> lines.
+func parseSavedSearches(r io.Reader, defaultFolder string) ([]*savedSearch, error) {
+	searches, order, err := lib.ParseFolderMap(r)
+	if err != nil {
+		return nil, err
+	}
+	var result []*savedSearch
+	for _, name := range order {
+		folder, expr, err := cutFolder(searches[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if folder == "" {
+			folder = defaultFolder
+		}
+		query, err := types.ParseQuery(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if query == nil {
+			return nil, fmt.Errorf("%s: empty query", name)
+		}
+		result = append(result, &savedSearch{
+			name:   name,
+			folder: folder,
+			query:  query,
+		})
+	}
+	return result, nil
+}
+
+// cutFolder extracts an optional leading folder:<name> term from a saved
+// search expression. The folder name may be double quoted.
+func cutFolder(expr string) (string, string, error) {
+	expr = strings.TrimSpace(expr)
+	rest, found := strings.CutPrefix(expr, "folder:")
+	if !found {
+		return "", expr, nil
+	}
+	var folder string
+	if strings.HasPrefix(rest, `"`) {
+		end := strings.Index(rest[1:], `"`)
+		if end < 0 {
+			return "", "", errors.New("unterminated quoted folder name")
+		}
+		folder = rest[1 : end+1]
+		rest = rest[end+2:]
+	} else {
+		folder, rest, _ = strings.Cut(rest, " ")
+	}
+	if folder == "" {
+		return "", "", errors.New("empty folder name")
+	}
+	return folder, strings.TrimSpace(rest), nil
+}
+
+func (s *savedSearches) Unwrap() types.WorkerInteractor {
+	return s.WorkerInteractor
+}
+
+func (s *savedSearches) search(name string) *savedSearch {
+	for _, search := range s.searches {
+		if search.name == name {
+			return search
+		}
+	}
+	return nil
+}
+
+// filter restricts the given criteria to a saved search, if any.
+func (s *savedSearch) filter(criteria *types.SearchCriteria) *types.SearchCriteria {
+	if s == nil {
+		return criteria
+	}
+	if criteria == nil {
+		return s.criteria()
+	}
+	return s.criteria().Combine(criteria)
+}
+
+// target returns the saved search an action applies to. Actions posted for a
+// saved search are performed in its folder.
+func (s *savedSearches) target(msg types.WorkerMessage) *savedSearch {
+	target := msg.Target()
+	if target == "" {
+		return s.current
+	}
+	search := s.search(target)
+	if search != nil {
+		types.Retarget(msg, search.folder)
+	}
+	return search
+}
+
+// folder returns the folder an action was performed in.
+func (s *savedSearches) folder(msg types.WorkerMessage) string {
+	folder := msg.Folder()
+	if folder == "" {
+		return s.selected
+	}
+	if search := s.search(folder); search != nil {
+		return search.folder
+	}
+	return folder
+}
+
+func (s *savedSearches) ProcessAction(msg types.WorkerMessage) types.WorkerMessage {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.counting[msg]; ok {
+		return s.WorkerInteractor.ProcessAction(msg)
+	}
+
+	search := s.target(msg)
+
+	switch msg := msg.(type) {
+	case *types.OpenDirectory:
+		s.current = s.search(msg.Directory)
+		if s.current != nil {
+			s.opening[msg] = s.current
+			msg.Directory = s.current.folder
+		}
+		s.selected = msg.Directory
+	case *types.FetchDirectoryContents:
+		msg.Filter = search.filter(msg.Filter)
+	case *types.FetchDirectoryThreaded:
+		msg.Filter = search.filter(msg.Filter)
+	case *types.SearchDirectory:
+		msg.Criteria = search.filter(msg.Criteria)
+	case *types.CheckMail:
+		var dirs []string
+		for _, dir := range msg.Directories {
+			if s.search(dir) != nil {
+				continue
+			}
+			if s.current != nil && dir == s.selected {
+				// the folder is open in the backend
+				continue
+			}
+			dirs = append(dirs, dir)
+		}
+		msg.Directories = dirs
+	}
+
+	return s.WorkerInteractor.ProcessAction(msg)
+}
+
+func (s *savedSearches) PostMessage(msg types.WorkerMessage, cb func(m types.WorkerMessage)) {
+	s.Lock()
+	defer s.Unlock()
+
+	if req, ok := s.counting[msg.InResponseTo()]; ok {
+		s.counted(req, msg)
+		return
+	}
+
+	var refresh []string
+
+	switch msg := msg.(type) {
+	case *types.Done:
+		switch resp := msg.InResponseTo().(type) {
+		case *types.ListDirectories:
+			for _, search := range s.searches {
+				s.WorkerInteractor.PostMessage(&types.Directory{
+					Message: types.RespondTo(resp),
+					Dir: &models.Directory{
+						Name: search.name,
+						Role: models.QueryRole,
+					},
+				}, nil)
+				refresh = append(refresh, search.folder)
+			}
+		case *types.OpenDirectory:
+			if search, ok := s.opening[resp]; ok {
+				delete(s.opening, resp)
+				resp.Directory = search.name
+			}
+			refresh = append(refresh, s.selected)
+		case *types.MoveMessages:
+			refresh = append(refresh, s.folder(resp), resp.Destination)
+		case *types.FlagMessages, *types.AnsweredMessages,
+			*types.ForwardedMessages, *types.DeleteMessages,
+			*types.ModifyLabels:
+			refresh = append(refresh, s.folder(resp))
+		}
+	case *types.Error, *types.Cancelled:
+		if resp, ok := msg.InResponseTo().(*types.OpenDirectory); ok {
+			delete(s.opening, resp)
+		}
+	case *types.DirectoryInfo:
+		refresh = append(refresh, msg.Info.Name)
+	}
+
+	s.WorkerInteractor.PostMessage(msg, cb)
+
+	s.refresh(refresh)
+}
+
+// refresh posts the searches needed to count the messages of the saved
+// searches based on the given folders. The searches are performed in the
+// folder of each saved search, whether it is opened or not.
+func (s *savedSearches) refresh(folders []string) {
+	for _, search := range s.searches {
+		if !slices.Contains(folders, search.folder) {
+			continue
+		}
+		count := &searchCount{search: search, pending: 2}
+		all := types.InFolder(&types.SearchDirectory{
+			Context:  context.Background(),
+			Criteria: search.criteria(),
+		}, search.folder)
+		unseen := types.InFolder(&types.SearchDirectory{
+			Context: context.Background(),
+			Criteria: &types.SearchCriteria{
+				Query: types.And(search.query, &types.Query{
+					Op: types.QueryNot,
+					Operands: []*types.Query{{
+						Key:  types.QueryFlag,
+						Flag: models.SeenFlag,
+					}},
+				}),
+			},
+		}, search.folder)
+		s.counting[all] = countRequest{count: count}
+		s.counting[unseen] = countRequest{count: count, unseen: true}
+		s.WorkerInteractor.PostAction(all, nil)
+		s.WorkerInteractor.PostAction(unseen, nil)
+	}
+}
+
+// counted handles the responses to the searches posted by refresh and
+// reports the saved search counts once both searches are complete. Some
+// backends do not send Done after the search results, any other response is
+// final.
+func (s *savedSearches) counted(req countRequest, msg types.WorkerMessage) {
+	count := req.count
+	switch msg := msg.(type) {
+	case *types.SearchResults:
+		if req.unseen {
+			count.unseen = len(msg.Uids)
+		} else {
+			count.exists = len(msg.Uids)
+		}
+	case *types.Error:
+		s.Warnf("%s: count failed: %v", count.search.name, msg.Error)
+		count.stale = true
+	case *types.Cancelled, *types.Unsupported:
+		count.stale = true
+	default:
+		return
+	}
+	delete(s.counting, msg.InResponseTo())
+	count.pending--
+	if count.pending > 0 || count.stale {
+		return
+	}
+	s.WorkerInteractor.PostMessage(&types.DirectoryInfo{
+		Info: &models.DirectoryInfo{
+			Name:   count.search.name,
+			Exists: count.exists,
+			Unseen: count.unseen,
+		},
+	}, nil)
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+const testSearches = `
+Unread = is:unread
+Lists = folder:"Mailing Lists" from:lists.sr.ht OR to:lists.sr.ht
+Patches = folder:Archive subject:PATCH
+`
+
+func TestParseSavedSearches(t *testing.T) {
+	searches, err := parseSavedSearches(strings.NewReader(testSearches), "INBOX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name   string
+		folder string
+		query  string
+	}{
+		{"Unread", "INBOX", "NOT is:seen"},
+		{"Lists", "Mailing Lists", `from:"lists.sr.ht" OR to:"lists.sr.ht"`},
+		{"Patches", "Archive", `subject:"PATCH"`},
+	}
+	if len(searches) != len(want) {
+		t.Fatalf("expected %d searches, got %d", len(want), len(searches))
+	}
+	for i, w := range want {
+		s := searches[i]
+		if s.name != w.name || s.folder != w.folder || s.query.String() != w.query {
+			t.Errorf("expected %v, got {%s %s %s}", w, s.name, s.folder, s.query)
+		}
+	}
+
+	for _, bad := range []string{
+		"Empty = folder:INBOX",
+		`Quote = folder:"INBOX is:unread`,
+		"Syntax = (is:unread",
+	} {
+		if _, err := parseSavedSearches(strings.NewReader(bad), "INBOX"); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestSavedSearches(t *testing.T) {
+	searches, err := parseSavedSearches(strings.NewReader(testSearches), "INBOX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := types.NewWorker("test")
+	w := newSavedSearches(base, searches)
+
+	open := &types.OpenDirectory{Directory: "Unread"}
+	w.ProcessAction(open)
+	if open.Directory != "INBOX" {
+		t.Errorf("expected INBOX to be opened, got %s", open.Directory)
+	}
+	fetch := &types.FetchDirectoryContents{}
+	w.ProcessAction(fetch)
+	if fetch.Filter == nil || fetch.Filter.Query.String() != "NOT is:seen" {
+		t.Errorf("unexpected filter %v", fetch.Filter)
+	}
+
+	w.PostMessage(&types.Done{Message: types.RespondTo(open)}, nil)
+	if open.Directory != "Unread" {
+		t.Errorf("expected Unread to be reported, got %s", open.Directory)
+	}
+	if msg := <-types.WorkerMessages; msg.InResponseTo() != open {
+		t.Errorf("unexpected message %T", msg)
+	}
+
+	// count searches posted after opening INBOX
+	for i := 0; i < 2; i++ {
+		search, ok := (<-base.Actions()).(*types.SearchDirectory)
+		if !ok {
+			t.Fatal("expected SearchDirectory")
+		}
+		w.ProcessAction(search)
+		w.PostMessage(&types.SearchResults{
+			Message: types.RespondTo(search),
+			Uids:    []models.UID{"1", "2"},
+		}, nil)
+	}
+	msg := <-types.WorkerMessages
+	info, ok := msg.(*types.DirectoryInfo)
+	if !ok {
+		t.Fatalf("expected DirectoryInfo, got %T", msg)
+	}
+	if info.Info.Name != "Unread" || info.Info.Exists != 2 || info.Info.Unseen != 2 {
+		t.Errorf("unexpected info %+v", info.Info)
+	}
+
+	// saved searches of folders which are not opened are counted too
+	w.PostMessage(&types.DirectoryInfo{
+		Info: &models.DirectoryInfo{Name: "Archive"},
+	}, nil)
+	<-types.WorkerMessages
+	for i := 0; i < 2; i++ {
+		search, ok := (<-base.Actions()).(*types.SearchDirectory)
+		if !ok {
+			t.Fatal("expected SearchDirectory")
+		}
+		if search.Target() != "Archive" {
+			t.Errorf("expected search in Archive, got %q", search.Target())
+		}
+		w.ProcessAction(search)
+		w.PostMessage(&types.SearchResults{
+			Message: types.RespondTo(search),
+			Uids:    []models.UID{"3"},
+		}, nil)
+	}
+	msg = <-types.WorkerMessages
+	info, ok = msg.(*types.DirectoryInfo)
+	if !ok {
+		t.Fatalf("expected DirectoryInfo, got %T", msg)
+	}
+	if info.Info.Name != "Patches" || info.Info.Exists != 1 {
+		t.Errorf("unexpected info %+v", info.Info)
+	}
+
+	// actions posted for a saved search run in its folder
+	fetch = types.InFolder(&types.FetchDirectoryContents{}, "Patches")
+	w.ProcessAction(fetch)
+	if fetch.Target() != "Archive" || fetch.Folder() != "Patches" {
+		t.Errorf("unexpected target %q", fetch.Target())
+	}
+	if fetch.Filter == nil || fetch.Filter.Query.String() != `subject:"PATCH"` {
+		t.Errorf("unexpected filter %v", fetch.Filter)
+	}
+
+	check := &types.CheckMail{Directories: []string{"INBOX", "Unread", "Archive"}}
+	w.ProcessAction(check)
+	if strings.Join(check.Directories, ",") != "Archive" {
+		t.Errorf("unexpected directories %v", check.Directories)
+	}
+}
@@ -36,9 +36,9 @@ func (q *queryBuilder) or(s string) {
 	q.s += "(" + s + ")"
 }
 
-func translate(crit *types.SearchCriteria) string {
+func translate(crit *types.SearchCriteria) (string, error) {
 	if crit == nil {
-		return ""
+		return "", nil
 	}
 	var base queryBuilder
 
@@ -67,6 +67,15 @@ func translate(crit *types.SearchCriteria) string {
 		base.and(cc.s)
 	}
 
+	// message ids
+	var ids queryBuilder
+	for _, id := range crit.Headers.Values("Message-Id") {
+		ids.or("id:" + opt.QuoteArg(strings.Trim(id, "<>")))
+	}
+	if ids.s != "" {
+		base.and(ids.s)
+	}
+
 	// flags
 	for f := range flagToTag {
 		if crit.WithFlags.Has(f) {
@@ -99,7 +108,112 @@ func translate(crit *types.SearchCriteria) string {
 		}
 	}
 
-	return base.s
+	// boolean query
+	if crit.Query != nil {
+		q, err := translateQuery(crit.Query, crit)
+		if err != nil {
+			return "", err
+		}
+		base.and(q)
+	}
+
+	return base.s, nil
+}
+
+// notmuchKeys are the query keys which have the same meaning in the notmuch
+// query language.
+var notmuchKeys = map[types.QueryKey]bool{
+	types.QueryWord:    true,
+	types.QueryFrom:    true,
+	types.QueryTo:      true,
+	types.QuerySubject: true,
+	types.QueryBody:    true,
+	types.QueryTag:     true,
+	types.QueryDate:    true,
+}
+
+// isNative returns true if the query only uses keys which notmuch understands.
+// It can then be passed verbatim with its own syntax, e.g. prefix matching
+// with "foo*", or date ranges parsed by notmuch.
+func isNative(q *types.Query) bool {
+	native := true
+	q.Walk(func(term *types.Query) {
+		if !notmuchKeys[term.Key] {
+			native = false
+		}
+	})
+	return native
+}
+
+func translateQuery(q *types.Query, crit *types.SearchCriteria) (string, error) {
+	if q.Source != "" && isNative(q) {
+		if crit.SearchBody {
+			return "body:" + opt.QuoteArg(q.Source), nil
+		}
+		return q.Source, nil
+	}
+
+	var b queryBuilder
+	switch q.Op {
+	case types.QueryAnd, types.QueryOr:
+		for _, o := range q.Operands {
+			s, err := translateQuery(o, crit)
+			if err != nil {
+				return "", err
+			}
+			if q.Op == types.QueryAnd {
+				b.and(s)
+			} else {
+				b.or(s)
+			}
+		}
+		return b.s, nil
+	case types.QueryNot:
+		s, err := translateQuery(q.Operands[0], crit)
+		if err != nil {
+			return "", err
+		}
+		return "not (" + s + ")", nil
+	}
+
+	switch q.Key {
+	case types.QueryWord:
+		if crit.SearchBody {
+			return "body:" + quote(q.Value), nil
+		}
+		return quote(q.Value), nil
+	case types.QueryFrom, types.QueryTo, types.QuerySubject, types.QueryBody:
+		return string(q.Key) + ":" + quote(q.Value), nil
+	case types.QueryCc:
+		return "cc:" + quote(q.Value), nil
+	case types.QueryText:
+		return quote(q.Value), nil
+	case types.QueryHeader:
+		if strings.EqualFold(q.Header, "Message-Id") {
+			return "id:" + quote(strings.Trim(q.Value, "<>")), nil
+		}
+	case types.QueryFlag:
+		for f := range flagToTag {
+			if q.Flag.Has(f) {
+				b.and(getParsedFlag(f, false))
+			}
+		}
+		return b.s, nil
+	case types.QueryTag:
+		return "tag:" + quote(q.Value), nil
+	case types.QueryAttachment:
+		return "tag:attachment", nil
+	case types.QueryDate:
+		start, end := "", ""
+		if !q.Start.IsZero() {
+			start = fmt.Sprintf("@%d", q.Start.Unix())
+		}
+		if !q.End.IsZero() {
+			end = fmt.Sprintf("@%d", q.End.Unix())
+		}
+		return fmt.Sprintf("date:%s..%s", start, end), nil
+	}
+	return "", fmt.Errorf("%s: not supported by notmuch", q)
 }
 
 func getParsedFlag(flag models.Flags, inverse bool) string {
@@ -109,3 +223,11 @@ func getParsedFlag(flag models.Flags, inverse bool) string {
 	}
 	return name
 }
+
+// quote encodes s as a notmuch phrase if needed.
+func quote(s string) string {
+	if strings.ContainsAny(s, " \t()\"") {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return s
+}
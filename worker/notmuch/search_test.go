@@ -0,0 +1,44 @@
+//go:build notmuch
+// +build notmuch
+
+package notmuch
+
+import (
+	"testing"
+
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+func TestTranslateQuery(t *testing.T) {
+	tests := []struct {
+		query    string
+		body     bool
+		expected string
+	}{
+		// native notmuch queries are passed unchanged
+		{"foo*", false, "(foo*)"},
+		{"from:alice and date:2024-01..2024-03", false, "(from:alice and date:2024-01..2024-03)"},
+		{"id:foo@bar or thread:abc", false, "(id:foo@bar or thread:abc)"},
+		{"hello world", true, "(body:'hello world')"},
+		// queries using aerc keys are translated
+		{"from:alice is:unread", false, "((from:alice) and (not ((not tag:unread))))"},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			q, err := types.ParseQuery(test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, err := translate(&types.SearchCriteria{
+				Query:      q,
+				SearchBody: test.body,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, s)
+			}
+		})
+	}
+}
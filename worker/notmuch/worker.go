@@ -24,9 +24,9 @@ import (
 	"git.sr.ht/~rjarry/aerc/models"
 	"git.sr.ht/~rjarry/aerc/worker/handlers"
 	"git.sr.ht/~rjarry/aerc/worker/lib"
+	"git.sr.ht/~rjarry/aerc/worker/middleware"
 	notmuch "git.sr.ht/~rjarry/aerc/worker/notmuch/lib"
 	"git.sr.ht/~rjarry/aerc/worker/types"
-	"github.com/emersion/go-maildir"
 )
 
 func init() {
@@ -36,7 +36,7 @@ func init() {
 var errUnsupported = fmt.Errorf("unsupported command")
 
 type worker struct {
-	w                   *types.Worker
+	w                   types.WorkerInteractor
 	nmStateChange       chan bool
 	query               string
 	currentQueryName    string
@@ -82,7 +82,11 @@ func (w *worker) Run() {
 		select {
 		case action := <-w.w.Actions():
 			msg := w.w.ProcessAction(action)
-			err := w.handleMessage(msg)
+			restore, err := w.enterFolder(msg.Target())
+			if err == nil {
+				err = w.handleMessage(msg)
+				restore()
+			}
 			switch {
 			case errors.Is(err, errUnsupported):
 				w.w.PostMessage(&types.Unsupported{
@@ -255,6 +259,16 @@ func (w *worker) handleConfigure(msg *types.Configure) error {
 		w.mfs = types.Refuse
 	}
 
+	if file := msg.Config.Searches; file != "" {
+		var searches types.WorkerInteractor
+		searches, err = middleware.NewSavedSearches(w.w, file,
+			msg.Config.Default)
+		if err != nil {
+			return err
+		}
+		w.w = searches
+	}
+
 	return nil
 }
 
@@ -353,32 +367,55 @@ func (w *worker) getDirectoryInfo(name string, query string) *models.DirectoryIn
 	return dirInfo
 }
 
+// folderQuery returns the query of a maildir folder or of a named query.
+func (w *worker) folderQuery(name string) (string, bool) {
+	if w.store != nil {
+		folders, _ := w.store.FolderMap()
+		if _, ok := folders[name]; ok {
+			folder := filepath.Join(w.maildirAccountPath, name)
+			return fmt.Sprintf("folder:%s", strconv.Quote(folder)), true
+		}
+	}
+	if q, ok := w.nameQueryMap[name]; ok {
+		return q, true
+	}
+	q, ok := w.dynamicNameQueryMap[name]
+	return q, ok
+}
+
+// enterFolder selects another query than the one opened by the user in order
+// to perform an action in it. The returned function restores the previous
+// selection.
+func (w *worker) enterFolder(name string) (func(), error) {
+	if name == "" || name == w.currentQueryName {
+		return func() {}, nil
+	}
+	q, ok := w.folderQuery(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown folder: %s", name)
+	}
+	query, queryName := w.query, w.currentQueryName
+	w.query, w.currentQueryName = q, name
+	return func() {
+		w.query, w.currentQueryName = query, queryName
+	}, nil
+}
+
 func (w *worker) handleOpenDirectory(msg *types.OpenDirectory) error {
 	if msg.Context.Err() != nil {
 		return context.Canceled
 	}
 	w.w.Tracef("opening %s with query %s", msg.Directory, msg.Query)
 
-	var exists bool
-	q := ""
 	if w.store != nil {
 		folders, _ := w.store.FolderMap()
-		var dir maildir.Dir
-		dir, exists = folders[msg.Directory]
-		if exists {
-			folder := filepath.Join(w.maildirAccountPath, msg.Directory)
-			q = fmt.Sprintf("folder:%s", strconv.Quote(folder))
+		if dir, ok := folders[msg.Directory]; ok {
 			if err := w.processNewMaildirFiles(string(dir)); err != nil {
 				return err
 			}
 		}
 	}
-	if q == "" {
-		q, exists = w.nameQueryMap[msg.Directory]
-		if !exists {
-			q, exists = w.dynamicNameQueryMap[msg.Directory]
-		}
-	}
+	q, exists := w.folderQuery(msg.Directory)
 	if !exists || msg.Force {
 		q = msg.Query
 		if q == "" {
@@ -590,7 +627,11 @@ func (w *worker) handleFlagMessages(msg *types.FlagMessages) error {
 }
 
 func (w *worker) handleSearchDirectory(msg *types.SearchDirectory) error {
-	search := notmuch.AndQueries(w.query, translate(msg.Criteria))
+	query, err := translate(msg.Criteria)
+	if err != nil {
+		return err
+	}
+	search := notmuch.AndQueries(w.query, query)
 	log.Debugf("search query: '%s'", search)
 	uids, err := w.uidsFromQuery(msg.Context, search)
 	if err != nil {
@@ -653,7 +694,11 @@ func (w *worker) emitDirectoryContents(parent types.WorkerMessage) error {
 	query := w.query
 	ctx := context.Background()
 	if msg, ok := parent.(*types.FetchDirectoryContents); ok {
-		query = notmuch.AndQueries(query, translate(msg.Filter))
+		filter, err := translate(msg.Filter)
+		if err != nil {
+			return err
+		}
+		query = notmuch.AndQueries(query, filter)
 		log.Debugf("filter query: '%s'", query)
 		ctx = msg.Context
 	}
@@ -678,7 +723,11 @@ func (w *worker) emitDirectoryThreaded(parent types.WorkerMessage) error {
 	ctx := context.Background()
 	threadContext := false
 	if msg, ok := parent.(*types.FetchDirectoryThreaded); ok {
-		query = notmuch.AndQueries(query, translate(msg.Filter))
+		filter, err := translate(msg.Filter)
+		if err != nil {
+			return err
+		}
+		query = notmuch.AndQueries(query, filter)
 		log.Debugf("filter query: '%s'", query)
 		ctx = msg.Context
 		threadContext = msg.ThreadContext
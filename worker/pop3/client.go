@@ -0,0 +1,141 @@
+package pop3
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// client is a minimal POP3 client, see RFC 1939 and RFC 2449.
+type client struct {
+	conn net.Conn
+	text *textproto.Conn
+}
+
+// message of the maildrop, as listed by UIDL
+type listing struct {
+	num  int
+	uidl string
+}
+
+func dial(cfg *serverConfig) (*client, error) {
+	dialer := &net.Dialer{Timeout: cfg.timeout}
+	var conn net.Conn
+	var err error
+	if cfg.tls {
+		conn, err = tls.DialWithDialer(dialer, "tcp", cfg.addr, cfg.tlsConfig())
+	} else {
+		conn, err = dialer.Dial("tcp", cfg.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &client{conn: conn, text: textproto.NewConn(conn)}
+	if _, err := c.response(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if cfg.starttls {
+		if err := c.startTLS(cfg.tlsConfig()); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// response reads the status line of a response.
+func (c *client) response() (string, error) {
+	line, err := c.text.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	status, text, _ := strings.Cut(line, " ")
+	switch status {
+	case "+OK":
+		return text, nil
+	case "-ERR":
+		return "", fmt.Errorf("pop3: %s", text)
+	}
+	return "", fmt.Errorf("pop3: unexpected response %q", line)
+}
+
+func (c *client) cmd(format string, args ...any) (string, error) {
+	if err := c.text.PrintfLine(format, args...); err != nil {
+		return "", err
+	}
+	return c.response()
+}
+
+func (c *client) startTLS(config *tls.Config) error {
+	if _, err := c.cmd("STLS"); err != nil {
+		return err
+	}
+	conn := tls.Client(c.conn, config)
+	if err := conn.Handshake(); err != nil {
+		return err
+	}
+	c.conn = conn
+	c.text = textproto.NewConn(conn)
+	return nil
+}
+
+func (c *client) login(user, password string) error {
+	if _, err := c.cmd("USER %s", user); err != nil {
+		return err
+	}
+	_, err := c.cmd("PASS %s", password)
+	return err
+}
+
+// uidl lists the messages of the maildrop with their unique id.
+func (c *client) uidl() ([]listing, error) {
+	if _, err := c.cmd("UIDL"); err != nil {
+		return nil, err
+	}
+	lines, err := c.text.ReadDotLines()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]listing, 0, len(lines))
+	for _, line := range lines {
+		num, uidl, found := strings.Cut(line, " ")
+		n, err := strconv.Atoi(num)
+		if !found || err != nil {
+			return nil, fmt.Errorf("pop3: invalid UIDL line %q", line)
+		}
+		list = append(list, listing{num: n, uidl: uidl})
+	}
+	return list, nil
+}
+
+// retr copies the content of a message to w.
+func (c *client) retr(num int, w io.Writer) error {
+	if _, err := c.cmd("RETR %d", num); err != nil {
+		return err
+	}
+	_, err := io.Copy(w, c.text.DotReader())
+	return err
+}
+
+func (c *client) dele(num int) error {
+	_, err := c.cmd("DELE %d", num)
+	return err
+}
+
+// quit ends the session. The deleted messages are removed from the
+// maildrop only when this succeeds.
+func (c *client) quit() error {
+	_, err := c.cmd("QUIT")
+	return errors.Join(err, c.text.Close())
+}
+
+// close drops the connection without committing the deletions.
+func (c *client) close() {
+	c.conn.Close()
+}
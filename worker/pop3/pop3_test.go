@@ -0,0 +1,258 @@
+package pop3
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"git.sr.ht/~rjarry/aerc/config"
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+// stand-in POP3 server with a single maildrop
+type testServer struct {
+	lock     sync.Mutex
+	uidls    []string
+	messages map[string]string
+	addr     string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &testServer{messages: make(map[string]string), addr: l.Addr().String()}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) add(uidl, subject string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.uidls = append(s.uidls, uidl)
+	s.messages[uidl] = fmt.Sprintf("Subject: %s\r\nFrom: a@example.com\r\n"+
+		"\r\nHello\r\n.dot-stuffed line\r\n", subject)
+}
+
+func (s *testServer) list() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string{}, s.uidls...)
+}
+
+func (s *testServer) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	_ = text.PrintfLine("+OK ready")
+	s.lock.Lock()
+	uidls := append([]string{}, s.uidls...)
+	s.lock.Unlock()
+	deleted := make(map[int]bool)
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+		num, _ := strconv.Atoi(arg)
+		switch {
+		case cmd == "USER":
+			_ = text.PrintfLine("+OK")
+		case cmd == "PASS" && arg == "s3cr3t":
+			_ = text.PrintfLine("+OK logged in")
+		case cmd == "PASS":
+			_ = text.PrintfLine("-ERR invalid password")
+		case cmd == "UIDL":
+			_ = text.PrintfLine("+OK")
+			w := text.DotWriter()
+			for i, uidl := range uidls {
+				if !deleted[i+1] {
+					fmt.Fprintf(w, "%d %s\n", i+1, uidl)
+				}
+			}
+			w.Close()
+		case cmd == "RETR" && num > 0 && num <= len(uidls) && !deleted[num]:
+			_ = text.PrintfLine("+OK")
+			s.lock.Lock()
+			body := s.messages[uidls[num-1]]
+			s.lock.Unlock()
+			w := text.DotWriter()
+			fmt.Fprint(w, strings.ReplaceAll(body, "\r\n", "\n"))
+			w.Close()
+		case cmd == "DELE" && num > 0 && num <= len(uidls) && !deleted[num]:
+			deleted[num] = true
+			_ = text.PrintfLine("+OK")
+		case cmd == "QUIT":
+			s.lock.Lock()
+			var remaining []string
+			for i, uidl := range uidls {
+				if deleted[i+1] {
+					delete(s.messages, uidl)
+				} else {
+					remaining = append(remaining, uidl)
+				}
+			}
+			s.uidls = remaining
+			s.lock.Unlock()
+			_ = text.PrintfLine("+OK bye")
+			return
+		default:
+			_ = text.PrintfLine("-ERR unknown command")
+		}
+	}
+}
+
+func newTestWorker(t *testing.T, s *testServer, leaveOnServer bool) *Worker {
+	t.Helper()
+	worker := types.NewWorker("test")
+	worker.SetMessages(make(chan types.WorkerMessage, 100))
+	backend, _ := NewWorker(worker)
+	w := backend.(*Worker)
+	err := w.handleConfigure(&types.Configure{
+		Config: &config.AccountConfig{
+			Name:   "test",
+			Source: "pop3+insecure://me:s3cr3t@" + s.addr,
+			Params: map[string]string{
+				"maildir-store":   t.TempDir(),
+				"leave-on-server": strconv.FormatBool(leaveOnServer),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func localSubjects(t *testing.T, w *Worker) []string {
+	t.Helper()
+	uids, err := w.c.UIDs(w.inbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subjects []string
+	for _, uid := range uids {
+		info, err := w.messageInfo(uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subjects = append(subjects, info.Envelope.Subject)
+	}
+	return subjects
+}
+
+func TestSync(t *testing.T) {
+	s := newTestServer(t)
+	s.add("u1", "first")
+	s.add("u2", "second")
+	w := newTestWorker(t, s, false)
+
+	keys, err := w.sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 new messages, got %d", len(keys))
+	}
+	if list := s.list(); len(list) != 0 {
+		t.Errorf("messages left on server: %v", list)
+	}
+
+	s.add("u3", "third")
+	keys, err = w.sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("expected 1 new message, got %d", len(keys))
+	}
+	if n := len(localSubjects(t, w)); n != 3 {
+		t.Errorf("expected 3 local messages, got %d", n)
+	}
+
+	m, err := w.c.Message(w.inbox, models.UID(keys[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := m.NewReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if lines[len(lines)-1] != ".dot-stuffed line" {
+		t.Errorf("unexpected message content %q", lines)
+	}
+}
+
+func TestLeaveOnServer(t *testing.T) {
+	s := newTestServer(t)
+	s.add("u1", "first")
+	s.add("u2", "second")
+	w := newTestWorker(t, s, true)
+
+	keys, err := w.sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || len(s.list()) != 2 {
+		t.Fatalf("unexpected sync: %d new, %v on server", len(keys), s.list())
+	}
+	keys, err = w.sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("messages downloaded twice")
+	}
+
+	// reload the tracked messages
+	w.uidls, err = loadUidls(w.uidls.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid := models.UID(w.uidls.keys["u1"])
+	err = w.handleDeleteMessages(&types.DeleteMessages{Uids: []models.UID{uid}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.sync(); err != nil {
+		t.Fatal(err)
+	}
+	if list := s.list(); len(list) != 1 || list[0] != "u2" {
+		t.Errorf("unexpected messages on server: %v", list)
+	}
+	if subjects := localSubjects(t, w); len(subjects) != 1 || subjects[0] != "second" {
+		t.Errorf("unexpected local messages: %v", subjects)
+	}
+}
+
+func TestLoginFailure(t *testing.T) {
+	s := newTestServer(t)
+	w := newTestWorker(t, s, false)
+	w.config.password = "bad"
+	if _, err := w.sync(); err == nil || !strings.Contains(err.Error(), "invalid password") {
+		t.Errorf("expected login failure, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package pop3
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/emersion/go-maildir"
+)
+
+// key of the messages deleted locally that must be removed from the server
+const deletedKey = "-"
+
+// uidlStore tracks the messages downloaded from the server. It maps the
+// UIDL of each message still on the server to its key in the local maildir.
+type uidlStore struct {
+	path string
+	keys map[string]string
+}
+
+func loadUidls(path string) (*uidlStore, error) {
+	s := &uidlStore{path: path, keys: make(map[string]string)}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		uidl, key, found := strings.Cut(scanner.Text(), " ")
+		if !found {
+			return nil, fmt.Errorf("%s: invalid line %q", path, scanner.Text())
+		}
+		s.keys[uidl] = key
+	}
+	return s, scanner.Err()
+}
+
+func (s *uidlStore) save() error {
+	uidls := make([]string, 0, len(s.keys))
+	for uidl := range s.keys {
+		uidls = append(uidls, uidl)
+	}
+	sort.Strings(uidls)
+	var b strings.Builder
+	for _, uidl := range uidls {
+		fmt.Fprintf(&b, "%s %s\n", uidl, s.keys[uidl])
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// markDeleted records that the local copy of a message was deleted. It
+// returns false if the message is not known to be on the server.
+func (s *uidlStore) markDeleted(key string) bool {
+	for uidl, k := range s.keys {
+		if k == key {
+			s.keys[uidl] = deletedKey
+			return true
+		}
+	}
+	return false
+}
+
+// sync downloads the new messages of the maildrop into the inbox. Messages
+// are removed from the server once downloaded, unless leave-on-server is
+// enabled. In that case, only the messages deleted locally are removed. The
+// keys of the new messages are returned.
+func (w *Worker) sync() ([]string, error) {
+	c, err := dial(&w.config)
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+	if err := c.login(w.config.user, w.config.password); err != nil {
+		return nil, err
+	}
+	list, err := c.uidl()
+	if err != nil {
+		return nil, err
+	}
+
+	onServer := make(map[string]bool, len(list))
+	for _, m := range list {
+		onServer[m.uidl] = true
+	}
+	for uidl := range w.uidls.keys {
+		if !onServer[uidl] {
+			delete(w.uidls.keys, uidl)
+		}
+	}
+
+	var keys []string
+	for _, m := range list {
+		key, known := w.uidls.keys[m.uidl]
+		if !known {
+			key, err = w.download(c, m.num)
+			if err != nil {
+				break
+			}
+			w.uidls.keys[m.uidl] = key
+			keys = append(keys, key)
+		}
+		if key == deletedKey || !w.config.leaveOnServer {
+			if err = c.dele(m.num); err != nil {
+				break
+			}
+		}
+	}
+	if serr := w.uidls.save(); serr != nil {
+		return keys, errors.Join(err, serr)
+	}
+	if err != nil {
+		return keys, err
+	}
+	return keys, c.quit()
+}
+
+func (w *Worker) download(c *client, num int) (string, error) {
+	key, f, err := w.inbox.Create([]maildir.Flag{})
+	if err != nil {
+		return "", err
+	}
+	err = c.retr(num, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = w.inbox.Remove(key)
+		return "", fmt.Errorf("could not download message %d: %w", num, err)
+	}
+	return key, nil
+}
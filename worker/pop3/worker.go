@@ -0,0 +1,538 @@
+package pop3
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-maildir"
+
+	"git.sr.ht/~rjarry/aerc/lib/rfc822"
+	"git.sr.ht/~rjarry/aerc/lib/xdg"
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/handlers"
+	"git.sr.ht/~rjarry/aerc/worker/lib"
+	local "git.sr.ht/~rjarry/aerc/worker/maildir"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+func init() {
+	handlers.RegisterWorkerFactory("pop3", NewWorker)
+	handlers.RegisterWorkerFactory("pop3s", NewWorker)
+}
+
+var errUnsupported = fmt.Errorf("unsupported command")
+
+// POP3 servers only provide the inbox
+const inboxName = "INBOX"
+
+type serverConfig struct {
+	addr          string
+	tls           bool
+	starttls      bool
+	insecure      bool
+	user          string
+	password      string
+	leaveOnServer bool
+	timeout       time.Duration
+}
+
+func (c *serverConfig) tlsConfig() *tls.Config {
+	host, _, _ := net.SplitHostPort(c.addr)
+	return &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: c.insecure,
+	}
+}
+
+// Worker downloads the messages of a POP3 maildrop into a local maildir,
+// from where they are read.
+type Worker struct {
+	worker types.WorkerInteractor
+	config serverConfig
+
+	c      *local.Container
+	inbox  maildir.Dir
+	uidls  *uidlStore
+	recent map[models.UID]struct{}
+
+	capabilities   *models.Capabilities
+	headers        []string
+	headersExclude []string
+}
+
+func NewWorker(worker *types.Worker) (types.Backend, error) {
+	return &Worker{
+		worker: worker,
+		recent: make(map[models.UID]struct{}),
+		capabilities: &models.Capabilities{
+			Sort:   true,
+			Thread: false,
+		},
+	}, nil
+}
+
+func (w *Worker) Run() {
+	for msg := range w.worker.Actions() {
+		msg = w.worker.ProcessAction(msg)
+		var err error
+		if folder := msg.Target(); folder != "" && folder != inboxName {
+			err = fmt.Errorf("no such folder: %s", folder)
+		} else {
+			err = w.handleMessage(msg)
+		}
+		switch {
+		case errors.Is(err, errUnsupported):
+			w.worker.PostMessage(&types.Unsupported{
+				Message: types.RespondTo(msg),
+			}, nil)
+		case err != nil:
+			w.worker.PostMessage(&types.Error{
+				Message: types.RespondTo(msg),
+				Error:   err,
+			}, nil)
+		default:
+			w.worker.PostMessage(&types.Done{
+				Message: types.RespondTo(msg),
+			}, nil)
+		}
+	}
+}
+
+func (w *Worker) Capabilities() *models.Capabilities {
+	return w.capabilities
+}
+
+func (w *Worker) PathSeparator() string {
+	return "/"
+}
+
+func (w *Worker) handleMessage(msg types.WorkerMessage) error {
+	switch msg := msg.(type) {
+	case *types.Unsupported:
+		// No-op
+	case *types.Configure:
+		return w.handleConfigure(msg)
+	case *types.Connect, *types.Reconnect:
+		w.checkMail()
+	case *types.Disconnect:
+		// No-op
+	case *types.ListDirectories:
+		return w.handleListDirectories(msg)
+	case *types.OpenDirectory:
+		return w.handleOpenDirectory(msg)
+	case *types.FetchDirectoryContents:
+		return w.handleFetchDirectoryContents(msg)
+	case *types.FetchMessageHeaders:
+		return w.handleFetchMessageHeaders(msg)
+	case *types.FetchMessageBodyPart:
+		return w.handleFetchMessageBodyPart(msg)
+	case *types.FetchFullMessages:
+		return w.handleFetchFullMessages(msg)
+	case *types.DeleteMessages:
+		return w.handleDeleteMessages(msg)
+	case *types.FlagMessages:
+		return w.handleFlagMessages(msg)
+	case *types.AnsweredMessages:
+		return w.handleAnsweredMessages(msg)
+	case *types.SearchDirectory:
+		return w.handleSearchDirectory(msg)
+	case *types.CheckMail:
+		w.checkMail()
+	default:
+		return errUnsupported
+	}
+	return nil
+}
+
+func (w *Worker) handleConfigure(msg *types.Configure) error {
+	u, err := url.Parse(msg.Config.Source)
+	if err != nil {
+		return err
+	}
+
+	scheme, mode, _ := strings.Cut(u.Scheme, "+")
+	port := "110"
+	switch scheme {
+	case "pop3":
+		w.config.starttls = mode != "insecure"
+	case "pop3s":
+		w.config.tls = true
+		w.config.insecure = mode == "insecure"
+		port = "995"
+	default:
+		return fmt.Errorf("unknown POP3 scheme %s", u.Scheme)
+	}
+	w.config.addr = u.Host
+	if u.Port() == "" {
+		w.config.addr = net.JoinHostPort(u.Host, port)
+	}
+	if u.User != nil {
+		w.config.user = u.User.Username()
+		w.config.password, _ = u.User.Password()
+	}
+
+	w.config.timeout = 30 * time.Second
+	store := xdg.DataPath("aerc", "pop3", msg.Config.Name)
+	for key, value := range msg.Config.Params {
+		switch key {
+		case "leave-on-server":
+			w.config.leaveOnServer, err = strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid leave-on-server value %v: %w", value, err)
+			}
+		case "connection-timeout":
+			w.config.timeout, err = time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid connection-timeout value %v: %w", value, err)
+			}
+		case "maildir-store":
+			store = xdg.ExpandHome(value)
+		}
+	}
+	w.headers = msg.Config.Headers
+	w.headersExclude = msg.Config.HeadersExclude
+
+	w.c, err = local.NewContainer(store, false)
+	if err != nil {
+		return err
+	}
+	w.inbox = w.c.Store.Dir(inboxName)
+	if err := os.MkdirAll(store, 0o700); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(string(w.inbox), "cur")); err != nil {
+		if err := w.inbox.Init(); err != nil {
+			return err
+		}
+	}
+	w.uidls, err = loadUidls(filepath.Join(store, "uidl"))
+	if err != nil {
+		return err
+	}
+	w.worker.Debugf("configured with local maildir %s", store)
+	return nil
+}
+
+// checkMail downloads the new messages. Errors are only reported since the
+// messages already downloaded remain available.
+func (w *Worker) checkMail() {
+	keys, err := w.sync()
+	if err != nil {
+		w.worker.PostMessage(&types.Error{
+			Error: fmt.Errorf("pop3: %s: %w", w.config.addr, err),
+		}, nil)
+	}
+	for _, key := range keys {
+		w.recent[models.UID(key)] = struct{}{}
+	}
+	if len(keys) > 0 {
+		w.worker.Debugf("%d new messages", len(keys))
+		w.worker.PostMessage(&types.DirectoryInfo{
+			Info:    w.directoryInfo(),
+			Refetch: true,
+		}, nil)
+	}
+}
+
+func (w *Worker) directoryInfo() *models.DirectoryInfo {
+	info := &models.DirectoryInfo{Name: inboxName}
+	uids, err := w.c.UIDs(w.inbox)
+	if err != nil && len(uids) == 0 {
+		w.worker.Errorf("could not get uids: %v", err)
+		return info
+	}
+	info.Exists = len(uids)
+	for _, uid := range uids {
+		m, err := w.c.Message(w.inbox, uid)
+		if err != nil {
+			continue
+		}
+		flags, err := m.ModelFlags()
+		if err != nil {
+			w.worker.Errorf("could not get flags: %v", err)
+			continue
+		}
+		if !flags.Has(models.SeenFlag) {
+			info.Unseen++
+		}
+		if _, ok := w.recent[uid]; ok {
+			info.Recent++
+		}
+	}
+	return info
+}
+
+func (w *Worker) handleListDirectories(msg *types.ListDirectories) error {
+	w.worker.PostMessage(&types.Directory{
+		Message: types.RespondTo(msg),
+		Dir: &models.Directory{
+			Name: inboxName,
+			Role: models.InboxRole,
+		},
+	}, nil)
+	w.worker.PostMessage(&types.DirectoryInfo{
+		Info: w.directoryInfo(),
+	}, nil)
+	return nil
+}
+
+func (w *Worker) handleOpenDirectory(msg *types.OpenDirectory) error {
+	if msg.Directory != inboxName {
+		return fmt.Errorf("no such folder: %s", msg.Directory)
+	}
+	if err := w.inbox.Clean(); err != nil {
+		return fmt.Errorf("could not clean directory: %w", err)
+	}
+	w.worker.PostMessage(&types.DirectoryInfo{
+		Info: w.directoryInfo(),
+	}, nil)
+	return nil
+}
+
+func (w *Worker) messages(uids []models.UID) []rfc822.RawMessage {
+	msgs := make([]rfc822.RawMessage, 0, len(uids))
+	for _, uid := range uids {
+		m, err := w.c.Message(w.inbox, uid)
+		if err != nil {
+			w.worker.Errorf("could not get message %s: %v", uid, err)
+			continue
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs
+}
+
+func (w *Worker) handleFetchDirectoryContents(
+	msg *types.FetchDirectoryContents,
+) error {
+	uids, err := w.c.UIDs(w.inbox)
+	if err != nil && len(uids) == 0 {
+		return err
+	}
+	if msg.Filter != nil {
+		uids, err = lib.Search(w.messages(uids), msg.Filter)
+		if err != nil {
+			return err
+		}
+	}
+	if len(msg.SortCriteria) > 0 {
+		var infos []*models.MessageInfo
+		for _, uid := range uids {
+			m, err := w.c.Message(w.inbox, uid)
+			if err != nil {
+				continue
+			}
+			info, err := m.MessageHeaders()
+			if err != nil {
+				w.worker.Errorf("could not get message headers: %v", err)
+				continue
+			}
+			infos = append(infos, info)
+		}
+		uids, err = lib.Sort(infos, msg.SortCriteria)
+		if err != nil {
+			return err
+		}
+	}
+	w.worker.PostMessage(&types.DirectoryContents{
+		Message: types.RespondTo(msg),
+		Uids:    uids,
+	}, nil)
+	return nil
+}
+
+func (w *Worker) messageInfo(uid models.UID) (*models.MessageInfo, error) {
+	m, err := w.c.Message(w.inbox, uid)
+	if err != nil {
+		return nil, err
+	}
+	info, err := m.MessageInfo()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := w.recent[uid]; ok {
+		info.Flags |= models.RecentFlag
+	}
+	return info, nil
+}
+
+func (w *Worker) handleFetchMessageHeaders(
+	msg *types.FetchMessageHeaders,
+) error {
+	for _, uid := range msg.Uids {
+		info, err := w.messageInfo(uid)
+		if err != nil {
+			w.worker.Errorf("could not get message info: %v", err)
+			w.worker.PostMessage(&types.MessageInfo{
+				Info: &models.MessageInfo{
+					Envelope: &models.Envelope{},
+					Flags:    models.SeenFlag,
+					Uid:      uid,
+					Error:    err,
+				},
+				Message: types.RespondTo(msg),
+			}, nil)
+			continue
+		}
+		switch {
+		case len(w.headersExclude) > 0:
+			info.RFC822Headers = lib.LimitHeaders(info.RFC822Headers, w.headersExclude, true)
+		case len(w.headers) > 0:
+			info.RFC822Headers = lib.LimitHeaders(info.RFC822Headers, w.headers, false)
+		}
+		w.worker.PostMessage(&types.MessageInfo{
+			Message: types.RespondTo(msg),
+			Info:    info,
+		}, nil)
+		delete(w.recent, uid)
+	}
+	return nil
+}
+
+func (w *Worker) handleFetchMessageBodyPart(
+	msg *types.FetchMessageBodyPart,
+) error {
+	m, err := w.c.Message(w.inbox, msg.Uid)
+	if err != nil {
+		return err
+	}
+	r, err := m.NewBodyPartReader(msg.Part)
+	if err != nil {
+		return err
+	}
+	w.worker.PostMessage(&types.MessageBodyPart{
+		Message: types.RespondTo(msg),
+		Part: &models.MessageBodyPart{
+			Reader: r,
+			Uid:    msg.Uid,
+		},
+	}, nil)
+	return nil
+}
+
+func (w *Worker) handleFetchFullMessages(msg *types.FetchFullMessages) error {
+	for _, uid := range msg.Uids {
+		m, err := w.c.Message(w.inbox, uid)
+		if err != nil {
+			return err
+		}
+		r, err := m.NewReader()
+		if err != nil {
+			return err
+		}
+		b, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		w.worker.PostMessage(&types.FullMessage{
+			Message: types.RespondTo(msg),
+			Content: &models.FullMessage{
+				Uid:    uid,
+				Reader: bytes.NewReader(b),
+			},
+		}, nil)
+	}
+	return nil
+}
+
+// handleDeleteMessages deletes the local copies of the messages. With
+// leave-on-server, they are removed from the server on the next check.
+func (w *Worker) handleDeleteMessages(msg *types.DeleteMessages) error {
+	deleted, err := w.c.DeleteAll(w.inbox, msg.Uids)
+	if len(deleted) > 0 {
+		w.worker.PostMessage(&types.MessagesDeleted{
+			Message: types.RespondTo(msg),
+			Uids:    deleted,
+		}, nil)
+	}
+	if w.config.leaveOnServer {
+		marked := false
+		for _, uid := range deleted {
+			if w.uidls.markDeleted(string(uid)) {
+				marked = true
+			}
+		}
+		if marked {
+			if serr := w.uidls.save(); serr != nil {
+				err = errors.Join(err, serr)
+			}
+		}
+	}
+	w.worker.PostMessage(&types.DirectoryInfo{
+		Info: w.directoryInfo(),
+	}, nil)
+	return err
+}
+
+func (w *Worker) handleFlagMessages(msg *types.FlagMessages) error {
+	for _, uid := range msg.Uids {
+		m, err := w.c.Message(w.inbox, uid)
+		if err != nil {
+			return err
+		}
+		flag := lib.FlagToMaildir[msg.Flags]
+		if err := m.SetOneFlag(flag, msg.Enable); err != nil {
+			return err
+		}
+		info, err := w.messageInfo(uid)
+		if err != nil {
+			return err
+		}
+		w.worker.PostMessage(&types.MessageInfo{
+			Message: types.RespondTo(msg),
+			Info:    info,
+		}, nil)
+	}
+	w.worker.PostMessage(&types.DirectoryInfo{
+		Info: w.directoryInfo(),
+	}, nil)
+	return nil
+}
+
+func (w *Worker) handleAnsweredMessages(msg *types.AnsweredMessages) error {
+	for _, uid := range msg.Uids {
+		m, err := w.c.Message(w.inbox, uid)
+		if err != nil {
+			return err
+		}
+		if err := m.MarkReplied(msg.Answered); err != nil {
+			return err
+		}
+		info, err := w.messageInfo(uid)
+		if err != nil {
+			return err
+		}
+		w.worker.PostMessage(&types.MessageInfo{
+			Message: types.RespondTo(msg),
+			Info:    info,
+		}, nil)
+	}
+	return nil
+}
+
+func (w *Worker) handleSearchDirectory(msg *types.SearchDirectory) error {
+	uids, err := w.c.UIDs(w.inbox)
+	if err != nil && len(uids) == 0 {
+		return err
+	}
+	uids, err = lib.Search(w.messages(uids), msg.Criteria)
+	if err != nil {
+		return err
+	}
+	w.worker.PostMessage(&types.SearchResults{
+		Message: types.RespondTo(msg),
+		Uids:    uids,
+	}, nil)
+	return nil
+}
@@ -16,12 +16,21 @@ type WorkerMessage interface {
 	setId(id int64)
 	Account() string
 	setAccount(string)
+	Folder() string
+	Target() string
+	setFolder(string)
+	setTarget(string)
+	replyTo() chan WorkerMessage
+	setReplyTo(chan WorkerMessage)
 }
 
 type Message struct {
 	inResponseTo WorkerMessage
 	id           int64
 	acct         string
+	folder       string
+	target       string
+	replies      chan WorkerMessage
 }
 
 func RespondTo(msg WorkerMessage) Message {
@@ -50,6 +59,59 @@ func (m *Message) setAccount(name string) {
 	m.acct = name
 }
 
+// Folder returns the folder an action was posted for. It is empty for actions
+// that apply to the selected folder.
+func (m *Message) Folder() string {
+	return m.folder
+}
+
+// Target returns the folder in which the backend must perform an action. It
+// is the folder the action was posted for, unless a middleware changed it.
+func (m *Message) Target() string {
+	return m.target
+}
+
+func (m *Message) setFolder(name string) {
+	m.folder = name
+}
+
+func (m *Message) setTarget(name string) {
+	m.target = name
+}
+
+func (m *Message) replyTo() chan WorkerMessage {
+	return m.replies
+}
+
+func (m *Message) setReplyTo(replies chan WorkerMessage) {
+	m.replies = replies
+}
+
+// InFolder makes an action apply to the given folder instead of the selected
+// one. The selection of the backend is left unchanged and the responses are
+// not dispatched to the message list of the selected folder. Backends which
+// cannot work on another folder than the selected one reply with an error.
+func InFolder[T WorkerMessage](msg T, folder string) T {
+	msg.setFolder(folder)
+	msg.setTarget(folder)
+	return msg
+}
+
+// ReplyTo sends the responses to an action to the given channel instead of the
+// UI. It allows a backend to perform actions with the worker of another
+// account. The receiver of the channel must pass the responses to the
+// ProcessMessage method of that worker.
+func ReplyTo[T WorkerMessage](msg T, replies chan WorkerMessage) T {
+	msg.setReplyTo(replies)
+	return msg
+}
+
+// Retarget changes the folder in which the backend performs an action posted
+// with InFolder. It is meant for middlewares which translate folder names.
+func Retarget(msg WorkerMessage, folder string) {
+	msg.setTarget(folder)
+}
+
 // Meta-messages
 
 type Done struct {
@@ -135,6 +197,8 @@ type CreateDirectory struct {
 	Message
 	Directory string
 	Quiet     bool
+	// special use of the new directory, if supported by the backend
+	Role models.Role
 }
 
 type RemoveDirectory struct {
@@ -143,6 +207,38 @@ type RemoveDirectory struct {
 	Quiet     bool
 }
 
+type ListACL struct {
+	Message
+	Directory string
+}
+
+type SetACL struct {
+	Message
+	Directory  string
+	Identifier string
+	Rights     string
+}
+
+type DeleteACL struct {
+	Message
+	Directory  string
+	Identifier string
+}
+
+type GetMetadata struct {
+	Message
+	Directory string
+	Entries   []string
+}
+
+// SetMetadata removes the entry when Value is empty.
+type SetMetadata struct {
+	Message
+	Directory string
+	Entry     string
+	Value     string
+}
+
 type FetchMessageHeaders struct {
 	Message
 	Context context.Context
@@ -242,6 +338,19 @@ type DirectoryInfo struct {
 	Refetch bool
 }
 
+type DirectoryACL struct {
+	Message
+	Directory string
+	// rights per identifier
+	Rights map[string]string
+}
+
+type DirectoryMetadata struct {
+	Message
+	Directory string
+	Entries   map[string]string
+}
+
 type DirectoryContents struct {
 	Message
 	Uids []models.UID
@@ -285,6 +394,14 @@ type MessagesMoved struct {
 	Uids        []models.UID
 }
 
+// NewMessages reports messages which arrived in a folder that is not
+// selected.
+type NewMessages struct {
+	Message
+	Directory string
+	Infos     []*models.MessageInfo
+}
+
 type ModifyLabels struct {
 	Message
 	Uids   []models.UID
@@ -0,0 +1,32 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestInFolder(t *testing.T) {
+	msg := &FetchDirectoryContents{}
+	if msg.Folder() != "" || msg.Target() != "" {
+		t.Fatalf("actions apply to the selected folder by default")
+	}
+
+	InFolder(msg, "Archive")
+	if msg.Folder() != "Archive" || msg.Target() != "Archive" {
+		t.Errorf("unexpected folder %q, target %q", msg.Folder(), msg.Target())
+	}
+
+	// middlewares change where the action is performed, not the folder
+	// the responses are dispatched to
+	Retarget(msg, "INBOX/Archive")
+	if msg.Folder() != "Archive" || msg.Target() != "INBOX/Archive" {
+		t.Errorf("unexpected folder %q, target %q", msg.Folder(), msg.Target())
+	}
+
+	resp := &DirectoryContents{Message: RespondTo(msg)}
+	if resp.Folder() != "" {
+		t.Errorf("responses are not posted for a folder")
+	}
+	if req := resp.InResponseTo(); req == nil || req.Folder() != "Archive" {
+		t.Errorf("the folder of the request is lost")
+	}
+}
@@ -0,0 +1,477 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"git.sr.ht/~rjarry/aerc/lib/parse"
+	"git.sr.ht/~rjarry/aerc/models"
+)
+
+type QueryOp int
+
+const (
+	// QueryTerm matches a single condition, see QueryKey.
+	QueryTerm QueryOp = iota
+	// QueryAnd matches if all its operands match.
+	QueryAnd
+	// QueryOr matches if any of its operands match.
+	QueryOr
+	// QueryNot matches if its single operand does not match.
+	QueryNot
+)
+
+type QueryKey string
+
+const (
+	// QueryWord is a term without any key. It is matched against the
+	// subject, the body or the whole message depending on the SearchBody
+	// and SearchAll search criteria. Backends with their own query
+	// language may use it verbatim.
+	QueryWord       QueryKey = ""
+	QueryFrom       QueryKey = "from"
+	QueryTo         QueryKey = "to"
+	QueryCc         QueryKey = "cc"
+	QuerySubject    QueryKey = "subject"
+	QueryBody       QueryKey = "body"
+	QueryText       QueryKey = "text"
+	QueryHeader     QueryKey = "header"
+	QueryFlag       QueryKey = "is"
+	QueryTag        QueryKey = "tag"
+	QueryAttachment QueryKey = "has"
+	QueryDate       QueryKey = "date"
+	QueryLarger     QueryKey = "larger"
+	QuerySmaller    QueryKey = "smaller"
+)
+
+// QueryFlags maps the values accepted by the "is:" key to message flags.
+var QueryFlags = map[string]models.Flags{
+	"seen":      models.SeenFlag,
+	"read":      models.SeenFlag,
+	"answered":  models.AnsweredFlag,
+	"replied":   models.AnsweredFlag,
+	"forwarded": models.ForwardedFlag,
+	"flagged":   models.FlaggedFlag,
+	"draft":     models.DraftFlag,
+}
+
+// Query is a node of a boolean search expression tree.
+type Query struct {
+	Op       QueryOp
+	Operands []*Query
+
+	// The following fields are only used by QueryTerm nodes.
+	Key QueryKey
+	// Text to search, or tag name for QueryTag.
+	Value string
+	// Header name for QueryHeader.
+	Header string
+	// Flag for QueryFlag.
+	Flag models.Flags
+	// Date range for QueryDate. The end date is excluded. Either bound
+	// may be zero.
+	Start time.Time
+	End   time.Time
+	// Size in bytes for QueryLarger and QuerySmaller.
+	Size uint64
+
+	// Source is the expression the query was parsed from. It is only set
+	// on the root node returned by ParseQuery. Backends with their own
+	// query language may use it verbatim.
+	Source string
+}
+
+// And combines queries that must all match. Nil queries are ignored.
+func And(queries ...*Query) *Query {
+	var operands []*Query
+	for _, q := range queries {
+		switch {
+		case q == nil:
+		case q.Op == QueryAnd && q.Source == "":
+			operands = append(operands, q.Operands...)
+		default:
+			operands = append(operands, q)
+		}
+	}
+	switch len(operands) {
+	case 0:
+		return nil
+	case 1:
+		return operands[0]
+	}
+	return &Query{Op: QueryAnd, Operands: operands}
+}
+
+// Walk calls fn for each term of the query.
+func (q *Query) Walk(fn func(term *Query)) {
+	if q == nil {
+		return
+	}
+	if q.Op == QueryTerm {
+		fn(q)
+		return
+	}
+	for _, o := range q.Operands {
+		o.Walk(fn)
+	}
+}
+
+func (q *Query) String() string {
+	if q == nil {
+		return ""
+	}
+	switch q.Op {
+	case QueryAnd, QueryOr:
+		op := " AND "
+		if q.Op == QueryOr {
+			op = " OR "
+		}
+		operands := make([]string, 0, len(q.Operands))
+		for _, o := range q.Operands {
+			if o.Op == QueryAnd || o.Op == QueryOr {
+				operands = append(operands, "("+o.String()+")")
+			} else {
+				operands = append(operands, o.String())
+			}
+		}
+		return strings.Join(operands, op)
+	case QueryNot:
+		if len(q.Operands) == 0 {
+			return "NOT"
+		}
+		o := q.Operands[0]
+		if o.Op == QueryAnd || o.Op == QueryOr {
+			return "NOT (" + o.String() + ")"
+		}
+		return "NOT " + o.String()
+	}
+	switch q.Key {
+	case QueryWord:
+		return fmt.Sprintf("%q", q.Value)
+	case QueryHeader:
+		return fmt.Sprintf("header:%s:%q", q.Header, q.Value)
+	case QueryFlag:
+		for _, name := range []string{
+			"seen", "answered", "forwarded", "flagged", "draft",
+		} {
+			if QueryFlags[name] == q.Flag {
+				return "is:" + name
+			}
+		}
+		return fmt.Sprintf("is:%d", q.Flag)
+	case QueryAttachment:
+		return "has:attachment"
+	case QueryDate:
+		var start, end string
+		if !q.Start.IsZero() {
+			start = q.Start.Format("2006-01-02")
+		}
+		if !q.End.IsZero() {
+			end = q.End.Format("2006-01-02")
+		}
+		return fmt.Sprintf("date:%s..%s", start, end)
+	case QueryLarger, QuerySmaller:
+		return fmt.Sprintf("%s:%d", q.Key, q.Size)
+	}
+	return fmt.Sprintf("%s:%q", q.Key, q.Value)
+}
+
+var queryKeys = map[string]QueryKey{
+	"from":    QueryFrom,
+	"to":      QueryTo,
+	"cc":      QueryCc,
+	"subject": QuerySubject,
+	"body":    QueryBody,
+	"text":    QueryText,
+	"header":  QueryHeader,
+	"is":      QueryFlag,
+	"tag":     QueryTag,
+	"has":     QueryAttachment,
+	"date":    QueryDate,
+	"larger":  QueryLarger,
+	"smaller": QuerySmaller,
+}
+
+// QueryKeys returns the keys supported by ParseQuery, with their trailing colon.
+func QueryKeys() []string {
+	keys := make([]string, 0, len(queryKeys))
+	for k := range queryKeys {
+		keys = append(keys, k+":")
+	}
+	return keys
+}
+
+// ParseQuery parses a boolean search expression such as:
+//
+//	from:alice AND (subject:"release" OR has:attachment) NOT is:read
+//
+// Terms are combined with AND, OR and NOT (case insensitive) and can be
+// grouped with parentheses. Terms without an operator between them are
+// implicitly combined with AND. Words without a known key prefix are
+// returned as QueryWord terms. An empty expression returns nil.
+func ParseQuery(s string) (*Query, error) {
+	tokens, err := lexQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	p := queryParser{tokens: tokens}
+	q, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t != nil {
+		return nil, fmt.Errorf("unexpected %q", t.text)
+	}
+	q.Source = s
+	return q, nil
+}
+
+type queryTokenKind int
+
+const (
+	queryWord queryTokenKind = iota
+	queryOpen
+	queryClose
+)
+
+type queryToken struct {
+	kind   queryTokenKind
+	key    string
+	text   string
+	quoted bool
+}
+
+// operator returns the boolean operator designated by the token, if any.
+func (t *queryToken) operator() string {
+	if t.kind != queryWord || t.quoted || t.key != "" {
+		return ""
+	}
+	switch op := strings.ToUpper(t.text); op {
+	case "AND", "OR", "NOT":
+		return op
+	}
+	return ""
+}
+
+func lexQuery(s string) ([]*queryToken, error) {
+	var tokens []*queryToken
+	var cur *queryToken
+	var text strings.Builder
+	inQuote := false
+
+	flush := func() {
+		if cur != nil {
+			cur.text = text.String()
+			tokens = append(tokens, cur)
+		}
+		cur = nil
+		text.Reset()
+	}
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if inQuote {
+			switch r {
+			case '"':
+				inQuote = false
+			case '\\':
+				if i+1 < len(runes) {
+					i++
+					text.WriteRune(runes[i])
+				}
+			default:
+				text.WriteRune(r)
+			}
+			continue
+		}
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case r == '(' && cur == nil:
+			tokens = append(tokens, &queryToken{kind: queryOpen, text: "("})
+		case r == ')':
+			flush()
+			tokens = append(tokens, &queryToken{kind: queryClose, text: ")"})
+		default:
+			if cur == nil {
+				cur = &queryToken{kind: queryWord}
+			}
+			switch {
+			case r == '"':
+				inQuote = true
+				cur.quoted = true
+			case r == ':' && cur.key == "" && !cur.quoted:
+				key := strings.ToLower(text.String())
+				if _, ok := queryKeys[key]; ok {
+					cur.key = key
+					text.Reset()
+				} else {
+					text.WriteRune(r)
+				}
+			default:
+				text.WriteRune(r)
+			}
+		}
+	}
+	if inQuote {
+		return nil, errors.New("unterminated quoted string")
+	}
+	flush()
+	return tokens, nil
+}
+
+type queryParser struct {
+	tokens []*queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() *queryToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *queryParser) next() *queryToken {
+	t := p.peek()
+	if t != nil {
+		p.pos++
+	}
+	return t
+}
+
+func (p *queryParser) or() (*Query, error) {
+	var operands []*Query
+	for {
+		q, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, q)
+		if t := p.peek(); t == nil || t.operator() != "OR" {
+			break
+		}
+		p.next()
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return &Query{Op: QueryOr, Operands: operands}, nil
+}
+
+func (p *queryParser) and() (*Query, error) {
+	var operands []*Query
+	for {
+		t := p.peek()
+		if t == nil || t.kind == queryClose || t.operator() == "OR" {
+			break
+		}
+		if t.operator() == "AND" {
+			if len(operands) == 0 {
+				return nil, errors.New("AND without left operand")
+			}
+			p.next()
+		}
+		q, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, q)
+	}
+	if len(operands) == 0 {
+		if t := p.peek(); t != nil {
+			return nil, fmt.Errorf("unexpected %q", t.text)
+		}
+		return nil, errors.New("unexpected end of query")
+	}
+	return And(operands...), nil
+}
+
+func (p *queryParser) unary() (*Query, error) {
+	t := p.next()
+	switch {
+	case t == nil:
+		return nil, errors.New("unexpected end of query")
+	case t.kind == queryOpen:
+		q, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t == nil || t.kind != queryClose {
+			return nil, errors.New("missing closing parenthesis")
+		}
+		return q, nil
+	case t.kind == queryClose:
+		return nil, errors.New("unexpected \")\"")
+	case t.operator() == "NOT":
+		q, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &Query{Op: QueryNot, Operands: []*Query{q}}, nil
+	case t.operator() != "":
+		return nil, fmt.Errorf("unexpected %s", t.operator())
+	}
+	q, err := queryTerm(t.key, t.text)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%s: %w", t.key, t.text, err)
+	}
+	return q, nil
+}
+
+func queryTerm(key, value string) (*Query, error) {
+	q := &Query{Op: QueryTerm, Key: queryKeys[key]}
+	if value == "" {
+		return nil, errors.New("empty value")
+	}
+	switch q.Key {
+	case QueryHeader:
+		name, value, found := strings.Cut(value, ":")
+		if !found || name == "" {
+			return nil, errors.New("expected header:<name>:<value>")
+		}
+		q.Header = name
+		q.Value = value
+	case QueryFlag:
+		value = strings.ToLower(value)
+		if flag, ok := QueryFlags[value]; ok {
+			q.Flag = flag
+			return q, nil
+		}
+		// unread, unflagged, etc.
+		if flag, ok := QueryFlags[strings.TrimPrefix(value, "un")]; ok {
+			q.Flag = flag
+			return &Query{
+				Op: QueryNot, Operands: []*Query{q},
+			}, nil
+		}
+		return nil, errors.New("unknown flag")
+	case QueryAttachment:
+		if !strings.EqualFold(value, "attachment") {
+			return nil, errors.New("only has:attachment is supported")
+		}
+	case QueryDate:
+		start, end, err := parse.InclusiveDateRange(value)
+		if err != nil {
+			return nil, err
+		}
+		q.Start = start
+		q.End = end
+	case QueryLarger, QuerySmaller:
+		size, err := parse.Size(value)
+		if err != nil {
+			return nil, err
+		}
+		q.Size = size
+	default:
+		q.Value = value
+	}
+	return q, nil
+}
@@ -0,0 +1,91 @@
+package types_test
+
+import (
+	"testing"
+
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"", ""},
+		{"foo bar", `"foo" AND "bar"`},
+		{`"foo bar"`, `"foo bar"`},
+		{
+			`from:alice AND (subject:"release notes" OR has:attachment) NOT is:read`,
+			`from:"alice" AND (subject:"release notes" OR has:attachment) AND NOT is:seen`,
+		},
+		{"a or b and c", `"a" OR ("b" AND "c")`},
+		{"not (a OR b)", `NOT ("a" OR "b")`},
+		{"is:unflagged", "NOT is:flagged"},
+		{"header:List-Id:aerc", `header:List-Id:"aerc"`},
+		{"date:2024-01..2024-03", "date:2024-01-01..2024-04-01"},
+		{"date:2024-01-10..2024-01-20", "date:2024-01-10..2024-01-21"},
+		{"larger:1M smaller:10k", "larger:1048576 AND smaller:10240"},
+		{"folder:INBOX", `"folder:INBOX"`},
+		{`"and" "NOT"`, `"and" AND "NOT"`},
+		{"FROM:Bob", `from:"Bob"`},
+		{"(tag:x)", `tag:"x"`},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			q, err := types.ParseQuery(test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := q.String(); s != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, s)
+			}
+		})
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, query := range []string{
+		"(foo",
+		"foo)",
+		"foo OR",
+		"AND foo",
+		"NOT",
+		`"foo`,
+		"is:bogus",
+		"has:wings",
+		"larger:big",
+		"header:foo",
+		"from:",
+	} {
+		if _, err := types.ParseQuery(query); err == nil {
+			t.Errorf("%q: expected error", query)
+		}
+	}
+}
+
+func TestQueryFlags(t *testing.T) {
+	q, err := types.ParseQuery("is:unread")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Op != types.QueryNot || q.Operands[0].Flag != models.SeenFlag {
+		t.Errorf("unexpected query %s", q)
+	}
+}
+
+func TestQuerySource(t *testing.T) {
+	q, err := types.ParseQuery("foo* and bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Source != "foo* and bar" {
+		t.Errorf("unexpected source %q", q.Source)
+	}
+	// parsed queries are not merged with others so their source is kept
+	other, _ := types.ParseQuery("baz")
+	and := types.And(q, other)
+	if len(and.Operands) != 2 || and.Operands[0] != q || and.Source != "" {
+		t.Errorf("unexpected query %s", and)
+	}
+}
@@ -20,6 +20,9 @@ type SearchCriteria struct {
 	SearchAll    bool
 	Terms        []string
 	UseExtension bool
+	// Query is a boolean expression which must match in addition to the
+	// other criteria.
+	Query *Query
 }
 
 func (c *SearchCriteria) PrepareHeader() {
@@ -80,5 +83,6 @@ func (c *SearchCriteria) Combine(other *SearchCriteria) *SearchCriteria {
 		SearchBody:   c.SearchBody || other.SearchBody,
 		SearchAll:    c.SearchAll || other.SearchAll,
 		Terms:        append(c.Terms, other.Terms...),
+		Query:        And(c.Query, other.Query),
 	}
 }
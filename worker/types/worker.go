@@ -33,6 +33,8 @@ type Worker struct {
 	actionCallbacks  map[int64]func(msg WorkerMessage)
 	messageCallbacks map[int64]func(msg WorkerMessage)
 	actionQueue      *list.List
+	messages         chan WorkerMessage
+	observers        []func(msg WorkerMessage)
 	status           int32
 	name             string
 
@@ -47,10 +49,52 @@ func NewWorker(name string) *Worker {
 		actionCallbacks:  make(map[int64]func(msg WorkerMessage)),
 		messageCallbacks: make(map[int64]func(msg WorkerMessage)),
 		actionQueue:      list.New(),
+		messages:         WorkerMessages,
 		name:             name,
 	}
 }
 
+// Name returns the name of the account.
+func (worker *Worker) Name() string {
+	return worker.name
+}
+
+// SetMessages redirects the messages posted by the worker to another channel
+// than the UI.
+func (worker *Worker) SetMessages(messages chan WorkerMessage) {
+	worker.messages = messages
+}
+
+// Observe registers a function which is called with every message posted to
+// the UI. It is called from the goroutine of the backend and must not block.
+func (worker *Worker) Observe(fn func(msg WorkerMessage)) {
+	worker.Lock()
+	defer worker.Unlock()
+	worker.observers = append(worker.observers, fn)
+}
+
+var (
+	accounts     = make(map[string]*Worker)
+	accountsLock sync.Mutex
+)
+
+// RegisterAccount makes the worker of an account available to the backends
+// which perform actions in other accounts.
+func RegisterAccount(worker *Worker) {
+	accountsLock.Lock()
+	defer accountsLock.Unlock()
+	accounts[worker.name] = worker
+}
+
+// AccountWorker returns the worker of an account registered with
+// RegisterAccount.
+func AccountWorker(name string) (*Worker, bool) {
+	accountsLock.Lock()
+	defer accountsLock.Unlock()
+	worker, ok := accounts[name]
+	return worker, ok
+}
+
 func (worker *Worker) Unwrap() WorkerInteractor {
 	return nil
 }
@@ -123,7 +167,17 @@ func (worker *Worker) PostMessage(msg WorkerMessage,
 	worker.setId(msg)
 	msg.setAccount(worker.name)
 
-	WorkerMessages <- msg
+	if req := msg.InResponseTo(); req != nil && req.replyTo() != nil {
+		req.replyTo() <- msg
+	} else {
+		worker.Lock()
+		observers := worker.observers
+		worker.Unlock()
+		for _, observe := range observers {
+			observe(msg)
+		}
+		worker.messages <- msg
+	}
 
 	if cb != nil {
 		worker.Lock()
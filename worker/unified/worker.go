@@ -0,0 +1,791 @@
+package unified
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"git.sr.ht/~rjarry/aerc/config"
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/handlers"
+	"git.sr.ht/~rjarry/aerc/worker/lib"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+func init() {
+	handlers.RegisterWorkerFactory("unified", NewWorker)
+}
+
+var errUnsupported = fmt.Errorf("unsupported command")
+
+// unifiedWorker merges one folder of several accounts into a single folder.
+// Each merged account is accessed through the worker of its account tab and
+// the UIDs of its messages are prefixed with the index of the account.
+type unifiedWorker struct {
+	worker types.WorkerInteractor
+
+	// name of the merged folder
+	name string
+	// role or name of the folder in the merged accounts
+	role   models.Role
+	folder string
+
+	members  []*member
+	messages chan types.WorkerMessage
+
+	// directory infos posted by the member accounts to the UI
+	observed     []types.WorkerMessage
+	observedLock sync.Mutex
+	notify       chan struct{}
+
+	capabilities *models.Capabilities
+}
+
+// member is one of the merged accounts.
+type member struct {
+	index  int
+	conf   *config.AccountConfig
+	worker *types.Worker
+	folder string
+	infos  map[models.UID]*models.MessageInfo
+	// last counts reported for the folders of the account
+	counts map[string]*models.DirectoryInfo
+}
+
+func NewWorker(worker *types.Worker) (types.Backend, error) {
+	return &unifiedWorker{
+		worker:   worker,
+		messages: make(chan types.WorkerMessage, 50),
+		notify:   make(chan struct{}, 1),
+		capabilities: &models.Capabilities{
+			Sort:   true,
+			Thread: false,
+		},
+	}, nil
+}
+
+func (w *unifiedWorker) Run() {
+	for {
+		select {
+		case msg := <-w.worker.Actions():
+			msg = w.worker.ProcessAction(msg)
+			if err := w.handleAction(msg); errors.Is(err, errUnsupported) {
+				w.worker.PostMessage(&types.Unsupported{
+					Message: types.RespondTo(msg),
+				}, nil)
+			} else if err != nil {
+				w.worker.PostMessage(&types.Error{
+					Message: types.RespondTo(msg),
+					Error:   err,
+				}, nil)
+			}
+		case msg := <-w.messages:
+			w.handleMemberMessage(msg)
+		case <-w.notify:
+			w.handleObserved()
+		}
+	}
+}
+
+func (w *unifiedWorker) Capabilities() *models.Capabilities {
+	return w.capabilities
+}
+
+func (w *unifiedWorker) PathSeparator() string {
+	return "/"
+}
+
+func (w *unifiedWorker) configure(conf *config.AccountConfig) error {
+	w.name = conf.Default
+	w.folder = conf.Params["folder"]
+	if w.folder == "" {
+		w.folder = string(models.InboxRole)
+	}
+	if role, ok := models.Roles[strings.ToLower(w.folder)]; ok &&
+		role != models.QueryRole {
+		w.role = role
+	}
+	accounts := conf.UnifiedAccounts()
+	if len(accounts) == 0 {
+		return fmt.Errorf("no accounts to merge")
+	}
+	for i, acct := range accounts {
+		worker, ok := types.AccountWorker(acct.Name)
+		if !ok {
+			return fmt.Errorf("%s: account not loaded", acct.Name)
+		}
+		m := &member{
+			index:  i,
+			conf:   acct,
+			worker: worker,
+			infos:  make(map[models.UID]*models.MessageInfo),
+			counts: make(map[string]*models.DirectoryInfo),
+		}
+		w.members = append(w.members, m)
+		worker.Observe(w.observe)
+		w.worker.Debugf("merging %s of account %s", w.folder, acct.Name)
+	}
+	return nil
+}
+
+// observe queues the directory infos posted by the member accounts to the UI.
+// It is called from the goroutines of the member backends.
+func (w *unifiedWorker) observe(msg types.WorkerMessage) {
+	if _, ok := msg.(*types.DirectoryInfo); !ok {
+		return
+	}
+	w.observedLock.Lock()
+	w.observed = append(w.observed, msg)
+	w.observedLock.Unlock()
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// post performs an action in the merged folder of a member account. The
+// responses are sent to the unified worker instead of the member account.
+func (w *unifiedWorker) post(
+	m *member, action types.WorkerMessage, cb func(types.WorkerMessage),
+) {
+	switch action.(type) {
+	case *types.ListDirectories, *types.CreateDirectory, *types.CheckMail:
+	default:
+		types.InFolder(action, m.folder)
+	}
+	m.worker.PostAction(types.ReplyTo(action, w.messages), cb)
+}
+
+// encode returns the UID of a member message in the merged folder.
+func (m *member) encode(uid models.UID) models.UID {
+	return models.UID(strconv.Itoa(m.index) + "/" + string(uid))
+}
+
+// decode returns the member that a merged UID belongs to and the UID of the
+// message in that member.
+func (w *unifiedWorker) decode(uid models.UID) (*member, models.UID, bool) {
+	prefix, u, ok := strings.Cut(string(uid), "/")
+	if !ok {
+		return nil, "", false
+	}
+	i, err := strconv.Atoi(prefix)
+	if err != nil || i < 0 || i >= len(w.members) {
+		return nil, "", false
+	}
+	return w.members[i], models.UID(u), true
+}
+
+func (w *unifiedWorker) member(name string) *member {
+	for _, m := range w.members {
+		if m.conf.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// split groups merged UIDs by member. Unknown UIDs are ignored.
+func (w *unifiedWorker) split(uids []models.UID) map[*member][]models.UID {
+	groups := make(map[*member][]models.UID)
+	for _, uid := range uids {
+		if m, u, ok := w.decode(uid); ok {
+			groups[m] = append(groups[m], u)
+		}
+	}
+	return groups
+}
+
+// messageInfo returns a copy of a member message info for the merged folder.
+func (m *member) messageInfo(info *models.MessageInfo) *models.MessageInfo {
+	merged := *info
+	merged.Uid = m.encode(info.Uid)
+	merged.Account = m.conf.Name
+	return &merged
+}
+
+// updateInfo stores the message info received from a member. Updates which
+// only contain flags are merged into the known message info.
+func (m *member) updateInfo(info *models.MessageInfo) *models.MessageInfo {
+	if cached, ok := m.infos[info.Uid]; ok && info.Envelope == nil &&
+		info.Error == nil {
+		updated := *cached
+		updated.Flags = info.Flags
+		if info.Labels != nil {
+			updated.Labels = info.Labels
+		}
+		m.infos[info.Uid] = &updated
+		return &updated
+	}
+	m.infos[info.Uid] = info
+	return info
+}
+
+func (m *member) encodeAll(uids []models.UID) []models.UID {
+	merged := make([]models.UID, 0, len(uids))
+	for _, uid := range uids {
+		merged = append(merged, m.encode(uid))
+	}
+	return merged
+}
+
+// each runs start for all members and calls done once all of them have
+// finished. Calling finish more than once for the same member is harmless.
+func (w *unifiedWorker) each(
+	members []*member,
+	start func(m *member, finish func(error)),
+	done func(errs []error),
+) {
+	pending := len(members)
+	if pending == 0 {
+		done(nil)
+		return
+	}
+	var errs []error
+	for _, m := range members {
+		finished := false
+		start(m, func(err error) {
+			if finished {
+				return
+			}
+			finished = true
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", m.conf.Name, err))
+			}
+			pending--
+			if pending == 0 {
+				done(errs)
+			}
+		})
+	}
+}
+
+// forward posts an action to members and translates their responses to
+// responses to msg.
+func (w *unifiedWorker) forward(
+	msg types.WorkerMessage, actions map[*member]types.WorkerMessage,
+) {
+	var members []*member
+	for _, m := range w.members {
+		if _, ok := actions[m]; ok {
+			members = append(members, m)
+		}
+	}
+	w.each(members, func(m *member, finish func(error)) {
+		w.post(m, actions[m], func(resp types.WorkerMessage) {
+			if !w.finished(resp, finish) {
+				w.translate(m, msg, resp)
+			}
+		})
+	}, func(errs []error) {
+		w.reply(msg, errs, len(members))
+	})
+}
+
+// finished reports whether a member response terminates its action.
+func (w *unifiedWorker) finished(
+	resp types.WorkerMessage, finish func(error),
+) bool {
+	switch resp := resp.(type) {
+	case *types.Done, *types.Cancelled:
+		finish(nil)
+	case *types.Unsupported:
+		finish(errUnsupported)
+	case *types.Error:
+		finish(resp.Error)
+	case *types.ConnError:
+		finish(resp.Error)
+	default:
+		return false
+	}
+	return true
+}
+
+// reply responds to msg once all members are done. Unless all members
+// failed, the errors are reported separately and the action succeeds.
+func (w *unifiedWorker) reply(msg types.WorkerMessage, errs []error, members int) {
+	if len(errs) > 0 && len(errs) >= members {
+		w.worker.PostMessage(&types.Error{
+			Message: types.RespondTo(msg),
+			Error:   errors.Join(errs...),
+		}, nil)
+		return
+	}
+	for _, err := range errs {
+		w.worker.PostMessage(&types.Error{Error: err}, nil)
+	}
+	w.worker.PostMessage(&types.Done{Message: types.RespondTo(msg)}, nil)
+}
+
+// translate converts a member response to a response to msg.
+func (w *unifiedWorker) translate(
+	m *member, msg types.WorkerMessage, resp types.WorkerMessage,
+) {
+	switch resp := resp.(type) {
+	case *types.MessageInfo:
+		info := m.updateInfo(resp.Info)
+		w.worker.PostMessage(&types.MessageInfo{
+			Message:    types.RespondTo(msg),
+			Info:       m.messageInfo(info),
+			NeedsFlags: resp.NeedsFlags,
+		}, nil)
+	case *types.FullMessage:
+		content := *resp.Content
+		content.Uid = m.encode(content.Uid)
+		w.worker.PostMessage(&types.FullMessage{
+			Message: types.RespondTo(msg),
+			Content: &content,
+		}, nil)
+	case *types.MessageBodyPart:
+		part := *resp.Part
+		part.Uid = m.encode(part.Uid)
+		w.worker.PostMessage(&types.MessageBodyPart{
+			Message: types.RespondTo(msg),
+			Part:    &part,
+		}, nil)
+	case *types.MessagesDeleted:
+		for _, uid := range resp.Uids {
+			delete(m.infos, uid)
+		}
+		w.worker.PostMessage(&types.MessagesDeleted{
+			Message: types.RespondTo(msg),
+			Uids:    m.encodeAll(resp.Uids),
+		}, nil)
+	case *types.MessagesCopied:
+		w.worker.PostMessage(&types.MessagesCopied{
+			Message:     types.RespondTo(msg),
+			Destination: resp.Destination,
+			Uids:        m.encodeAll(resp.Uids),
+		}, nil)
+	case *types.MessagesMoved:
+		w.worker.PostMessage(&types.MessagesMoved{
+			Message:     types.RespondTo(msg),
+			Destination: resp.Destination,
+			Uids:        m.encodeAll(resp.Uids),
+		}, nil)
+	case *types.DirectoryInfo:
+		w.updateDirectoryInfo(m, resp)
+	default:
+		w.worker.Tracef("%s: ignoring %T", m.conf.Name, resp)
+	}
+}
+
+// handleMemberMessage dispatches the responses of the member accounts to the
+// actions posted by the unified worker.
+func (w *unifiedWorker) handleMemberMessage(msg types.WorkerMessage) {
+	if m := w.member(msg.Account()); m != nil {
+		m.worker.ProcessMessage(msg)
+	}
+}
+
+// handleObserved updates the merged folder counts when the counts of a member
+// folder change.
+func (w *unifiedWorker) handleObserved() {
+	w.observedLock.Lock()
+	observed := w.observed
+	w.observed = nil
+	w.observedLock.Unlock()
+	for _, msg := range observed {
+		m := w.member(msg.Account())
+		if info, ok := msg.(*types.DirectoryInfo); ok && m != nil {
+			w.updateDirectoryInfo(m, info)
+		}
+	}
+}
+
+// updateDirectoryInfo aggregates the counts of the merged folders. The merged
+// folder is refetched when the counts of a member folder change.
+func (w *unifiedWorker) updateDirectoryInfo(m *member, msg *types.DirectoryInfo) {
+	if msg.Info == nil {
+		return
+	}
+	prev, known := m.counts[msg.Info.Name]
+	m.counts[msg.Info.Name] = msg.Info
+	if m.folder == "" || msg.Info.Name != m.folder {
+		return
+	}
+	refetch := msg.Refetch || (known && *prev != *msg.Info)
+	w.postDirectoryInfo(refetch)
+}
+
+func (w *unifiedWorker) postDirectoryInfo(refetch bool) {
+	info := &models.DirectoryInfo{Name: w.name}
+	for _, m := range w.members {
+		if counts, ok := m.counts[m.folder]; ok {
+			info.Exists += counts.Exists
+			info.Recent += counts.Recent
+			info.Unseen += counts.Unseen
+		}
+	}
+	w.worker.PostMessage(&types.DirectoryInfo{
+		Info:    info,
+		Refetch: refetch,
+	}, nil)
+}
+
+func (w *unifiedWorker) handleAction(msg types.WorkerMessage) error {
+	switch msg := msg.(type) {
+	case *types.Unsupported:
+		// No-op
+	case *types.Configure:
+		return w.configure(msg.Config)
+	case *types.Connect, *types.Reconnect, *types.Disconnect:
+		// the member accounts manage their own connection
+		w.worker.PostMessage(&types.Done{Message: types.RespondTo(msg)}, nil)
+	case *types.ListDirectories:
+		w.worker.PostMessage(&types.Directory{
+			Message: types.RespondTo(msg),
+			Dir: &models.Directory{
+				Name: w.name,
+				Role: w.role,
+			},
+		}, nil)
+		w.worker.PostMessage(&types.Done{Message: types.RespondTo(msg)}, nil)
+	case *types.OpenDirectory:
+		if msg.Directory != w.name {
+			return fmt.Errorf("unknown folder %q", msg.Directory)
+		}
+		w.each(w.members, func(m *member, finish func(error)) {
+			w.open(m, finish)
+		}, func(errs []error) {
+			w.reply(msg, errs, len(w.members))
+		})
+	case *types.FetchDirectoryContents:
+		w.fetchContents(msg)
+	case *types.SearchDirectory:
+		w.search(msg)
+	case *types.FetchMessageHeaders:
+		w.fetchHeaders(msg)
+	case *types.CheckMail:
+		w.forwardAll(msg, func(m *member) types.WorkerMessage {
+			if m.folder == "" {
+				return nil
+			}
+			return &types.CheckMail{
+				Directories: []string{m.folder},
+				Command:     msg.Command,
+				Timeout:     msg.Timeout,
+			}
+		})
+	case *types.CreateDirectory:
+		w.forwardAll(msg, func(*member) types.WorkerMessage {
+			return &types.CreateDirectory{
+				Directory: msg.Directory,
+				Quiet:     msg.Quiet,
+				Role:      msg.Role,
+			}
+		})
+	case *types.FetchMessageBodyPart:
+		m, uid, ok := w.decode(msg.Uid)
+		if !ok {
+			return fmt.Errorf("unknown message %s", msg.Uid)
+		}
+		w.forward(msg, map[*member]types.WorkerMessage{
+			m: &types.FetchMessageBodyPart{Uid: uid, Part: msg.Part},
+		})
+	case *types.FetchFullMessages:
+		w.forwardUids(msg, msg.Uids, func(uids []models.UID) types.WorkerMessage {
+			return &types.FetchFullMessages{Uids: uids}
+		})
+	case *types.FetchMessageFlags:
+		w.forwardUids(msg, msg.Uids, func(uids []models.UID) types.WorkerMessage {
+			return &types.FetchMessageFlags{Context: msg.Context, Uids: uids}
+		})
+	case *types.DeleteMessages:
+		w.forwardUids(msg, msg.Uids, func(uids []models.UID) types.WorkerMessage {
+			return &types.DeleteMessages{
+				Uids:              uids,
+				MultiFileStrategy: msg.MultiFileStrategy,
+			}
+		})
+	case *types.FlagMessages:
+		w.forwardUids(msg, msg.Uids, func(uids []models.UID) types.WorkerMessage {
+			return &types.FlagMessages{
+				Enable: msg.Enable,
+				Flags:  msg.Flags,
+				Uids:   uids,
+			}
+		})
+	case *types.AnsweredMessages:
+		w.forwardUids(msg, msg.Uids, func(uids []models.UID) types.WorkerMessage {
+			return &types.AnsweredMessages{Answered: msg.Answered, Uids: uids}
+		})
+	case *types.ForwardedMessages:
+		w.forwardUids(msg, msg.Uids, func(uids []models.UID) types.WorkerMessage {
+			return &types.ForwardedMessages{Forwarded: msg.Forwarded, Uids: uids}
+		})
+	case *types.CopyMessages:
+		w.forwardUids(msg, msg.Uids, func(uids []models.UID) types.WorkerMessage {
+			return &types.CopyMessages{
+				Destination:       msg.Destination,
+				Uids:              uids,
+				MultiFileStrategy: msg.MultiFileStrategy,
+			}
+		})
+	case *types.MoveMessages:
+		w.forwardUids(msg, msg.Uids, func(uids []models.UID) types.WorkerMessage {
+			return &types.MoveMessages{
+				Destination:       msg.Destination,
+				Uids:              uids,
+				MultiFileStrategy: msg.MultiFileStrategy,
+			}
+		})
+	case *types.ModifyLabels:
+		w.forwardUids(msg, msg.Uids, func(uids []models.UID) types.WorkerMessage {
+			return &types.ModifyLabels{
+				Uids:   uids,
+				Add:    msg.Add,
+				Remove: msg.Remove,
+			}
+		})
+	default:
+		return errUnsupported
+	}
+	return nil
+}
+
+// forwardAll forwards an action to all members. Members for which action
+// returns nil are skipped.
+func (w *unifiedWorker) forwardAll(
+	msg types.WorkerMessage, action func(m *member) types.WorkerMessage,
+) {
+	actions := make(map[*member]types.WorkerMessage)
+	for _, m := range w.members {
+		if a := action(m); a != nil {
+			actions[m] = a
+		}
+	}
+	w.forward(msg, actions)
+}
+
+// forwardUids forwards an action on messages to the members that own them.
+func (w *unifiedWorker) forwardUids(
+	msg types.WorkerMessage, uids []models.UID,
+	action func([]models.UID) types.WorkerMessage,
+) {
+	actions := make(map[*member]types.WorkerMessage)
+	for m, uids := range w.split(uids) {
+		actions[m] = action(uids)
+	}
+	w.forward(msg, actions)
+}
+
+// open resolves the merged folder of a member from the folder roles reported
+// by the backend the first time. The folder is not opened in the member
+// account, actions are performed in it with types.InFolder.
+func (w *unifiedWorker) open(m *member, finish func(error)) {
+	if m.folder != "" {
+		finish(nil)
+		return
+	}
+	var dirs []*models.Directory
+	w.post(m, &types.ListDirectories{}, func(resp types.WorkerMessage) {
+		switch resp := resp.(type) {
+		case *types.Directory:
+			dirs = append(dirs, resp.Dir)
+		case *types.Done:
+			folder, err := w.resolve(m, dirs)
+			if err != nil {
+				finish(err)
+				return
+			}
+			m.folder = folder
+			if _, ok := m.counts[folder]; ok {
+				w.postDirectoryInfo(false)
+			}
+			finish(nil)
+		default:
+			w.finished(resp, finish)
+		}
+	})
+}
+
+// resolve finds the folder to merge among the folders of a member.
+func (w *unifiedWorker) resolve(m *member, dirs []*models.Directory) (string, error) {
+	name := w.folder
+	if w.role != "" {
+		for _, dir := range dirs {
+			if dir.Role == w.role {
+				return dir.Name, nil
+			}
+		}
+		switch w.role {
+		case models.InboxRole:
+			name = m.conf.Default
+		case models.ArchiveRole:
+			name = m.conf.ArchiveFolder()
+		case models.DraftsRole:
+			name = m.conf.PostponeFolder()
+		case models.SentRole:
+			if copyTo := m.conf.CopyToFolder(); copyTo != "" {
+				name = copyTo
+			}
+		}
+	}
+	for _, dir := range dirs {
+		if strings.EqualFold(dir.Name, name) {
+			return dir.Name, nil
+		}
+	}
+	return "", fmt.Errorf("folder %q not found", name)
+}
+
+// opened returns the members whose merged folder is open.
+func (w *unifiedWorker) opened() []*member {
+	var members []*member
+	for _, m := range w.members {
+		if m.folder != "" {
+			members = append(members, m)
+		}
+	}
+	return members
+}
+
+// fetchContents lists the messages of all members, fetches the headers that
+// are not known yet and sorts the merged list.
+func (w *unifiedWorker) fetchContents(msg *types.FetchDirectoryContents) {
+	contents := make(map[*member][]models.UID)
+	members := w.opened()
+	w.each(members, func(m *member, finish func(error)) {
+		var uids []models.UID
+		w.post(m, &types.FetchDirectoryContents{
+			Context: msg.Context,
+			Filter:  msg.Filter,
+		}, func(resp types.WorkerMessage) {
+			switch resp := resp.(type) {
+			case *types.DirectoryContents:
+				uids = append(uids, resp.Uids...)
+			case *types.Done:
+				contents[m] = uids
+				w.fetchMissing(m, msg.Context, uids, finish)
+			default:
+				w.finished(resp, finish)
+			}
+		})
+	}, func(errs []error) {
+		var infos []*models.MessageInfo
+		var broken []models.UID
+		for _, m := range w.members {
+			uids, ok := contents[m]
+			if !ok {
+				continue
+			}
+			if msg.Filter == nil {
+				known := make(map[models.UID]*models.MessageInfo, len(uids))
+				for _, uid := range uids {
+					if info, ok := m.infos[uid]; ok {
+						known[uid] = info
+					}
+				}
+				m.infos = known
+			}
+			for _, uid := range uids {
+				info, ok := m.infos[uid]
+				if !ok || info.Envelope == nil {
+					// cannot be sorted, keep them first
+					broken = append(broken, m.encode(uid))
+					continue
+				}
+				infos = append(infos, m.messageInfo(info))
+			}
+		}
+		criteria := msg.SortCriteria
+		if len(criteria) == 0 {
+			// same order as unsorted backends: oldest messages first
+			criteria = []*types.SortCriterion{
+				{Field: types.SortDate, Reverse: true},
+			}
+		}
+		uids, err := lib.Sort(infos, criteria)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		w.worker.PostMessage(&types.DirectoryContents{
+			Message: types.RespondTo(msg),
+			Uids:    append(broken, uids...),
+		}, nil)
+		w.reply(msg, errs, len(members))
+	})
+}
+
+// fetchMissing fetches the headers of the member messages which are not
+// known yet. They are needed to sort the merged list.
+func (w *unifiedWorker) fetchMissing(
+	m *member, ctx context.Context, uids []models.UID, finish func(error),
+) {
+	var missing []models.UID
+	for _, uid := range uids {
+		if _, ok := m.infos[uid]; !ok {
+			missing = append(missing, uid)
+		}
+	}
+	if len(missing) == 0 {
+		finish(nil)
+		return
+	}
+	w.post(m, &types.FetchMessageHeaders{
+		Context: ctx,
+		Uids:    missing,
+	}, func(resp types.WorkerMessage) {
+		if info, ok := resp.(*types.MessageInfo); ok {
+			m.updateInfo(info.Info)
+			return
+		}
+		w.finished(resp, finish)
+	})
+}
+
+// search runs a search in all members and merges the results.
+func (w *unifiedWorker) search(msg *types.SearchDirectory) {
+	var results []models.UID
+	members := w.opened()
+	w.each(members, func(m *member, finish func(error)) {
+		w.post(m, &types.SearchDirectory{
+			Context:  msg.Context,
+			Criteria: msg.Criteria,
+		}, func(resp types.WorkerMessage) {
+			// some backends do not send Done after the results
+			if res, ok := resp.(*types.SearchResults); ok {
+				results = append(results, m.encodeAll(res.Uids)...)
+				finish(nil)
+				return
+			}
+			w.finished(resp, finish)
+		})
+	}, func(errs []error) {
+		w.worker.PostMessage(&types.SearchResults{
+			Message: types.RespondTo(msg),
+			Uids:    results,
+		}, nil)
+		w.reply(msg, errs, len(members))
+	})
+}
+
+// fetchHeaders answers from the known message infos and only fetches the
+// headers of unknown messages from the members.
+func (w *unifiedWorker) fetchHeaders(msg *types.FetchMessageHeaders) {
+	actions := make(map[*member]types.WorkerMessage)
+	for m, uids := range w.split(msg.Uids) {
+		var missing []models.UID
+		for _, uid := range uids {
+			info, ok := m.infos[uid]
+			if !ok || info.Envelope == nil {
+				missing = append(missing, uid)
+				continue
+			}
+			w.worker.PostMessage(&types.MessageInfo{
+				Message: types.RespondTo(msg),
+				Info:    m.messageInfo(info),
+			}, nil)
+		}
+		if len(missing) > 0 {
+			actions[m] = &types.FetchMessageHeaders{
+				Context: msg.Context,
+				Uids:    missing,
+			}
+		}
+	}
+	w.forward(msg, actions)
+}
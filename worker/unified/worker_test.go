@@ -0,0 +1,235 @@
+package unified
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~rjarry/aerc/config"
+	"git.sr.ht/~rjarry/aerc/models"
+	"git.sr.ht/~rjarry/aerc/worker/types"
+)
+
+func newTestWorker(names ...string) *unifiedWorker {
+	w := &unifiedWorker{
+		worker:   types.NewWorker("Unified"),
+		name:     "INBOX",
+		messages: make(chan types.WorkerMessage, 50),
+		notify:   make(chan struct{}, 1),
+	}
+	for i, name := range names {
+		worker := types.NewWorker(name)
+		worker.Observe(w.observe)
+		w.members = append(w.members, &member{
+			index:  i,
+			conf:   &config.AccountConfig{Name: name},
+			worker: worker,
+			folder: "INBOX",
+			infos:  make(map[models.UID]*models.MessageInfo),
+			counts: make(map[string]*models.DirectoryInfo),
+		})
+	}
+	return w
+}
+
+// respond posts responses from a member backend and dispatches them.
+func (w *unifiedWorker) respond(m *member, msgs ...types.WorkerMessage) {
+	for _, msg := range msgs {
+		m.worker.PostMessage(msg, nil)
+		w.handleMemberMessage(<-w.messages)
+	}
+}
+
+// notice posts a message from a member backend to its account and lets the
+// unified worker observe it.
+func (w *unifiedWorker) notice(m *member, msg types.WorkerMessage) {
+	m.worker.PostMessage(msg, nil)
+	<-types.WorkerMessages
+	<-w.notify
+	w.handleObserved()
+}
+
+func TestUids(t *testing.T) {
+	w := newTestWorker("a", "b")
+	uid := w.members[1].encode("42")
+	m, u, ok := w.decode(uid)
+	if !ok || m != w.members[1] || u != "42" {
+		t.Errorf("cannot decode %s", uid)
+	}
+	for _, bad := range []models.UID{"42", "2/42", "x/42"} {
+		if _, _, ok := w.decode(bad); ok {
+			t.Errorf("%s: expected decoding to fail", bad)
+		}
+	}
+}
+
+func TestFetchDirectoryContents(t *testing.T) {
+	w := newTestWorker("a", "b")
+	fetch := &types.FetchDirectoryContents{}
+	if err := w.handleAction(fetch); err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, m := range w.members {
+		action := <-m.worker.Actions()
+		w.respond(m,
+			&types.DirectoryContents{
+				Message: types.RespondTo(action),
+				Uids:    []models.UID{"1", "2"},
+			},
+			&types.Done{Message: types.RespondTo(action)},
+		)
+		headers, ok := (<-m.worker.Actions()).(*types.FetchMessageHeaders)
+		if !ok {
+			t.Fatal("expected FetchMessageHeaders")
+		}
+		for _, uid := range headers.Uids {
+			// interleave the messages of both accounts
+			d := date.Add(time.Duration(i) * time.Hour)
+			if uid == "2" {
+				d = d.Add(2 * time.Hour)
+			}
+			w.respond(m, &types.MessageInfo{
+				Message: types.RespondTo(headers),
+				Info: &models.MessageInfo{
+					Uid:      uid,
+					Envelope: &models.Envelope{Date: d},
+				},
+			})
+		}
+		w.respond(m, &types.Done{Message: types.RespondTo(headers)})
+	}
+	contents, ok := (<-types.WorkerMessages).(*types.DirectoryContents)
+	if !ok {
+		t.Fatal("expected DirectoryContents")
+	}
+	var uids []string
+	for _, uid := range contents.Uids {
+		uids = append(uids, string(uid))
+	}
+	if s := strings.Join(uids, ","); s != "0/1,1/1,0/2,1/2" {
+		t.Errorf("unexpected order %s", s)
+	}
+	if _, ok := (<-types.WorkerMessages).(*types.Done); !ok {
+		t.Error("expected Done")
+	}
+
+	// headers are now answered without asking the member backends
+	h := &types.FetchMessageHeaders{Uids: []models.UID{"1/2"}}
+	if err := w.handleAction(h); err != nil {
+		t.Fatal(err)
+	}
+	info, ok := (<-types.WorkerMessages).(*types.MessageInfo)
+	if !ok {
+		t.Fatal("expected MessageInfo")
+	}
+	if info.Info.Uid != "1/2" || info.Info.Account != "b" {
+		t.Errorf("unexpected info %+v", info.Info)
+	}
+	if _, ok := (<-types.WorkerMessages).(*types.Done); !ok {
+		t.Error("expected Done")
+	}
+}
+
+func TestMoveMessages(t *testing.T) {
+	w := newTestWorker("a", "b")
+	move := &types.MoveMessages{
+		Destination: "Archive",
+		Uids:        []models.UID{"0/1", "1/7", "1/8"},
+	}
+	if err := w.handleAction(move); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"1", "7,8"}
+	for i, m := range w.members {
+		action, ok := (<-m.worker.Actions()).(*types.MoveMessages)
+		if !ok {
+			t.Fatal("expected MoveMessages")
+		}
+		if action.Target() != "INBOX" {
+			t.Errorf("%s: expected move from INBOX, got %q",
+				m.conf.Name, action.Target())
+		}
+		var uids []string
+		for _, uid := range action.Uids {
+			uids = append(uids, string(uid))
+		}
+		if s := strings.Join(uids, ","); s != expected[i] ||
+			action.Destination != "Archive" {
+			t.Errorf("%s: unexpected move of %s to %s",
+				m.conf.Name, s, action.Destination)
+		}
+		w.respond(m,
+			&types.MessagesDeleted{
+				Message: types.RespondTo(action),
+				Uids:    action.Uids,
+			},
+			&types.Done{Message: types.RespondTo(action)},
+		)
+	}
+	deleted := 0
+	for {
+		msg := <-types.WorkerMessages
+		if msg.InResponseTo() != move {
+			t.Fatalf("unexpected message %T", msg)
+		}
+		if d, ok := msg.(*types.MessagesDeleted); ok {
+			deleted += len(d.Uids)
+			continue
+		}
+		if _, ok := msg.(*types.Done); !ok {
+			t.Fatalf("expected Done, got %T", msg)
+		}
+		break
+	}
+	if deleted != 3 {
+		t.Errorf("expected 3 deleted messages, got %d", deleted)
+	}
+}
+
+func TestFlagUpdates(t *testing.T) {
+	w := newTestWorker("a")
+	m := w.members[0]
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.updateInfo(&models.MessageInfo{
+		Uid:      "1",
+		Envelope: &models.Envelope{Date: date},
+	})
+	info := m.updateInfo(&models.MessageInfo{
+		Uid:   "1",
+		Flags: models.SeenFlag,
+	})
+	if info.Envelope == nil || info.Flags != models.SeenFlag {
+		t.Errorf("flags not merged into known info: %+v", info)
+	}
+}
+
+func TestDirectoryInfo(t *testing.T) {
+	w := newTestWorker("a", "b")
+	for _, m := range w.members {
+		w.notice(m, &types.DirectoryInfo{
+			Info: &models.DirectoryInfo{Name: "INBOX", Exists: 2, Unseen: 1},
+		})
+		<-types.WorkerMessages
+	}
+	// counts of other folders are ignored
+	w.notice(w.members[0], &types.DirectoryInfo{
+		Info: &models.DirectoryInfo{Name: "Archive", Exists: 5},
+	})
+	select {
+	case msg := <-types.WorkerMessages:
+		t.Fatalf("unexpected %T", msg)
+	default:
+	}
+	w.notice(w.members[1], &types.DirectoryInfo{
+		Info: &models.DirectoryInfo{Name: "INBOX", Exists: 3, Unseen: 2},
+	})
+	info, ok := (<-types.WorkerMessages).(*types.DirectoryInfo)
+	if !ok {
+		t.Fatal("expected DirectoryInfo")
+	}
+	if info.Info.Name != "INBOX" || info.Info.Exists != 5 ||
+		info.Info.Unseen != 3 || !info.Refetch {
+		t.Errorf("unexpected info %+v (refetch %v)", info.Info, info.Refetch)
+	}
+}
@@ -6,4 +6,6 @@ import (
 	_ "git.sr.ht/~rjarry/aerc/worker/jmap"
 	_ "git.sr.ht/~rjarry/aerc/worker/maildir"
 	_ "git.sr.ht/~rjarry/aerc/worker/mbox"
+	_ "git.sr.ht/~rjarry/aerc/worker/pop3"
+	_ "git.sr.ht/~rjarry/aerc/worker/unified"
 )